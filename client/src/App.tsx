@@ -6,6 +6,7 @@ import { DialogOption } from './types';
 import { useGameState } from './hooks/useGameState';
 import { registerWindowFunction } from './api/windowApi';
 import PlayerCoords from './components/PlayerCoords';
+import Minimap from './components/Minimap';
 import HealthBar from './components/HealthBar';
 import ResonanceBar from './components/ResonanceBar';
 import PlayerNameDisplay from './components/PlayerNameDisplay';
@@ -190,6 +191,7 @@ function App() {
             <CraftSuccessAnimation />
             <ChannelingBar />
             <canvas id="game-canvas"></canvas>
+            <Minimap x={gameState.coords.x} y={gameState.coords.y} />
         </div>
         
         <BankPanel
@@ -1,7 +1,7 @@
 import React, { useState } from 'react';
 import { InventoryItem } from '../types';
 import { itemDefinitions, edibleDefs } from '../definitions';
-import { send, sendLearnRecipe, sendDepositItem, sendReorderItem } from '../network';
+import { send, sendLearnRecipe, sendReadMap, sendDepositItem, sendReorderItem } from '../network';
 import Tooltip from './Tooltip';
 import InventorySlot from './shared/InventorySlot';
 import PanelHeader from './shared/PanelHeader';
@@ -41,11 +41,12 @@ const InventoryPanel: React.FC<InventoryPanelProps> = ({ isOpen, onClose, invent
     const edible = edibleDefs[item.id];
     const equippable = itemDef.equippable;
     const isRecipe = itemDef.kind === 'recipe';
+    const isTreasureMap = itemDef.kind === 'treasure_map';
 
     return (
       <>
         <div className="tooltip-title">{itemDef.text || item.id}</div>
-        {(edible || equippable || isRecipe) && <hr />}
+        {(edible || equippable || isRecipe || isTreasureMap) && <hr />}
         {equippable && (
           <>
             <p className="tooltip-action">Equip {itemDef.text || item.id}</p>
@@ -60,6 +61,7 @@ const InventoryPanel: React.FC<InventoryPanelProps> = ({ isOpen, onClose, invent
           </>
         )}
         {isRecipe && <p className="tooltip-action">Learn Recipe</p>}
+        {isTreasureMap && <p className="tooltip-action">Read Map</p>}
       </>
     );
   };
@@ -105,6 +107,7 @@ const InventoryPanel: React.FC<InventoryPanelProps> = ({ isOpen, onClose, invent
     const edible = edibleDefs[item.id];
     const equippable = itemDef.equippable;
     const isRecipe = itemDef.kind === 'recipe';
+    const isTreasureMap = itemDef.kind === 'treasure_map';
 
     if (edible) {
       send({ type: 'eat', payload: { item: item.id } });
@@ -112,6 +115,8 @@ const InventoryPanel: React.FC<InventoryPanelProps> = ({ isOpen, onClose, invent
       send({ type: 'equip', payload: { inventorySlot: slotKey } });
     } else if (isRecipe) {
       sendLearnRecipe(slotKey);
+    } else if (isTreasureMap) {
+      sendReadMap(slotKey);
     }
   };
 
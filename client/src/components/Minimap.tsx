@@ -0,0 +1,97 @@
+import React, { useEffect, useRef, useState } from 'react';
+import * as state from '../state';
+import { addStateUpdateListener } from '../network';
+import { getTileProperties } from '../definitions';
+
+interface MinimapProps {
+  x: number;
+  y: number;
+}
+
+// Size of the minimap in pixels, and of each minimap cell on it.
+const MINIMAP_SIZE = 160;
+const CELL_PIXELS = 2;
+
+// cellIndex decodes a minimap cell character into a palette index.
+function cellIndex(code: number): number {
+  return code >= 97 ? code - 97 : code - 65 + 26; // 'a'-'z', then 'A'-'Z'
+}
+
+const Minimap: React.FC<MinimapProps> = ({ x, y }) => {
+  const canvasRef = useRef<HTMLCanvasElement>(null);
+  const [version, setVersion] = useState(state.getState().minimap.version);
+  const [digSite, setDigSite] = useState(state.getState().digSite);
+
+  useEffect(() => {
+    return addStateUpdateListener(() => {
+      setVersion(state.getState().minimap.version);
+      setDigSite(state.getState().digSite);
+    });
+  }, []);
+
+  useEffect(() => {
+    const canvas = canvasRef.current;
+    const ctx = canvas?.getContext('2d');
+    if (!canvas || !ctx) return;
+    const { cellSize, palette, chunks } = state.getState().minimap;
+
+    ctx.fillStyle = '#000';
+    ctx.fillRect(0, 0, MINIMAP_SIZE, MINIMAP_SIZE);
+    if (chunks.size === 0) return;
+
+    // The player is at the center; each cell covers cellSize tiles.
+    const scale = CELL_PIXELS / cellSize;
+    const toPixel = (tx: number, ty: number) => ({
+      px: MINIMAP_SIZE / 2 + (tx - x) * scale,
+      py: MINIMAP_SIZE / 2 + (ty - y) * scale,
+    });
+
+    chunks.forEach(chunk => {
+      const cells = Math.sqrt(chunk.cells.length);
+      const chunkTiles = cells * cellSize;
+      const { px, py } = toPixel(chunk.x * chunkTiles, chunk.y * chunkTiles);
+      if (px > MINIMAP_SIZE || py > MINIMAP_SIZE || px + cells * CELL_PIXELS < 0 || py + cells * CELL_PIXELS < 0) {
+        return; // Out of sight
+      }
+      for (let i = 0; i < chunk.cells.length; i++) {
+        const tileType = palette[cellIndex(chunk.cells.charCodeAt(i))] || 'void';
+        ctx.fillStyle = getTileProperties(tileType).color;
+        ctx.fillRect(px + (i % cells) * CELL_PIXELS, py + Math.floor(i / cells) * CELL_PIXELS, CELL_PIXELS, CELL_PIXELS);
+      }
+    });
+
+    // Markers are drawn over every chunk's cells, so none hides another's.
+    chunks.forEach(chunk => {
+      ctx.fillStyle = '#FF1493';
+      for (const [sx, sy] of chunk.structures || []) {
+        const { px, py } = toPixel(sx, sy);
+        ctx.fillRect(px - 1, py - 1, 2, 2);
+      }
+      ctx.fillStyle = '#FFFFFF';
+      for (const [sx, sy] of chunk.sanctuaries || []) {
+        const { px, py } = toPixel(sx, sy);
+        ctx.fillRect(px - 2, py - 2, 4, 4);
+      }
+    });
+
+    // The dig site of the treasure map the player read, as an X.
+    if (digSite) {
+      const { px, py } = toPixel(digSite.x, digSite.y);
+      ctx.strokeStyle = '#FF0000';
+      ctx.lineWidth = 2;
+      ctx.beginPath();
+      ctx.moveTo(px - 3, py - 3);
+      ctx.lineTo(px + 3, py + 3);
+      ctx.moveTo(px + 3, py - 3);
+      ctx.lineTo(px - 3, py + 3);
+      ctx.stroke();
+    }
+
+    ctx.fillStyle = '#00FFFF';
+    ctx.fillRect(MINIMAP_SIZE / 2 - 2, MINIMAP_SIZE / 2 - 2, 4, 4);
+  }, [x, y, version, digSite]);
+
+  return <canvas id="minimap" ref={canvasRef} width={MINIMAP_SIZE} height={MINIMAP_SIZE} />;
+};
+
+export default Minimap;
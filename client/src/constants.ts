@@ -11,4 +11,6 @@ export const BACKGROUND_TILE_SIZE = 264;
 // Cooldown durations in milliseconds. These should match the server's constants.
 export const ACTION_COOLDOWN = 100;
 export const WATER_PENALTY = 500;
+// Diagonal steps take this much longer than straight ones.
+export const DIAGONAL_COOLDOWN_FACTOR = 1.4;
 export const INTERACTION_COOLDOWN = 100;
@@ -3,6 +3,7 @@
 import { 
     drawRockTile, 
     drawTree, 
+    drawPineTree,
     drawPlayer, 
     drawItem, 
     drawWizard,
@@ -119,6 +120,265 @@ export const tileDefs: Record<string, TileProperties> = {
         draw: drawSanctuaryStone,
         gatherResource: ''
     },
+    'grass': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: true,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#4F7A28',
+        gatherResource: '',
+        draw: undefined
+    },
+    'mud': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#5C4A32',
+        gatherResource: '',
+        draw: undefined
+    },
+    'gravel': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: true,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#8B8378',
+        gatherResource: '',
+        draw: undefined
+    },
+    'snow': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: true,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#EEF3F7',
+        gatherResource: '',
+        draw: undefined
+    },
+    'ice': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#BFE3F0',
+        gatherResource: '',
+        draw: undefined
+    },
+    'pine_tree': {
+        isCollidable: true,
+        isGatherable: true,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        gatherResource: 'wood',
+        maxHealth: 3,
+        color: '#2F5D3A',
+        draw: drawPineTree,
+    },
+    'road': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        moveCooldownFactor: 0.75,
+        maxHealth: 0,
+        color: '#A68A64',
+        gatherResource: '',
+        draw: undefined
+    },
+    'bridge': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        moveCooldownFactor: 0.75,
+        maxHealth: 0,
+        color: '#7A5230',
+        gatherResource: '',
+        draw: undefined
+    },
+    'dungeon_entrance': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#2B1D14',
+        gatherResource: '',
+        draw: undefined
+    },
+    'dungeon_floor': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        moveCooldownFactor: 1,
+        maxHealth: 0,
+        color: '#5A5248',
+        gatherResource: '',
+        draw: undefined
+    },
+    'dungeon_wall': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#2E2A26',
+        gatherResource: '',
+        draw: undefined
+    },
+    'dungeon_exit': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#C9B458',
+        gatherResource: '',
+        draw: undefined
+    },
+    'cliff': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#6E5F50',
+        gatherResource: '',
+        draw: undefined
+    },    'portal': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#9B30FF',
+        gatherResource: '',
+        draw: undefined
+    },
+    'cave_floor': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: true,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#4A443F',
+        gatherResource: '',
+        draw: undefined
+    },
+    'cave_wall': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#24201E',
+        gatherResource: '',
+        draw: undefined
+    },
+    'ethereal_ground': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: true,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#B8C6E8',
+        gatherResource: '',
+        draw: undefined
+    },
+    'rift': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#14102A',
+        gatherResource: '',
+        draw: undefined
+    },
+    'spirit_tree': {
+        isCollidable: true,
+        isGatherable: true,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 4,
+        color: '#7FE0D0',
+        gatherResource: 'wood',
+        draw: undefined
+    },
+    'ruins': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#9E9484',
+        gatherResource: '',
+        poi: 'search',
+        draw: undefined
+    },
+    'shrine': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#E8D58C',
+        gatherResource: '',
+        poi: 'search',
+        draw: undefined
+    },
+    'abandoned_camp': {
+        isCollidable: true,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#B5651D',
+        gatherResource: '',
+        poi: 'search',
+        draw: undefined
+    },
+    'buried_cache': {
+        isCollidable: false,
+        isGatherable: false,
+        isDestructible: false,
+        isBuildableOn: false,
+        movementPenalty: false,
+        maxHealth: 0,
+        color: '#73563A',
+        gatherResource: '',
+        poi: 'dig',
+        draw: undefined
+    },
 };
 
 /**
@@ -134,7 +394,7 @@ export const itemDefinitions: { [key: string]: ItemProperties } = {
     'goop': { text: 'Goop', icon: '💧', character: 'g', color: '#90ee90', asset: 'assets/goop-icon.png' },
     'rat_meat': { text: 'Rat Meat', icon: '🍖', character: 'm', color: '#dc143c', asset: 'assets/rat-meat-icon.png' },
     'cooked_rat_meat': { text: 'Cooked Meat', icon: '🥩', character: 'm', color: '#a52a2a', asset: 'assets/cooked-meat-icon.png' },
-    'treasure_map': { text: 'Treasure Map', icon: '🗺️', character: 'm', color: '#ffd700', asset: 'assets/treasure-map-icon.png' },
+    'treasure_map': { text: 'Treasure Map', icon: '🗺️', character: 'm', color: '#ffd700', asset: 'assets/treasure-map-icon.png', kind: 'treasure_map' },
     'slice_of_pizza': { text: 'Slice of Pizza', icon: '🍕', character: 'p', color: '#ffd700', asset: 'assets/pizza-slice-icon.png' },
     'fire': { text: 'Fire', icon: '🔥', character: 'f', color: '#ff4500', asset: 'assets/fire-icon.png' },
     'wooden_wall': { text: 'Wooden Wall', icon: '🧱', character: '#', color: '#a0522d', asset: 'assets/wooden-wall-icon.png' },
@@ -6,7 +6,7 @@
  */
 
 // Tile drawing functions
-export { drawRockTile, drawIronRockTile, drawSanctuaryStone, drawTree, drawWaterGroup } from './drawing/tiles';
+export { drawRockTile, drawIronRockTile, drawSanctuaryStone, drawTree, drawPineTree, drawWaterGroup } from './drawing/tiles';
 
 // Entity drawing functions
 export { drawPlayer, drawWizard, drawItem, drawRat, drawSlime, drawGolemBanker } from './drawing/entities';
@@ -8,5 +8,6 @@ export { drawRockTile } from './rock';
 export { drawIronRockTile } from './ironRock';
 export { drawSanctuaryStone } from './sanctuaryStone';
 export { drawTree } from './tree';
+export { drawPineTree } from './pineTree';
 export { drawWaterGroup } from './water';
 
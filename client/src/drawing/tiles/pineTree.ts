@@ -0,0 +1,54 @@
+/**
+ * Pine Tree Tile Drawing
+ * 
+ * Draws a snowy tundra pine as stacked tiers of needles.
+ */
+
+import { WorldTile } from '../../types';
+import { SeededRandom } from '../../utils';
+
+export function drawPineTree(ctx: CanvasRenderingContext2D, x: number, y: number, tileSize: number, tileX: number, tileY: number, _time: number, tileData: WorldTile) {
+    const rand = new SeededRandom(tileX * 1000 + tileY);
+
+    const centerX = x * tileSize + tileSize / 2;
+    const baseY = y * tileSize + tileSize * 0.9;
+
+    const healthPercentage = tileData.health ? (tileData.health / 3) : 1;
+
+    // Trunk
+    ctx.fillStyle = 'rgb(70, 50, 35)';
+    ctx.fillRect(centerX - 2, baseY - tileSize * 0.2, 4, tileSize * 0.2);
+
+    if (healthPercentage <= 0) {
+        // Just a stump
+        return;
+    }
+
+    // Shadow
+    ctx.fillStyle = 'rgba(0, 0, 0, 0.25)';
+    ctx.beginPath();
+    ctx.ellipse(centerX + 2, baseY, tileSize * 0.35, tileSize * 0.1, 0, 0, 2 * Math.PI);
+    ctx.fill();
+
+    // Tiers of needles, narrowing towards the top. Damage thins them out.
+    const tiers = 3;
+    const height = tileSize * (0.75 + rand.next() * 0.1);
+    const green = 60 + rand.nextInt(0, 20);
+    for (let i = 0; i < tiers; i++) {
+        const tierBottom = baseY - tileSize * 0.15 - (height * 0.8 * i) / tiers;
+        const tierTop = tierBottom - height * 0.45;
+        const halfWidth = tileSize * (0.42 - i * 0.1) * (0.6 + 0.4 * healthPercentage);
+
+        ctx.fillStyle = `rgb(${green - 30}, ${green + 30}, ${green - 10})`;
+        ctx.beginPath();
+        ctx.moveTo(centerX, tierTop);
+        ctx.lineTo(centerX + halfWidth, tierBottom);
+        ctx.lineTo(centerX - halfWidth, tierBottom);
+        ctx.closePath();
+        ctx.fill();
+
+        // Snow along the tier's lower edge
+        ctx.fillStyle = 'rgba(240, 245, 250, 0.8)';
+        ctx.fillRect(centerX - halfWidth * 0.8, tierBottom - 2, halfWidth * 1.6, 2);
+    }
+}
@@ -1,7 +1,7 @@
 import * as state from './state';
 import * as network from './network';
 import { setBuildModeActive, hideDialog, closeBankWindow } from './ui';
-import { ACTION_COOLDOWN, TILE_SIZE, WATER_PENALTY } from './constants';
+import { ACTION_COOLDOWN, DIAGONAL_COOLDOWN_FACTOR, TILE_SIZE, WATER_PENALTY } from './constants';
 import { getEntityProperties, getTileProperties } from './definitions';
 import { getWindowAPI } from './api/windowApi';
 
@@ -17,6 +17,8 @@ const pressedKeys: string[] = [];
 let isMouseDown = false;
 let lastMouseEvent: MouseEvent | null = null;
 let pathQueue: string[] = [];
+// The "x,y" target of the walk the server is driving, if any
+let walkTarget: string | null = null;
 
 export function setPath(path: string[]) {
     pathQueue = path;
@@ -25,6 +27,21 @@ export function setPath(path: string[]) {
 
 function clearPathQueue() {
     pathQueue = [];
+    walkTarget = null;
+}
+
+export function clearWalkTarget() {
+    walkTarget = null;
+}
+
+// Asks the server to walk us to a tile, optionally interacting with it on arrival.
+// Repeated requests for the walk already under way are not resent.
+function sendWalkTo(x: number, y: number, interact?: { x: number, y: number, entityId?: string }) {
+    const key = `${x},${y}`;
+    if (walkTarget === key) return;
+    pathQueue = [];
+    walkTarget = key;
+    network.send({ type: 'walk_to', payload: { x, y, interact } });
 }
 
 function getTileCoordinatesFromMouseEvent(e: MouseEvent): { x: number, y: number } | null {
@@ -58,7 +75,17 @@ function sendMoveCommand(dx: number, dy: number, isContinuous: boolean = false)
 
     if (targetProps.isCollidable) return;
 
-    const cooldown = targetProps.movementPenalty ? WATER_PENALTY : ACTION_COOLDOWN;
+    const isDiagonal = dx !== 0 && dy !== 0;
+    if (isDiagonal) {
+        // No squeezing diagonally between two walls
+        const sideX = getTileProperties(state.getTileData(me.x + dx, me.y).type);
+        const sideY = getTileProperties(state.getTileData(me.x, me.y + dy).type);
+        if (sideX.isCollidable && sideY.isCollidable) return;
+    }
+
+    let cooldown = targetProps.movementPenalty ? WATER_PENALTY : ACTION_COOLDOWN;
+    cooldown *= targetProps.moveCooldownFactor || state.getMoveFactor(me.x + dx, me.y + dy);
+    if (isDiagonal) cooldown *= DIAGONAL_COOLDOWN_FACTOR;
     startActionCooldown(cooldown);
 
     const directionMap: { [key: string]: string } = {
@@ -66,6 +93,10 @@ function sendMoveCommand(dx: number, dy: number, isContinuous: boolean = false)
         '1,0': 'right',
         '0,-1': 'up',
         '0,1': 'down',
+        '-1,-1': 'up-left',
+        '1,-1': 'up-right',
+        '-1,1': 'down-left',
+        '1,1': 'down-right',
     };
     const dirKey = `${dx},${dy}`;
     network.send({ type: 'move', payload: { direction: directionMap[dirKey] } });
@@ -111,31 +142,30 @@ function sendMoveCommand(dx: number, dy: number, isContinuous: boolean = false)
 
 
 function updateMovement() {
-    const lastKey = pressedKeys[pressedKeys.length - 1];
-    if (!lastKey) return;
-
     let dx = 0;
     let dy = 0;
 
-    switch (lastKey) {
-        case 'w':
-        case 'ArrowUp':
-            dy = -1;
-            break;
-        case 's':
-        case 'ArrowDown':
-            dy = 1;
-            break;
-        case 'a':
-        case 'ArrowLeft':
-            dx = -1;
-            break;
-        case 'd':
-        case 'ArrowRight':
-            dx = 1;
-            break;
-        default:
-            return; // Not a movement key
+    // The most recent key on each axis wins, so holding a vertical and a horizontal key
+    // moves diagonally.
+    for (const key of pressedKeys) {
+        switch (key) {
+            case 'w':
+            case 'ArrowUp':
+                dy = -1;
+                break;
+            case 's':
+            case 'ArrowDown':
+                dy = 1;
+                break;
+            case 'a':
+            case 'ArrowLeft':
+                dx = -1;
+                break;
+            case 'd':
+            case 'ArrowRight':
+                dx = 1;
+                break;
+        }
     }
     
     if (dx === 0 && dy === 0) return;
@@ -274,7 +304,7 @@ function handleInteractionLogic() {
 
     // Priority 2: Attack
     if (attackableEntityId) {
-        if (Math.abs(me.x - tileX) + Math.abs(me.y - tileY) !== 1) return;
+        if (Math.max(Math.abs(me.x - tileX), Math.abs(me.y - tileY)) !== 1) return;
         startActionCooldown(ACTION_COOLDOWN);
         network.send({ type: 'attack', payload: { entityId: attackableEntityId } });
         return;
@@ -282,16 +312,48 @@ function handleInteractionLogic() {
 
     // Priority 3: Pick up item
     if (itemOnTileId) {
-        if (Math.max(Math.abs(me.x - tileX), Math.abs(me.y - tileY)) > 1) return;
+        if (Math.max(Math.abs(me.x - tileX), Math.abs(me.y - tileY)) > 1) {
+            sendWalkTo(tileX, tileY, { x: tileX, y: tileY, entityId: itemOnTileId });
+            return;
+        }
         startActionCooldown(ACTION_COOLDOWN);
         network.send({ type: 'interact', payload: { entityId: itemOnTileId } });
         return;
     }
 
-    // Priority 4: Gather resource
+    // Priority 4: Dig up the treasure map's dig site or a buried cache, or search a point of interest
     const targetTileProps = getTileProperties(state.getTileData(tileX, tileY).type);
+    const digSite = state.getState().digSite;
+    const isDigSite = digSite !== null && digSite.x === tileX && digSite.y === tileY;
+    if (isDigSite || targetTileProps.poi) {
+        const isDig = isDigSite || targetTileProps.poi === 'dig';
+        if (Math.max(Math.abs(me.x - tileX), Math.abs(me.y - tileY)) > 1) {
+            // Digging waits for the walk to arrive; searching happens on arrival.
+            sendWalkTo(tileX, tileY, isDig ? undefined : { x: tileX, y: tileY });
+            if (isDig) return;
+        } else {
+            startActionCooldown(ACTION_COOLDOWN);
+            if (isDig) {
+                network.sendDig(tileX, tileY);
+            } else {
+                network.send({ type: 'interact', payload: { x: tileX, y: tileY } });
+            }
+        }
+
+        // Either happens once, so holding the mouse down should not repeat it.
+        if (interactionInterval) {
+            clearInterval(interactionInterval);
+            interactionInterval = null;
+        }
+        return;
+    }
+
+    // Priority 5: Gather resource
     if (targetTileProps.isGatherable || targetTileProps.isDestructible || state.getTileData(tileX, tileY).type === 'sanctuary_stone') {
-        if (Math.max(Math.abs(me.x - tileX), Math.abs(me.y - tileY)) > 1) return;
+        if (Math.max(Math.abs(me.x - tileX), Math.abs(me.y - tileY)) > 1) {
+            sendWalkTo(tileX, tileY, { x: tileX, y: tileY });
+            return;
+        }
         state.setLastInteractionPosition(lastMouseEvent!.clientX, lastMouseEvent!.clientY);
         const cooldown = targetTileProps.movementPenalty ? WATER_PENALTY : ACTION_COOLDOWN;
         startActionCooldown(cooldown);
@@ -320,28 +382,24 @@ function handleRightClick(e: MouseEvent) {
     const me = state.getMyEntity();
     if (!me) return;
 
-    // 1. Immediate movement for responsiveness
-    const dx = coords.x - me.x;
-    const dy = coords.y - me.y;
-
-    let moveDx = 0;
-    let moveDy = 0;
+    // Clicked on self, do nothing
+    if (coords.x === me.x && coords.y === me.y) return;
 
-    if (Math.abs(dx) > Math.abs(dy)) {
-        moveDx = Math.sign(dx);
-    } else if (Math.abs(dy) > 0) {
-        moveDy = Math.sign(dy);
-    } else {
-        // Clicked on self, do nothing
-        return;
-    }
-    
-    if ((moveDx !== 0 || moveDy !== 0)) {
-        sendMoveCommand(moveDx, moveDy, false);
+    // Right-clicking another player follows them
+    const entities = state.getState().entities;
+    const myPlayerId = state.getState().playerId;
+    for (const id in entities) {
+        const e = entities[id];
+        if (e.type === 'player' && id !== myPlayerId && e.x === coords.x && e.y === coords.y) {
+            pathQueue = [];
+            walkTarget = `follow:${id}`;
+            network.send({ type: 'follow', payload: { entityId: id } });
+            return;
+        }
     }
 
-    // 2. Send find-path request to server
-    network.send({ type: 'find-path', payload: { x: coords.x, y: coords.y } });
+    // The server plans the path and walks us along it
+    sendWalkTo(coords.x, coords.y);
 }
 
 function handleMouseUpOrLeave() {
@@ -365,12 +423,10 @@ function processPathMovement() {
         if (direction) {
             let dx = 0;
             let dy = 0;
-            switch (direction) {
-                case 'up': dy = -1; break;
-                case 'down': dy = 1; break;
-                case 'left': dx = -1; break;
-                case 'right': dx = 1; break;
-            }
+            if (direction.startsWith('up')) dy = -1;
+            if (direction.startsWith('down')) dy = 1;
+            if (direction.endsWith('left')) dx = -1;
+            if (direction.endsWith('right')) dx = 1;
             if (dx !== 0 || dy !== 0) {
                 sendMoveCommand(dx, dy, false);
             }
@@ -16,6 +16,8 @@ import {
     ServerMessage, 
     StateCorrectionMessage, 
     WorldUpdateMessage,
+    WorldChunkMessage,
+    MinimapMessage,
     EntityAttackMessage,
     DialogMessage,
     QuestUpdateMessage,
@@ -25,12 +27,13 @@ import {
     BankUpdateMessage,
     ValidPathMessage,
     NotificationMessage,
+    DigSiteMessage,
     TeleportChannelStartMessage,
 } from './types';
 import * as state from './state';
 import { showDamageIndicator } from './renderer';
 import { showErrorMessage } from './renderer/layers/errorMessages';
-import { setPath } from './input';
+import { setPath, clearWalkTarget } from './input';
 import { callWindowFunction } from './api/windowApi';
 
 let ws: WebSocket;
@@ -79,6 +82,8 @@ function handleMessage(event: MessageEvent) {
                 initialState.activeRune || '',
                 initialState.knownRecipes || {},
             );
+            state.setBiomeMap(initialState.biomeMap);
+            state.addMinimapChunks(initialState.minimap);
             const myEntity = state.getMyEntity();
             if (myEntity && myEntity.name) {
                 // This is now handled by React state
@@ -147,6 +152,7 @@ function handleMessage(event: MessageEvent) {
                 owner: joinMsg.owner,
                 createdAt: joinMsg.createdAt,
                 publicAt: joinMsg.publicAt,
+                quantity: joinMsg.quantity,
                 shirtColor: joinMsg.shirtColor,
                 gear: joinMsg.gear,
             });
@@ -173,6 +179,17 @@ function handleMessage(event: MessageEvent) {
             onStateUpdate();
             break;
         }
+        case 'world_chunk': {
+            const chunkMsg = msg as WorldChunkMessage;
+            state.addWorldChunk(chunkMsg.x, chunkMsg.y, chunkMsg.tiles || {}, chunkMsg.biomes);
+            onStateUpdate();
+            break;
+        }
+        case 'minimap': {
+            state.addMinimapChunks(msg as MinimapMessage);
+            onStateUpdate();
+            break;
+        }
         case 'inventory_update': {
             const inventoryMsg = msg as InventoryUpdateMessage;
             state.setInventory(inventoryMsg.inventory || {});
@@ -267,6 +284,12 @@ function handleMessage(event: MessageEvent) {
             onStateUpdate();
             break;
         }
+        case 'dig_site': {
+            const digSiteMsg = msg as DigSiteMessage;
+            state.setDigSite(digSiteMsg.dug ? null : { x: digSiteMsg.x, y: digSiteMsg.y });
+            onStateUpdate();
+            break;
+        }
         case 'registered': {
             const regMsg = msg as RegisteredMessage;
             localStorage.setItem('secretKey', regMsg.secretKey);
@@ -277,12 +300,19 @@ function handleMessage(event: MessageEvent) {
         }
         case 'no-valid-path':
             console.log("No valid path found.");
+            clearWalkTarget();
             break;
         case 'valid-path': {
             const validPathMsg = msg as ValidPathMessage;
             setPath(validPathMsg.directions);
             break;
         }
+        case 'walk_path':
+            // The server moves us itself; positions arrive as entity_moved updates.
+            break;
+        case 'walk_ended':
+            clearWalkTarget();
+            break;
         case 'open_bank_window': {
             // Bank opening is now handled by React via state updates
             callWindowFunction('togglePanel', 'bank');
@@ -334,6 +364,25 @@ export function sendLearnRecipe(inventorySlot: string) {
     });
 }
 
+export function sendReadMap(inventorySlot: string) {
+    send({
+        type: 'read_map',
+        payload: {
+            inventorySlot,
+        },
+    });
+}
+
+export function sendDig(x: number, y: number) {
+    send({
+        type: 'dig',
+        payload: {
+            x,
+            y,
+        },
+    });
+}
+
 export function sendDepositItem(slot: string, quantity: number) {
     send({
         type: 'deposit_item',
@@ -65,6 +65,14 @@ export function renderEntities(ctx: CanvasRenderingContext2D, params: RenderPara
 
         ctx.restore();
 
+        // Pile size for merged item drops
+        if (entity.type === 'item' && entity.quantity && entity.quantity > 1) {
+            ctx.font = "bold 10px 'Roboto', sans-serif";
+            ctx.textAlign = 'right';
+            ctx.fillStyle = 'white';
+            ctx.fillText(String(entity.quantity), screenX + TILE_SIZE - 2, screenY + TILE_SIZE - 2);
+        }
+
         if (entity.questState) {
             drawQuestIndicator(ctx, screenX, screenY, TILE_SIZE, params.time, entity.questState);
         }
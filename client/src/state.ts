@@ -1,4 +1,4 @@
-import { ClientState, WorldTile, EntityState, InventoryItem, Quest } from './types';
+import { ClientState, WorldTile, EntityState, InventoryItem, Quest, BiomeMap, MinimapMessage } from './types';
 
 // The global client state object. It is private to this module.
 const clientState: ClientState = {
@@ -19,6 +19,9 @@ const clientState: ClientState = {
     activeRune: '',
     knownRecipes: {},
     camera: { x: 0, y: 0 },
+    biomes: null,
+    minimap: { cellSize: 4, palette: [], chunks: new Map(), version: 0 },
+    digSite: null,
 };
 
 // --- State Accessors (Getters) ---
@@ -38,7 +41,31 @@ export function getMyEntity(): EntityState | undefined {
 
 export function getTileData(x: number, y: number): WorldTile {
     const key = `${x},${y}`;
-    return clientState.world[key] || { type: 'ground', health: 0 };
+    return clientState.world[key] || { type: getFloorType(x, y), health: 0 };
+}
+
+// biomeIndex returns the index of the biome a tile is in, or -1 if it is unknown.
+function biomeIndex(x: number, y: number): number {
+    const biomes = clientState.biomes;
+    if (!biomes) return -1;
+    const size = biomes.chunkSize;
+    const cx = Math.floor(x / size);
+    const cy = Math.floor(y / size);
+    const codes = biomes.chunks.get(`${cx},${cy}`);
+    if (!codes) return -1;
+    return codes[(y - cy * size) * size + (x - cx * size)];
+}
+
+// getFloorType returns the floor of the tile's biome, which the server leaves out of the world state.
+export function getFloorType(x: number, y: number): string {
+    const index = biomeIndex(x, y);
+    return index < 0 ? 'ground' : clientState.biomes!.floors[index];
+}
+
+// getMoveFactor returns how much the tile's biome lengthens a step onto it.
+export function getMoveFactor(x: number, y: number): number {
+    const index = biomeIndex(x, y);
+    return index < 0 ? 1 : clientState.biomes!.moveFactors[index] || 1;
 }
 
 // --- State Mutators (Setters) ---
@@ -51,6 +78,10 @@ export function setActiveNpcId(npcId: string | null) {
     clientState.activeNpcId = npcId;
 }
 
+export function setDigSite(digSite: { x: number, y: number } | null) {
+    clientState.digSite = digSite;
+}
+
 export function setInitialState(
     playerId: string, 
     entities: Record<string, EntityState>, // This map now includes 'type'
@@ -98,6 +129,57 @@ export function setInitialState(
     clientState.knownRecipes = knownRecipes;
 }
 
+export function setBiomeMap(biomeMap: BiomeMap | undefined) {
+    if (!biomeMap) {
+        clientState.biomes = null;
+        return;
+    }
+    const chunks = new Map<string, Uint8Array>();
+    for (const [key, runs] of Object.entries(biomeMap.chunks || {})) {
+        chunks.set(key, decodeBiomeRuns(runs, biomeMap.chunkSize));
+    }
+    clientState.biomes = { chunkSize: biomeMap.chunkSize, floors: biomeMap.floors, moveFactors: biomeMap.moveFactors, chunks };
+}
+
+// addWorldChunk adds a chunk that has come into view: its tiles and its biomes.
+export function addWorldChunk(cx: number, cy: number, tiles: Record<string, WorldTile>, biomes: string) {
+    Object.assign(clientState.world, tiles);
+    if (clientState.biomes) {
+        clientState.biomes.chunks.set(`${cx},${cy}`, decodeBiomeRuns(biomes, clientState.biomes.chunkSize));
+    }
+}
+
+// addMinimapChunks adds the minimap of chunks the player has discovered, replacing any
+// they had of the same chunks.
+export function addMinimapChunks(minimap: MinimapMessage | undefined) {
+    if (!minimap) return;
+    clientState.minimap.cellSize = minimap.cellSize;
+    clientState.minimap.palette = minimap.palette;
+    for (const chunk of minimap.chunks || []) {
+        clientState.minimap.chunks.set(`${chunk.x},${chunk.y}`, chunk);
+    }
+    clientState.minimap.version++;
+}
+
+// decodeBiomeRuns expands a chunk's run-length encoded biomes: each run is a count
+// followed by a letter, 'a' for the first biome, 'b' for the second and so on.
+function decodeBiomeRuns(runs: string, chunkSize: number): Uint8Array {
+    const codes = new Uint8Array(chunkSize * chunkSize);
+    let pos = 0;
+    let count = 0;
+    for (let i = 0; i < runs.length; i++) {
+        const ch = runs.charCodeAt(i);
+        if (ch >= 48 && ch <= 57) { // '0'-'9'
+            count = count * 10 + (ch - 48);
+            continue;
+        }
+        codes.fill(ch - 97, pos, pos + count); // 'a' is biome 0
+        pos += count;
+        count = 0;
+    }
+    return codes;
+}
+
 export function setEntityPosition(entityId: string, x: number, y: number, direction?: 'up' | 'down' | 'left' | 'right') {
     const entity = clientState.entities[entityId];
     if (entity) {
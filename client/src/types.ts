@@ -11,6 +11,7 @@ export interface EntityState {
     owner?: string;
     createdAt?: number;
     publicAt?: number;
+    quantity?: number;
     lastChatMessage?: string;
     lastChatTimestamp?: number;
     name?: string;
@@ -29,6 +30,7 @@ export interface WorldTile {
     type: string;
     health: number;
     isSanctuary?: boolean;
+    height?: number;
 }
 
 export interface InventoryItem {
@@ -67,6 +69,36 @@ export interface ClientState {
     activeRune: string;
     knownRecipes: Record<string, boolean>;
     camera: { x: number, y: number };
+    biomes: DecodedBiomeMap | null;
+    minimap: ClientMinimap;
+    digSite: { x: number, y: number } | null;
+}
+
+// BiomeMap describes the biomes, with the run-length encoded biome map of each chunk
+// around the player keyed by "cx,cy". It is sent with the initial state; later chunks
+// arrive in world_chunk messages.
+export interface BiomeMap {
+    chunkSize: number;
+    biomes: string[];
+    floors: string[];
+    moveFactors: number[];
+    chunks: Record<string, string>;
+}
+
+// ClientMinimap holds the minimap of every chunk the player has discovered, keyed by
+// "cx,cy". version changes whenever chunks are added, so the minimap knows to redraw.
+export interface ClientMinimap {
+    cellSize: number;
+    palette: string[];
+    chunks: Map<string, MinimapChunk>;
+    version: number;
+}
+
+export interface DecodedBiomeMap {
+    chunkSize: number;
+    floors: string[];
+    moveFactors: number[];
+    chunks: Map<string, Uint8Array>; // Biome index of each tile of a chunk, row by row
 }
 
 export interface TileProperties {
@@ -75,9 +107,13 @@ export interface TileProperties {
     isDestructible: boolean;
     isBuildableOn: boolean;
     movementPenalty: boolean;
+    // Scales the cooldown of a step onto the tile in place of its biome's factor, as on roads.
+    moveCooldownFactor?: number;
     gatherResource: string;
     maxHealth: number;
     color: string;
+    // Points of interest are searched by interacting with them, or dug up if buried.
+    poi?: 'search' | 'dig';
     draw?: (ctx: CanvasRenderingContext2D, x: number, y: number, tileSize: number, tileX: number, tileY: number, time: number, tileData: WorldTile) => void;
     asset?: string | string[];
 }
@@ -90,7 +126,7 @@ export interface ItemProperties {
     asset?: string;
     equippable?: { slot: string, damage?: number, defense?: number };
     draw?: (ctx: CanvasRenderingContext2D, pixelSize: number, direction: string) => void;
-    kind?: 'recipe';
+    kind?: 'recipe' | 'treasure_map';
 }
 
 export interface EntityProperties {
@@ -129,6 +165,8 @@ export interface InitialStateMessage extends ServerMessage {
     runes: string[];
     activeRune: string;
     knownRecipes: Record<string, boolean>;
+    biomeMap?: BiomeMap;
+    minimap?: MinimapMessage;
 }
 
 export interface BankUpdateMessage extends ServerMessage {
@@ -178,6 +216,7 @@ export interface EntityJoinedMessage extends ServerMessage {
     owner?: string;
     createdAt?: number;
     publicAt?: number;
+    quantity?: number;
     shirtColor?: string;
     gear?: Record<string, InventoryItem>;
 }
@@ -208,6 +247,32 @@ export interface WorldUpdateMessage extends ServerMessage {
     tile: WorldTile;
 }
 
+// MinimapChunk is the minimap of one chunk: the most common tile of each square of
+// cellSize tiles, row by row, one character per cell coding an index into the palette
+// ('a' is 0, 'A' follows 'z'), and the [x, y] tiles of sanctuary stones and structures.
+export interface MinimapChunk {
+    x: number;
+    y: number;
+    cells: string;
+    sanctuaries?: [number, number][];
+    structures?: [number, number][];
+}
+
+export interface MinimapMessage extends ServerMessage {
+    type: 'minimap';
+    cellSize: number;
+    palette: string[];
+    chunks: MinimapChunk[];
+}
+
+export interface WorldChunkMessage extends ServerMessage {
+    type: 'world_chunk';
+    x: number;
+    y: number;
+    tiles: Record<string, WorldTile>;
+    biomes: string;
+}
+
 export interface InventoryUpdateMessage extends ServerMessage {
     type: 'inventory_update';
     inventory: Record<string, InventoryItem>;
@@ -264,6 +329,15 @@ export interface NotificationMessage extends ServerMessage {
     message: string;
 }
 
+// DigSiteMessage tells the player where the treasure map they read leads; dug is set
+// once the site has been dug up.
+export interface DigSiteMessage extends ServerMessage {
+    type: 'dig_site';
+    x: number;
+    y: number;
+    dug?: boolean;
+}
+
 export interface SendChatMessage {
     type: 'send_chat';
     message: string;
@@ -315,6 +389,16 @@ export interface ValidPathMessage extends ServerMessage {
     directions: string[];
 }
 
+export interface WalkPathMessage extends ServerMessage {
+    type: 'walk_path';
+    directions: string[];
+}
+
+export interface WalkEndedMessage extends ServerMessage {
+    type: 'walk_ended';
+    arrived: boolean;
+}
+
 export interface TeleportChannelStartMessage extends ServerMessage {
     type: 'teleport_channel_start';
     duration: number;
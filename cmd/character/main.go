@@ -0,0 +1,112 @@
+// Command character exports a player's full character to a portable JSON file
+// and imports such a file into a server.
+//
+//	go run ./cmd/character export -player player:1234 -o alice.json
+//	go run ./cmd/character import -i alice.json -id new
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"mmo-game/game"
+	"os"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: character export -player <id> [-secret] [-o file]")
+	fmt.Fprintln(os.Stderr, "       character import -i file [-id <id>|new] [-overwrite] [-secret]")
+	os.Exit(2)
+}
+
+func connect(addr string) {
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	if _, err := rdb.Ping(context.Background()).Result(); err != nil {
+		log.Fatalf("Could not connect to Redis: %v", err)
+	}
+	game.Init(rdb, func(string, []byte) {}, func(string) bool { return false })
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "export":
+		runExport(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	redisAddr := fs.String("redis", "localhost:6379", "Redis address")
+	playerID := fs.String("player", "", "player ID to export (e.g. player:1234)")
+	includeSecret := fs.Bool("secret", false, "include the secret key that logs into this player")
+	output := fs.String("o", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	if *playerID == "" {
+		usage()
+	}
+	connect(*redisAddr)
+
+	export, err := game.ExportCharacter(*playerID, *includeSecret)
+	if err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		log.Fatalf("Could not encode export: %v", err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(append(data, '\n'))
+		return
+	}
+	if err := os.WriteFile(*output, append(data, '\n'), 0o600); err != nil {
+		log.Fatalf("Could not write %s: %v", *output, err)
+	}
+	log.Printf("Exported %s to %s.", *playerID, *output)
+}
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	redisAddr := fs.String("redis", "localhost:6379", "Redis address")
+	input := fs.String("i", "", "export file to import")
+	targetID := fs.String("id", "", `player ID to import as ("new" generates one; default keeps the exported ID)`)
+	overwrite := fs.Bool("overwrite", false, "replace an existing player and reassign the secret key if needed")
+	restoreSecret := fs.Bool("secret", false, "restore the exported secret key mapping")
+	fs.Parse(args)
+
+	if *input == "" {
+		usage()
+	}
+	data, err := os.ReadFile(*input)
+	if err != nil {
+		log.Fatalf("Could not read %s: %v", *input, err)
+	}
+	var export game.CharacterExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		log.Fatalf("Could not parse %s: %v", *input, err)
+	}
+
+	connect(*redisAddr)
+	playerID, err := game.ImportCharacter(&export, game.ImportCharacterOptions{
+		TargetID:      *targetID,
+		Overwrite:     *overwrite,
+		RestoreSecret: *restoreSecret,
+	})
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	fmt.Println(playerID)
+}
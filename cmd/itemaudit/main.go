@@ -0,0 +1,158 @@
+// Command itemaudit reconstructs a player's item history from the audit streams
+// and reports anomalies such as net-positive crafting or unexplained items.
+//
+//	go run ./cmd/itemaudit -player player:1234
+//	go run ./cmd/itemaudit -player player:1234 -item iron_ore
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"mmo-game/game"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func main() {
+	redisAddr := flag.String("redis", "localhost:6379", "Redis address")
+	playerID := flag.String("player", "", "player ID to audit (e.g. player:1234)")
+	itemFilter := flag.String("item", "", "only show history and totals for this item ID")
+	limit := flag.Int64("limit", 0, "maximum number of history entries to read (0 = all)")
+	quiet := flag.Bool("quiet", false, "omit the full history listing")
+	trace := flag.Bool("trace", false, "look up the original drop for each loot pickup (scans the global stream)")
+	flag.Parse()
+
+	if *playerID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: *redisAddr})
+	if _, err := rdb.Ping(context.Background()).Result(); err != nil {
+		log.Fatalf("Could not connect to Redis: %v", err)
+	}
+	game.Init(rdb, func(string, []byte) {}, func(string) bool { return false })
+
+	entries, err := game.GetPlayerItemHistory(*playerID, *limit)
+	if err != nil {
+		log.Fatalf("Could not read item history: %v", err)
+	}
+	if len(entries) == 0 {
+		fmt.Printf("No item history recorded for %s.\n", *playerID)
+		return
+	}
+
+	matches := func(itemID game.ItemID) bool {
+		return *itemFilter == "" || string(itemID) == *itemFilter
+	}
+
+	if !*quiet {
+		fmt.Printf("== History for %s (%d entries) ==\n", *playerID, len(entries))
+		for _, entry := range entries {
+			if !matches(entry.ItemID) {
+				continue
+			}
+			line := fmt.Sprintf("%s  %-14s %5d %-20s %9s -> %-9s",
+				time.UnixMilli(entry.Timestamp).Format(time.RFC3339), entry.Reason, entry.Quantity, entry.ItemID, entry.Source, entry.Dest)
+			if entry.Ref != "" {
+				line += "  ref=" + entry.Ref
+			}
+			if *trace && entry.Reason == game.ItemAuditLootPickup && entry.Ref != "" {
+				line += "  " + describeDrop(entry.Ref)
+			}
+			fmt.Println(line)
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("== Origin of items received ==")
+	type originKey struct {
+		item   game.ItemID
+		reason game.ItemAuditReason
+	}
+	origins := make(map[originKey]int)
+	for _, entry := range entries {
+		if !matches(entry.ItemID) {
+			continue
+		}
+		if entry.Source == game.ItemLocationNone || entry.Source == game.ItemLocationWorld {
+			origins[originKey{entry.ItemID, entry.Reason}] += entry.Quantity
+		}
+	}
+	keys := make([]originKey, 0, len(origins))
+	for k := range origins {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].item != keys[j].item {
+			return keys[i].item < keys[j].item
+		}
+		return keys[i].reason < keys[j].reason
+	})
+	for _, k := range keys {
+		fmt.Printf("  %-20s %-14s %d\n", k.item, k.reason, origins[k])
+	}
+	fmt.Println()
+
+	fmt.Println("== Anomalies ==")
+	anomalies := game.AnalyzeItemHistory(entries)
+	found := false
+	for _, anomaly := range anomalies {
+		if anomaly.ItemID != "" && !matches(anomaly.ItemID) {
+			continue
+		}
+		found = true
+		fmt.Printf("  [%s] %s\n", anomaly.EntryID, anomaly.Message)
+	}
+	if !found {
+		fmt.Println("  none")
+	}
+	fmt.Println()
+
+	if *limit > 0 {
+		fmt.Println("History was truncated by -limit; skipping holdings comparison.")
+		return
+	}
+
+	fmt.Println("== Holdings (reconstructed vs actual) ==")
+	actual, err := game.GetActualHoldings(*playerID)
+	if err != nil {
+		log.Fatalf("Could not load current holdings: %v", err)
+	}
+	discrepancies := game.CompareHoldings(game.ReconstructHoldings(entries), actual)
+	found = false
+	for _, d := range discrepancies {
+		if !matches(d.ItemID) {
+			continue
+		}
+		found = true
+		fmt.Printf("  %-9s %-20s reconstructed=%d actual=%d (%+d unexplained)\n",
+			d.Location, d.ItemID, d.Reconstructed, d.Actual, d.Actual-d.Reconstructed)
+	}
+	if !found {
+		fmt.Println("  all holdings match the audit log")
+	}
+}
+
+// describeDrop finds where a picked-up world item originally came from.
+func describeDrop(ref string) string {
+	related, err := game.GetItemAuditEntriesByRef(ref)
+	if err != nil {
+		return "(drop lookup failed)"
+	}
+	for _, entry := range related {
+		if entry.Dest == game.ItemLocationWorld {
+			owner := entry.PlayerID
+			if owner == "" {
+				owner = "nobody"
+			}
+			return fmt.Sprintf("(dropped via %s, owner %s)", entry.Reason, owner)
+		}
+	}
+	return "(no matching drop recorded)"
+}
@@ -0,0 +1,50 @@
+// Command migrateplayers upgrades every player record in Redis to the current
+// schema version. It is safe to run against a live server.
+//
+//	go run ./cmd/migrateplayers          # migrate all players
+//	go run ./cmd/migrateplayers -dry-run # only report schema versions
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"mmo-game/game"
+	"sort"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func main() {
+	redisAddr := flag.String("redis", "localhost:6379", "Redis address")
+	dryRun := flag.Bool("dry-run", false, "report how many players are at each schema version without migrating")
+	flag.Parse()
+
+	rdb := redis.NewClient(&redis.Options{Addr: *redisAddr})
+	if _, err := rdb.Ping(context.Background()).Result(); err != nil {
+		log.Fatalf("Could not connect to Redis: %v", err)
+	}
+	game.Init(rdb, func(string, []byte) {}, func(string) bool { return false })
+
+	counts, err := game.CountPlayerSchemaVersions()
+	if err != nil {
+		log.Fatalf("Could not scan player records: %v", err)
+	}
+	versions := make([]int, 0, len(counts))
+	for version := range counts {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	fmt.Printf("Current player schema version: %d\n", game.PlayerSchemaVersion)
+	for _, version := range versions {
+		fmt.Printf("  version %d: %d players\n", version, counts[version])
+	}
+
+	if *dryRun {
+		return
+	}
+
+	migrated, failed := game.MigrateAllPlayers()
+	fmt.Printf("Migrated %d players, %d failed.\n", migrated, failed)
+}
@@ -0,0 +1,131 @@
+// Command worldgen previews the starting area of the world a generation config
+// produces, without Redis. The world extends beyond it, generated as it is explored.
+//
+//	go run ./cmd/worldgen preview -seed 42
+//	go run ./cmd/worldgen preview -config world.json
+//	go run ./cmd/worldgen config -seed 42 > world.json
+//
+// Start the server with -world-config world.json (or -world-seed 42) to generate that
+// world. A world that already exists in Redis keeps the config it was generated with.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"mmo-game/game"
+	"os"
+	"sort"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: worldgen preview [-config file] [-seed n]")
+	fmt.Fprintln(os.Stderr, "       worldgen config [-config file] [-seed n]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "preview":
+		runPreview(os.Args[2:])
+	case "config":
+		runConfig(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+// parseConfig reads the -config and -seed flags shared by every subcommand.
+func parseConfig(name string, args []string) game.WorldGenConfig {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	configFile := fs.String("config", "", "JSON world generation config; defaults are used for missing fields")
+	seed := fs.Int64("seed", 0, "world seed, overriding the one in -config")
+	fs.Parse(args)
+
+	cfg := game.DefaultWorldGenConfig()
+	if *configFile != "" {
+		var err error
+		if cfg, err = game.LoadWorldGenConfigFile(*configFile); err != nil {
+			log.Fatalf("Could not load %s: %v", *configFile, err)
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			cfg.Seed = *seed
+		}
+	})
+	return cfg
+}
+
+func runConfig(args []string) {
+	cfg := parseConfig("config", args)
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	out, _ := json.MarshalIndent(cfg, "", "  ")
+	fmt.Println(string(out))
+}
+
+func runPreview(args []string) {
+	cfg := parseConfig("preview", args)
+	preview, err := game.PreviewWorld(cfg)
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+
+	side := 2*cfg.WorldSize + 1
+	total := side * side
+	fmt.Printf("Seed:         %d\n", cfg.Seed)
+	fmt.Printf("Start area:   %d (%dx%d, %d tiles)\n", cfg.WorldSize, side, side, total)
+
+	fmt.Println("Tiles:")
+	for _, tileType := range sortedTypes(preview.TileCounts) {
+		count := preview.TileCounts[tileType]
+		fmt.Printf("  %-16s %7d  %5.1f%%\n", tileType, count, 100*float64(count)/float64(total))
+	}
+
+	fmt.Printf("Sanctuaries:  %d\n", len(preview.Sanctuaries))
+	for _, s := range preview.Sanctuaries {
+		fmt.Printf("  (%d, %d) radius %d\n", s.X, s.Y, s.Radius)
+	}
+
+	fmt.Println("Biomes:")
+	for _, biome := range game.BiomeOrder {
+		count := preview.BiomeCounts[biome]
+		fmt.Printf("  %-16s %7d  %5.1f%%\n", biome, count, 100*float64(count)/float64(total))
+	}
+
+	fmt.Println("Resources:          spawns   target")
+	totals := make(map[game.TileType]int)
+	for _, biome := range game.BiomeOrder {
+		resources := preview.Resources[biome]
+		if len(resources) == 0 {
+			continue
+		}
+		fmt.Printf("  %s\n", biome)
+		for _, tileType := range sortedTypes(resources) {
+			totals[tileType] += resources[tileType]
+			fmt.Printf("    %-14s %7d  %7d\n", tileType, resources[tileType], preview.ResourceTargets[biome][tileType])
+		}
+	}
+
+	fmt.Println("By quadrant:        total       NW      NE      SW      SE")
+	for _, tileType := range sortedTypes(totals) {
+		q := preview.ResourceQuadrants[tileType]
+		fmt.Printf("  %-16s %7d  %7d %7d %7d %7d\n", tileType, totals[tileType], q[0], q[1], q[2], q[3])
+	}
+}
+
+func sortedTypes(counts map[game.TileType]int) []game.TileType {
+	types := make([]game.TileType, 0, len(counts))
+	for tileType := range counts {
+		types = append(types, tileType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
@@ -0,0 +1,88 @@
+// Command worldmap draws the world as a PNG, from Redis or from a snapshot, to review a
+// seed, check how resources and NPCs are spread, or look into a griefing report without
+// logging into the game.
+//
+//	go run ./cmd/worldmap -o world.png
+//	go run ./cmd/worldmap -snapshot griefed.world.gz -rect -40,-40,40,40 -scale 8 -overlays structures -o walls.png
+//	go run ./cmd/worldmap -overlays all -o debug.png
+//
+// Overlays are sanctuaries, resources, structures, entities and spawns, or all of them.
+// Chunks that have not been stored yet are drawn darkened, as they would be generated.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"mmo-game/game"
+	"os"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func connect(addr string) {
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	if _, err := rdb.Ping(context.Background()).Result(); err != nil {
+		log.Fatalf("Could not connect to Redis: %v", err)
+	}
+	game.Init(rdb, func(string, []byte) {}, func(string) bool { return false })
+}
+
+func main() {
+	redisAddr := flag.String("redis", "localhost:6379", "Redis address")
+	snapshotFile := flag.String("snapshot", "", "snapshot file to draw instead of the world in Redis")
+	output := flag.String("o", "", "PNG file to write")
+	rect := flag.String("rect", "", "area to draw as minX,minY,maxX,maxY; defaults to the starting area")
+	scale := flag.Int("scale", 1, "pixels on each side of a tile")
+	overlays := flag.String("overlays", "sanctuaries", "comma separated overlays to draw, or all")
+	flag.Parse()
+
+	if *output == "" {
+		fmt.Fprintln(os.Stderr, "usage: worldmap -o file [-snapshot file] [-rect minX,minY,maxX,maxY] [-scale n] [-overlays list]")
+		os.Exit(2)
+	}
+	opts := game.WorldMapOptions{Scale: *scale}
+	if *rect != "" {
+		area, err := game.ParseWorldMapArea(*rect)
+		if err != nil {
+			log.Fatalf("Invalid -rect: %v", err)
+		}
+		opts.Area = &area
+	}
+	var err error
+	if opts.Overlays, err = game.ParseWorldMapOverlays(*overlays); err != nil {
+		log.Fatalf("Invalid -overlays: %v", err)
+	}
+
+	var img *image.RGBA
+	if *snapshotFile != "" {
+		snapshot, err := game.LoadWorldSnapshotFile(*snapshotFile)
+		if err != nil {
+			log.Fatalf("Could not read %s: %v", *snapshotFile, err)
+		}
+		img, err = game.RenderWorldSnapshotMap(snapshot, opts)
+		if err != nil {
+			log.Fatalf("Could not draw %s: %v", *snapshotFile, err)
+		}
+	} else {
+		connect(*redisAddr)
+		if img, err = game.RenderWorldMap(opts); err != nil {
+			log.Fatalf("Could not draw the world: %v", err)
+		}
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("Could not create %s: %v", *output, err)
+	}
+	if err := png.Encode(f, img); err != nil {
+		log.Fatalf("Could not write %s: %v", *output, err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatalf("Could not write %s: %v", *output, err)
+	}
+	log.Printf("Wrote a %dx%d map to %s.", img.Bounds().Dx(), img.Bounds().Dy(), *output)
+}
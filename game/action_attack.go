@@ -32,7 +32,7 @@ func ProcessAttack(playerID string, targetEntityID string) *models.EntityDamaged
 	playerX, playerY := GetEntityPosition(playerData)
 	targetX, targetY := GetEntityPosition(targetData)
 
-	if !IsAdjacent(playerX, playerY, targetX, targetY) {
+	if !IsWithinMeleeRange(playerX, playerY, targetX, targetY) {
 		// Silently fail, client-side check should prevent this.
 		return nil
 	}
@@ -120,23 +120,22 @@ func cleanupAndDropLoot(npcID string, npcData map[string]string) {
 		x, _ := strconv.Atoi(npcData["x"])
 		y, _ := strconv.Atoi(npcData["y"])
 		for itemID, quantity := range loot {
-			dropID, createdAt, publicAt, err := CreateWorldItem(x, y, itemID, quantity, ownerID, time.Minute*1)
+			worldItem, err := CreateWorldItem(x, y, itemID, quantity, ownerID, time.Minute*1)
 			if err != nil {
 				log.Printf("Failed to create world item from loot: %v", err)
 				continue
 			}
-
-			itemUpdate := map[string]interface{}{
-				"type":       string(ServerEventEntityJoined),
-				"entityId":   dropID,
-				"entityType": string(EntityTypeItem),
-				"itemId":     string(itemID),
-				"x":          x,
-				"y":          y,
-				"owner":      ownerID,
-				"createdAt":  createdAt,
-				"publicAt":   publicAt,
-			}
+			RecordItemMovement(rdb, ItemAuditEntry{
+				PlayerID: ownerID,
+				ItemID:   itemID,
+				Quantity: quantity,
+				Source:   ItemLocationNone,
+				Dest:     ItemLocationWorld,
+				Reason:   ItemAuditDrop,
+				Ref:      worldItem.ID,
+			})
+
+			itemUpdate := worldItem.JoinedMessage()
 			if ownerID != "" {
 				PublishPrivately(ownerID, itemUpdate)
 			} else {
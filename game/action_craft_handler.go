@@ -3,6 +3,7 @@ package game
 import (
 	"encoding/json"
 	"log"
+	"mmo-game/game/utils"
 	"mmo-game/models"
 	"strconv"
 	"strings"
@@ -93,8 +94,20 @@ func (h *CraftActionHandler) Process(playerID string, payload json.RawMessage) *
 
 	pipe := rdb.Pipeline()
 
+	// All audit entries for this craft share a ref so consumption and output can be matched up.
+	craftRef := utils.GenerateUniqueID()
+
 	// Consume ingredients
 	for ingredient, required := range recipe.Ingredients {
+		RecordItemMovement(pipe, ItemAuditEntry{
+			PlayerID: playerID,
+			ItemID:   ingredient,
+			Quantity: required,
+			Source:   ItemLocationInventory,
+			Dest:     ItemLocationNone,
+			Reason:   ItemAuditCraftConsume,
+			Ref:      craftRef,
+		})
 		remaining := required
 		for slotKey, item := range inventorySlots {
 			if ItemID(item.ID) == ingredient {
@@ -134,6 +147,15 @@ func (h *CraftActionHandler) Process(playerID string, payload json.RawMessage) *
 		}
 		return Failed()
 	}
+	RecordItemMovement(rdb, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemID(craftData.Item),
+		Quantity: recipe.Yield,
+		Source:   ItemLocationNone,
+		Dest:     ItemLocationInventory,
+		Reason:   ItemAuditCraftProduce,
+		Ref:      craftRef,
+	})
 
 	// Add experience
 	if recipe.CraftingSkill != "" && recipe.CraftingXP > 0 {
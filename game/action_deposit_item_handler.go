@@ -53,6 +53,15 @@ func (h *DepositItemActionHandler) Process(playerID string, payload json.RawMess
 		return Failed()
 	}
 
+	RecordItemMovement(pipe, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemID(item.ID),
+		Quantity: depositData.Quantity,
+		Source:   ItemLocationInventory,
+		Dest:     ItemLocationBank,
+		Reason:   ItemAuditDeposit,
+	})
+
 	pipe.HSet(ctx, playerID, "nextActionAt", time.Now().Add(BaseActionCooldown).UnixMilli())
 	_, err = pipe.Exec(ctx)
 	if err != nil {
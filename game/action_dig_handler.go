@@ -0,0 +1,106 @@
+package game
+
+import (
+	"encoding/json"
+	"log"
+	"mmo-game/models"
+	"strconv"
+	"time"
+)
+
+// DigActionHandler handles client dig actions.
+// This implements the ActionHandler interface for standardized action processing.
+type DigActionHandler struct{}
+
+// Process handles a dig action request from the client.
+// Digging up a buried point of interest drops its loot; digging at the dig site of the
+// player's treasure map consumes the map and drops the treasure.
+func (h *DigActionHandler) Process(playerID string, payload json.RawMessage) *ActionResult {
+	var digData models.DigPayload
+	if err := json.Unmarshal(payload, &digData); err != nil {
+		return Failed()
+	}
+
+	canAct, playerData := CanEntityAct(playerID)
+	if !canAct {
+		return Failed()
+	}
+
+	currentX, currentY := GetEntityPosition(playerData)
+	targetX, targetY := digData.X, digData.Y
+	if (currentX != targetX || currentY != targetY) && !IsAdjacentOrDiagonal(currentX, currentY, targetX, targetY) {
+		return Failed()
+	}
+
+	tile, _, err := GetWorldTile(targetX, targetY)
+	if err != nil {
+		return Failed()
+	}
+	UpdateEntityDirection(playerID, targetX, targetY)
+	rdb.HSet(ctx, playerID, "nextActionAt", time.Now().Add(BaseActionCooldown).UnixMilli())
+
+	if poi, ok := POIDefs[TileType(tile.Type)]; ok && poi.Buried {
+		digPOI(playerID, playerData, poi, targetX, targetY)
+		return NewActionResult()
+	}
+
+	siteX, siteY, ok := playerDigSite(playerData)
+	if !ok || siteX != targetX || siteY != targetY {
+		sendNotification(playerID, "You dig but find nothing.")
+		return NewActionResult()
+	}
+
+	slotInfo, err := FindItemInInventory(playerID, ItemTreasureMap, "")
+	if err != nil || slotInfo.SlotKey == "" {
+		sendNotification(playerID, "You need your treasure map to find the treasure.")
+		return NewActionResult()
+	}
+
+	pipe := rdb.TxPipeline()
+	inventoryKey := string(RedisKeyPlayerInventory) + playerID
+	if _, err := ConsumeItemFromSlot(pipe, inventoryKey, slotInfo.SlotKey, 1); err != nil {
+		log.Printf("Player %s failed to consume treasure map: %v", playerID, err)
+		return Failed()
+	}
+	pipe.HDel(ctx, playerID, "digSite")
+	siteKey := strconv.Itoa(siteX) + "," + strconv.Itoa(siteY)
+	RecordItemMovement(pipe, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemTreasureMap,
+		Quantity: 1,
+		Source:   ItemLocationInventory,
+		Dest:     ItemLocationNone,
+		Reason:   ItemAuditDig,
+		Ref:      siteKey,
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Redis error during dig action for player %s: %v", playerID, err)
+		return Failed()
+	}
+
+	dropLoot(playerID, currentX, currentY, rollLoot(TreasureLootTable), ItemAuditDig, siteKey)
+	sendNotification(playerID, "You dig up the treasure!")
+	log.Printf("Player %s dug up the treasure at %s.", playerID, siteKey)
+
+	result := NewActionResult()
+	msg := models.DigSiteMessage{
+		Type: string(ServerEventDigSite),
+		X:    siteX,
+		Y:    siteY,
+		Dug:  true,
+	}
+	msgBytes, _ := json.Marshal(msg)
+	result.AddToPlayer(models.WebSocketMessage{
+		Type:    msg.Type,
+		Payload: msgBytes,
+	})
+	inventoryUpdate := CreateInventoryUpdateMessage(playerID)
+	if inventoryUpdate != nil {
+		inventoryJSON, _ := json.Marshal(inventoryUpdate)
+		result.AddToPlayer(models.WebSocketMessage{
+			Type:    inventoryUpdate.Type,
+			Payload: inventoryJSON,
+		})
+	}
+	return result
+}
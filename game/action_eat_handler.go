@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"log"
 	"mmo-game/models"
-	"strconv"
 	"time"
 )
 
@@ -46,8 +45,17 @@ func (h *EatActionHandler) Process(playerID string, payload json.RawMessage) *Ac
 		return Failed()
 	}
 
+	RecordItemMovement(pipe, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemID(eatData.Item),
+		Quantity: 1,
+		Source:   ItemLocationInventory,
+		Dest:     ItemLocationNone,
+		Reason:   ItemAuditEat,
+	})
+
 	// 2. Heal the player
-	health, _ := strconv.Atoi(playerData["health"])
+	health := playerStoredInt(playerData, "health")
 	// Ensure health is at least 0 (handle empty string case)
 	if health < 0 {
 		health = 0
@@ -57,9 +57,29 @@ func (h *EquipActionHandler) Process(playerID string, payload json.RawMessage) *
 	// Equip the new item
 	pipe.HSet(ctx, gearKey, gearSlot, itemJSON)
 
+	RecordItemMovement(pipe, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemID(item.ID),
+		Quantity: item.Quantity,
+		Source:   ItemLocationInventory,
+		Dest:     ItemLocationGear,
+		Reason:   ItemAuditEquip,
+	})
+
 	// If an item was already equipped, move it to the now-empty inventory slot
 	if currentlyEquippedJSON != "" {
 		pipe.HSet(ctx, inventoryKey, equipData.InventorySlot, currentlyEquippedJSON)
+
+		var previousItem models.Item
+		json.Unmarshal([]byte(currentlyEquippedJSON), &previousItem)
+		RecordItemMovement(pipe, ItemAuditEntry{
+			PlayerID: playerID,
+			ItemID:   ItemID(previousItem.ID),
+			Quantity: previousItem.Quantity,
+			Source:   ItemLocationGear,
+			Dest:     ItemLocationInventory,
+			Reason:   ItemAuditUnequip,
+		})
 	}
 
 	_, err = pipe.Exec(ctx)
@@ -0,0 +1,32 @@
+package game
+
+import (
+	"encoding/json"
+	"mmo-game/models"
+)
+
+// FollowActionHandler handles client follow actions.
+// This implements the ActionHandler interface for standardized action processing.
+type FollowActionHandler struct{}
+
+// Process handles a follow action request from the client.
+// It hands the follow to the walk system, which keeps the player next to the target
+// until they act, the target gets out of range, or they send a follow with no target.
+func (h *FollowActionHandler) Process(playerID string, payload json.RawMessage) *ActionResult {
+	var followData models.FollowPayload
+	if err := json.Unmarshal(payload, &followData); err != nil {
+		return Failed()
+	}
+
+	if followData.EntityID == "" {
+		StopWalking(playerID)
+		return NewActionResult()
+	}
+
+	if !StartFollow(playerID, followData.EntityID) {
+		notification := CreateNotificationMessage("You cannot follow that.")
+		SendPrivately(playerID, notification)
+		return Failed()
+	}
+	return NewActionResult()
+}
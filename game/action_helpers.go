@@ -221,6 +221,15 @@ func IsAdjacentOrDiagonal(x1, y1, x2, y2 int) bool {
 	return dx <= 1 && dy <= 1 && (dx != 0 || dy != 0)
 }
 
+// IsWithinMeleeRange checks if (x1, y1) can strike (x2, y2): the two are next to each
+// other, diagonally too, unless a diagonal is squeezed between two collidable tiles.
+func IsWithinMeleeRange(x1, y1, x2, y2 int) bool {
+	if !IsAdjacentOrDiagonal(x1, y1, x2, y2) {
+		return false
+	}
+	return !isCornerSqueeze(x1, y1, [2]int{x2 - x1, y2 - y1})
+}
+
 func IsWithinPickupRange(x1, y1, x2, y2 int) bool {
 	dx := x1 - x2
 	if dx < 0 {
@@ -233,36 +242,13 @@ func IsWithinPickupRange(x1, y1, x2, y2 int) bool {
 	return dx <= 1 && dy <= 1
 }
 
-// GetEntitiesInRange uses Redis geospatial queries to find entities of a certain type
-// within a given tile radius of a central point (x, y).
+// GetEntitiesInRange uses the spatial index to find entities of a certain type
+// within a given tile radius of a central point (x, y), nearest first.
 func GetEntitiesInRange(x, y, radius int, entityType EntityType) []string {
-	// Use the geo key for the correct zone (hardcoded to zone 0 for now)
-	geoKey := string(RedisKeyZone0Positions)
-
-	// Perform the search
-	locations, err := rdb.GeoRadius(ctx, geoKey, float64(x), float64(y), &redis.GeoRadiusQuery{
-		Radius:    TilesToKilometers(radius), // Convert tile radius to km
-		Unit:      "km",
-		WithDist:  false,
-		WithCoord: false,
-		Count:     0,     // Get all matches
-		Sort:      "ASC", // Sort by distance
-	}).Result()
-
-	if err != nil {
-		log.Printf("Error performing GeoRadius search: %v", err)
-		return []string{}
-	}
-
-	// Filter the results by entity type
 	var entityIDs []string
-	for _, location := range locations {
-		// The entity type is part of the key name (e.g., "player:uuid")
-		if EntityType(location.Name[0:len(entityType)]) == entityType {
-			entityIDs = append(entityIDs, location.Name)
-		}
+	for _, entry := range EntityIndex.InRange(x, y, radius, string(entityType)) {
+		entityIDs = append(entityIDs, entry.ID)
 	}
-
 	return entityIDs
 }
 
@@ -412,6 +398,7 @@ func interruptTeleport(playerID string) {
 
 	if _, ok := playerData["teleportingUntil"]; ok {
 		rdb.HDel(ctx, playerID, "teleportingUntil")
+		CancelJob(JobCompleteTeleport, playerID)
 
 		// Notify client that channel is over
 		channelEndMsg := map[string]interface{}{"type": string(ServerEventTeleportChannelEnd)}
@@ -476,33 +463,24 @@ func findItemInInventory(inventoryDataRaw map[string]string, itemID ItemID) (str
 // scheduleFireExpiration schedules a fire to expire after its duration.
 func scheduleFireExpiration(x, y int) {
 	fireProps := TileDefs[TileTypeFire]
-	time.AfterFunc(time.Duration(fireProps.Duration)*time.Millisecond, func() {
-		expireFire(x, y)
-	})
+	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
+	ScheduleJob(JobExpireFire, coordKey, time.Now().Add(time.Duration(fireProps.Duration)*time.Millisecond), nil)
 }
 
 // expireFire removes a fire tile and updates the world.
 func expireFire(x, y int) {
-	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
-	tileJSON, err := rdb.HGet(ctx, string(RedisKeyWorldZone0), coordKey).Result()
+	currentTile, _, err := GetWorldTile(x, y)
 	if err != nil {
 		return
 	}
-
-	var tile models.WorldTile
-	if err := json.Unmarshal([]byte(tileJSON), &tile); err != nil {
-		log.Printf("Failed to unmarshal tile at %s: %v", coordKey, err)
-		return
-	}
+	tile := *currentTile
 
 	if TileType(tile.Type) == TileTypeFire {
-		tile.Type = string(TileTypeGround)
-		newTileJSON, _ := json.Marshal(tile)
-		rdb.HSet(ctx, string(RedisKeyWorldZone0), coordKey, string(newTileJSON))
+		tile.Type = string(floorTileAt(x, y))
+		SetWorldTile(rdb, x, y, tile)
 
 		// Remove the fire from the resource positions set
-		member := string(TileTypeFire) + ":" + coordKey
-		rdb.ZRem(ctx, string(RedisKeyResourcePositions), member)
+		removeResourcePosition(rdb, TileTypeFire, x, y)
 
 		worldUpdate := models.WorldUpdateMessage{
 			Type: string(ServerEventWorldUpdate),
@@ -525,17 +503,8 @@ func convertPathToDirections(path []*Node) []string {
 		currentNode := path[i]
 		nextNode := path[i+1]
 
-		dx := nextNode.X - currentNode.X
-		dy := nextNode.Y - currentNode.Y
-
-		if dx == 1 && dy == 0 {
-			directions = append(directions, "right")
-		} else if dx == -1 && dy == 0 {
-			directions = append(directions, "left")
-		} else if dx == 0 && dy == 1 {
-			directions = append(directions, "down")
-		} else if dx == 0 && dy == -1 {
-			directions = append(directions, "up")
+		if IsAdjacentOrDiagonal(currentNode.X, currentNode.Y, nextNode.X, nextNode.Y) {
+			directions = append(directions, string(directionTowards(currentNode.X, currentNode.Y, nextNode.X, nextNode.Y)))
 		}
 	}
 	return directions
@@ -76,12 +76,19 @@ func ProcessInteract(playerID string, payload json.RawMessage) (*models.StateCor
 
 			isPublic := owner == "" || (publicAt > 0 && time.Now().UnixMilli() >= publicAt)
 			if owner == playerID || isPublic {
-				itemID := ItemID(targetData["itemId"])
-				quantity, _ := strconv.Atoi(targetData["quantity"])
+				// Claim the pile before adding it, so it cannot also be picked up by
+				// someone else or despawn while it is being added.
+				itemData, claimed := ClaimWorldItem(interactData.EntityID)
+				if !claimed {
+					return nil, nil
+				}
+				itemID := ItemID(itemData["itemId"])
+				quantity, _ := strconv.Atoi(itemData["quantity"])
 
 				newInventory, err := AddItemToInventory(playerID, itemID, quantity)
 				if err != nil {
 					log.Printf("could not add item to inventory: %v", err)
+					RestoreWorldItem(interactData.EntityID, itemData)
 					if strings.Contains(err.Error(), "inventory full") {
 						notification := models.NotificationMessage{
 							Type:    string(ServerEventNotification),
@@ -92,8 +99,21 @@ func ProcessInteract(playerID string, payload json.RawMessage) (*models.StateCor
 					return nil, nil
 				}
 
-				// Remove item from world
-				CleanupEntity(interactData.EntityID, targetData)
+				RecordItemMovement(rdb, ItemAuditEntry{
+					PlayerID: playerID,
+					ItemID:   itemID,
+					Quantity: quantity,
+					Source:   ItemLocationWorld,
+					Dest:     ItemLocationInventory,
+					Reason:   ItemAuditLootPickup,
+					Ref:      interactData.EntityID,
+				})
+
+				// The item was removed from the world when it was claimed
+				PublishUpdate(map[string]interface{}{
+					"type":     string(ServerEventEntityLeft),
+					"entityId": interactData.EntityID,
+				})
 
 				inventoryUpdateMsg := &models.InventoryUpdateMessage{
 					Type:      string(ServerEventInventoryUpdate),
@@ -143,6 +163,16 @@ func ProcessInteract(playerID string, payload json.RawMessage) (*models.StateCor
 		return nil, nil
 	}
 
+	if interactDungeonTile(playerID, playerData, TileType(tile.Type), targetX, targetY) {
+		return nil, nil
+	}
+	if interactPortalTile(playerID, playerData, TileType(tile.Type), targetX, targetY) {
+		return nil, nil
+	}
+	if interactPOITile(playerID, playerData, TileType(tile.Type), targetX, targetY) {
+		return nil, nil
+	}
+
 	if !props.IsGatherable && !props.IsDestructible {
 		return nil, nil
 	}
@@ -162,6 +192,15 @@ func ProcessInteract(playerID string, payload json.RawMessage) (*models.StateCor
 	if props.IsGatherable {
 		newInventory, err := AddItemToInventory(playerID, props.GatherResource, 1)
 		if err == nil {
+			RecordItemMovement(rdb, ItemAuditEntry{
+				PlayerID: playerID,
+				ItemID:   props.GatherResource,
+				Quantity: 1,
+				Source:   ItemLocationNone,
+				Dest:     ItemLocationInventory,
+				Reason:   ItemAuditGather,
+				Ref:      targetCoordKey,
+			})
 			inventoryUpdateMsg = &models.InventoryUpdateMessage{
 				Type:      string(ServerEventInventoryUpdate),
 				Inventory: newInventory,
@@ -183,7 +222,7 @@ func ProcessInteract(playerID string, payload json.RawMessage) (*models.StateCor
 	}
 
 	if tile.Health <= 0 {
-		groundTile := models.WorldTile{Type: string(TileTypeGround), Health: 0}
+		groundTile := models.WorldTile{Type: string(floorTileAt(targetX, targetY)), Health: 0}
 		worldUpdateMsg := models.WorldUpdateMessage{
 			Type: string(ServerEventWorldUpdate),
 			X:    targetX,
@@ -193,21 +232,18 @@ func ProcessInteract(playerID string, payload json.RawMessage) (*models.StateCor
 		PublishUpdate(worldUpdateMsg)
 
 		if props.IsGatherable {
-			member := originalTileType + ":" + targetCoordKey
-			rdb.ZRem(ctx, string(RedisKeyResourcePositions), member)
+			removeResourcePosition(rdb, TileType(originalTileType), targetX, targetY)
 		}
 
 		if TileType(originalTileType) == TileTypeWoodenWall {
 			log.Printf("Wall at %s destroyed, removing lock.", targetCoordKey)
-			rdb.Del(ctx, string(RedisKeyLockTile)+targetCoordKey)
+			UnlockTileForWorldObject(targetX, targetY)
 			rdb.SRem(ctx, string(RedisKeyActiveDecay), targetCoordKey)
 		}
 
-		newTileJSON, _ := json.Marshal(groundTile)
-		rdb.HSet(ctx, string(RedisKeyWorldZone0), targetCoordKey, string(newTileJSON))
+		SetWorldTile(rdb, targetX, targetY, groundTile)
 	} else {
-		newTileJSON, _ := json.Marshal(tile)
-		rdb.HSet(ctx, string(RedisKeyWorldZone0), targetCoordKey, string(newTileJSON))
+		SetWorldTile(rdb, targetX, targetY, *tile)
 	}
 
 	rdb.HSet(ctx, playerID, "nextActionAt", time.Now().Add(BaseActionCooldown).UnixMilli())
@@ -14,7 +14,8 @@ import (
 type InteractActionHandler struct{}
 
 // Process handles an interact action request from the client.
-// It can interact with entities (NPCs, items) or tiles (resources, sanctuary stones).
+// It can interact with entities (NPCs, items) or tiles (resources, sanctuary stones,
+// dungeon entrances and exits).
 func (h *InteractActionHandler) Process(playerID string, payload json.RawMessage) *ActionResult {
 	var interactData models.InteractPayload
 	if err := json.Unmarshal(payload, &interactData); err != nil {
@@ -86,12 +87,19 @@ func (h *InteractActionHandler) Process(playerID string, payload json.RawMessage
 			isPublic := owner == "" || (publicAt > 0 && time.Now().UnixMilli() >= publicAt)
 
 			if owner == playerID || isPublic {
-				itemID := ItemID(targetData["itemId"])
-				quantity, _ := strconv.Atoi(targetData["quantity"])
+				// Claim the pile before adding it, so it cannot also be picked up by
+				// someone else or despawn while it is being added.
+				itemData, claimed := ClaimWorldItem(interactData.EntityID)
+				if !claimed {
+					return Failed()
+				}
+				itemID := ItemID(itemData["itemId"])
+				quantity, _ := strconv.Atoi(itemData["quantity"])
 
 				newInventory, err := AddItemToInventory(playerID, itemID, quantity)
 				if err != nil {
 					log.Printf("could not add item to inventory: %v", err)
+					RestoreWorldItem(interactData.EntityID, itemData)
 					if strings.Contains(err.Error(), "inventory full") {
 						notification := CreateNotificationMessage("Your inventory is full.")
 						SendPrivately(playerID, notification)
@@ -99,8 +107,21 @@ func (h *InteractActionHandler) Process(playerID string, payload json.RawMessage
 					return Failed()
 				}
 
-				// Remove item from world
-				CleanupEntity(interactData.EntityID, targetData)
+				RecordItemMovement(rdb, ItemAuditEntry{
+					PlayerID: playerID,
+					ItemID:   itemID,
+					Quantity: quantity,
+					Source:   ItemLocationWorld,
+					Dest:     ItemLocationInventory,
+					Reason:   ItemAuditLootPickup,
+					Ref:      interactData.EntityID,
+				})
+
+				// The item was removed from the world when it was claimed
+				PublishUpdate(map[string]interface{}{
+					"type":     string(ServerEventEntityLeft),
+					"entityId": interactData.EntityID,
+				})
 
 				// Send inventory update
 				inventoryUpdateMsg := &models.InventoryUpdateMessage{
@@ -156,6 +177,11 @@ func (h *InteractActionHandler) Process(playerID string, payload json.RawMessage
 		return result
 	}
 
+	// Dungeon entrance and exit interaction
+	if interactDungeonTile(playerID, playerData, TileType(tile.Type), targetX, targetY) {
+		return result
+	}
+
 	if !props.IsGatherable && !props.IsDestructible {
 		return Failed()
 	}
@@ -177,6 +203,15 @@ func (h *InteractActionHandler) Process(playerID string, payload json.RawMessage
 	if props.IsGatherable {
 		newInventory, err := AddItemToInventory(playerID, props.GatherResource, 1)
 		if err == nil {
+			RecordItemMovement(rdb, ItemAuditEntry{
+				PlayerID: playerID,
+				ItemID:   props.GatherResource,
+				Quantity: 1,
+				Source:   ItemLocationNone,
+				Dest:     ItemLocationInventory,
+				Reason:   ItemAuditGather,
+				Ref:      targetCoordKey,
+			})
 			inventoryUpdateMsg = &models.InventoryUpdateMessage{
 				Type:      string(ServerEventInventoryUpdate),
 				Inventory: newInventory,
@@ -193,7 +228,7 @@ func (h *InteractActionHandler) Process(playerID string, payload json.RawMessage
 
 	// Handle tile destruction
 	if tile.Health <= 0 {
-		groundTile := models.WorldTile{Type: string(TileTypeGround), Health: 0}
+		groundTile := models.WorldTile{Type: string(floorTileAt(targetX, targetY)), Health: 0}
 		worldUpdateMsg := models.WorldUpdateMessage{
 			Type: string(ServerEventWorldUpdate),
 			X:    targetX,
@@ -203,21 +238,18 @@ func (h *InteractActionHandler) Process(playerID string, payload json.RawMessage
 		Broadcast(worldUpdateMsg)
 
 		if props.IsGatherable {
-			member := originalTileType + ":" + targetCoordKey
-			rdb.ZRem(ctx, string(RedisKeyResourcePositions), member)
+			removeResourcePosition(rdb, TileType(originalTileType), targetX, targetY)
 		}
 
 		if TileType(originalTileType) == TileTypeWoodenWall {
 			log.Printf("Wall at %s destroyed, removing lock.", targetCoordKey)
-			rdb.Del(ctx, string(RedisKeyLockTile)+targetCoordKey)
+			UnlockTileForWorldObject(targetX, targetY)
 			rdb.SRem(ctx, string(RedisKeyActiveDecay), targetCoordKey)
 		}
 
-		newTileJSON, _ := json.Marshal(groundTile)
-		rdb.HSet(ctx, string(RedisKeyWorldZone0), targetCoordKey, string(newTileJSON))
+		SetWorldTile(rdb, targetX, targetY, groundTile)
 	} else {
-		newTileJSON, _ := json.Marshal(tile)
-		rdb.HSet(ctx, string(RedisKeyWorldZone0), targetCoordKey, string(newTileJSON))
+		SetWorldTile(rdb, targetX, targetY, *tile)
 	}
 
 	rdb.HSet(ctx, playerID, "nextActionAt", time.Now().Add(BaseActionCooldown).UnixMilli())
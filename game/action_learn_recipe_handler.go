@@ -54,6 +54,14 @@ func (h *LearnRecipeActionHandler) Process(playerID string, payload json.RawMess
 	pipe := rdb.Pipeline()
 	pipe.HSet(ctx, playerID, "knownRecipes", newKnownRecipesJSON)
 	pipe.HDel(ctx, inventoryKey, learnRecipePayload.InventorySlot)
+	RecordItemMovement(pipe, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemID(item.ID),
+		Quantity: item.Quantity,
+		Source:   ItemLocationInventory,
+		Dest:     ItemLocationNone,
+		Reason:   ItemAuditLearnRecipe,
+	})
 	pipe.HSet(ctx, playerID, "nextActionAt", time.Now().Add(BaseActionCooldown).UnixMilli())
 	_, err = pipe.Exec(ctx)
 	if err != nil {
@@ -26,6 +26,8 @@ func ProcessMove(entityID string, direction MoveDirection) *models.StateCorrecti
 		if _, ok := entityData["teleportingUntil"]; ok {
 			// Cancel the teleport by removing the teleportingUntil field
 			rdb.HDel(ctx, entityID, "teleportingUntil")
+			delete(entityData, "teleportingUntil")
+			CancelJob(JobCompleteTeleport, entityID)
 
 			// Notify the client that the teleport channel was canceled
 			channelEndMsg := map[string]interface{}{"type": string(ServerEventTeleportChannelEnd)}
@@ -35,49 +37,159 @@ func ProcessMove(entityID string, direction MoveDirection) *models.StateCorrecti
 	}
 	// --- END CANCEL TELEPORT ---
 
-	// Use new generic helper
-	currentX, currentY := GetEntityPosition(entityData)
-	targetX, targetY := currentX, currentY
-
-	// Use MoveDirection constants
-	switch direction {
-	case MoveDirectionUp:
-		targetY--
-	case MoveDirectionDown:
-		targetY++
-	case MoveDirectionLeft:
-		targetX--
-	case MoveDirectionRight:
-		targetX++
+	step, ok := checkMove(entityID, entityData, direction)
+	if !ok {
+		return nil
 	}
+	currentX, currentY := step.FromX, step.FromY
 
-	tile, props, err := GetWorldTile(targetX, targetY)
-	if err != nil {
-		return nil // Tile doesn't exist or other error
+	// Lock the tile for the entity, unless it's a sanctuary
+	if !step.Tile.IsSanctuary {
+		wasSet, err := LockTileForEntity(entityID, step.ToX, step.ToY)
+		if err != nil || !wasSet {
+			// Tile is locked, send state correction
+			return &models.StateCorrectionMessage{Type: string(ServerEventStateCorrection), X: currentX, Y: currentY}
+		}
 	}
 
-	// --- NEW: Prevent NPCs from entering sanctuaries ---
-	if strings.HasPrefix(entityID, "npc:") {
-		if tile.IsSanctuary {
-			return nil // NPC runs into a sanctuary, treat as a wall
+	pipe := rdb.Pipeline()
+	writeMove(pipe, entityID, entityData, step) // Any teleport was cancelled above
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Error updating entity state, rolling back lock for tile %d,%d", step.ToX, step.ToY)
+		indexEntity(rdb, entityID, currentX, currentY)
+		// Release the lock using the entity's ID, if it was set, and take back the one it
+		// may have released on the tile it stands on.
+		if !step.Tile.IsSanctuary {
+			UnlockTileForEntity(entityID, step.ToX, step.ToY)
 		}
+		LockTileForEntity(entityID, currentX, currentY)
+		// Use ServerEventType constant
+		return &models.StateCorrectionMessage{Type: string(ServerEventStateCorrection), X: currentX, Y: currentY}
 	}
-	// --- END NEW ---
 
-	if props.IsCollidable {
-		return nil // Ran into a wall
+	// --- UPDATED MESSAGE ---
+	// Use new ServerEventType constant and generic "entityId"
+	updateMsg := map[string]interface{}{
+		"type":     string(ServerEventEntityMoved),
+		"entityId": entityID,
+		"x":        step.ToX,
+		"y":        step.ToY,
 	}
+	PublishUpdate(updateMsg)
 
-	// Lock the tile for the entity, unless it's a sanctuary
-	if !tile.IsSanctuary {
-		wasSet, err := LockTileForEntity(entityID, targetX, targetY)
-		if err != nil || !wasSet {
-			// Tile is locked, send state correction
-			return &models.StateCorrectionMessage{Type: string(ServerEventStateCorrection), X: currentX, Y: currentY}
+	if strings.HasPrefix(entityID, "player:") {
+		visitWorld(entityID, currentX, currentY, step.ToX, step.ToY)
+	}
+
+	if TileType(step.Tile.Type) == TileTypePortal {
+		pipe := rdb.Pipeline()
+		joinMsg, destX, destY, ok := stepThroughPortal(pipe, entityID, entityData, step.ToX, step.ToY)
+		if !ok {
+			return nil // Left standing on a dormant portal
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Failed to carry %s through the portal at %d,%d: %v", entityID, step.ToX, step.ToY, err)
+			// Leave them on the portal: release the tile they were to come out on and
+			// take back the portal tile.
+			indexEntity(rdb, entityID, step.ToX, step.ToY)
+			UnlockTileForEntity(entityID, destX, destY)
+			LockTileForEntity(entityID, step.ToX, step.ToY)
+			return nil
 		}
+		PublishUpdate(joinMsg)
+		if strings.HasPrefix(entityID, "player:") {
+			visitWorld(entityID, step.ToX, step.ToY, destX, destY)
+			sendNotification(entityID, "You step through the portal into "+zoneName(zoneAt(destX, destY))+".")
+		}
+	}
+
+	return nil
+}
+
+// moveStep is a single step an entity may take, as found by checkMove.
+type moveStep struct {
+	FromX, FromY int
+	ToX, ToY     int
+	Offset       [2]int
+	Tile         *models.WorldTile
+	Props        *TileProperties
+}
+
+// checkMove applies the rules every step follows, whoever takes it: the tile stepped onto
+// must exist and not be collidable, bar portals, which carry whoever steps onto them
+// through (paths still go around them); NPCs treat sanctuaries as walls, and no one squeezes
+// diagonally between two walls or steps up or down a cliff. Tile locks are left to the caller. ok is false if the
+// entity cannot take the step.
+func checkMove(entityID string, entityData map[string]string, direction MoveDirection) (step moveStep, ok bool) {
+	currentX, currentY := GetEntityPosition(entityData)
+	offset := getDirectionOffset(direction)
+	if offset == [2]int{0, 0} {
+		return step, false // Not a direction
 	}
+	targetX, targetY := currentX+offset[0], currentY+offset[1]
 
-	// --- UPDATED COOLDOWN LOGIC ---
+	tile, props, err := GetWorldTile(targetX, targetY)
+	if err != nil {
+		return step, false // Tile doesn't exist or other error
+	}
+	if strings.HasPrefix(entityID, "npc:") && tile.IsSanctuary {
+		return step, false // NPC runs into a sanctuary, treat as a wall
+	}
+	if props.IsCollidable && TileType(tile.Type) != TileTypePortal {
+		return step, false // Ran into a wall
+	}
+	if isCornerSqueeze(currentX, currentY, offset) {
+		return step, false // No squeezing diagonally between two walls
+	}
+	if source, _, err := GetWorldTile(currentX, currentY); err == nil && isCliffStep(source, currentX, currentY, tile, targetX, targetY) {
+		return step, false // Too steep to climb or jump down
+	}
+	return moveStep{
+		FromX:  currentX,
+		FromY:  currentY,
+		ToX:    targetX,
+		ToY:    targetY,
+		Offset: offset,
+		Tile:   tile,
+		Props:  props,
+	}, true
+}
+
+// writeMove writes a step through c, a pipeline the caller executes: the entity's new
+// position and next action time, and the release of the tile lock it leaves behind. The
+// lock on the tile it steps onto must already be held. A player's teleport channel is
+// cancelled by moving, echoes included; it reports whether there was one, so the caller
+// can tell the client. entityData is updated to match.
+func writeMove(c redis.Cmdable, entityID string, entityData map[string]string, step moveStep) bool {
+	nextActionTime := time.Now().Add(moveCooldown(entityData, step.Props, step.ToX, step.ToY, step.Offset)).UnixMilli()
+
+	teleportCancelled := false
+	if _, ok := entityData["teleportingUntil"]; ok && strings.HasPrefix(entityID, "player:") {
+		c.HDel(ctx, entityID, "teleportingUntil")
+		delete(entityData, "teleportingUntil")
+		CancelJob(JobCompleteTeleport, entityID)
+		teleportCancelled = true
+	}
+
+	c.HSet(ctx, entityID, "x", step.ToX, "y", step.ToY, "nextActionAt", nextActionTime)
+	entityData["x"] = strconv.Itoa(step.ToX)
+	entityData["y"] = strconv.Itoa(step.ToY)
+	entityData["nextActionAt"] = strconv.FormatInt(nextActionTime, 10)
+	setEntityPosition(c, entityID, step.ToX, step.ToY)
+
+	// Release the lock on the previous tile, if the entity was on a non-sanctuary tile.
+	if sourceTile, _, err := GetWorldTile(step.FromX, step.FromY); err != nil || !sourceTile.IsSanctuary {
+		sourceKey := strconv.Itoa(step.FromX) + "," + strconv.Itoa(step.FromY)
+		releaseTileLockScript.Eval(ctx, c, tileLockKeys(sourceKey),
+			entityID, sourceKey, string(RedisKeyLockOwnerPrefix), 0)
+	}
+	return teleportCancelled
+}
+
+// moveCooldown is how long an entity must wait after a step onto the tile at (x, y) with
+// the given properties: its own move cooldown, or the water penalty, scaled by the
+// tile's biome (or the tile itself, for roads) and lengthened for diagonals.
+func moveCooldown(entityData map[string]string, props *TileProperties, x, y int, offset [2]int) time.Duration {
 	var cooldown int64 = 1000 // Default cooldown
 	if props.MovementPenalty {
 		cooldown = 1500 // Water move penalty
@@ -89,53 +201,23 @@ func ProcessMove(entityID string, direction MoveDirection) *models.StateCorrecti
 			}
 		}
 	}
-	nextActionTime := time.Now().Add(time.Duration(cooldown) * time.Millisecond).UnixMilli()
-	// --- END UPDATED COOLDOWN LOGIC ---
-
-	pipe := rdb.Pipeline()
-	// Update the entity's hash
-	pipe.HSet(ctx, entityID, "x", targetX, "y", targetY, "nextActionAt", nextActionTime)
-
-	// --- BUG FIX REVERT: Use a single GeoAdd to correctly update the GeoSet position ---
-	// GeoAdd correctly adds a new member or updates the position of an existing one.
-	lon, lat := NormalizeCoords(targetX, targetY)
-	pipe.GeoAdd(ctx, string(RedisKeyZone0Positions), &redis.GeoLocation{
-		Name:      entityID,
-		Longitude: lon,
-		Latitude:  lat,
-	})
-
-	_, err = pipe.Exec(ctx)
-	if err != nil {
-		log.Printf("Error updating entity state, rolling back lock for tile %d,%d", targetX, targetY)
-		// Release the lock using the entity's ID, if it was set
-		if !tile.IsSanctuary {
-			UnlockTileForEntity(entityID, targetX, targetY)
-		}
-		// Use ServerEventType constant
-		return &models.StateCorrectionMessage{Type: string(ServerEventStateCorrection), X: currentX, Y: currentY}
-	}
-
-	// Get info about the source tile to decide whether to unlock it.
-	sourceTile, _, err := GetWorldTile(currentX, currentY)
-	if err != nil {
-		log.Printf("Could not get source tile %d,%d for unlocking: %v", currentX, currentY, err)
+	factor := props.MoveCooldownFactor
+	if factor == 0 {
+		factor = biomeMoveFactor(x, y)
 	}
-
-	// Release the lock on the previous tile, if the entity was on a non-sanctuary tile.
-	if sourceTile == nil || !sourceTile.IsSanctuary {
-		UnlockTileForEntity(entityID, currentX, currentY)
+	if offset[0] != 0 && offset[1] != 0 {
+		factor *= DiagonalMoveCooldownFactor
 	}
+	return time.Duration(float64(cooldown)*factor) * time.Millisecond
+}
 
-	// --- UPDATED MESSAGE ---
-	// Use new ServerEventType constant and generic "entityId"
-	updateMsg := map[string]interface{}{
-		"type":     string(ServerEventEntityMoved),
-		"entityId": entityID,
-		"x":        targetX,
-		"y":        targetY,
+// isCornerSqueeze reports whether a diagonal step from (x, y) would squeeze between the
+// two collidable tiles on either side of it.
+func isCornerSqueeze(x, y int, offset [2]int) bool {
+	if offset[0] == 0 || offset[1] == 0 {
+		return false
 	}
-	PublishUpdate(updateMsg)
-
-	return nil
+	_, propsX, errX := GetWorldTile(x+offset[0], y)
+	_, propsY, errY := GetWorldTile(x, y+offset[1])
+	return (errX != nil || propsX.IsCollidable) && (errY != nil || propsY.IsCollidable)
 }
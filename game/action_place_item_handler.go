@@ -2,12 +2,9 @@ package game
 
 import (
 	"encoding/json"
-	"mmo-game/game/utils"
 	"mmo-game/models"
 	"strconv"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
 // PlaceItemActionHandler handles client place item actions.
@@ -82,8 +79,7 @@ func (h *PlaceItemActionHandler) handlePlaceWoodenWall(playerID string, currentX
 		return Failed()
 	}
 
-	targetTileLockKey := string(RedisKeyLockTile) + targetCoordKey
-	wasSet, err := rdb.SetNX(ctx, targetTileLockKey, string(RedisKeyLockWorldObject), 0).Result()
+	wasSet, err := LockTileForWorldObject(targetX, targetY)
 	if err != nil || !wasSet {
 		result := NewActionResult()
 		correctionMsg := CreateStateCorrectionMessage(currentX, currentY)
@@ -93,7 +89,6 @@ func (h *PlaceItemActionHandler) handlePlaceWoodenWall(playerID string, currentX
 
 	wallProps := TileDefs[TileTypeWoodenWall]
 	newWallTile := models.WorldTile{Type: string(TileTypeWoodenWall), Health: wallProps.MaxHealth}
-	newTileJSON, _ := json.Marshal(newWallTile)
 
 	pipe := rdb.Pipeline()
 
@@ -105,11 +100,20 @@ func (h *PlaceItemActionHandler) handlePlaceWoodenWall(playerID string, currentX
 		pipe.HSet(ctx, inventoryKey, wallSlot, "")
 	}
 
-	pipe.HSet(ctx, string(RedisKeyWorldZone0), targetCoordKey, string(newTileJSON))
+	SetWorldTile(pipe, targetX, targetY, newWallTile)
 	pipe.SAdd(ctx, string(RedisKeyActiveDecay), targetCoordKey)
+	RecordItemMovement(pipe, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemWoodenWall,
+		Quantity: 1,
+		Source:   ItemLocationInventory,
+		Dest:     ItemLocationWorld,
+		Reason:   ItemAuditPlace,
+		Ref:      targetCoordKey,
+	})
 	_, err = pipe.Exec(ctx)
 	if err != nil {
-		rdb.Del(ctx, targetTileLockKey)
+		UnlockTileForWorldObject(targetX, targetY)
 		result := NewActionResult()
 		correctionMsg := CreateStateCorrectionMessage(currentX, currentY)
 		result.AddToPlayer(correctionMsg)
@@ -173,8 +177,16 @@ func (h *PlaceItemActionHandler) handlePlaceFire(playerID string, currentX, curr
 	}
 
 	currentTile.Type = string(TileTypeFire)
-	newTileJSON, _ := json.Marshal(currentTile)
-	pipe.HSet(ctx, string(RedisKeyWorldZone0), targetCoordKey, string(newTileJSON))
+	SetWorldTile(pipe, targetX, targetY, *currentTile)
+	RecordItemMovement(pipe, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemFire,
+		Quantity: 1,
+		Source:   ItemLocationInventory,
+		Dest:     ItemLocationWorld,
+		Reason:   ItemAuditPlace,
+		Ref:      targetCoordKey,
+	})
 	_, err = pipe.Exec(ctx)
 	if err != nil {
 		return Failed()
@@ -189,16 +201,7 @@ func (h *PlaceItemActionHandler) handlePlaceFire(playerID string, currentX, curr
 	Broadcast(worldUpdate)
 
 	// Add the fire to the resource positions set so the damage system can find it
-	member := string(TileTypeFire) + ":" + targetCoordKey
-	x, y := utils.ParseCoordKey(targetCoordKey)
-
-	// Update the resource's geo-position
-	lon, lat := NormalizeCoords(x, y)
-	rdb.GeoAdd(ctx, string(RedisKeyResourcePositions), &redis.GeoLocation{
-		Name:      member,
-		Longitude: lon,
-		Latitude:  lat,
-	})
+	setResourcePosition(rdb, TileTypeFire, targetX, targetY)
 
 	inventoryUpdateMsg := getInventoryUpdateMessage(inventoryKey)
 	rdb.HSet(ctx, playerID, "nextActionAt", time.Now().Add(BaseActionCooldown).UnixMilli())
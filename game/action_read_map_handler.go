@@ -0,0 +1,78 @@
+package game
+
+import (
+	"encoding/json"
+	"log"
+	"mmo-game/models"
+	"strconv"
+)
+
+// ReadMapActionHandler handles client read map actions.
+// This implements the ActionHandler interface for standardized action processing.
+type ReadMapActionHandler struct{}
+
+// Process handles a read map action request from the client.
+// It gives the player a dig site if they have none, and tells them where it is.
+func (h *ReadMapActionHandler) Process(playerID string, payload json.RawMessage) *ActionResult {
+	var readMapPayload models.ReadMapPayload
+	if err := json.Unmarshal(payload, &readMapPayload); err != nil {
+		return Failed()
+	}
+
+	canAct, playerData := CanEntityAct(playerID)
+	if !canAct {
+		return Failed()
+	}
+
+	inventoryKey := string(RedisKeyPlayerInventory) + playerID
+	itemJSON, err := rdb.HGet(ctx, inventoryKey, readMapPayload.InventorySlot).Result()
+	if err != nil || itemJSON == "" {
+		log.Printf("item not found in slot %s for player %s", readMapPayload.InventorySlot, playerID)
+		return Failed()
+	}
+
+	var item models.Item
+	json.Unmarshal([]byte(itemJSON), &item)
+	if ItemDefs[ItemID(item.ID)].Kind != ItemKindTreasureMap {
+		log.Printf("item %s is not a treasure map", item.ID)
+		return Failed()
+	}
+
+	x, y := GetEntityPosition(playerData)
+	if !isZoneLayer(zoneAt(x, y)) {
+		sendNotification(playerID, "The map shows nothing of this place.")
+		return Failed()
+	}
+
+	// A dig site left in another zone is forgotten for one here.
+	siteX, siteY, ok := playerDigSite(playerData)
+	if !ok || zoneAt(siteX, siteY) != zoneAt(x, y) {
+		siteX, siteY, ok = chooseDigSite(x, y)
+		if !ok {
+			sendNotification(playerID, "The map is too faded to make out.")
+			return Failed()
+		}
+		rdb.HSet(ctx, playerID, "digSite", strconv.Itoa(siteX)+","+strconv.Itoa(siteY))
+		log.Printf("Player %s's treasure map leads to %d,%d.", playerID, siteX, siteY)
+	}
+
+	result := NewActionResult()
+
+	msg := models.DigSiteMessage{
+		Type: string(ServerEventDigSite),
+		X:    siteX,
+		Y:    siteY,
+	}
+	msgBytes, _ := json.Marshal(msg)
+	result.AddToPlayer(models.WebSocketMessage{
+		Type:    msg.Type,
+		Payload: msgBytes,
+	})
+
+	if offset := describeOffset(siteX-x, siteY-y); offset != "" {
+		sendNotification(playerID, "The map marks a spot "+offset+" of here.")
+	} else {
+		sendNotification(playerID, "The map marks the spot you are standing on.")
+	}
+	return result
+}
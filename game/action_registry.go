@@ -42,6 +42,11 @@ func HandleAction(eventType ClientEventType, playerID string, payload json.RawMe
 		return Failed()
 	}
 
+	// A new command takes over from a server-driven walk.
+	if !walkKeepingEvents[eventType] {
+		StopWalking(playerID)
+	}
+
 	result := handler.Process(playerID, payload)
 	if result == nil {
 		return Failed()
@@ -18,6 +18,8 @@ func init() {
 	RegisterAction(ClientEventLearnRecipe, &LearnRecipeActionHandler{})
 	RegisterAction(ClientEventSetRune, &SetRuneActionHandler{})
 	RegisterAction(ClientEventFindPath, &FindPathActionHandler{})
+	RegisterAction(ClientEventWalkTo, &WalkToActionHandler{})
+	RegisterAction(ClientEventFollow, &FollowActionHandler{})
 	RegisterAction(ClientEventTeleport, &TeleportActionHandler{})
 	RegisterAction(ClientEventToggleEcho, &ToggleEchoActionHandler{})
 	RegisterAction(ClientEventSendChat, &SendChatActionHandler{})
@@ -25,5 +27,7 @@ func init() {
 	RegisterAction(ClientEventDepositItem, &DepositItemActionHandler{})
 	RegisterAction(ClientEventWithdrawItem, &WithdrawItemActionHandler{})
 	RegisterAction(ClientEventReorderItem, &ReorderItemActionHandler{})
+	RegisterAction(ClientEventReadMap, &ReadMapActionHandler{})
+	RegisterAction(ClientEventDig, &DigActionHandler{})
 }
 
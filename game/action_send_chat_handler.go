@@ -35,8 +35,14 @@ func (h *SendChatActionHandler) Process(playerID string, payload json.RawMessage
 	// Define chat radius
 	const chatRadius = ChatRadius
 
-	// Find nearby players (including self)
-	nearbyPlayerIDs := GetEntitiesInRange(x, y, chatRadius, EntityTypePlayer)
+	// Find nearby players (including self) in the same zone
+	zone := playerZone(playerData)
+	var nearbyPlayerIDs []string
+	for _, nearbyID := range GetEntitiesInRange(x, y, chatRadius, EntityTypePlayer) {
+		if entityZone(nearbyID) == zone {
+			nearbyPlayerIDs = append(nearbyPlayerIDs, nearbyID)
+		}
+	}
 
 	// Create the message to broadcast
 	chatMessage := models.PlayerChatMessage{
@@ -5,12 +5,15 @@ import (
 	"mmo-game/models"
 	"strconv"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
 const teleportChannelTime = 3 * time.Second
 
+// teleportJob is the payload of a JobCompleteTeleport job.
+type teleportJob struct {
+	CompleteAt int64 `json:"completeAt"`
+}
+
 func completeTeleport(playerID string, expectedCompleteTime time.Time) {
 	playerData, err := rdb.HGetAll(ctx, playerID).Result()
 	if err != nil {
@@ -24,7 +27,7 @@ func completeTeleport(playerID string, expectedCompleteTime time.Time) {
 	}
 	teleportingUntil, _ := strconv.ParseInt(teleportingUntilStr, 10, 64)
 
-	// Check if the timestamp matches, ensuring this isn't a stale AfterFunc call
+	// Check if the timestamp matches, ensuring this isn't a stale or repeated job
 	if teleportingUntil != expectedCompleteTime.UnixMilli() {
 		return
 	}
@@ -40,12 +43,8 @@ func completeTeleport(playerID string, expectedCompleteTime time.Time) {
 	LockTileForEntity(playerID, destX, destY)
 
 	rdb.HSet(ctx, playerID, "x", destX, "y", destY)
-	lon, lat := NormalizeCoords(destX, destY)
-	rdb.GeoAdd(ctx, string(RedisKeyZone0Positions), &redis.GeoLocation{
-		Name:      playerID,
-		Longitude: lon,
-		Latitude:  lat,
-	})
+	setEntityPosition(rdb, playerID, destX, destY)
+	visitWorld(playerID, oldX, oldY, destX, destY)
 
 	moveUpdate := map[string]interface{}{
 		"type":      string(ServerEventEntityMoved),
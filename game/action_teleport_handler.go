@@ -23,8 +23,7 @@ func (h *TeleportActionHandler) Process(playerID string, payload json.RawMessage
 		return Failed()
 	}
 
-	binding, ok := playerData["binding"]
-	if !ok || binding == "" {
+	if _, ok := playerStoredString(playerData, "binding"); !ok {
 		notification := CreateNotificationMessage("You have no binding point set.")
 		SendPrivately(playerID, notification)
 		return Failed()
@@ -49,9 +48,7 @@ func (h *TeleportActionHandler) Process(playerID string, payload json.RawMessage
 	})
 
 	// Schedule the teleport to complete
-	time.AfterFunc(teleportChannelTime, func() {
-		completeTeleport(playerID, teleportCompleteAt)
-	})
+	ScheduleJob(JobCompleteTeleport, playerID, teleportCompleteAt, teleportJob{CompleteAt: teleportCompleteAt.UnixMilli()})
 
 	return result
 }
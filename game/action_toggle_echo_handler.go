@@ -3,7 +3,6 @@ package game
 import (
 	"encoding/json"
 	"log"
-	"strconv"
 )
 
 // ToggleEchoActionHandler handles client toggle echo actions.
@@ -22,8 +21,8 @@ func (h *ToggleEchoActionHandler) Process(playerID string, payload json.RawMessa
 		return Failed()
 	}
 
-	isEcho, _ := strconv.ParseBool(playerData["isEcho"])
-	resonance, _ := strconv.ParseInt(playerData["resonance"], 10, 64)
+	isEcho := playerBool(playerData, "isEcho")
+	resonance := playerInt64(playerData, "resonance")
 
 	if !isEcho && resonance <= 0 {
 		// Can't activate echo without resonance
@@ -60,6 +60,14 @@ func (h *UnequipActionHandler) Process(playerID string, payload json.RawMessage)
 	pipe := rdb.Pipeline()
 	pipe.HSet(ctx, gearKey, unequipData.GearSlot, "")
 	pipe.HSet(ctx, inventoryKey, emptySlot, itemJSON)
+	RecordItemMovement(pipe, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemID(item.ID),
+		Quantity: item.Quantity,
+		Source:   ItemLocationGear,
+		Dest:     ItemLocationInventory,
+		Reason:   ItemAuditUnequip,
+	})
 	_, err = pipe.Exec(ctx)
 	if err != nil {
 		log.Printf("error executing unequip pipeline: %v", err)
@@ -0,0 +1,33 @@
+package game
+
+import (
+	"encoding/json"
+	"mmo-game/models"
+)
+
+// WalkToActionHandler handles client walk_to actions.
+// This implements the ActionHandler interface for standardized action processing.
+type WalkToActionHandler struct{}
+
+// Process handles a walk_to action request from the client.
+// It plans a path to the target and hands it to the walk system, which steps the player
+// along it; the client no longer sends the individual moves.
+func (h *WalkToActionHandler) Process(playerID string, payload json.RawMessage) *ActionResult {
+	var walkToData models.WalkToPayload
+	if err := json.Unmarshal(payload, &walkToData); err != nil {
+		return Failed()
+	}
+
+	result := NewActionResult()
+	if !StartWalk(playerID, walkToData.X, walkToData.Y, walkToData.Interact) {
+		noValidPathMsg := &models.WebSocketMessage{
+			Type: string(ServerEventNoValidPath),
+		}
+		noValidPathJSON, _ := json.Marshal(noValidPathMsg)
+		result.AddToPlayer(models.WebSocketMessage{
+			Type:    noValidPathMsg.Type,
+			Payload: noValidPathJSON,
+		})
+	}
+	return result
+}
@@ -60,6 +60,15 @@ func (h *WithdrawItemActionHandler) Process(playerID string, payload json.RawMes
 		return Failed()
 	}
 
+	RecordItemMovement(pipe, ItemAuditEntry{
+		PlayerID: playerID,
+		ItemID:   ItemID(item.ID),
+		Quantity: withdrawData.Quantity,
+		Source:   ItemLocationBank,
+		Dest:     ItemLocationInventory,
+		Reason:   ItemAuditWithdraw,
+	})
+
 	pipe.HSet(ctx, playerID, "nextActionAt", time.Now().Add(BaseActionCooldown).UnixMilli())
 	_, err = pipe.Exec(ctx)
 	if err != nil {
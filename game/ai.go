@@ -4,6 +4,7 @@ import (
 	"log"
 	"math/rand"
 	"mmo-game/models"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,16 +17,15 @@ import (
 const LeashDistance = 5
 
 // StartAILoop begins the main game loop for processing NPC actions.
+// Ticks run one after another at AITickInterval; a tick that overruns delays the next
+// one rather than overlapping it.
 func StartAILoop() {
 	log.Println("Starting AI loop...")
-	// Run the AI logic on a ticker (e.g., every 750ms)
-	ticker := time.NewTicker(750 * time.Millisecond)
+	ticker := time.NewTicker(AITickInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		// Run in a goroutine so the ticker isn't blocked
-		// if AI processing takes a long time.
-		go runAIActions()
+		runAIActions()
 	}
 }
 
@@ -37,86 +37,81 @@ const (
 	EchoStateGathering EchoState = "gathering"
 )
 
-// runAIActions fetches all entities and processes their next action if they are AI-controlled.
+// runAIActions runs a tick of every active zone in turn.
 func runAIActions() {
 	startTime := time.Now()
-	tickCache, err := buildTickCache()
+	for _, zone := range activeZones() {
+		runZoneAIActions(zone)
+	}
+
+	duration := time.Since(startTime)
+	if duration > AITickInterval {
+		log.Printf("AI tick took longer than tick rate: %s", duration)
+	}
+}
+
+// runZoneAIActions fetches all entities in a zone and processes their next action if
+// they are AI-controlled. A dungeon left empty is torn down instead.
+func runZoneAIActions(zone ZoneID) {
+	tickCache, err := buildTickCache(zone)
 	if err != nil {
-		log.Printf("Error building tick cache for AI loop: %v", err)
+		log.Printf("Error building tick cache for AI loop in zone %s: %v", zone, err)
 		return
 	}
+	if !isZoneLayer(zone) && dungeonAbandoned(zone, tickCache) {
+		teardownDungeon(zone)
+		return
+	}
+
+	// The AI tick is every entity's heartbeat for its tile lock lease.
+	RenewTileLeases(tickCache.EntityData)
 
-	for entityID, entityData := range tickCache.EntityData {
+	// Process entities in a fixed order so ties for a tile resolve the same way each tick.
+	entityIDs := make([]string, 0, len(tickCache.EntityData))
+	for entityID := range tickCache.EntityData {
+		entityIDs = append(entityIDs, entityID)
+	}
+	sort.Strings(entityIDs)
+
+	tick := newAITick(tickCache)
+	for _, entityID := range entityIDs {
 		// Check the entity type and process accordingly
 		if strings.HasPrefix(entityID, "npc:") {
-			go processNPCAction(entityID, tickCache)
+			processNPCAction(entityID, tick)
 		} else if strings.HasPrefix(entityID, "player:") {
-			isEcho, _ := strconv.ParseBool(entityData["isEcho"])
-			if isEcho {
-				go runEchoAI(entityID, tickCache)
+			if playerBool(tickCache.EntityData[entityID], "isEcho") {
+				runEchoAI(entityID, tick)
 			}
 		}
 	}
-	duration := time.Since(startTime)
-	if duration > 750*time.Millisecond {
-		log.Printf("AI tick took longer than tick rate: %s", duration)
-	}
+	tick.Flush()
 }
 
-func buildTickCache() (*TickCache, error) {
+// buildTickCache snapshots the entities of a zone for a tick.
+func buildTickCache(zone ZoneID) (*TickCache, error) {
 	cache := &TickCache{
 		EntityData:    make(map[string]map[string]string),
-		ResourceNodes: make(map[TileType][]redis.GeoLocation),
 		LockedTiles:   make(map[string]bool),
 		CollisionGrid: BuildCollisionGrid(),
 	}
 
 	// 1. Get all entity IDs
-	entityIDs, err := rdb.ZRange(ctx, string(RedisKeyZone0Positions), 0, -1).Result()
+	entityIDs, err := rdb.ZRange(ctx, zonePositionsKey(zone), 0, -1).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	// --- Pre-fetch locked tiles using SCAN to avoid blocking with KEYS ---
-	var lockedTileKeys []string
-	var cursor uint64
-	for {
-		keys, nextCursor, err := rdb.Scan(ctx, cursor, string(RedisKeyLockTile)+"*", 100).Result()
-		if err != nil {
-			log.Printf("Error scanning for locked tiles: %v", err)
-			break // Exit on error
-		}
-		lockedTileKeys = append(lockedTileKeys, keys...)
-		if nextCursor == 0 {
-			break // We're done
-		}
-		cursor = nextCursor
-	}
-
 	pipe := rdb.Pipeline()
 
+	// Locked tiles come from the lock index rather than a keyspace SCAN
+	lockedTilesCmd := pipe.HKeys(ctx, string(RedisKeyLockIndex))
+
 	// 2. Queue HGETALL for each entity
 	entityDataCmds := make(map[string]*redis.StringStringMapCmd)
 	for _, entityID := range entityIDs {
 		entityDataCmds[entityID] = pipe.HGetAll(ctx, entityID)
 	}
 
-	// 3. Queue GEORADIUS for all resource types
-	// We search from the center of the map with a radius large enough to cover everything.
-	const searchRadiusKm = 20000
-	resourceCmds := make(map[TileType]*redis.GeoLocationCmd)
-	for tileType, props := range TileDefs {
-		if props.IsGatherable {
-			query := &redis.GeoRadiusQuery{
-				Radius:    searchRadiusKm,
-				Unit:      "km",
-				WithCoord: true,
-				Sort:      "ASC",
-			}
-			resourceCmds[tileType] = pipe.GeoRadius(ctx, string(RedisKeyResourcePositions), 0, 0, query)
-		}
-	}
-
 	// Execute all queued commands
 	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
 		return nil, err
@@ -132,37 +127,21 @@ func buildTickCache() (*TickCache, error) {
 		}
 	}
 
-	// Process resource locations
-	for tileType, cmd := range resourceCmds {
-		locations, err := cmd.Result()
-		if err == nil {
-			// Filter for the correct resource type since GeoRadius on "positions:resource"
-			// returns all resources. The member name is "tileType:x,y".
-			var filteredLocations []redis.GeoLocation
-			for _, loc := range locations {
-				if strings.HasPrefix(loc.Name, string(tileType)+":") {
-					filteredLocations = append(filteredLocations, loc)
-				}
-			}
-			cache.ResourceNodes[tileType] = filteredLocations
+	// Process locked tiles; the index fields are already "x,y"
+	if lockedTiles, err := lockedTilesCmd.Result(); err == nil {
+		for _, coords := range lockedTiles {
+			cache.LockedTiles[coords] = true
 		}
-	}
-
-	// Process locked tiles
-	for _, key := range lockedTileKeys {
-		// key is "lock:tile:x,y", we want to store "x,y"
-		coords := strings.TrimPrefix(key, string(RedisKeyLockTile))
-		cache.LockedTiles[coords] = true
+	} else {
+		log.Printf("Error reading locked tiles: %v", err)
 	}
 
 	return cache, nil
 }
 
 // runEchoAI handles the logic for a player's Echo.
-func runEchoAI(playerID string, tickCache *TickCache) {
-	// Stagger AI ticks to smooth out server load.
-	time.Sleep(time.Duration(rand.Intn(50)) * time.Millisecond)
-	playerData := tickCache.EntityData[playerID]
+func runEchoAI(playerID string, tick *AITick) {
+	playerData := tick.Cache.EntityData[playerID]
 	if nextActionAtStr, ok := playerData["nextActionAt"]; ok {
 		if nextActionAt, err := strconv.ParseInt(nextActionAtStr, 10, 64); err == nil {
 			if time.Now().UnixMilli() < nextActionAt {
@@ -172,28 +151,20 @@ func runEchoAI(playerID string, tickCache *TickCache) {
 	}
 
 	// 1. Decrement Resonance
-	newResonance, err := rdb.HIncrBy(ctx, playerID, "resonance", -1).Result()
-	if err != nil {
-		log.Printf("[Echo AI Error] Could not decrement resonance for %s: %v", playerID, err)
-		return
-	}
+	newResonance := playerInt64(playerData, "resonance") - 1
+	tick.pipe.HIncrBy(ctx, playerID, "resonance", -1)
+	playerData["resonance"] = strconv.FormatInt(newResonance, 10)
 
 	// NEW: Send a stats update to the player if they are online
-	if IsPlayerOnline(playerID) {
-		statsUpdateMsg := models.PlayerStatsUpdateMessage{
-			Type:      string(ServerEventPlayerStatsUpdate),
-			Resonance: &newResonance,
-		}
-		statsUpdateJSON, _ := json.Marshal(statsUpdateMsg)
-		if sendDirectMessage != nil {
-			sendDirectMessage(playerID, statsUpdateJSON)
-		}
-	}
+	tick.sendStats(playerID, models.PlayerStatsUpdateMessage{
+		Type:      string(ServerEventPlayerStatsUpdate),
+		Resonance: &newResonance,
+	})
 
 	// 2. Check if Resonance has run out
 	if newResonance <= 0 {
 		log.Printf("Echo for player %s has run out of Resonance.", playerID)
-		rdb.HSet(ctx, playerID, "isEcho", "false") // Ensure echo is turned off
+		tick.Set(playerID, "isEcho", "false") // Ensure echo is turned off
 
 		// Check if the player is currently online.
 		// A simple way is to see if we have a client connection for them.
@@ -206,27 +177,35 @@ func runEchoAI(playerID string, tickCache *TickCache) {
 				"entityId": playerID,
 				"isEcho":   false,
 			}
-			PublishUpdate(updateMsg)
+			tick.Publish(updateMsg)
 		} else {
 			// Player is offline, so despawn the Echo completely.
-			rdb.ZRem(ctx, string(RedisKeyZone0Positions), playerID)
+			removeEntityPosition(tick.pipe, playerID)
 		}
 		return // Stop further AI processing
 	}
 
-	// 3. AI State Machine
+	// 3. An Echo left following someone keeps following them instead of its rune
+	if targetID := playerData["following"]; targetID != "" {
+		if !followEntity(playerID, targetID, tick) {
+			tick.Unset(playerID, "following")
+		}
+		return
+	}
+
+	// 4. AI State Machine
 	state := EchoState(playerData["echoState"])
 	switch state {
 	case EchoStateIdling:
-		handleEchoIdling(playerID, playerData, tickCache)
+		handleEchoIdling(playerID, playerData, tick)
 	case EchoStateMoving:
-		handleEchoMoving(playerID, playerData)
+		handleEchoMoving(playerID, playerData, tick)
 	case EchoStateGathering:
-		handleEchoGathering(playerID, playerData)
+		handleEchoGathering(playerID, playerData, tick)
 	}
 }
 
-func handleEchoIdling(playerID string, playerData map[string]string, tickCache *TickCache) {
+func handleEchoIdling(playerID string, playerData map[string]string, tick *AITick) {
 	activeRune := RuneType(playerData["activeRune"])
 	var resourceType TileType
 
@@ -238,49 +217,47 @@ func handleEchoIdling(playerID string, playerData map[string]string, tickCache *
 	default: // No active rune, or an unknown rune
 		if rand.Intn(100) < 40 {
 			dir := getRandomDirection()
-			ProcessMove(playerID, dir)
+			tick.Move(playerID, dir)
 		}
 		return
 	}
 
 	currentX, currentY := GetEntityPosition(playerData)
-	targetX, targetY, found := findNearestResource(currentX, currentY, resourceType, tickCache)
+	targetX, targetY, found := findNearestResource(currentX, currentY, resourceType, tick.Cache)
 
 	if found {
-		// First, check if we're already adjacent.
-		if IsAdjacent(currentX, currentY, targetX, targetY) {
-			pipe := rdb.Pipeline()
-			pipe.HSet(ctx, playerID, "echoState", string(EchoStateGathering))
-			pipe.HSet(ctx, playerID, "echoTarget", strconv.Itoa(targetX)+","+strconv.Itoa(targetY))
-			pipe.Exec(ctx)
+		// First, check if we're already adjacent. Gathering works diagonally too.
+		if IsAdjacentOrDiagonal(currentX, currentY, targetX, targetY) {
+			tick.Set(playerID,
+				"echoState", string(EchoStateGathering),
+				"echoTarget", strconv.Itoa(targetX)+","+strconv.Itoa(targetY))
 			return // End this tick's logic here.
 		}
 
 		// If not adjacent, then find a path.
-		path := FindPathToAdjacent(currentX, currentY, targetX, targetY, tickCache)
+		path := FindPathToAdjacent(currentX, currentY, targetX, targetY, tick.Cache)
 		if len(path) > 1 {
 			pathJSON, _ := json.Marshal(path)
-			pipe := rdb.Pipeline()
-			pipe.HSet(ctx, playerID, "echoState", string(EchoStateMoving))
-			pipe.HSet(ctx, playerID, "echoPath", string(pathJSON))
-			pipe.HSet(ctx, playerID, "echoTarget", strconv.Itoa(targetX)+","+strconv.Itoa(targetY))
-			pipe.Exec(ctx)
+			tick.Set(playerID,
+				"echoState", string(EchoStateMoving),
+				"echoPath", string(pathJSON),
+				"echoTarget", strconv.Itoa(targetX)+","+strconv.Itoa(targetY))
 		} else {
 		}
 	} else {
 		// Wander if no resources are found
 		if rand.Intn(100) < 40 {
 			dir := getRandomDirection()
-			ProcessMove(playerID, dir)
+			tick.Move(playerID, dir)
 		}
 	}
 }
 
-func handleEchoMoving(playerID string, playerData map[string]string) {
+func handleEchoMoving(playerID string, playerData map[string]string, tick *AITick) {
 	var path []*Node
 	err := json.Unmarshal([]byte(playerData["echoPath"]), &path)
 	if err != nil || len(path) <= 1 {
-		rdb.HSet(ctx, playerID, "echoState", string(EchoStateIdling))
+		tick.Set(playerID, "echoState", string(EchoStateIdling))
 		return
 	}
 
@@ -289,39 +266,30 @@ func handleEchoMoving(playerID string, playerData map[string]string) {
 	currentX, currentY := GetEntityPosition(playerData)
 
 	// Determine direction
-	var moveDir MoveDirection
-	if nextStep.X > currentX {
-		moveDir = MoveDirectionRight
-	} else if nextStep.X < currentX {
-		moveDir = MoveDirectionLeft
-	} else if nextStep.Y > currentY {
-		moveDir = MoveDirectionDown
-	} else {
-		moveDir = MoveDirectionUp
-	}
+	moveDir := directionTowards(currentX, currentY, nextStep.X, nextStep.Y)
 
 	// Perform the move
-	ProcessMove(playerID, moveDir)
+	tick.Move(playerID, moveDir)
 
 	// Update the path
 	remainingPath := path[1:]
 	if len(remainingPath) == 0 {
 		// This should not happen if len(path) > 1, but as a safeguard.
-		rdb.HSet(ctx, playerID, "echoState", string(EchoStateIdling))
+		tick.Set(playerID, "echoState", string(EchoStateIdling))
 	} else if len(remainingPath) == 1 {
 		// We have arrived at the destination (the tile adjacent to the resource)
-		rdb.HSet(ctx, playerID, "echoState", string(EchoStateGathering))
+		tick.Set(playerID, "echoState", string(EchoStateGathering))
 	} else {
 		// Still more path to traverse
 		pathJSON, _ := json.Marshal(remainingPath)
-		rdb.HSet(ctx, playerID, "echoPath", string(pathJSON))
+		tick.Set(playerID, "echoPath", string(pathJSON))
 	}
 }
 
-func handleEchoGathering(playerID string, playerData map[string]string) {
+func handleEchoGathering(playerID string, playerData map[string]string, tick *AITick) {
 	targetCoords := strings.Split(playerData["echoTarget"], ",")
 	if len(targetCoords) != 2 {
-		rdb.HSet(ctx, playerID, "echoState", string(EchoStateIdling))
+		tick.Set(playerID, "echoState", string(EchoStateIdling))
 		return
 	}
 	targetX, _ := strconv.Atoi(targetCoords[0])
@@ -337,62 +305,31 @@ func handleEchoGathering(playerID string, playerData map[string]string) {
 	_, props, err := GetWorldTile(targetX, targetY)
 	if err != nil || !props.IsGatherable {
 		// Resource is gone or no longer gatherable, find a new one.
-		rdb.HSet(ctx, playerID, "echoState", string(EchoStateIdling))
+		tick.Set(playerID, "echoState", string(EchoStateIdling))
 	}
 	// If the resource is still there, the AI will remain in the "gathering"
 	// state and this function will be called again on the next AI tick.
 }
 
-// findNearestResource finds the nearest resource of a given type to a point.
+// echoResourceRadius is how far an Echo looks for a resource to gather.
+const echoResourceRadius = 64
+
+// findNearestResource finds the nearest unlocked resource of a given type to a point,
+// within echoResourceRadius and in the same zone.
 func findNearestResource(x, y int, tileType TileType, tickCache *TickCache) (int, int, bool) {
-	locations, ok := tickCache.ResourceNodes[tileType]
-	if !ok {
+	zone := zoneAt(x, y)
+	node, found := ResourceIndex.Nearest(x, y, string(tileType), echoResourceRadius, func(entry SpatialEntry) bool {
+		return zoneAt(entry.X, entry.Y) == zone && !tickCache.LockedTiles[strconv.Itoa(entry.X)+","+strconv.Itoa(entry.Y)]
+	})
+	if !found {
 		return 0, 0, false
 	}
-
-	// The locations are pre-sorted by distance from the center of the map,
-	// which is not useful here. We need to find the closest to the entity's position.
-	var closestDistSq float64 = -1
-	var targetX, targetY int
-	found := false
-
-	for _, loc := range locations {
-		coordsStr := strings.Split(loc.Name, ":")[1] // "tileType:x,y" -> "x,y"
-		if tickCache.LockedTiles[coordsStr] {
-			continue // Tile is locked
-		}
-
-		// --- BUG FIX: Use the coordinate string from the member name as the source of truth ---
-		// The lat/lon from the GEO query can have minor precision errors.
-		// The exact game coordinates are stored in the member name.
-		coordParts := strings.Split(coordsStr, ",")
-		resX, _ := strconv.Atoi(coordParts[0])
-		resY, _ := strconv.Atoi(coordParts[1])
-
-		dx := float64(x - resX)
-		dy := float64(y - resY)
-		distSq := dx*dx + dy*dy
-
-		if !found || distSq < closestDistSq {
-			closestDistSq = distSq
-			targetX = resX
-			targetY = resY
-			found = true
-		}
-	}
-
-	if found {
-		return targetX, targetY, true
-	}
-
-	return 0, 0, false
+	return node.X, node.Y, true
 }
 
 // processNPCAction contains the core logic for an individual NPC's turn.
-func processNPCAction(npcID string, tickCache *TickCache) {
-	// Stagger AI ticks to smooth out server load.
-	time.Sleep(time.Duration(rand.Intn(50)) * time.Millisecond)
-	npcData := tickCache.EntityData[npcID]
+func processNPCAction(npcID string, tick *AITick) {
+	npcData := tick.Cache.EntityData[npcID]
 
 	// 1. Cooldown Check
 	if nextActionAtStr, ok := npcData["nextActionAt"]; ok {
@@ -425,17 +362,20 @@ func processNPCAction(npcID string, tickCache *TickCache) {
 	distToOriginSq := (npcX-originX)*(npcX-originX) + (npcY-originY)*(npcY-originY)
 	if !isLeashing && distToOriginSq > LeashDistance*LeashDistance {
 		isLeashing = true
-		pipe := rdb.Pipeline()
-		pipe.HSet(ctx, npcID, "isLeashing", "true")
-		pipe.HSet(ctx, npcID, "health", props.MaxHealth)
-		pipe.Exec(ctx)
+		tick.Set(npcID, "isLeashing", "true")
+		// Healed straight away rather than with the tick, whose write would overwrite any
+		// damage ApplyDamage deals in the meantime.
+		if err := rdb.HSet(ctx, npcID, "health", props.MaxHealth).Err(); err != nil {
+			log.Printf("Failed to heal leashing NPC %s: %v", npcID, err)
+		}
+		npcData["health"] = strconv.Itoa(props.MaxHealth)
 
 		healthUpdateMsg := map[string]interface{}{
 			"type":     string(ServerEventEntityUpdate),
 			"entityId": npcID,
 			"health":   props.MaxHealth,
 		}
-		PublishUpdate(healthUpdateMsg)
+		tick.Publish(healthUpdateMsg)
 	}
 
 	// 4. Determine Action based on State (Leashing > Combat > Idle)
@@ -446,9 +386,9 @@ func processNPCAction(npcID string, tickCache *TickCache) {
 	// State 1: Leashing takes highest priority
 	if isLeashing {
 		if npcX == originX && npcY == originY {
-			rdb.HSet(ctx, npcID, "isLeashing", "false")
+			tick.Set(npcID, "isLeashing", "false")
 			if hasGroup {
-				rdb.Del(ctx, string(GroupTargetPrefix)+groupID)
+				tick.ClearGroupTarget(groupID)
 			}
 		} else {
 			hasTarget = true
@@ -459,9 +399,9 @@ func processNPCAction(npcID string, tickCache *TickCache) {
 		var targetFound bool
 		// Priority 2a: Check for a shared group target
 		if hasGroup {
-			groupTargetID, err := rdb.Get(ctx, string(GroupTargetPrefix)+groupID).Result()
-			if err == nil && groupTargetID != "" {
-				targetData, inCache := tickCache.EntityData[groupTargetID]
+			groupTargetID := tick.GroupTarget(groupID)
+			if groupTargetID != "" {
+				targetData, inCache := tick.Cache.EntityData[groupTargetID]
 				if inCache {
 					pX, pY := GetEntityPosition(targetData)
 					targetID = groupTargetID
@@ -470,7 +410,7 @@ func processNPCAction(npcID string, tickCache *TickCache) {
 					hasTarget = true
 				} else {
 					// Target is not in cache (maybe disconnected/dead), clear group target
-					rdb.Del(ctx, string(GroupTargetPrefix)+groupID)
+					tick.ClearGroupTarget(groupID)
 				}
 			}
 		}
@@ -482,7 +422,7 @@ func processNPCAction(npcID string, tickCache *TickCache) {
 				canAcquireTarget = false
 			}
 			if canAcquireTarget {
-				foundPlayerID, pX, pY, found := findClosestPlayer(npcID, npcData, props.AggroRange, tickCache)
+				foundPlayerID, pX, pY, found := findClosestPlayer(npcID, npcData, props.AggroRange, tick.Cache)
 				if found {
 					targetID = foundPlayerID
 					finalTargetX, finalTargetY = pX, pY
@@ -490,7 +430,7 @@ func processNPCAction(npcID string, tickCache *TickCache) {
 					hasTarget = true
 					// If in a group, "shout" the new target to the group
 					if hasGroup {
-						rdb.Set(ctx, string(GroupTargetPrefix)+groupID, targetID, 10*time.Second) // Target expires after 10s
+						tick.SetGroupTarget(groupID, targetID)
 					}
 				}
 			}
@@ -498,8 +438,8 @@ func processNPCAction(npcID string, tickCache *TickCache) {
 
 		// If a target is set (either from group or new), decide action
 		if targetFound {
-			if IsAdjacent(npcX, npcY, finalTargetX, finalTargetY) {
-				performNPCAttack(npcID, targetID, npcData)
+			if IsWithinMeleeRange(npcX, npcY, finalTargetX, finalTargetY) {
+				performNPCAttack(npcID, targetID, npcData, tick)
 				hasTarget = false // Attack is the action, no need to move
 			}
 			// if not adjacent, hasTarget is already true, so it will move
@@ -521,10 +461,10 @@ func processNPCAction(npcID string, tickCache *TickCache) {
 						finalTargetX, finalTargetY = originX, originY
 					} else if rand.Intn(100) < 10 { // Wander further
 						dir := getRandomDirection()
-						ProcessMove(npcID, dir)
+						tick.Move(npcID, dir)
 					} else if rand.Intn(100) < 30 { // Just rotate
 						dir := getRandomDirection()
-						UpdateEntityDirection(npcID, npcX+getDirectionOffset(dir)[0], npcY+getDirectionOffset(dir)[1])
+						tick.Face(npcID, npcX+getDirectionOffset(dir)[0], npcY+getDirectionOffset(dir)[1])
 					}
 				}
 			} else if npcX != originX || npcY != originY {
@@ -538,63 +478,73 @@ func processNPCAction(npcID string, tickCache *TickCache) {
 	// 5. Execute move action if a target was set
 	if hasTarget {
 		// Pathfind to the final target (could be player, origin, etc.)
-		path := FindPath(npcX, npcY, finalTargetX, finalTargetY, tickCache)
+		path := FindPath(npcX, npcY, finalTargetX, finalTargetY, tick.Cache)
 		if len(path) > 1 {
-			moveAlongPath(npcID, path)
+			moveAlongPath(npcID, path, tick)
 		} else if isLeashing {
 			// If leashing and can't find path, set new origin
-			pipe := rdb.Pipeline()
-			pipe.HSet(ctx, npcID, "originX", npcX)
-			pipe.HSet(ctx, npcID, "originY", npcY)
-			pipe.HSet(ctx, npcID, "isLeashing", "false")
-			pipe.Exec(ctx)
+			tick.Set(npcID, "originX", npcX, "originY", npcY, "isLeashing", "false")
 		}
 	}
 
-	// 6. Set cooldown
+	// 6. Set cooldown, keeping the longer one of a diagonal or water step taken above
 	cooldown, _ := strconv.ParseInt(npcData["moveCooldown"], 10, 64)
 	nextActionTime := time.Now().UnixMilli() + cooldown
-	rdb.HSet(ctx, npcID, "nextActionAt", nextActionTime)
+	if stepDoneAt, err := strconv.ParseInt(npcData["nextActionAt"], 10, 64); err == nil && stepDoneAt > nextActionTime {
+		nextActionTime = stepDoneAt
+	}
+	tick.Set(npcID, "nextActionAt", nextActionTime)
 }
 
 // moveAlongPath moves an NPC one step along a given path.
-func moveAlongPath(npcID string, path []*Node) {
+func moveAlongPath(npcID string, path []*Node, tick *AITick) {
 	if len(path) < 2 {
 		return
 	}
-	currentX, _ := rdb.HGet(ctx, npcID, "x").Int()
-	currentY, _ := rdb.HGet(ctx, npcID, "y").Int()
+	currentX, currentY := GetEntityPosition(tick.Cache.EntityData[npcID])
 
 	nextStep := path[1]
-	dx := nextStep.X - currentX
-	dy := nextStep.Y - currentY
-	var moveDir MoveDirection
-	if abs(dx) > abs(dy) {
-		if dx > 0 {
-			moveDir = MoveDirectionRight
-		} else {
-			moveDir = MoveDirectionLeft
+	tick.Move(npcID, directionTowards(currentX, currentY, nextStep.X, nextStep.Y))
+}
+
+// followEntity moves an AI-controlled entity one step towards staying next to another.
+// It returns false once the target is gone or beyond FollowRange, unless they went
+// through a portal still within it.
+func followEntity(entityID, targetID string, tick *AITick) bool {
+	target, ok := EntityIndex.Get(targetID)
+	currentX, currentY := GetEntityPosition(tick.Cache.EntityData[entityID])
+	if !ok {
+		return false
+	}
+	if !withinFollowRange(currentX, currentY, target) {
+		// The target may have stepped through a portal; follow them in.
+		portal, through := portalFollowedThrough(targetID, target, currentX, currentY)
+		if !through {
+			return false
 		}
-	} else {
-		if dy > 0 {
-			moveDir = MoveDirectionDown
-		} else {
-			moveDir = MoveDirectionUp
+		if IsAdjacentOrDiagonal(currentX, currentY, portal.X, portal.Y) {
+			tick.Move(entityID, directionTowards(currentX, currentY, portal.X, portal.Y))
+			return true
 		}
+		target.X, target.Y = portal.X, portal.Y
+	} else if IsAdjacentOrDiagonal(currentX, currentY, target.X, target.Y) {
+		return true // Caught up
+	}
+	path := FindPathToAdjacent(currentX, currentY, target.X, target.Y, tick.Cache)
+	if len(path) > 1 {
+		moveAlongPath(entityID, path, tick)
 	}
-	ProcessMove(npcID, moveDir)
+	return true
 }
 
-// findClosestPlayer finds the nearest attackable player within aggro range.
+// findClosestPlayer finds the nearest attackable player within aggro range and in sight.
 func findClosestPlayer(npcID string, npcData map[string]string, aggroRange int, tickCache *TickCache) (string, int, int, bool) {
 	npcX, npcY := GetEntityPosition(npcData)
-	var targetID string
-	var targetX, targetY int
-	var targetFound bool
-	var closestDistSq float64 = -1
 
-	for entityID, entityData := range tickCache.EntityData {
-		if !strings.HasPrefix(entityID, "player:") {
+	// InRange returns the nearest players first, so the first valid one is the target.
+	for _, entry := range EntityIndex.InRange(npcX, npcY, aggroRange, string(EntityTypePlayer)) {
+		entityData, ok := tickCache.EntityData[entry.ID]
+		if !ok {
 			continue
 		}
 		if _, ok := entityData["health"]; !ok {
@@ -605,29 +555,18 @@ func findClosestPlayer(npcID string, npcData map[string]string, aggroRange int,
 		if err == nil && targetTile.IsSanctuary {
 			continue
 		}
-
-		dx := float64(npcX - pX)
-		dy := float64(npcY - pY)
-		distSq := dx*dx + dy*dy
-
-		if distSq <= float64(aggroRange*aggroRange) {
-			if !targetFound || distSq < closestDistSq {
-				targetFound = true
-				closestDistSq = distSq
-				targetID = entityID
-				targetX = pX
-				targetY = pY
-			}
+		if !hasLineOfSight(npcX, npcY, pX, pY) {
+			continue // Out of sight, behind a wall or a ridge
 		}
+		return entry.ID, pX, pY, true
 	}
-	return targetID, targetX, targetY, targetFound
+	return "", 0, 0, false
 }
 
 // performNPCAttack handles the logic for an NPC attacking a player.
-func performNPCAttack(npcID, targetID string, npcData map[string]string) {
-	targetX, _ := rdb.HGet(ctx, targetID, "x").Int()
-	targetY, _ := rdb.HGet(ctx, targetID, "y").Int()
-	UpdateEntityDirection(npcID, targetX, targetY)
+func performNPCAttack(npcID, targetID string, npcData map[string]string, tick *AITick) {
+	targetX, targetY := GetEntityPosition(tick.Cache.EntityData[targetID])
+	tick.Face(npcID, targetX, targetY)
 
 	npcType := NPCType(npcData["npcType"])
 	props := NPCDefs[npcType]
@@ -635,13 +574,17 @@ func performNPCAttack(npcID, targetID string, npcData map[string]string) {
 	ApplyDamage(npcID, targetID, props.Attack)
 }
 
-// getRandomDirection selects a random cardinal direction.
+// getRandomDirection selects a random direction, diagonals included.
 func getRandomDirection() MoveDirection {
 	directions := []MoveDirection{
 		MoveDirectionUp,
 		MoveDirectionDown,
 		MoveDirectionLeft,
 		MoveDirectionRight,
+		MoveDirectionUpLeft,
+		MoveDirectionUpRight,
+		MoveDirectionDownLeft,
+		MoveDirectionDownRight,
 	}
 	return directions[rand.Intn(len(directions))]
 }
@@ -656,10 +599,45 @@ func getDirectionOffset(dir MoveDirection) [2]int {
 		return [2]int{-1, 0}
 	case MoveDirectionRight:
 		return [2]int{1, 0}
+	case MoveDirectionUpLeft:
+		return [2]int{-1, -1}
+	case MoveDirectionUpRight:
+		return [2]int{1, -1}
+	case MoveDirectionDownLeft:
+		return [2]int{-1, 1}
+	case MoveDirectionDownRight:
+		return [2]int{1, 1}
 	}
 	return [2]int{0, 0}
 }
 
+// directionTowards returns the direction of the single step from (x, y) that heads
+// towards (targetX, targetY), diagonally if both axes differ. It is empty if the two are
+// the same tile.
+func directionTowards(x, y, targetX, targetY int) MoveDirection {
+	dx, dy := sign(targetX-x), sign(targetY-y)
+	for _, dir := range []MoveDirection{
+		MoveDirectionUp, MoveDirectionDown, MoveDirectionLeft, MoveDirectionRight,
+		MoveDirectionUpLeft, MoveDirectionUpRight, MoveDirectionDownLeft, MoveDirectionDownRight,
+	} {
+		if getDirectionOffset(dir) == [2]int{dx, dy} {
+			return dir
+		}
+	}
+	return ""
+}
+
+// sign returns -1, 0 or 1 according to the sign of x.
+func sign(x int) int {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	}
+	return 0
+}
+
 // abs is a simple helper function to get the absolute value of an integer.
 func abs(x int) int {
 	if x < 0 {
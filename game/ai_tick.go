@@ -0,0 +1,248 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mmo-game/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// AITickInterval is the fixed rate of the AI simulation.
+const AITickInterval = 750 * time.Millisecond
+
+// AITick is one step of the AI simulation. Entities are processed one at a time against
+// the tick's TickCache; their state changes are queued into a single pipeline and their
+// events are published together when the tick is flushed.
+//
+// Changes are also applied to the cache, so later entities in the same tick see where
+// earlier ones moved. Tile locks are still taken immediately, since they are how AI and
+// player movement avoid stepping onto the same tile. Attacks and gathering go through
+// the shared ApplyDamage and ProcessInteract, which must read and write player state
+// consistently with player actions.
+type AITick struct {
+	Cache *TickCache
+
+	pipe         redis.Pipeliner
+	events       []interface{}
+	direct       []aiDirectMessage
+	groupTargets map[string]string
+	// rollbacks undo, newest first, what was done straight away for writes that were
+	// only queued, such as the tile lock an entity comes out of a portal on, if the
+	// pipeline fails.
+	rollbacks []func()
+}
+
+type aiDirectMessage struct {
+	playerID string
+	message  []byte
+}
+
+// newAITick starts a tick over a freshly built cache.
+func newAITick(cache *TickCache) *AITick {
+	return &AITick{
+		Cache:        cache,
+		pipe:         rdb.Pipeline(),
+		groupTargets: make(map[string]string),
+	}
+}
+
+// Set queues an HSET of field/value pairs on an entity and applies it to the cache.
+//
+// Usage:
+//   tick.Set(playerID, "echoState", string(EchoStateIdling))
+func (t *AITick) Set(entityID string, values ...interface{}) {
+	t.pipe.HSet(ctx, entityID, values...)
+	if data, ok := t.Cache.EntityData[entityID]; ok {
+		for i := 0; i+1 < len(values); i += 2 {
+			data[fmt.Sprint(values[i])] = fmt.Sprint(values[i+1])
+		}
+	}
+}
+
+// Unset queues an HDEL of fields on an entity and removes them from the cache.
+func (t *AITick) Unset(entityID string, fields ...string) {
+	t.pipe.HDel(ctx, entityID, fields...)
+	if data, ok := t.Cache.EntityData[entityID]; ok {
+		for _, field := range fields {
+			delete(data, field)
+		}
+	}
+}
+
+// Publish queues a broadcast, sent with the rest of the tick's events.
+func (t *AITick) Publish(message interface{}) {
+	t.events = append(t.events, message)
+}
+
+// SendTo queues a message for a single player, sent with the rest of the tick's events.
+func (t *AITick) SendTo(playerID string, message interface{}) {
+	jsonMsg, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Error marshalling AI message for %s: %v", playerID, err)
+		return
+	}
+	t.direct = append(t.direct, aiDirectMessage{playerID: playerID, message: jsonMsg})
+}
+
+// GroupTarget returns the shared target of an NPC group, reading it at most once a tick.
+func (t *AITick) GroupTarget(groupID string) string {
+	if targetID, ok := t.groupTargets[groupID]; ok {
+		return targetID
+	}
+	targetID, _ := rdb.Get(ctx, string(GroupTargetPrefix)+groupID).Result()
+	t.groupTargets[groupID] = targetID
+	return targetID
+}
+
+// SetGroupTarget shouts a new target to an NPC group. The target expires after 10s.
+func (t *AITick) SetGroupTarget(groupID, targetID string) {
+	t.groupTargets[groupID] = targetID
+	t.pipe.Set(ctx, string(GroupTargetPrefix)+groupID, targetID, 10*time.Second)
+}
+
+// ClearGroupTarget forgets an NPC group's shared target.
+func (t *AITick) ClearGroupTarget(groupID string) {
+	t.groupTargets[groupID] = ""
+	t.pipe.Del(ctx, string(GroupTargetPrefix)+groupID)
+}
+
+// Move moves an AI-controlled entity one tile. It shares checkMove and writeMove with
+// ProcessMove, but reads the entity from the cache and queues its writes and events on
+// the tick.
+// It returns false if the entity could not move.
+func (t *AITick) Move(entityID string, direction MoveDirection) bool {
+	entityData, ok := t.Cache.EntityData[entityID]
+	if !ok {
+		return false
+	}
+	if healthStr, ok := entityData["health"]; ok {
+		if health, _ := strconv.Atoi(healthStr); health <= 0 {
+			return false
+		}
+	}
+
+	step, ok := checkMove(entityID, entityData, direction)
+	if !ok {
+		return false
+	}
+
+	targetKey := strconv.Itoa(step.ToX) + "," + strconv.Itoa(step.ToY)
+	if !step.Tile.IsSanctuary {
+		if t.Cache.LockedTiles[targetKey] {
+			return false
+		}
+		wasSet, err := LockTileForEntity(entityID, step.ToX, step.ToY)
+		if err != nil || !wasSet {
+			t.Cache.LockedTiles[targetKey] = true
+			return false
+		}
+		t.Cache.LockedTiles[targetKey] = true
+	}
+
+	// An echo moving cancels its player's teleport, just like the player moving.
+	if writeMove(t.pipe, entityID, entityData, step) {
+		t.SendTo(entityID, map[string]interface{}{"type": string(ServerEventTeleportChannelEnd)})
+	}
+	delete(t.Cache.LockedTiles, strconv.Itoa(step.FromX)+","+strconv.Itoa(step.FromY))
+	if strings.HasPrefix(entityID, "player:") {
+		visitWorld(entityID, step.FromX, step.FromY, step.ToX, step.ToY)
+	}
+
+	t.Publish(map[string]interface{}{
+		"type":     string(ServerEventEntityMoved),
+		"entityId": entityID,
+		"x":        step.ToX,
+		"y":        step.ToY,
+	})
+
+	if TileType(step.Tile.Type) == TileTypePortal {
+		if joinMsg, destX, destY, ok := stepThroughPortal(t.pipe, entityID, entityData, step.ToX, step.ToY); ok {
+			t.rollbacks = append(t.rollbacks, func() {
+				// Leave them on the portal, as in ProcessMove.
+				UnlockTileForEntity(entityID, destX, destY)
+				LockTileForEntity(entityID, step.ToX, step.ToY)
+			})
+			delete(t.Cache.LockedTiles, targetKey)
+			t.Cache.LockedTiles[strconv.Itoa(destX)+","+strconv.Itoa(destY)] = true
+			if strings.HasPrefix(entityID, "player:") {
+				visitWorld(entityID, step.ToX, step.ToY, destX, destY)
+			}
+			t.Publish(joinMsg)
+		}
+	}
+	return true
+}
+
+// Face turns an entity towards a tile without moving it.
+func (t *AITick) Face(entityID string, targetX, targetY int) {
+	currentX, currentY := GetEntityPosition(t.Cache.EntityData[entityID])
+	dx := targetX - currentX
+	dy := targetY - currentY
+
+	var direction MoveDirection
+	if Abs(dx) > Abs(dy) {
+		if dx > 0 {
+			direction = MoveDirectionRight
+		} else {
+			direction = MoveDirectionLeft
+		}
+	} else {
+		if dy > 0 {
+			direction = MoveDirectionDown
+		} else {
+			direction = MoveDirectionUp
+		}
+	}
+	t.Set(entityID, "direction", string(direction))
+	t.Publish(map[string]interface{}{
+		"type":      string(ServerEventEntityMoved),
+		"entityId":  entityID,
+		"x":         currentX,
+		"y":         currentY,
+		"direction": string(direction),
+	})
+}
+
+// sendStats queues a stats update for an online player.
+func (t *AITick) sendStats(playerID string, stats models.PlayerStatsUpdateMessage) {
+	if IsPlayerOnline(playerID) {
+		t.SendTo(playerID, stats)
+	}
+}
+
+// Flush writes the tick's state changes in one pipeline, then publishes its events in a
+// second one, so clients never hear about a change before it is stored.
+func (t *AITick) Flush() {
+	if _, err := t.pipe.Exec(ctx); err != nil && err != redis.Nil {
+		log.Printf("Error writing AI tick: %v", err)
+		for i := len(t.rollbacks) - 1; i >= 0; i-- {
+			t.rollbacks[i]()
+		}
+	}
+
+	if len(t.events) > 0 {
+		pipe := rdb.Pipeline()
+		for _, event := range t.events {
+			jsonMsg, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error marshalling AI event: %v", err)
+				continue
+			}
+			pipe.Publish(ctx, "world_updates", string(jsonMsg))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("Error publishing AI tick events: %v", err)
+		}
+	}
+
+	if sendDirectMessage != nil {
+		for _, msg := range t.direct {
+			sendDirectMessage(msg.playerID, msg.message)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package game
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ItemLocation identifies the container an item moves out of or into.
+type ItemLocation string
+
+const (
+	// ItemLocationNone is used as the source when an item is created and as the
+	// destination when an item is destroyed.
+	ItemLocationNone ItemLocation = "none"
+	// ItemLocationWorld is an item lying on the ground as an item entity.
+	ItemLocationWorld ItemLocation = "world"
+	// ItemLocationInventory is the player's carried inventory.
+	ItemLocationInventory ItemLocation = "inventory"
+	// ItemLocationBank is the player's bank.
+	ItemLocationBank ItemLocation = "bank"
+	// ItemLocationGear is the player's equipped gear.
+	ItemLocationGear ItemLocation = "gear"
+)
+
+// ItemAuditReason describes why an item moved.
+type ItemAuditReason string
+
+const (
+	ItemAuditGather       ItemAuditReason = "gather"
+	ItemAuditLootPickup   ItemAuditReason = "loot_pickup"
+	ItemAuditCraftConsume ItemAuditReason = "craft_consume"
+	ItemAuditCraftProduce ItemAuditReason = "craft_produce"
+	ItemAuditDeposit      ItemAuditReason = "deposit"
+	ItemAuditWithdraw     ItemAuditReason = "withdraw"
+	ItemAuditEquip        ItemAuditReason = "equip"
+	ItemAuditUnequip      ItemAuditReason = "unequip"
+	ItemAuditPlace        ItemAuditReason = "place"
+	ItemAuditEat          ItemAuditReason = "eat"
+	ItemAuditLearnRecipe  ItemAuditReason = "learn_recipe"
+	ItemAuditQuestReward  ItemAuditReason = "quest_reward"
+	ItemAuditQuestTurnIn  ItemAuditReason = "quest_turn_in"
+	ItemAuditDrop         ItemAuditReason = "drop"
+	ItemAuditStarterKit   ItemAuditReason = "starter_kit"
+	ItemAuditImport       ItemAuditReason = "import"
+	ItemAuditImportRemove ItemAuditReason = "import_remove"
+	ItemAuditDespawn      ItemAuditReason = "despawn"
+	ItemAuditSearch       ItemAuditReason = "search"
+	ItemAuditDig          ItemAuditReason = "dig"
+)
+
+// ItemAuditGlobalMaxLen caps the global audit stream. Per-player streams are
+// never trimmed, so a player's full history is always available.
+const ItemAuditGlobalMaxLen = 1000000
+
+// ItemAuditEntry is a single item movement as stored in the audit streams.
+type ItemAuditEntry struct {
+	// ID is the Redis stream entry ID. It is only set on entries read back from Redis.
+	ID        string
+	Timestamp int64
+	PlayerID  string
+	ItemID    ItemID
+	Quantity  int
+	Source    ItemLocation
+	Dest      ItemLocation
+	Reason    ItemAuditReason
+	// Ref correlates related entries, e.g. the ingredients and product of a
+	// single craft, or a world drop and its pickup (the drop's entity ID).
+	Ref string
+}
+
+// RecordItemMovement appends an entry to the global item audit stream and, if the
+// entry belongs to a player, to that player's stream. Pass the pipeline that
+// performs the item change so the audit record is written alongside it; pass rdb
+// when the change has already been committed.
+//
+// Usage:
+//   pipe := rdb.Pipeline()
+//   pipe.HSet(ctx, inventoryKey, slotKey, "")
+//   RecordItemMovement(pipe, ItemAuditEntry{PlayerID: playerID, ItemID: ItemFire, Quantity: 1,
+//       Source: ItemLocationInventory, Dest: ItemLocationWorld, Reason: ItemAuditPlace})
+//   pipe.Exec(ctx)
+func RecordItemMovement(c redis.Cmdable, entry ItemAuditEntry) {
+	if entry.Quantity <= 0 || entry.ItemID == "" {
+		return
+	}
+	if entry.Timestamp == 0 {
+		entry.Timestamp = time.Now().UnixMilli()
+	}
+
+	values := map[string]interface{}{
+		"ts":     entry.Timestamp,
+		"player": entry.PlayerID,
+		"item":   string(entry.ItemID),
+		"qty":    entry.Quantity,
+		"source": string(entry.Source),
+		"dest":   string(entry.Dest),
+		"reason": string(entry.Reason),
+		"ref":    entry.Ref,
+	}
+
+	if err := c.XAdd(ctx, &redis.XAddArgs{
+		Stream: string(RedisKeyItemAudit),
+		MaxLen: ItemAuditGlobalMaxLen,
+		Approx: true,
+		Values: values,
+	}).Err(); err != nil {
+		log.Printf("Failed to record item audit entry for %s: %v", entry.PlayerID, err)
+	}
+
+	if entry.PlayerID != "" {
+		if err := c.XAdd(ctx, &redis.XAddArgs{
+			Stream: string(RedisKeyItemAuditPlayerPrefix) + entry.PlayerID,
+			Values: values,
+		}).Err(); err != nil {
+			log.Printf("Failed to record player item audit entry for %s: %v", entry.PlayerID, err)
+		}
+	}
+}
+
+// GetPlayerItemHistory returns a player's item audit entries in chronological order.
+// A count of 0 returns the full history.
+func GetPlayerItemHistory(playerID string, count int64) ([]ItemAuditEntry, error) {
+	streamKey := string(RedisKeyItemAuditPlayerPrefix) + playerID
+	var messages []redis.XMessage
+	var err error
+	if count > 0 {
+		messages, err = rdb.XRangeN(ctx, streamKey, "-", "+", count).Result()
+	} else {
+		messages, err = rdb.XRange(ctx, streamKey, "-", "+").Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ItemAuditEntry, 0, len(messages))
+	for _, msg := range messages {
+		entries = append(entries, parseItemAuditEntry(msg))
+	}
+	return entries, nil
+}
+
+// GetItemAuditEntriesByRef scans the global stream for entries sharing a correlation ref.
+// This is used to find where a picked-up world item was originally dropped.
+func GetItemAuditEntriesByRef(ref string) ([]ItemAuditEntry, error) {
+	var entries []ItemAuditEntry
+	start := "-"
+	for {
+		messages, err := rdb.XRangeN(ctx, string(RedisKeyItemAudit), start, "+", 1000).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range messages {
+			if msg.Values["ref"] == ref {
+				entries = append(entries, parseItemAuditEntry(msg))
+			}
+		}
+		if len(messages) < 1000 {
+			return entries, nil
+		}
+		start = "(" + messages[len(messages)-1].ID
+	}
+}
+
+func parseItemAuditEntry(msg redis.XMessage) ItemAuditEntry {
+	str := func(key string) string {
+		s, _ := msg.Values[key].(string)
+		return s
+	}
+	ts, _ := strconv.ParseInt(str("ts"), 10, 64)
+	qty, _ := strconv.Atoi(str("qty"))
+	return ItemAuditEntry{
+		ID:        msg.ID,
+		Timestamp: ts,
+		PlayerID:  str("player"),
+		ItemID:    ItemID(str("item")),
+		Quantity:  qty,
+		Source:    ItemLocation(str("source")),
+		Dest:      ItemLocation(str("dest")),
+		Reason:    ItemAuditReason(str("reason")),
+		Ref:       str("ref"),
+	}
+}
@@ -0,0 +1,229 @@
+package game
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ItemAuditAnomaly is a suspicious pattern found while replaying an item history.
+type ItemAuditAnomaly struct {
+	EntryID string
+	ItemID  ItemID
+	Message string
+}
+
+// ItemHoldingDiscrepancy reports a difference between the holdings rebuilt from
+// the audit log and what the player actually has stored in Redis.
+type ItemHoldingDiscrepancy struct {
+	Location      ItemLocation
+	ItemID        ItemID
+	Reconstructed int
+	Actual        int
+}
+
+// ItemHoldings maps a container to the quantity of each item in it.
+type ItemHoldings map[ItemLocation]map[ItemID]int
+
+func (h ItemHoldings) add(location ItemLocation, itemID ItemID, quantity int) int {
+	if h[location] == nil {
+		h[location] = make(map[ItemID]int)
+	}
+	h[location][itemID] += quantity
+	return h[location][itemID]
+}
+
+// isPlayerContainer reports whether the location is one of the player's own containers.
+func isPlayerContainer(location ItemLocation) bool {
+	return location == ItemLocationInventory || location == ItemLocationBank || location == ItemLocationGear
+}
+
+// ReconstructHoldings replays a player's history and returns what they should be holding.
+func ReconstructHoldings(entries []ItemAuditEntry) ItemHoldings {
+	holdings := make(ItemHoldings)
+	for _, entry := range entries {
+		if isPlayerContainer(entry.Source) {
+			holdings.add(entry.Source, entry.ItemID, -entry.Quantity)
+		}
+		if isPlayerContainer(entry.Dest) {
+			holdings.add(entry.Dest, entry.ItemID, entry.Quantity)
+		}
+	}
+	return holdings
+}
+
+// AnalyzeItemHistory replays a player's history and flags anomalies:
+// crafts that produced more than their recipe allows for what was consumed,
+// crafts that consumed ingredients without producing anything, the same world
+// drop being picked up more than once, and containers going negative (items
+// removed that the log never saw arrive).
+func AnalyzeItemHistory(entries []ItemAuditEntry) []ItemAuditAnomaly {
+	var anomalies []ItemAuditAnomaly
+
+	type craft struct {
+		firstEntryID string
+		consumed     map[ItemID]int
+		produced     map[ItemID]int
+	}
+	crafts := make(map[string]*craft)
+	var craftOrder []string
+	pickups := make(map[string]string)
+	holdings := make(ItemHoldings)
+
+	for _, entry := range entries {
+		switch entry.Reason {
+		case ItemAuditCraftConsume, ItemAuditCraftProduce:
+			c, ok := crafts[entry.Ref]
+			if !ok {
+				c = &craft{firstEntryID: entry.ID, consumed: make(map[ItemID]int), produced: make(map[ItemID]int)}
+				crafts[entry.Ref] = c
+				craftOrder = append(craftOrder, entry.Ref)
+			}
+			if entry.Reason == ItemAuditCraftConsume {
+				c.consumed[entry.ItemID] += entry.Quantity
+			} else {
+				c.produced[entry.ItemID] += entry.Quantity
+			}
+		case ItemAuditLootPickup:
+			if entry.Ref != "" {
+				if firstID, seen := pickups[entry.Ref]; seen {
+					anomalies = append(anomalies, ItemAuditAnomaly{
+						EntryID: entry.ID,
+						ItemID:  entry.ItemID,
+						Message: fmt.Sprintf("world item %s picked up again (first pickup %s)", entry.Ref, firstID),
+					})
+				} else {
+					pickups[entry.Ref] = entry.ID
+				}
+			}
+		}
+
+		if isPlayerContainer(entry.Source) {
+			if remaining := holdings.add(entry.Source, entry.ItemID, -entry.Quantity); remaining < 0 {
+				anomalies = append(anomalies, ItemAuditAnomaly{
+					EntryID: entry.ID,
+					ItemID:  entry.ItemID,
+					Message: fmt.Sprintf("%s went to %d %s after %s; items were removed that were never recorded arriving", entry.Source, remaining, entry.ItemID, entry.Reason),
+				})
+				holdings[entry.Source][entry.ItemID] = 0
+			}
+		}
+		if isPlayerContainer(entry.Dest) {
+			holdings.add(entry.Dest, entry.ItemID, entry.Quantity)
+		}
+	}
+
+	for _, ref := range craftOrder {
+		c := crafts[ref]
+		if len(c.produced) == 0 {
+			anomalies = append(anomalies, ItemAuditAnomaly{
+				EntryID: c.firstEntryID,
+				Message: fmt.Sprintf("craft %s consumed %s but produced nothing", ref, formatItemCounts(c.consumed)),
+			})
+			continue
+		}
+		for itemID, produced := range c.produced {
+			recipe, ok := RecipeDefs[itemID]
+			if !ok {
+				anomalies = append(anomalies, ItemAuditAnomaly{
+					EntryID: c.firstEntryID,
+					ItemID:  itemID,
+					Message: fmt.Sprintf("craft %s produced %d %s, which has no recipe", ref, produced, itemID),
+				})
+				continue
+			}
+			if produced > recipe.Yield {
+				anomalies = append(anomalies, ItemAuditAnomaly{
+					EntryID: c.firstEntryID,
+					ItemID:  itemID,
+					Message: fmt.Sprintf("craft %s produced %d %s; recipe yields %d", ref, produced, itemID, recipe.Yield),
+				})
+			}
+			for ingredient, required := range recipe.Ingredients {
+				if c.consumed[ingredient] < required {
+					anomalies = append(anomalies, ItemAuditAnomaly{
+						EntryID: c.firstEntryID,
+						ItemID:  itemID,
+						Message: fmt.Sprintf("net-positive craft %s: produced %s consuming only %d of %d %s", ref, itemID, c.consumed[ingredient], required, ingredient),
+					})
+				}
+			}
+		}
+	}
+
+	return anomalies
+}
+
+// CompareHoldings diffs reconstructed holdings against a player's actual
+// inventory, bank and gear. Only non-zero differences are returned.
+func CompareHoldings(reconstructed ItemHoldings, actual ItemHoldings) []ItemHoldingDiscrepancy {
+	var discrepancies []ItemHoldingDiscrepancy
+	for _, location := range []ItemLocation{ItemLocationInventory, ItemLocationBank, ItemLocationGear} {
+		items := make(map[ItemID]bool)
+		for itemID := range reconstructed[location] {
+			items[itemID] = true
+		}
+		for itemID := range actual[location] {
+			items[itemID] = true
+		}
+		ids := make([]string, 0, len(items))
+		for itemID := range items {
+			ids = append(ids, string(itemID))
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			itemID := ItemID(id)
+			if reconstructed[location][itemID] != actual[location][itemID] {
+				discrepancies = append(discrepancies, ItemHoldingDiscrepancy{
+					Location:      location,
+					ItemID:        itemID,
+					Reconstructed: reconstructed[location][itemID],
+					Actual:        actual[location][itemID],
+				})
+			}
+		}
+	}
+	return discrepancies
+}
+
+// GetActualHoldings loads a player's current inventory, bank and gear totals.
+func GetActualHoldings(playerID string) (ItemHoldings, error) {
+	holdings := make(ItemHoldings)
+	inventory, err := GetInventory(playerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range inventory {
+		holdings.add(ItemLocationInventory, ItemID(item.ID), item.Quantity)
+	}
+	bank, err := GetBank(playerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range bank {
+		holdings.add(ItemLocationBank, ItemID(item.ID), item.Quantity)
+	}
+	gear, err := GetGear(playerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range gear {
+		holdings.add(ItemLocationGear, ItemID(item.ID), item.Quantity)
+	}
+	return holdings, nil
+}
+
+func formatItemCounts(counts map[ItemID]int) string {
+	ids := make([]string, 0, len(counts))
+	for itemID := range counts {
+		ids = append(ids, string(itemID))
+	}
+	sort.Strings(ids)
+	s := ""
+	for i, id := range ids {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%d %s", counts[ItemID(id)], id)
+	}
+	return s
+}
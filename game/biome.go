@@ -0,0 +1,276 @@
+package game
+
+import (
+	"mmo-game/models"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// BiomeType identifies a region of the world with its own terrain, resources and NPCs.
+// Biomes are chosen by the world generator from temperature and moisture noise.
+type BiomeType string
+
+const (
+	// BiomePlains is open ground with scattered trees and rocks.
+	BiomePlains BiomeType = "plains"
+
+	// BiomeForest is grassland thick with trees.
+	BiomeForest BiomeType = "forest"
+
+	// BiomeSwamp is warm, wet mud and water that slows movement.
+	BiomeSwamp BiomeType = "swamp"
+
+	// BiomeHighlands is dry, rocky gravel rich in ore.
+	BiomeHighlands BiomeType = "highlands"
+
+	// BiomeTundra is cold snow with frozen water and pine trees.
+	BiomeTundra BiomeType = "tundra"
+
+	// BiomeCaverns is the caves: bare rock floor, underground pools and rich ore.
+	BiomeCaverns BiomeType = "caverns"
+
+	// BiomeEthereal is the ethereal plane: pale ground, rifts and spirit trees.
+	BiomeEthereal BiomeType = "ethereal"
+)
+
+// BiomeOrder lists every biome. Its order is used for the codes in a BiomeMap.
+var BiomeOrder = []BiomeType{BiomePlains, BiomeForest, BiomeSwamp, BiomeHighlands, BiomeTundra, BiomeCaverns, BiomeEthereal}
+
+// BiomeProperties defines how a biome looks and plays.
+type BiomeProperties struct {
+	// Tile palette: the biome's walkable floor, and what it uses for water and trees.
+	Floor TileType
+	Water TileType
+	Tree  TileType
+
+	// Terrain biases shift the WorldGenConfig thresholds within the biome: a positive
+	// bias makes that terrain more common. ScatteredTreeFactor scales the chance of
+	// lone trees.
+	WaterBias           float64
+	TreeBias            float64
+	RockBias            float64
+	OreBias             float64
+	ScatteredTreeFactor float64
+
+	// ResourceFill is the fraction of each resource's potential spawn points in the
+	// biome that the resource spawner keeps filled.
+	ResourceFill map[TileType]float64
+
+	// NPCSpawns is how many of each NPC the spawner keeps in the biome.
+	NPCSpawns map[NPCType]int
+
+	// MoveCooldownFactor lengthens (or shortens) the cooldown of a step into the biome.
+	MoveCooldownFactor float64
+}
+
+// BiomeDefs is our master map of all biome definitions.
+var BiomeDefs map[BiomeType]BiomeProperties
+
+func init() {
+	BiomeDefs = make(map[BiomeType]BiomeProperties)
+
+	BiomeDefs[BiomePlains] = BiomeProperties{
+		Floor:               TileTypeGround,
+		Water:               TileTypeWater,
+		Tree:                TileTypeTree,
+		ScatteredTreeFactor: 1,
+		ResourceFill: map[TileType]float64{
+			TileTypeTree:     0.9,
+			TileTypeRock:     0.65,
+			TileTypeIronRock: 0.5,
+		},
+		NPCSpawns: map[NPCType]int{
+			NPCTypeSlime: 6,
+			NPCTypeRat:   6,
+		},
+		MoveCooldownFactor: 1,
+	}
+	BiomeDefs[BiomeForest] = BiomeProperties{
+		Floor:               TileTypeGrass,
+		Water:               TileTypeWater,
+		Tree:                TileTypeTree,
+		TreeBias:            0.2,
+		ScatteredTreeFactor: 4,
+		ResourceFill: map[TileType]float64{
+			TileTypeTree:     0.95,
+			TileTypeRock:     0.5,
+			TileTypeIronRock: 0.4,
+		},
+		NPCSpawns: map[NPCType]int{
+			NPCTypeRat:   8,
+			NPCTypeSlime: 3,
+		},
+		MoveCooldownFactor: 1,
+	}
+	BiomeDefs[BiomeSwamp] = BiomeProperties{
+		Floor:               TileTypeMud,
+		Water:               TileTypeWater,
+		Tree:                TileTypeTree,
+		WaterBias:           0.35,
+		RockBias:            -0.1,
+		ScatteredTreeFactor: 2,
+		ResourceFill: map[TileType]float64{
+			TileTypeTree:     0.8,
+			TileTypeRock:     0.4,
+			TileTypeIronRock: 0.3,
+		},
+		NPCSpawns: map[NPCType]int{
+			NPCTypeSlime:     8,
+			NPCTypeSlimeBoss: 2,
+		},
+		MoveCooldownFactor: 1.5,
+	}
+	BiomeDefs[BiomeHighlands] = BiomeProperties{
+		Floor:               TileTypeGravel,
+		Water:               TileTypeWater,
+		Tree:                TileTypeTree,
+		WaterBias:           -0.1,
+		TreeBias:            -0.1,
+		RockBias:            0.15,
+		OreBias:             0.1,
+		ScatteredTreeFactor: 0.25,
+		ResourceFill: map[TileType]float64{
+			TileTypeTree:     0.6,
+			TileTypeRock:     0.8,
+			TileTypeIronRock: 0.7,
+		},
+		NPCSpawns: map[NPCType]int{
+			NPCTypeRat:       3,
+			NPCTypeSlimeBoss: 2,
+		},
+		MoveCooldownFactor: 1.2,
+	}
+	BiomeDefs[BiomeTundra] = BiomeProperties{
+		Floor:               TileTypeSnow,
+		Water:               TileTypeIce,
+		Tree:                TileTypePineTree,
+		TreeBias:            0.05,
+		ScatteredTreeFactor: 1.5,
+		ResourceFill: map[TileType]float64{
+			TileTypePineTree: 0.7,
+			TileTypeRock:     0.6,
+			TileTypeIronRock: 0.5,
+		},
+		NPCSpawns: map[NPCType]int{
+			NPCTypeRat:   3,
+			NPCTypeSlime: 3,
+		},
+		MoveCooldownFactor: 1.25,
+	}
+	BiomeDefs[BiomeCaverns] = BiomeProperties{
+		Floor:               TileTypeCaveFloor,
+		Water:               TileTypeWater,
+		Tree:                TileTypeCaveWall, // Pillars of rock
+		WaterBias:           -0.05,
+		RockBias:            0.25,
+		OreBias:             0.25,
+		ScatteredTreeFactor: 2,
+		ResourceFill: map[TileType]float64{
+			TileTypeRock:     0.9,
+			TileTypeIronRock: 0.8,
+		},
+		NPCSpawns: map[NPCType]int{
+			NPCTypeRat:       8,
+			NPCTypeSlime:     4,
+			NPCTypeSlimeBoss: 1,
+		},
+		MoveCooldownFactor: 1.1,
+	}
+	BiomeDefs[BiomeEthereal] = BiomeProperties{
+		Floor:               TileTypeEtherealGround,
+		Water:               TileTypeRift,
+		Tree:                TileTypeSpiritTree,
+		TreeBias:            0.1,
+		RockBias:            -0.2,
+		OreBias:             -0.2,
+		ScatteredTreeFactor: 1,
+		ResourceFill: map[TileType]float64{
+			TileTypeSpiritTree: 0.8,
+			TileTypeRock:       0.4,
+			TileTypeIronRock:   0.3,
+		},
+		NPCSpawns: map[NPCType]int{
+			NPCTypeSlime:     8,
+			NPCTypeSlimeBoss: 2,
+		},
+		MoveCooldownFactor: 0.9,
+	}
+}
+
+// biomeAreas is how many tiles of the stored world each biome covers, counted as chunks
+// are stored. The NPC spawner skips biomes the world does not have yet.
+var (
+	biomeAreas   map[BiomeType]int
+	biomeAreasMu sync.RWMutex
+)
+
+func biomeArea(biome BiomeType) int {
+	biomeAreasMu.RLock()
+	defer biomeAreasMu.RUnlock()
+	return biomeAreas[biome]
+}
+
+// BiomeAt returns the biome of a tile in the current world.
+func BiomeAt(x, y int) BiomeType {
+	return worldGen.biome(x, y)
+}
+
+// floorTileAt returns the floor tile a tile reverts to when whatever stood on it, such
+// as a resource or a wall, is destroyed.
+func floorTileAt(x, y int) TileType {
+	if !isZoneLayer(zoneAt(x, y)) {
+		return TileTypeDungeonFloor
+	}
+	return BiomeDefs[BiomeAt(x, y)].Floor
+}
+
+// biomeMoveFactor returns the move cooldown factor of the biome a tile is in.
+func biomeMoveFactor(x, y int) float64 {
+	if factor := BiomeDefs[BiomeAt(x, y)].MoveCooldownFactor; factor > 0 {
+		return factor
+	}
+	return 1
+}
+
+// BuildBiomeMap describes the biomes for the client, which uses them to draw floors the
+// server leaves out of the world state and to predict move cooldowns, along with the
+// biome maps of the given chunks.
+func BuildBiomeMap(chunks []worldChunkCoord) *models.BiomeMap {
+	biomeMap := &models.BiomeMap{ChunkSize: WorldChunkSize, Chunks: make(map[string]string, len(chunks))}
+	for _, biome := range BiomeOrder {
+		props := BiomeDefs[biome]
+		biomeMap.Biomes = append(biomeMap.Biomes, string(biome))
+		biomeMap.Floors = append(biomeMap.Floors, string(props.Floor))
+		biomeMap.MoveFactors = append(biomeMap.MoveFactors, props.MoveCooldownFactor)
+	}
+	for _, c := range chunks {
+		biomeMap.Chunks[strconv.Itoa(c.CX)+","+strconv.Itoa(c.CY)] = chunkBiomeRuns(c)
+	}
+	return biomeMap
+}
+
+// chunkBiomeRuns encodes the biome of every tile in a chunk, row by row from its
+// north-west corner, as a sequence of run lengths each followed by a letter: 'a' for the
+// first biome in BiomeOrder, 'b' for the second, and so on.
+func chunkBiomeRuns(c worldChunkCoord) string {
+	var runs strings.Builder
+	var current byte
+	count := 0
+	for ly := 0; ly < WorldChunkSize; ly++ {
+		for lx := 0; lx < WorldChunkSize; lx++ {
+			code := byte('a' + slices.Index(BiomeOrder, BiomeAt(c.CX*WorldChunkSize+lx, c.CY*WorldChunkSize+ly)))
+			if code != current && count > 0 {
+				runs.WriteString(strconv.Itoa(count))
+				runs.WriteByte(current)
+				count = 0
+			}
+			current = code
+			count++
+		}
+	}
+	runs.WriteString(strconv.Itoa(count))
+	runs.WriteByte(current)
+	return runs.String()
+}
@@ -0,0 +1,332 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mmo-game/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// CharacterExportFormatVersion is the version of the CharacterExport document layout.
+// It is independent of PlayerSchemaVersion: the document format describes the file,
+// the schema version describes the player hash stored inside it.
+const CharacterExportFormatVersion = 1
+
+// CharacterExport is a portable snapshot of everything that makes up a player.
+// The embedded JSON blobs from the player hash are lifted into their own fields so the
+// file is readable and diffable; Player holds the remaining hash fields verbatim.
+type CharacterExport struct {
+	FormatVersion int                    `json:"formatVersion"`
+	SchemaVersion int                    `json:"schemaVersion"`
+	ExportedAt    int64                  `json:"exportedAt"`
+	PlayerID      string                 `json:"playerId"`
+	Player        map[string]string      `json:"player"`
+	Binding       string                 `json:"binding"`
+	Quests        json.RawMessage        `json:"quests,omitempty"`
+	Experience    json.RawMessage        `json:"experience,omitempty"`
+	Runes         json.RawMessage        `json:"runes,omitempty"`
+	KnownRecipes  json.RawMessage        `json:"knownRecipes,omitempty"`
+	Inventory     map[string]models.Item `json:"inventory"`
+	Gear          map[string]models.Item `json:"gear"`
+	Bank          map[string]models.Item `json:"bank"`
+	// SecretKey is only present when the export was asked to include the login mapping.
+	SecretKey string `json:"secretKey,omitempty"`
+}
+
+// characterJSONFields are player hash fields holding embedded JSON that are exported separately.
+var characterJSONFields = []string{"quests", "experience", "runes", "knownRecipes"}
+
+// characterTransientFields are player hash fields describing in-flight session state.
+// They are not exported, and are reset on import so the character arrives idle.
+var characterTransientFields = []string{"teleportingUntil", "echoTarget", "echoPath"}
+
+// ExportCharacter gathers a player's full character into a CharacterExport.
+// If includeSecret is set, the secret key that logs into this player is included.
+func ExportCharacter(playerID string, includeSecret bool) (*CharacterExport, error) {
+	playerData, err := rdb.HGetAll(ctx, playerID).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(playerData) == 0 {
+		return nil, fmt.Errorf("player %s does not exist", playerID)
+	}
+
+	inventory, err := GetInventory(playerID)
+	if err != nil {
+		return nil, err
+	}
+	gear, err := GetGear(playerID)
+	if err != nil {
+		return nil, err
+	}
+	bank, err := GetBank(playerID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &CharacterExport{
+		FormatVersion: CharacterExportFormatVersion,
+		SchemaVersion: playerInt(playerData, PlayerFieldSchemaVersion),
+		ExportedAt:    time.Now().UnixMilli(),
+		PlayerID:      playerID,
+		Player:        make(map[string]string),
+		Binding:       playerData["binding"],
+		Inventory:     inventory,
+		Gear:          gear,
+		Bank:          bank,
+	}
+
+	rawFields := map[string]*json.RawMessage{
+		"quests":       &export.Quests,
+		"experience":   &export.Experience,
+		"runes":        &export.Runes,
+		"knownRecipes": &export.KnownRecipes,
+	}
+	for field, value := range playerData {
+		if target, ok := rawFields[field]; ok {
+			if json.Valid([]byte(value)) {
+				*target = json.RawMessage(value)
+			}
+			continue
+		}
+		if field == "binding" || isTransientCharacterField(field) {
+			continue
+		}
+		export.Player[field] = value
+	}
+
+	if includeSecret {
+		secretKey, err := findSecretKeyForPlayer(playerID)
+		if err != nil {
+			return nil, err
+		}
+		export.SecretKey = secretKey
+	}
+
+	return export, nil
+}
+
+// ImportCharacterOptions controls how ImportCharacter writes a character.
+type ImportCharacterOptions struct {
+	// TargetID is the player ID to import as. Empty keeps the exported ID; "new" generates one.
+	TargetID string
+	// Overwrite replaces an existing player with the same ID instead of failing.
+	Overwrite bool
+	// RestoreSecret maps the exported secret key to the imported player.
+	RestoreSecret bool
+}
+
+// ImportCharacter writes a CharacterExport into Redis and returns the player ID it was
+// imported as. The record is migrated to the current schema afterwards, so exports from
+// older servers can be imported into newer ones.
+func ImportCharacter(export *CharacterExport, opts ImportCharacterOptions) (string, error) {
+	if export.FormatVersion < 1 || export.FormatVersion > CharacterExportFormatVersion {
+		return "", fmt.Errorf("unsupported character export format version %d", export.FormatVersion)
+	}
+	if export.SchemaVersion > PlayerSchemaVersion {
+		return "", fmt.Errorf("export has player schema version %d, newer than this server's %d", export.SchemaVersion, PlayerSchemaVersion)
+	}
+
+	playerID := opts.TargetID
+	switch playerID {
+	case "":
+		playerID = export.PlayerID
+	case "new":
+		playerID = string(RedisKeyPlayerPrefix) + uuid.New().String()
+	}
+	if !strings.HasPrefix(playerID, string(RedisKeyPlayerPrefix)) {
+		return "", fmt.Errorf("player ID %q must start with %q", playerID, RedisKeyPlayerPrefix)
+	}
+
+	exists, err := rdb.Exists(ctx, playerID).Result()
+	if err != nil {
+		return "", err
+	}
+	if exists > 0 && !opts.Overwrite {
+		return "", fmt.Errorf("player %s already exists; use overwrite to replace it", playerID)
+	}
+
+	secretKeyName := ""
+	if opts.RestoreSecret && export.SecretKey != "" {
+		secretKeyName = string(RedisKeySecretPrefix) + export.SecretKey
+		owner, err := rdb.Get(ctx, secretKeyName).Result()
+		if err != nil && err != redis.Nil {
+			return "", err
+		}
+		if owner != "" && owner != playerID && !opts.Overwrite {
+			return "", fmt.Errorf("secret key already belongs to %s; use overwrite to reassign it", owner)
+		}
+	}
+
+	// The secret key of a player that is about to be overwritten must stop logging in to
+	// this ID. Secret keys are not kept in the player hash, so it is looked up.
+	replacedSecretKeyName := ""
+	if exists > 0 {
+		replacedSecret, err := findSecretKeyForPlayer(playerID)
+		if err != nil {
+			return "", err
+		}
+		if replacedSecret != "" {
+			replacedSecretKeyName = string(RedisKeySecretPrefix) + replacedSecret
+		}
+	}
+
+	// Items held by a player that is about to be overwritten are recorded as removed, so the
+	// audit history for this ID still adds up after the import.
+	var replacedHoldings ItemHoldings
+	if exists > 0 {
+		replacedHoldings, err = GetActualHoldings(playerID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Take the target off the map before replacing it so nobody sees a half-written record.
+	if exists > 0 {
+		if playerData, err := rdb.HGetAll(ctx, playerID).Result(); err == nil {
+			x, _ := strconv.Atoi(playerData["x"])
+			y, _ := strconv.Atoi(playerData["y"])
+			UnlockTileForEntity(playerID, x, y)
+		}
+		removeEntityPosition(rdb, playerID)
+	}
+
+	playerFields := make(map[string]interface{})
+	for field, value := range export.Player {
+		if isTransientCharacterField(field) {
+			continue
+		}
+		playerFields[field] = value
+	}
+	if export.Binding != "" {
+		playerFields["binding"] = export.Binding
+	}
+	rawFields := map[string]json.RawMessage{
+		"quests":       export.Quests,
+		"experience":   export.Experience,
+		"runes":        export.Runes,
+		"knownRecipes": export.KnownRecipes,
+	}
+	for _, field := range characterJSONFields {
+		if len(rawFields[field]) > 0 {
+			playerFields[field] = string(rawFields[field])
+		}
+	}
+	playerFields["entityType"] = string(EntityTypePlayer)
+	playerFields[PlayerFieldSchemaVersion] = export.SchemaVersion
+	playerFields["nextActionAt"] = time.Now().UnixMilli()
+	playerFields["isEcho"] = "false"
+	playerFields["echoState"] = string(EchoStateIdling)
+	playerFields["echoTarget"] = ""
+	playerFields["echoPath"] = ""
+
+	inventoryKey := string(RedisKeyPlayerInventory) + playerID
+	gearKey := string(RedisKeyPlayerGear) + playerID
+	bankKey := "bank:" + playerID
+
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, playerID, inventoryKey, gearKey, bankKey)
+	pipe.HSet(ctx, playerID, playerFields)
+	pipe.HSet(ctx, inventoryKey, characterContainerFields(export.Inventory, InventorySize))
+	pipe.HSet(ctx, bankKey, characterContainerFields(export.Bank, BankSize))
+	gearFields := map[string]interface{}{"weapon-slot": ""}
+	for slot, item := range export.Gear {
+		itemJSON, _ := json.Marshal(item)
+		gearFields[slot] = string(itemJSON)
+	}
+	pipe.HSet(ctx, gearKey, gearFields)
+	if replacedSecretKeyName != "" && replacedSecretKeyName != secretKeyName {
+		pipe.Del(ctx, replacedSecretKeyName)
+	}
+	if secretKeyName != "" {
+		pipe.Set(ctx, secretKeyName, playerID, 0)
+	}
+
+	for location, items := range replacedHoldings {
+		for itemID, quantity := range items {
+			RecordItemMovement(pipe, ItemAuditEntry{
+				PlayerID: playerID,
+				ItemID:   itemID,
+				Quantity: quantity,
+				Source:   location,
+				Dest:     ItemLocationNone,
+				Reason:   ItemAuditImportRemove,
+				Ref:      export.PlayerID,
+			})
+		}
+	}
+	for location, container := range map[ItemLocation]map[string]models.Item{
+		ItemLocationInventory: export.Inventory,
+		ItemLocationGear:      export.Gear,
+		ItemLocationBank:      export.Bank,
+	} {
+		for _, item := range container {
+			RecordItemMovement(pipe, ItemAuditEntry{
+				PlayerID: playerID,
+				ItemID:   ItemID(item.ID),
+				Quantity: item.Quantity,
+				Source:   ItemLocationNone,
+				Dest:     location,
+				Reason:   ItemAuditImport,
+				Ref:      export.PlayerID,
+			})
+		}
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+
+	if _, err := MigratePlayer(playerID); err != nil {
+		return playerID, fmt.Errorf("imported %s but migration failed: %v", playerID, err)
+	}
+
+	log.Printf("Imported character %s as %s.", export.PlayerID, playerID)
+	return playerID, nil
+}
+
+// characterContainerFields builds the hash fields for a slot container, writing an
+// empty string for every unused slot as InitializePlayer does.
+func characterContainerFields(items map[string]models.Item, size int) map[string]interface{} {
+	fields := make(map[string]interface{}, size)
+	for i := 0; i < size; i++ {
+		fields["slot_"+strconv.Itoa(i)] = ""
+	}
+	for slot, item := range items {
+		itemJSON, _ := json.Marshal(item)
+		fields[slot] = string(itemJSON)
+	}
+	return fields
+}
+
+func isTransientCharacterField(field string) bool {
+	for _, transient := range characterTransientFields {
+		if field == transient {
+			return true
+		}
+	}
+	return false
+}
+
+// findSecretKeyForPlayer returns the secret key that logs into playerID, or "" if the
+// player never registered. Secret keys are only indexed by key, so this scans.
+func findSecretKeyForPlayer(playerID string) (string, error) {
+	iter := rdb.Scan(ctx, 0, string(RedisKeySecretPrefix)+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		owner, err := rdb.Get(ctx, key).Result()
+		if err != nil {
+			continue
+		}
+		if owner == playerID {
+			return strings.TrimPrefix(key, string(RedisKeySecretPrefix)), nil
+		}
+	}
+	return "", iter.Err()
+}
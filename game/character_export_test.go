@@ -0,0 +1,106 @@
+package game
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// setUpTestRedis points the package at database 15 of the Redis at TEST_REDIS_ADDR, or
+// localhost:6379, emptying it before and after the test. The test is skipped if there
+// is no Redis to use.
+func setUpTestRedis(t *testing.T) {
+	t.Helper()
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr, DB: 15})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		t.Skipf("no Redis at %s: %v", addr, err)
+	}
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		client.Close()
+		t.Fatalf("could not empty the test database: %v", err)
+	}
+
+	previous := rdb
+	Init(client, func(string, []byte) {}, func(string) bool { return false })
+	t.Cleanup(func() {
+		client.FlushDB(ctx)
+		client.Close()
+		rdb = previous
+	})
+}
+
+// TestImportCharacterOverwriteSecret checks that overwriting a player moves its login
+// to the imported character's secret key, rather than leaving the old one working.
+func TestImportCharacterOverwriteSecret(t *testing.T) {
+	const playerID = "player:import-target"
+	tests := []struct {
+		name          string
+		secretKey     string
+		restoreSecret bool
+		oldLogsIn     bool
+		newLogsIn     bool
+	}{
+		{
+			name:          "restored secret replaces the old one",
+			secretKey:     "new-secret",
+			restoreSecret: true,
+			oldLogsIn:     false,
+			newLogsIn:     true,
+		},
+		{
+			name:          "restoring the same secret keeps it",
+			secretKey:     "old-secret",
+			restoreSecret: true,
+			oldLogsIn:     true,
+		},
+		{
+			name:          "no restored secret still drops the old one",
+			secretKey:     "new-secret",
+			restoreSecret: false,
+			oldLogsIn:     false,
+			newLogsIn:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setUpTestRedis(t)
+			rdb.HSet(ctx, playerID, "name", "Replaced", "x", 0, "y", 0)
+			rdb.Set(ctx, string(RedisKeySecretPrefix)+"old-secret", playerID, 0)
+
+			export := &CharacterExport{
+				FormatVersion: CharacterExportFormatVersion,
+				SchemaVersion: PlayerSchemaVersion,
+				PlayerID:      "player:exported",
+				Player:        map[string]string{"name": "Imported"},
+				SecretKey:     tt.secretKey,
+			}
+			opts := ImportCharacterOptions{TargetID: playerID, Overwrite: true, RestoreSecret: tt.restoreSecret}
+			if _, err := ImportCharacter(export, opts); err != nil {
+				t.Fatalf("import failed: %v", err)
+			}
+
+			logsIn := func(secretKey string) bool {
+				owner, _ := rdb.Get(ctx, string(RedisKeySecretPrefix)+secretKey).Result()
+				return owner == playerID
+			}
+			if got := logsIn("old-secret"); got != tt.oldLogsIn {
+				t.Errorf("old secret logs in = %v, want %v", got, tt.oldLogsIn)
+			}
+			if tt.secretKey != "old-secret" {
+				if got := logsIn(tt.secretKey); got != tt.newLogsIn {
+					t.Errorf("new secret logs in = %v, want %v", got, tt.newLogsIn)
+				}
+			}
+			if name, _ := rdb.HGet(ctx, playerID, "name").Result(); name != "Imported" {
+				t.Errorf("player name = %q, want the imported one", name)
+			}
+		})
+	}
+}
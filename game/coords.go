@@ -1,11 +1,16 @@
 package game
 
+// geoCoordScale is the tile coordinate mapped to the edge of Redis's geo ranges. The
+// world has no edge, so it is fixed rather than tied to WorldSize; tiles beyond it are
+// clamped.
+const geoCoordScale = 1 << 16
+
 // NormalizeCoords scales game coordinates to fit within Redis's valid geo ranges.
 func NormalizeCoords(x, y int) (float64, float64) {
 	// Longitude: [-180, 180]
 	// Latitude:  [-85.05, 85.05]
-	normalizedLon := (float64(x) / float64(WorldSize)) * 180.0
-	normalizedLat := (float64(y) / float64(WorldSize)) * 85.0
+	normalizedLon := (float64(x) / geoCoordScale) * 180.0
+	normalizedLat := (float64(y) / geoCoordScale) * 85.0
 
 	// Clamp values to be safe
 	if normalizedLon > 180.0 {
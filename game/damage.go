@@ -3,13 +3,10 @@ package game
 import (
 	"encoding/json"
 	"log"
-	"mmo-game/game/utils"
 	"mmo-game/models"
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
 func StartDamageSystem() {
@@ -22,48 +19,21 @@ func StartDamageSystem() {
 }
 
 func checkFires() {
-	// Use GEORADIUS to find only fire tiles, instead of scanning the whole world.
-	// We search from the center of the map with a radius large enough to cover everything.
-	const searchRadiusKm = 20000
-	query := &redis.GeoRadiusQuery{
-		Radius: searchRadiusKm,
-		Unit:   "km",
-	}
-	locations, err := rdb.GeoRadius(ctx, string(RedisKeyResourcePositions), 0, 0, query).Result()
-	if err != nil {
-		log.Printf("Failed to get resource locations for fire check: %v", err)
-		return
-	}
-
-	for _, loc := range locations {
-		// The member name is "tileType:x,y". We only care about fires.
-		if strings.HasPrefix(loc.Name, string(TileTypeFire)+":") {
-			coordKey := strings.Split(loc.Name, ":")[1]
-			x, y := utils.ParseCoordKey(coordKey)
-			checkForEntitiesOnFire(x, y)
-		}
+	// The resource index holds fires too, so only fire tiles are visited.
+	for _, fire := range ResourceIndex.All(string(TileTypeFire)) {
+		checkForEntitiesOnFire(fire.X, fire.Y)
 	}
 }
 
 func checkForEntitiesOnFire(x, y int) {
-	// Use a precise GEORADIUS query to find entities at the exact location of the fire.
-	// This is much more efficient than scanning all entities.
-	query := &redis.GeoRadiusQuery{
-		Radius: 50, // A large radius to cover the entire "degree" tile
-		Unit:   "km",
-	}
-
-	locations, err := rdb.GeoRadius(ctx, string(RedisKeyZone0Positions), float64(x), float64(y), query).Result()
-	if err != nil {
-		log.Printf("Error getting entities at fire location (%d, %d): %v", x, y, err)
-		return
-	}
-
-	for _, loc := range locations {
-		// loc.Name is the entity ID
-		entityData, err := rdb.HGetAll(ctx, loc.Name).Result()
-		if err == nil {
-			applyFireDamage(loc.Name, entityData, x, y)
+	// Find the entities standing exactly on the fire. Items lying in it have no health.
+	for _, entry := range EntityIndex.At(x, y, "") {
+		if entry.Kind == string(EntityTypeItem) {
+			continue
+		}
+		entityData, err := rdb.HGetAll(ctx, entry.ID).Result()
+		if err == nil && len(entityData) > 0 {
+			applyFireDamage(entry.ID, entityData, x, y)
 		}
 	}
 }
@@ -93,6 +63,7 @@ func applyFireDamage(entityID string, entityData map[string]string, x, y int) {
 		}
 	} else if strings.HasPrefix(entityID, "player:") {
 		interruptTeleport(entityID)
+		StopWalking(entityID)
 		// Also send a stats update to the player who was damaged
 		experience := make(map[models.Skill]float64)
 		experienceJSON, err := rdb.HGet(ctx, entityID, "experience").Result()
@@ -179,6 +150,7 @@ func ApplyDamage(attackerID, defenderID string, baseDamage int) {
 		}
 	} else if strings.HasPrefix(defenderID, "player:") {
 		interruptTeleport(defenderID)
+		StopWalking(defenderID)
 		h := newHealth
 		mh := PlayerDefs.MaxHealth
 		statsUpdateMsg := models.PlayerStatsUpdateMessage{
@@ -1,7 +1,6 @@
 package game
 
 import (
-	"encoding/json"
 	"log"
 	"math/rand"
 	"mmo-game/game/utils"
@@ -63,9 +62,8 @@ func handleDecay() {
 			if tile.Health <= 0 {
 				// Tile is destroyed
 				originalTileType := tile.Type
-				groundTile := models.WorldTile{Type: string(TileTypeGround), Health: 0}
-				newTileJSON, _ := json.Marshal(groundTile)
-				rdb.HSet(ctx, string(RedisKeyWorldZone0), coordKey, string(newTileJSON))
+				groundTile := models.WorldTile{Type: string(floorTileAt(x, y)), Health: 0}
+				SetWorldTile(rdb, x, y, groundTile)
 
 				worldUpdateMsg := models.WorldUpdateMessage{
 					Type: string(ServerEventWorldUpdate),
@@ -77,13 +75,12 @@ func handleDecay() {
 
 				if TileType(originalTileType) == TileTypeWoodenWall {
 					log.Printf("Wall at %s decayed, removing lock.", coordKey)
-					rdb.Del(ctx, string(RedisKeyLockTile)+coordKey)
+					UnlockTileForWorldObject(x, y)
 					rdb.SRem(ctx, string(RedisKeyActiveDecay), coordKey)
 				}
 			} else {
 				// Update tile in Redis
-				newTileJSON, _ := json.Marshal(tile)
-				rdb.HSet(ctx, string(RedisKeyWorldZone0), coordKey, string(newTileJSON))
+				SetWorldTile(rdb, x, y, *tile)
 			}
 		}
 	}
@@ -79,6 +79,75 @@ const (
 	// TileTypeSanctuaryStone is a permanent structure that marks a sanctuary zone.
 	// Players can bind to sanctuary stones for respawn and teleportation.
 	TileTypeSanctuaryStone TileType = "sanctuary_stone"
+	
+	// TileTypeGrass is the walkable forest floor.
+	TileTypeGrass TileType = "grass"
+	
+	// TileTypeMud is the walkable swamp floor.
+	TileTypeMud TileType = "mud"
+	
+	// TileTypeGravel is the walkable floor of the rocky highlands.
+	TileTypeGravel TileType = "gravel"
+	
+	// TileTypeSnow is the walkable tundra floor.
+	TileTypeSnow TileType = "snow"
+	
+	// TileTypeIce is frozen tundra water. Unlike water it carries no movement penalty.
+	TileTypeIce TileType = "ice"
+	
+	// TileTypePineTree is the tundra's tree, yielding wood like TileTypeTree.
+	TileTypePineTree TileType = "pine_tree"
+	
+	// TileTypeRoad is a road between sanctuaries, quicker to walk than the land around it.
+	TileTypeRoad TileType = "road"
+	
+	// TileTypeBridge carries a road over water.
+	TileTypeBridge TileType = "bridge"
+	
+	// TileTypeDungeonEntrance leads from the overworld into a dungeon instance.
+	TileTypeDungeonEntrance TileType = "dungeon_entrance"
+	
+	// TileTypeDungeonFloor is the walkable floor of a dungeon's rooms and corridors.
+	TileTypeDungeonFloor TileType = "dungeon_floor"
+	
+	// TileTypeDungeonWall is the solid rock around a dungeon's rooms and corridors.
+	TileTypeDungeonWall TileType = "dungeon_wall"
+	
+	// TileTypeDungeonExit leads from a dungeon back to the entrance it was entered by.
+	TileTypeDungeonExit TileType = "dungeon_exit"
+	
+	// TileTypeCliff is the sheer top of a step in the height map, which blocks movement and sight.
+	TileTypeCliff TileType = "cliff"
+	
+	// TileTypePortal leads to another designed zone; see ZonePortals.
+	TileTypePortal TileType = "portal"
+	
+	// TileTypeCaveFloor is the walkable floor of the caves.
+	TileTypeCaveFloor TileType = "cave_floor"
+	
+	// TileTypeCaveWall is the solid rock the caves are tunnelled through.
+	TileTypeCaveWall TileType = "cave_wall"
+	
+	// TileTypeEtherealGround is the walkable ground of the ethereal plane's islands.
+	TileTypeEtherealGround TileType = "ethereal_ground"
+	
+	// TileTypeRift is the void between the ethereal plane's islands, which cannot be crossed.
+	TileTypeRift TileType = "rift"
+	
+	// TileTypeSpiritTree is the ethereal plane's tree, yielding wood like TileTypeTree.
+	TileTypeSpiritTree TileType = "spirit_tree"
+	
+	// TileTypeRuins is a point of interest: a crumbled building that can be searched once.
+	TileTypeRuins TileType = "ruins"
+	
+	// TileTypeShrine is a point of interest: a forgotten shrine that can be searched once.
+	TileTypeShrine TileType = "shrine"
+	
+	// TileTypeAbandonedCamp is a point of interest: a deserted camp that can be searched once.
+	TileTypeAbandonedCamp TileType = "abandoned_camp"
+	
+	// TileTypeBuriedCache is a point of interest: a mound of loose earth that can be dug up.
+	TileTypeBuriedCache TileType = "buried_cache"
 )
 
 // ItemID defines the unique identifier for an item type in the game.
@@ -117,7 +186,7 @@ const (
 	// ItemSliceOfPizza is a rare food item that restores significant health.
 	ItemSliceOfPizza ItemID = "slice_of_pizza"
 	
-	// ItemTreasureMap is a special item used for quests or future features.
+	// ItemTreasureMap marks a dig site when read; digging there finds buried treasure.
 	ItemTreasureMap ItemID = "treasure_map"
 	
 	// ItemFire is a placeable item that creates a fire tile.
@@ -148,6 +217,9 @@ const (
 	
 	// ItemKindRecipe represents an item that teaches a crafting recipe when used.
 	ItemKindRecipe ItemKind = "recipe"
+	
+	// ItemKindTreasureMap represents an item that leads to a dig site when read.
+	ItemKindTreasureMap ItemKind = "treasure_map"
 )
 
 // ItemProperties defines the static properties of an item type.
@@ -157,6 +229,9 @@ type ItemProperties struct {
 	Equippable *EquippableProperties
 	Kind       ItemKind
 	RecipeID   ItemID
+	// DespawnTime is how long, in milliseconds, a dropped pile of this item stays in
+	// the world. 0 uses DefaultItemDespawnTime.
+	DespawnTime int64
 }
 
 // EquippableProperties defines properties for items that can be equipped.
@@ -223,6 +298,12 @@ const (
 	// ClientEventFindPath is sent when a player requests pathfinding.
 	ClientEventFindPath ClientEventType = "find-path"
 	
+	// ClientEventWalkTo is sent when a player wants the server to walk them to a tile.
+	ClientEventWalkTo ClientEventType = "walk_to"
+	
+	// ClientEventFollow is sent when a player wants to follow another entity, or stop following.
+	ClientEventFollow ClientEventType = "follow"
+	
 	// ClientEventDepositItem is sent when a player deposits an item in the bank.
 	ClientEventDepositItem ClientEventType = "deposit_item"
 	
@@ -231,6 +312,12 @@ const (
 	
 	// ClientEventReorderItem is sent when a player reorders items in inventory or bank.
 	ClientEventReorderItem ClientEventType = "reorder_item"
+	
+	// ClientEventReadMap is sent when a player reads a treasure map.
+	ClientEventReadMap ClientEventType = "read_map"
+	
+	// ClientEventDig is sent when a player digs at a tile.
+	ClientEventDig ClientEventType = "dig"
 )
 
 // ServerEventType defines outgoing WebSocket message types sent to clients.
@@ -248,6 +335,12 @@ const (
 	// ServerEventWorldUpdate is broadcast when a tile in the world changes.
 	ServerEventWorldUpdate ServerEventType = "world_update"
 	
+	// ServerEventWorldChunk is sent to a player when a chunk of the world comes into view.
+	ServerEventWorldChunk ServerEventType = "world_chunk"
+	
+	// ServerEventMinimap is sent to a player with the minimap of chunks they discovered.
+	ServerEventMinimap ServerEventType = "minimap"
+	
 	// ServerEventInventoryUpdate is sent to a player when their inventory changes.
 	ServerEventInventoryUpdate ServerEventType = "inventory_update"
 	
@@ -320,8 +413,17 @@ const (
 	// ServerEventValidPath is sent to a player when pathfinding finds a valid path.
 	ServerEventValidPath ServerEventType = "valid-path"
 	
+	// ServerEventWalkPath is sent to a player when the server starts walking them along a path, or re-paths.
+	ServerEventWalkPath ServerEventType = "walk_path"
+	
+	// ServerEventWalkEnded is sent to a player when their server-driven walk finishes or stops.
+	ServerEventWalkEnded ServerEventType = "walk_ended"
+	
 	// ServerEventOpenBankWindow is sent to a player to open the bank interface.
 	ServerEventOpenBankWindow ServerEventType = "open_bank_window"
+	
+	// ServerEventDigSite is sent to a player with the dig site their treasure map marks.
+	ServerEventDigSite ServerEventType = "dig_site"
 )
 
 // MoveDirection defines the valid movement directions for entities.
@@ -340,6 +442,18 @@ const (
 	
 	// MoveDirectionRight moves the entity one tile right (positive X).
 	MoveDirectionRight MoveDirection = "right"
+	
+	// MoveDirectionUpLeft moves the entity one tile diagonally up and left.
+	MoveDirectionUpLeft MoveDirection = "up-left"
+	
+	// MoveDirectionUpRight moves the entity one tile diagonally up and right.
+	MoveDirectionUpRight MoveDirection = "up-right"
+	
+	// MoveDirectionDownLeft moves the entity one tile diagonally down and left.
+	MoveDirectionDownLeft MoveDirection = "down-left"
+	
+	// MoveDirectionDownRight moves the entity one tile diagonally down and right.
+	MoveDirectionDownRight MoveDirection = "down-right"
 )
 
 // RedisKey defines the prefixes and keys used in Redis for storing game state.
@@ -355,6 +469,18 @@ const (
 	// RedisKeyLockWorldObject is the value used when locking a tile for world objects.
 	RedisKeyLockWorldObject RedisKey = "lock:world"
 	
+	// RedisKeyLockIndex is a hash of every tile lock, "x,y" -> owner, so locks can be
+	// listed without scanning the keyspace.
+	RedisKeyLockIndex RedisKey = "locks:tiles"
+	
+	// RedisKeyLockOwnerPrefix is the prefix for the set of "x,y" tiles an owner has locked
+	// (format: "locks:owner:npc:slime:uuid").
+	RedisKeyLockOwnerPrefix RedisKey = "locks:owner:"
+	
+	// RedisKeyLockLeases is a sorted set of entity-held tiles scored by the Unix millisecond
+	// time their lease runs out. World object locks have no lease.
+	RedisKeyLockLeases RedisKey = "locks:leases"
+	
 	// RedisKeyPlayerPrefix is the prefix for player entity keys (format: "player:uuid").
 	RedisKeyPlayerPrefix RedisKey = "player:"
 	
@@ -364,18 +490,82 @@ const (
 	// RedisKeyPlayerGear is the prefix for player gear keys (format: "gear:player:uuid").
 	RedisKeyPlayerGear RedisKey = "gear:"
 	
+	// RedisKeyPlayerDiscovered is the prefix for the set of "cx,cy" overworld chunks a
+	// player has seen, which their minimap shows (format: "discovered:player:uuid").
+	RedisKeyPlayerDiscovered RedisKey = "discovered:"
+	
 	// RedisKeyZone0Positions is the Redis geospatial key for entity positions in zone 0.
 	// Used for efficient spatial queries to find entities near a location.
 	RedisKeyZone0Positions RedisKey = "positions:zone:0"
 	
+	// RedisKeyZonePositionsPrefix is the prefix for the geospatial key of entity positions
+	// in a zone (format: "positions:zone:caves" or "positions:zone:dungeon:3"). Zone 0 is
+	// RedisKeyZone0Positions.
+	RedisKeyZonePositionsPrefix RedisKey = "positions:zone:"
+	
 	// RedisKeyResourcePositions is the Redis geospatial key for resource tile positions.
 	// Used for efficient spatial queries to find resources near a location.
 	RedisKeyResourcePositions RedisKey = "positions:resource"
 	
-	// RedisKeyWorldZone0 is the Redis hash key for world tile data in zone 0.
+	// RedisKeySpatialUpdates is the pub/sub channel on which server nodes announce changes
+	// to entity and resource positions to each other, keeping their spatial indexes in sync.
+	RedisKeySpatialUpdates RedisKey = "positions:updates"
+	
+	// RedisKeyWorldZone0 is the legacy Redis hash of world tile data in zone 0.
 	// Format: "world:zone:0" with field keys like "x,y" containing tile JSON.
+	// Worlds stored this way are migrated to chunks on startup; see MigrateLegacyWorldHash.
 	RedisKeyWorldZone0 RedisKey = "world:zone:0"
 	
+	// RedisKeyWorldChunkPrefix is the prefix for binary tile chunks in zone 0.
+	// Format: "world:zone:0:chunk:{cx},{cy}"; see world_chunks.go for the layout.
+	RedisKeyWorldChunkPrefix RedisKey = "world:zone:0:chunk:"
+	
+	// RedisKeyWorldZonePrefix is the prefix for the stored tiles of a zone: its chunks
+	// (format: "world:zone:caves:chunk:{cx},{cy}") and the set of them
+	// ("world:zone:caves:chunks"). Zone 0's are RedisKeyWorldChunkPrefix and
+	// RedisKeyWorldChunks.
+	RedisKeyWorldZonePrefix RedisKey = "world:zone:"
+	
+	// RedisKeyWorldFormat records the storage format of the zone 0 tiles.
+	RedisKeyWorldFormat RedisKey = "world:zone:0:format"
+	
+	// RedisKeyWorldTileUpdates is the pub/sub channel on which server nodes announce
+	// tile changes to each other, keeping their chunk caches and collision grids in sync.
+	RedisKeyWorldTileUpdates RedisKey = "world:zone:0:tile_updates"
+	
+	// RedisKeySanctuaries holds the JSON list of sanctuaries in the stored world.
+	RedisKeySanctuaries RedisKey = "world:sanctuaries"
+	
+	// RedisKeyWorldChunks is the set of "cx,cy" chunks that are stored. Chunks that are not
+	// stored are generated from the seed whenever they are needed.
+	RedisKeyWorldChunks RedisKey = "world:zone:0:chunks"
+	
+	// RedisKeyWorldStats is a hash of counts over the stored chunks, added to as each chunk
+	// is stored: "area:<biome>" is the number of tiles in a biome and
+	// "potential:<biome>:<tileType>" the number of potential spawn points of a resource.
+	RedisKeyWorldStats RedisKey = "world:zone:0:stats"
+	
+	// RedisKeyWorldGenConfig holds the JSON WorldGenConfig the world was generated from.
+	RedisKeyWorldGenConfig RedisKey = "world:zone:0:gen"
+	
+	// RedisKeyDungeonPrefix is the prefix for the zone ID of a dungeon instance (format:
+	// "dungeon:3"), which is also the key of a hash describing the instance.
+	RedisKeyDungeonPrefix RedisKey = "dungeon:"
+	
+	// RedisKeyDungeonSlots counts the dungeon instances ever created; each new instance
+	// takes the next number as its slot in the dungeon region of the map.
+	RedisKeyDungeonSlots RedisKey = "dungeons:slots"
+	
+	// RedisKeyDungeons is the set of zone IDs of the live dungeon instances.
+	RedisKeyDungeons RedisKey = "dungeons:live"
+	
+	// RedisKeyDungeonEntrances is a hash of dungeon entrance "x,y" -> zone ID of the
+	// instance a party entering there joins.
+	RedisKeyDungeonEntrances RedisKey = "dungeons:entrances"
+	
+	// RedisKeySearchedPOIs is the set of "x,y" points of interest that have been searched.
+	RedisKeySearchedPOIs RedisKey = "pois:searched"
+	
 	// RedisKeyActiveDecay is the Redis set key containing coordinates of tiles that are actively decaying.
 	// Format: set of "x,y" strings. Used to efficiently find tiles that need decay processing.
 	RedisKeyActiveDecay RedisKey = "active_decay"
@@ -402,10 +592,52 @@ const (
 	// GroupTargetPrefix is the prefix for group targeting keys (format: "group:target:entityId").
 	// Used for tracking which entities are being targeted by groups of players.
 	GroupTargetPrefix RedisKey = "group:target:"
+	
+	// RedisKeyItemAudit is the global append-only stream of every item creation,
+	// destruction and transfer. Each entry is an ItemAuditEntry.
+	RedisKeyItemAudit RedisKey = "audit:items"
+	
+	// RedisKeyItemAuditPlayerPrefix is the prefix for per-player item audit streams
+	// (format: "audit:items:player:uuid"). Holds the same entries as the global
+	// stream, filtered to a single player, so history queries stay cheap.
+	RedisKeyItemAuditPlayerPrefix RedisKey = "audit:items:"
+	
+	// RedisKeyItemPiles is a hash mapping "x,y|itemId|owner" to the world item ID of the
+	// pile on that tile, so drops of the same item merge into one entity.
+	RedisKeyItemPiles RedisKey = "items:piles"
+	
+	// RedisKeyJobsDue is a sorted set of scheduled job keys scored by the Unix
+	// millisecond time at which they should run.
+	RedisKeyJobsDue RedisKey = "jobs:due"
+	
+	// RedisKeyJobsClaimed is a sorted set of running job keys scored by the time the
+	// claiming node's lease on them runs out.
+	RedisKeyJobsClaimed RedisKey = "jobs:claimed"
+	
+	// RedisKeyJobsData is a hash of job key -> job JSON for scheduled and running jobs.
+	RedisKeyJobsData RedisKey = "jobs:data"
 )
 
 // --- END NEW CONSTANTS ---
 
+// JobType identifies a kind of scheduled job. Each job type has a JobHandler
+// registered in the JobRegistry.
+type JobType string
+
+const (
+	// JobCompleteTeleport finishes a teleport channel. Key: player ID.
+	JobCompleteTeleport JobType = "complete_teleport"
+	
+	// JobExpireFire turns a fire tile back into ground. Key: "x,y".
+	JobExpireFire JobType = "expire_fire"
+	
+	// JobItemPublic lets anyone pick up an owned world item. Key: item entity ID.
+	JobItemPublic JobType = "item_public"
+	
+	// JobItemDespawn removes a world item pile. Key: item entity ID.
+	JobItemDespawn JobType = "item_despawn"
+)
+
 // Recipe defines the ingredients required to craft an item.
 type Recipe struct {
 	Ingredients   map[ItemID]int // Use new type
@@ -433,6 +665,17 @@ type TileProperties struct {
 	Decays               bool
 	DecayChancePerSecond float64
 	DecayAmount          int
+
+	// Road properties: NPCs seldom spawn on roads, and MoveCooldownFactor, if set, scales
+	// the cooldown of a step onto the tile in place of its biome's factor.
+	IsRoad             bool
+	MoveCooldownFactor float64
+
+	// IsStructure marks the tiles players build, which minimaps and world maps show.
+	IsStructure bool
+
+	// BlocksSight marks tiles that cannot be seen past; see hasLineOfSight.
+	BlocksSight bool
 }
 
 // NPCType defines the types of NPCs that can exist in the game world.
@@ -493,14 +736,16 @@ type Sanctuary struct {
 	X, Y, Radius int
 }
 
+// startingSanctuary is where new players appear. It is the first of Sanctuaries.
+var startingSanctuary = Sanctuary{X: 0, Y: 1, Radius: 8}
+
+// Sanctuaries lists the sanctuaries of the stored world; more are added as the world is
+// explored. Read it with knownSanctuaries.
 var Sanctuaries []Sanctuary
-var ResourceTargets map[TileType]int
 
-var resourceFillPercentage = map[TileType]float64{
-	TileTypeTree:     0.9,
-	TileTypeRock:     0.65,
-	TileTypeIronRock: 0.5,
-}
+// ResourceTargets is how many of each resource the resource spawner keeps in each biome.
+// Fill percentages are set per biome by BiomeProperties.ResourceFill.
+var ResourceTargets map[BiomeType]map[TileType]int
 
 // --- END NEW ---
 
@@ -539,9 +784,7 @@ func init() {
 	PlayerDefs = PlayerProperties{
 		MaxHealth: 10,
 	}
-	Sanctuaries = []Sanctuary{
-		{X: 0, Y: 1, Radius: 8}, // Starting sanctuary at origin
-	}
+	Sanctuaries = []Sanctuary{startingSanctuary}
 	// --- END NEW ---
 
 	// --- NPC Definitions ---
@@ -623,10 +866,11 @@ func init() {
 		MaxStack:  50,
 	}
 	ItemDefs[ItemRecipeIronHelmet] = ItemProperties{
-		Stackable: false,
-		MaxStack:  1,
-		Kind:      ItemKindRecipe,
-		RecipeID:  ItemIronHelmet,
+		Stackable:   false,
+		MaxStack:    1,
+		Kind:        ItemKindRecipe,
+		RecipeID:    ItemIronHelmet,
+		DespawnTime: 600000, // Rare drop, so it lingers for 10 minutes
 	}
 	ItemDefs[ItemIronHelmet] = ItemProperties{
 		Stackable: false,
@@ -635,10 +879,12 @@ func init() {
 			Slot:    "head-slot",
 			Defense: 1,
 		},
+		DespawnTime: 600000,
 	}
 	ItemDefs[ItemRatMeat] = ItemProperties{
-		Stackable: true,
-		MaxStack:  50,
+		Stackable:   true,
+		MaxStack:    50,
+		DespawnTime: 120000, // Common drop, cleared quickly
 	}
 	ItemDefs[ItemCookedRatMeat] = ItemProperties{
 		Stackable: true,
@@ -649,8 +895,10 @@ func init() {
 		MaxStack:  10,
 	}
 	ItemDefs[ItemTreasureMap] = ItemProperties{
-		Stackable: false,
-		MaxStack:  1,
+		Stackable:   false,
+		MaxStack:    1,
+		Kind:        ItemKindTreasureMap,
+		DespawnTime: 600000,
 	}
 	ItemDefs[ItemFire] = ItemProperties{
 		Stackable: true,
@@ -689,6 +937,7 @@ func init() {
 		GatherSkill:    models.SkillMining,
 		GatherXP:       10,
 		MaxHealth:      4,
+		BlocksSight:    true,
 	}
 	TileDefs[TileTypeIronRock] = TileProperties{
 		IsCollidable:   true,
@@ -697,6 +946,7 @@ func init() {
 		GatherSkill:    models.SkillMining,
 		GatherXP:       20,
 		MaxHealth:      8,
+		BlocksSight:    true,
 	}
 	TileDefs[TileTypeWoodenWall] = TileProperties{
 		IsCollidable:         true,
@@ -705,6 +955,8 @@ func init() {
 		Decays:               true,
 		DecayChancePerSecond: 1.0 / (90.0 * 60.0 / 10.0), // ~1.5 hour lifetime for 10HP
 		DecayAmount:          1,
+		IsStructure:          true,
+		BlocksSight:          true,
 	}
 	TileDefs[TileTypeFire] = TileProperties{
 		IsCollidable:   false,
@@ -712,12 +964,132 @@ func init() {
 		Damage:         1,
 		DamageInterval: 1000,   // 1 second
 		Duration:       120000, // 2 minutes
+		IsStructure:    true,
 	}
 	TileDefs[TileTypeSanctuaryStone] = TileProperties{
 		IsCollidable:   true,
 		IsBuildableOn:  false,
 		IsDestructible: false,
 	}
+	TileDefs[TileTypeGrass] = TileProperties{
+		IsCollidable:  false,
+		IsBuildableOn: true,
+	}
+	TileDefs[TileTypeMud] = TileProperties{
+		IsCollidable:  false,
+		IsBuildableOn: false,
+	}
+	TileDefs[TileTypeGravel] = TileProperties{
+		IsCollidable:  false,
+		IsBuildableOn: true,
+	}
+	TileDefs[TileTypeSnow] = TileProperties{
+		IsCollidable:  false,
+		IsBuildableOn: true,
+	}
+	TileDefs[TileTypeIce] = TileProperties{
+		IsCollidable:  false,
+		IsBuildableOn: false,
+	}
+	TileDefs[TileTypePineTree] = TileProperties{
+		IsCollidable:   true,
+		IsGatherable:   true,
+		GatherResource: ItemWood,
+		GatherSkill:    models.SkillWoodcutting,
+		GatherXP:       15,
+		MaxHealth:      3,
+	}
+	TileDefs[TileTypeRoad] = TileProperties{
+		IsCollidable:       false,
+		IsBuildableOn:      false,
+		IsRoad:             true,
+		MoveCooldownFactor: 0.75,
+	}
+	TileDefs[TileTypeBridge] = TileProperties{
+		IsCollidable:       false,
+		IsBuildableOn:      false,
+		IsRoad:             true,
+		MoveCooldownFactor: 0.75,
+	}
+	TileDefs[TileTypeDungeonEntrance] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+	}
+	TileDefs[TileTypeDungeonFloor] = TileProperties{
+		IsCollidable:       false,
+		IsBuildableOn:      false,
+		MoveCooldownFactor: 1,
+	}
+	TileDefs[TileTypeDungeonWall] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+		BlocksSight:    true,
+	}
+	TileDefs[TileTypeDungeonExit] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+	}
+	TileDefs[TileTypeCliff] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+		BlocksSight:    true,
+	}
+	TileDefs[TileTypePortal] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+	}
+	TileDefs[TileTypeCaveFloor] = TileProperties{
+		IsCollidable:  false,
+		IsBuildableOn: true,
+	}
+	TileDefs[TileTypeCaveWall] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+		BlocksSight:    true,
+	}
+	TileDefs[TileTypeEtherealGround] = TileProperties{
+		IsCollidable:  false,
+		IsBuildableOn: true,
+	}
+	TileDefs[TileTypeRift] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+	}
+	TileDefs[TileTypeSpiritTree] = TileProperties{
+		IsCollidable:   true,
+		IsGatherable:   true,
+		GatherResource: ItemWood,
+		GatherSkill:    models.SkillWoodcutting,
+		GatherXP:       20,
+		MaxHealth:      4,
+	}
+	TileDefs[TileTypeRuins] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+		BlocksSight:    true,
+	}
+	TileDefs[TileTypeShrine] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+	}
+	TileDefs[TileTypeAbandonedCamp] = TileProperties{
+		IsCollidable:   true,
+		IsBuildableOn:  false,
+		IsDestructible: false,
+	}
+	TileDefs[TileTypeBuriedCache] = TileProperties{
+		IsCollidable:  false,
+		IsBuildableOn: false,
+	}
 
 	// --- Recipe Definitions (USING CONSTANTS) ---
 	RecipeDefs[ItemWoodenWall] = Recipe{
@@ -79,7 +79,17 @@ func HandleWizardDialogAction(playerID string, action string) *models.DialogMess
 
 		// Take quest item if specified
 		if turnInAction.ItemToTake != "" {
-			RemoveItemFromInventory(playerID, turnInAction.ItemToTake, turnInAction.ItemToTakeQuantity)
+			if _, err := RemoveItemFromInventory(playerID, turnInAction.ItemToTake, turnInAction.ItemToTakeQuantity); err == nil {
+				RecordItemMovement(rdb, ItemAuditEntry{
+					PlayerID: playerID,
+					ItemID:   turnInAction.ItemToTake,
+					Quantity: turnInAction.ItemToTakeQuantity,
+					Source:   ItemLocationInventory,
+					Dest:     ItemLocationNone,
+					Reason:   ItemAuditQuestTurnIn,
+					Ref:      string(turnInAction.QuestID),
+				})
+			}
 		}
 
 		// Give reward
@@ -90,6 +100,16 @@ func HandleWizardDialogAction(playerID string, action string) *models.DialogMess
 			if err != nil && strings.Contains(err.Error(), "inventory full") {
 				notification := CreateNotificationMessage("Your inventory is full.")
 				SendPrivately(playerID, notification)
+			} else if err == nil {
+				RecordItemMovement(rdb, ItemAuditEntry{
+					PlayerID: playerID,
+					ItemID:   turnInAction.RewardItem,
+					Quantity: turnInAction.RewardQuantity,
+					Source:   ItemLocationNone,
+					Dest:     ItemLocationInventory,
+					Reason:   ItemAuditQuestReward,
+					Ref:      string(turnInAction.QuestID),
+				})
 			}
 		}
 
@@ -0,0 +1,347 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"mmo-game/game/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// A dungeon instance is made when a player enters a dungeon entrance that has none, and
+// everyone else entering there joins it, so a party can follow its leader in one at a
+// time. The instance is laid out from a seed that belongs to its entrance, filled with
+// monsters, and torn down again by the AI loop once no player is left in it.
+const (
+	// dungeonTilesPerMonster is how many tiles of room floor there are for each monster,
+	// far denser than the overworld's spawns.
+	dungeonTilesPerMonster = 15
+	// dungeonTeardownGrace is how long a new instance is kept while it is still empty, so
+	// the player who made it has time to arrive.
+	dungeonTeardownGrace = 30 * time.Second
+)
+
+// dungeonInstance is a live dungeon, as described by the hash at its zone ID.
+type dungeonInstance struct {
+	Zone                 ZoneID
+	Seed                 int64
+	EntranceX, EntranceY int
+	CreatedAt            time.Time
+	Layout               *dungeonLayout
+}
+
+// origin returns the map coordinates of the north-west corner of the instance's layout.
+func (d *dungeonInstance) origin() (int, int) {
+	slot, _ := dungeonSlot(d.Zone)
+	return slot * dungeonSpacing, dungeonOriginY
+}
+
+// dungeons caches the live instances this node has seen, so their layouts are only
+// generated once.
+var (
+	dungeons   = make(map[ZoneID]*dungeonInstance)
+	dungeonsMu sync.Mutex
+)
+
+// dungeonFor returns a live dungeon instance, or nil if there is none in the zone. An
+// instance is live while it is in RedisKeyDungeons; one being torn down is not, though
+// its keys may not all be gone yet.
+func dungeonFor(zone ZoneID) *dungeonInstance {
+	live, err := rdb.SIsMember(ctx, string(RedisKeyDungeons), string(zone)).Result()
+	if err != nil {
+		log.Printf("Failed to check dungeon %s: %v", zone, err)
+		return nil
+	}
+	if !live {
+		forgetDungeon(zone)
+		return nil
+	}
+
+	dungeonsMu.Lock()
+	instance, ok := dungeons[zone]
+	dungeonsMu.Unlock()
+	if ok {
+		return instance
+	}
+
+	data, err := rdb.HGetAll(ctx, string(zone)).Result()
+	if err != nil {
+		log.Printf("Failed to read dungeon %s: %v", zone, err)
+		return nil
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	instance = &dungeonInstance{Zone: zone}
+	instance.Seed, _ = strconv.ParseInt(data["seed"], 10, 64)
+	instance.EntranceX, _ = strconv.Atoi(data["entranceX"])
+	instance.EntranceY, _ = strconv.Atoi(data["entranceY"])
+	createdAt, _ := strconv.ParseInt(data["createdAt"], 10, 64)
+	instance.CreatedAt = time.UnixMilli(createdAt)
+	instance.Layout = generateDungeon(instance.Seed)
+
+	dungeonsMu.Lock()
+	dungeons[zone] = instance
+	dungeonsMu.Unlock()
+	return instance
+}
+
+// forgetDungeon drops a torn down instance from the cache.
+func forgetDungeon(zone ZoneID) {
+	dungeonsMu.Lock()
+	delete(dungeons, zone)
+	dungeonsMu.Unlock()
+}
+
+// dungeonAtEntrance returns the live instance entered at a dungeon entrance, making one
+// if there is none.
+func dungeonAtEntrance(x, y int) (*dungeonInstance, error) {
+	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
+	zone, err := rdb.HGet(ctx, string(RedisKeyDungeonEntrances), coordKey).Result()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if zone != "" {
+		if instance := dungeonFor(ZoneID(zone)); instance != nil {
+			return instance, nil
+		}
+	}
+
+	slot, err := rdb.Incr(ctx, string(RedisKeyDungeonSlots)).Result()
+	if err != nil {
+		return nil, err
+	}
+	instance := &dungeonInstance{
+		Zone:      dungeonZone(int(slot)),
+		Seed:      worldGen.dungeonSeed(x, y),
+		EntranceX: x,
+		EntranceY: y,
+		CreatedAt: time.Now(),
+	}
+	err = rdb.HSet(ctx, string(instance.Zone),
+		"seed", instance.Seed,
+		"entranceX", x,
+		"entranceY", y,
+		"createdAt", instance.CreatedAt.UnixMilli(),
+	).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	// Someone entering at the same time may have made an instance first; join theirs.
+	claimed, err := claimDungeonEntranceScript.Run(ctx, rdb,
+		[]string{string(RedisKeyDungeonEntrances), string(RedisKeyDungeons)},
+		coordKey, string(instance.Zone)).Text()
+	if err != nil || claimed != string(instance.Zone) {
+		rdb.Del(ctx, string(instance.Zone))
+		if err != nil {
+			return nil, err
+		}
+		if existing := dungeonFor(ZoneID(claimed)); existing != nil {
+			return existing, nil
+		}
+		return nil, fmt.Errorf("dungeon %s at %s is gone", claimed, coordKey)
+	}
+	instance.Layout = generateDungeon(instance.Seed)
+	dungeonsMu.Lock()
+	dungeons[instance.Zone] = instance
+	dungeonsMu.Unlock()
+
+	log.Printf("Created dungeon %s for the entrance at %s.", instance.Zone, coordKey)
+	populateDungeon(instance)
+	return instance, nil
+}
+
+// populateDungeon spawns the monsters of a new instance: a pack in every room but the
+// first, and a slime boss with its slimes in the boss room.
+func populateDungeon(d *dungeonInstance) {
+	originX, originY := d.origin()
+	monsters := []NPCType{NPCTypeSlime, NPCTypeRat}
+	for i, room := range d.Layout.Rooms {
+		if i == 0 {
+			continue // The party arrives here
+		}
+		if i == d.Layout.Boss {
+			c := room.center()
+			spawnSlimeBossAt(originX+c.X, originY+c.Y)
+			continue
+		}
+		for n := room.W * room.H / dungeonTilesPerMonster; n > 0; n-- {
+			x := originX + room.X + rand.Intn(room.W)
+			y := originY + room.Y + rand.Intn(room.H)
+			if !isTileAvailable(x, y) {
+				continue
+			}
+			npcType := monsters[rand.Intn(len(monsters))]
+			entityID := string(npcIDPrefixes[npcType]) + utils.GenerateUniqueID()
+			spawnNPC(entityID, x, y, npcType, "", x, y, min(NPCDefs[npcType].WanderDistance, min(room.W, room.H)/2))
+		}
+	}
+}
+
+// enterDungeon moves a player through a dungeon entrance into its instance, beside the
+// exit.
+func enterDungeon(playerID string, playerData map[string]string, entranceX, entranceY int) {
+	instance, err := dungeonAtEntrance(entranceX, entranceY)
+	if err != nil {
+		log.Printf("Failed to open the dungeon at %d,%d for %s: %v", entranceX, entranceY, playerID, err)
+		sendNotification(playerID, "The way down is blocked.")
+		return
+	}
+	originX, originY := instance.origin()
+	destX, destY := findNearbyOpenTile(originX+instance.Layout.Exit.X, originY+instance.Layout.Exit.Y, 3)
+	movePlayerToZone(playerID, playerData, destX, destY)
+	sendNotification(playerID, "You descend into the dungeon.")
+}
+
+// leaveDungeon moves a player out of the dungeon they are in, back beside its entrance.
+func leaveDungeon(playerID string, playerData map[string]string) {
+	x, y := GetEntityPosition(playerData)
+	instance := dungeonFor(zoneAt(x, y))
+	if instance == nil {
+		return
+	}
+	destX, destY := findNearbyOpenTile(instance.EntranceX, instance.EntranceY, 3)
+	movePlayerToZone(playerID, playerData, destX, destY)
+	sendNotification(playerID, "You climb back out of the dungeon.")
+}
+
+// movePlayerToZone moves a player straight to a tile in another zone. visitWorld sends
+// them the state of the zone they arrive in.
+func movePlayerToZone(playerID string, playerData map[string]string, destX, destY int) {
+	StopWalking(playerID)
+	oldX, oldY := GetEntityPosition(playerData)
+	UnlockTileForEntity(playerID, oldX, oldY)
+	LockTileForEntity(playerID, destX, destY)
+
+	rdb.HSet(ctx, playerID, "x", destX, "y", destY)
+	setEntityPosition(rdb, playerID, destX, destY)
+
+	// Players in the new zone have not seen them yet; clients treat this as an upsert.
+	gear, _ := GetGear(playerID)
+	PublishUpdate(map[string]interface{}{
+		"type":       string(ServerEventEntityJoined),
+		"entityId":   playerID,
+		"x":          destX,
+		"y":          destY,
+		"entityType": string(EntityTypePlayer),
+		"name":       playerData["name"],
+		"shirtColor": playerData["shirtColor"],
+		"gear":       gear,
+	})
+	visitWorld(playerID, oldX, oldY, destX, destY)
+}
+
+// interactDungeonTile handles a player interacting with a dungeon entrance or exit. It
+// reports whether the tile was one.
+func interactDungeonTile(playerID string, playerData map[string]string, tileType TileType, x, y int) bool {
+	switch tileType {
+	case TileTypeDungeonEntrance:
+		enterDungeon(playerID, playerData, x, y)
+	case TileTypeDungeonExit:
+		leaveDungeon(playerID, playerData)
+	default:
+		return false
+	}
+	return true
+}
+
+// dungeonAbandoned reports whether a dungeon's AI tick found no player in it, and the
+// instance is old enough to be torn down. Echoes do not keep it open; they are moved
+// out with it.
+func dungeonAbandoned(zone ZoneID, cache *TickCache) bool {
+	for entityID, data := range cache.EntityData {
+		if strings.HasPrefix(entityID, string(RedisKeyPlayerPrefix)) && !playerBool(data, "isEcho") {
+			return false
+		}
+	}
+	instance := dungeonFor(zone)
+	return instance == nil || time.Since(instance.CreatedAt) > dungeonTeardownGrace
+}
+
+// teardownDungeon removes an empty dungeon instance: its monsters and items, its stored
+// tiles and every key describing it. Echoes, and players who entered as it was being
+// torn down, are moved back out beside its entrance. Only the node that takes it off
+// the live set tears it down.
+func teardownDungeon(zone ZoneID) {
+	instance := dungeonFor(zone)
+	if instance == nil {
+		return
+	}
+	coordKey := strconv.Itoa(instance.EntranceX) + "," + strconv.Itoa(instance.EntranceY)
+	released, err := releaseDungeonEntranceScript.Run(ctx, rdb,
+		[]string{string(RedisKeyDungeonEntrances), string(RedisKeyDungeons)},
+		coordKey, string(zone)).Int()
+	if err != nil {
+		log.Printf("Failed to release dungeon %s: %v", zone, err)
+		return
+	}
+	if released == 0 {
+		return
+	}
+	// Forgotten before anything is deleted, so no one can enter it any more.
+	forgetDungeon(zone)
+	log.Printf("Tearing down empty dungeon %s.", zone)
+
+	members, err := rdb.ZRange(ctx, zonePositionsKey(zone), 0, -1).Result()
+	if err != nil {
+		log.Printf("Failed to list the entities of dungeon %s: %v", zone, err)
+	}
+	for _, entityID := range members {
+		if strings.HasPrefix(entityID, string(RedisKeyPlayerPrefix)) {
+			if playerData, err := rdb.HGetAll(ctx, entityID).Result(); err == nil && len(playerData) > 0 {
+				destX, destY := findNearbyOpenTile(instance.EntranceX, instance.EntranceY, 3)
+				movePlayerToZone(entityID, playerData, destX, destY)
+				sendNotification(entityID, "The dungeon collapses behind you.")
+				continue
+			}
+		}
+		if strings.HasPrefix(entityID, string(ItemPrefix)) {
+			data, ok := claimWorldItem(entityID, 0)
+			if ok {
+				item := worldItemFromData(entityID, data)
+				RecordItemMovement(rdb, ItemAuditEntry{
+					ItemID:   item.ItemID,
+					Quantity: item.Quantity,
+					Source:   ItemLocationWorld,
+					Dest:     ItemLocationNone,
+					Reason:   ItemAuditDespawn,
+					Ref:      entityID,
+				})
+			}
+		} else {
+			if position, err := rdb.HMGet(ctx, entityID, "x", "y").Result(); err == nil && position[0] != nil && position[1] != nil {
+				x, _ := strconv.Atoi(position[0].(string))
+				y, _ := strconv.Atoi(position[1].(string))
+				UnlockTileForEntity(entityID, x, y)
+			}
+			if strings.HasPrefix(entityID, "npc:") {
+				rdb.Del(ctx, entityID)
+			}
+			removeEntityPosition(rdb, entityID)
+		}
+		PublishUpdate(map[string]interface{}{
+			"type":     string(ServerEventEntityLeft),
+			"entityId": entityID,
+		})
+	}
+
+	keys := []string{string(zone), zonePositionsKey(zone), zoneChunksKey(zone)}
+	if chunks, err := rdb.SMembers(ctx, zoneChunksKey(zone)).Result(); err == nil {
+		for _, member := range chunks {
+			keys = append(keys, zoneChunkPrefix(zone)+member)
+		}
+	}
+	pipe := rdb.TxPipeline()
+	pipe.Del(ctx, keys...)
+	publishWorldTileChange(pipe, worldTileChange{Zone: zone})
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to delete dungeon %s: %v", zone, err)
+	}
+	evictZoneChunks(zone)
+}
@@ -0,0 +1,169 @@
+package game
+
+import (
+	"math/rand"
+	"mmo-game/models"
+)
+
+// Dungeons are laid out in their slot's column of the map, south of the overworld. Each
+// layout is dungeonSize tiles square, at the north-west corner of its slot; the rest of
+// the slot, like the slots of dungeons that are not live, is solid wall.
+const (
+	dungeonOriginY = 1 << 20
+	dungeonSpacing = 4 * WorldChunkSize // Zone boundaries must be chunk aligned
+	dungeonSize    = 3 * WorldChunkSize
+
+	// A layout places up to dungeonMaxRooms rooms, making dungeonRoomAttempts tries,
+	// each room between dungeonRoomMin and dungeonRoomMax tiles on a side and at least
+	// dungeonRoomGap tiles of wall from the next.
+	dungeonRoomAttempts = 60
+	dungeonMaxRooms     = 10
+	dungeonRoomMin      = 5
+	dungeonRoomMax      = 11
+	dungeonRoomGap      = 3
+)
+
+// dungeonRoom is a rectangle of floor in layout coordinates.
+type dungeonRoom struct{ X, Y, W, H int }
+
+func (r dungeonRoom) center() pathPoint {
+	return pathPoint{r.X + r.W/2, r.Y + r.H/2}
+}
+
+func (r dungeonRoom) overlaps(other dungeonRoom, gap int) bool {
+	return r.X-gap < other.X+other.W && other.X-gap < r.X+r.W &&
+		r.Y-gap < other.Y+other.H && other.Y-gap < r.Y+r.H
+}
+
+// dungeonLayout is the rooms and corridors of a dungeon, in layout coordinates: (0, 0)
+// is the north-west corner of its slot. The first room is where the party arrives, by
+// the exit at its center; the boss room is the room furthest from it.
+type dungeonLayout struct {
+	floor []bool // dungeonSize*dungeonSize, row by row
+	Rooms []dungeonRoom
+	Boss  int
+	Exit  pathPoint
+}
+
+// generateDungeon lays out a dungeon from a seed. The same seed always gives the same
+// dungeon.
+func generateDungeon(seed int64) *dungeonLayout {
+	rng := rand.New(rand.NewSource(seed))
+	layout := &dungeonLayout{floor: make([]bool, dungeonSize*dungeonSize)}
+
+	for i := 0; i < dungeonRoomAttempts && len(layout.Rooms) < dungeonMaxRooms; i++ {
+		room := dungeonRoom{
+			W: dungeonRoomMin + rng.Intn(dungeonRoomMax-dungeonRoomMin+1),
+			H: dungeonRoomMin + rng.Intn(dungeonRoomMax-dungeonRoomMin+1),
+		}
+		room.X = 1 + rng.Intn(dungeonSize-room.W-1)
+		room.Y = 1 + rng.Intn(dungeonSize-room.H-1)
+		fits := true
+		for _, other := range layout.Rooms {
+			if room.overlaps(other, dungeonRoomGap) {
+				fits = false
+				break
+			}
+		}
+		if !fits {
+			continue
+		}
+		for x := room.X; x < room.X+room.W; x++ {
+			for y := room.Y; y < room.Y+room.H; y++ {
+				layout.carve(x, y)
+			}
+		}
+		// Each room is joined to the nearest room before it, so every room can be reached
+		if len(layout.Rooms) > 0 {
+			layout.corridor(room.center(), layout.Rooms[layout.nearestRoom(room.center())].center(), rng.Intn(2) == 0)
+		}
+		layout.Rooms = append(layout.Rooms, room)
+	}
+
+	start := layout.Rooms[0].center()
+	layout.Exit = start
+	best := -1
+	for i, room := range layout.Rooms[1:] {
+		c := room.center()
+		if d := (c.X-start.X)*(c.X-start.X) + (c.Y-start.Y)*(c.Y-start.Y); d > best {
+			layout.Boss, best = i+1, d
+		}
+	}
+	return layout
+}
+
+func (l *dungeonLayout) carve(x, y int) {
+	if x > 0 && y > 0 && x < dungeonSize-1 && y < dungeonSize-1 {
+		l.floor[y*dungeonSize+x] = true
+	}
+}
+
+// corridor carves a corridor two tiles wide between two points, turning once: across
+// then down, or down then across if vertical is set.
+func (l *dungeonLayout) corridor(from, to pathPoint, vertical bool) {
+	corner := pathPoint{to.X, from.Y}
+	if vertical {
+		corner = pathPoint{from.X, to.Y}
+	}
+	for _, leg := range [][2]pathPoint{{from, corner}, {corner, to}} {
+		p, end := leg[0], leg[1]
+		for {
+			l.carve(p.X, p.Y)
+			l.carve(p.X+1, p.Y)
+			l.carve(p.X, p.Y+1)
+			l.carve(p.X+1, p.Y+1)
+			if p == end {
+				break
+			}
+			p.X += sign(end.X - p.X)
+			p.Y += sign(end.Y - p.Y)
+		}
+	}
+}
+
+// nearestRoom returns the index of the room whose center is nearest a point.
+func (l *dungeonLayout) nearestRoom(p pathPoint) int {
+	nearest, best := 0, -1
+	for i, room := range l.Rooms {
+		c := room.center()
+		if d := (c.X-p.X)*(c.X-p.X) + (c.Y-p.Y)*(c.Y-p.Y); best < 0 || d < best {
+			nearest, best = i, d
+		}
+	}
+	return nearest
+}
+
+// tile returns the tile at a point of the layout. Points outside it are wall.
+func (l *dungeonLayout) tile(x, y int) models.WorldTile {
+	tileType := TileTypeDungeonWall
+	switch {
+	case l == nil || x < 0 || y < 0 || x >= dungeonSize || y >= dungeonSize:
+	case x == l.Exit.X && y == l.Exit.Y:
+		tileType = TileTypeDungeonExit
+	case l.floor[y*dungeonSize+x]:
+		tileType = TileTypeDungeonFloor
+	}
+	return models.WorldTile{Type: string(tileType), Health: TileDefs[tileType].MaxHealth}
+}
+
+// fillDungeonChunk generates the tiles of a chunk of a dungeon zone that have none
+// stored, from its layout if the dungeon is live.
+func fillDungeonChunk(zone ZoneID, c worldChunkCoord, chunk []byte) {
+	slot, _ := dungeonSlot(zone)
+	originX := slot * dungeonSpacing
+	var layout *dungeonLayout
+	if instance := dungeonFor(zone); instance != nil {
+		layout = instance.Layout
+	}
+	for ly := 0; ly < WorldChunkSize; ly++ {
+		for lx := 0; lx < WorldChunkSize; lx++ {
+			offset := (ly*WorldChunkSize + lx) * worldTileRecordSize
+			if chunk[offset] != 0 {
+				continue
+			}
+			x, y := c.CX*WorldChunkSize+lx, c.CY*WorldChunkSize+ly
+			record := encodeWorldTile(layout.tile(x-originX, y-dungeonOriginY))
+			copy(chunk[offset:], record[:])
+		}
+	}
+}
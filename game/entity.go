@@ -2,7 +2,6 @@ package game
 
 import (
 	"log"
-	"strconv"
 )
 
 // CleanupEntity removes a generic entity's core data from Redis.
@@ -13,16 +12,14 @@ func CleanupEntity(entityID string, entityData map[string]string) {
 	log.Printf("Cleaning up entity %s.", entityID)
 	pipe := rdb.Pipeline()
 
-	if entityData != nil {
-		currentX, _ := strconv.Atoi(entityData["x"])
-		currentY, _ := strconv.Atoi(entityData["y"])
-		UnlockTileForEntity(entityID, currentX, currentY)
-	}
+	// Release every lock the entity holds rather than just the one at its recorded
+	// position, so a stale position cannot leave an invisible wall behind.
+	ReleaseAllTileLocks(entityID)
 
 	// Remove the entity's main hash
 	pipe.Del(ctx, entityID)
 	// Remove the entity from the geospatial index
-	pipe.ZRem(ctx, string(RedisKeyZone0Positions), entityID)
+	removeEntityPosition(pipe, entityID)
 
 	_, err := pipe.Exec(ctx)
 	if err != nil {
@@ -1,58 +1,213 @@
 package game
 
 import (
-	"encoding/json"
 	"log"
 	"mmo-game/models"
+	"strconv"
+	"sync"
+)
+
+// collisionGrid holds the collidable tiles of the cached world chunks, keyed by "x,y".
+// It is updated as chunks are cached and by SetWorldTile on every tile change, and only
+// accessed under collisionGridMu; readers get a snapshot from BuildCollisionGrid instead.
+var (
+	collisionGridMu      sync.RWMutex
+	collisionGrid        = make(map[string]bool)
+	collisionGridVersion uint64
+
+	// collisionSnapshot is the last copy handed out by BuildCollisionGrid, valid while
+	// collisionSnapshotVersion matches collisionGridVersion.
+	collisionSnapshot        map[string]bool
+	collisionSnapshotVersion uint64
 
-	"github.com/go-redis/redis/v8"
+	// collisionDirty holds the path clusters with tiles that changed since the path graph
+	// last caught up with the grid, and collisionReplaced whether the whole grid was
+	// replaced meanwhile; see takeCollisionChanges.
+	collisionDirty    = make(map[pathCluster]bool)
+	collisionReplaced bool
+
+	// collisionClusterVersions holds the grid version at which each path cluster last
+	// changed, so a cached path only goes stale when a cluster it crosses does. Clusters
+	// missing from it last changed at collisionVersionFloor at the latest.
+	collisionClusterVersions = make(map[pathCluster]uint64)
+	collisionVersionFloor    uint64
 )
 
-// CollisionGrid holds static, read-only world collision data.
-// The key is a string concatenation of "x,y" coordinates.
-var CollisionGrid map[string]bool
+// collisionClusterVersionLimit is how many clusters collisionClusterVersions holds
+// before it is cleared and collisionVersionFloor raised instead.
+const collisionClusterVersionLimit = 1 << 16
 
 // TickCache holds a snapshot of all dynamic data needed for one AI tick.
+// Resource nodes are not copied in; they are looked up in the ResourceIndex.
 type TickCache struct {
 	EntityData    map[string]map[string]string
-	ResourceNodes map[TileType][]redis.GeoLocation
 	LockedTiles   map[string]bool
 	CollisionGrid map[string]bool
 }
 
-// InitializeCollisionGrid scans the world state from Redis and populates a local,
-// in-memory grid of all collidable tiles for fast pathfinding checks.
-// This should be called once at server startup.
+// InitializeCollisionGrid rebuilds the local, in-memory grid of all collidable tiles
+// for fast pathfinding checks from the cached world chunks. Chunks add their tiles as
+// they are cached, so call it only when the whole world is replaced; single tile
+// changes keep it up to date through SetWorldTile.
 func InitializeCollisionGrid() {
-	CollisionGrid = make(map[string]bool)
+	worldChunkCacheMu.RLock()
+	chunks := make(map[worldChunkCoord][]byte, len(worldChunkCache))
+	for c, chunk := range worldChunkCache {
+		chunks[c] = chunk
+	}
+	worldChunkCacheMu.RUnlock()
+
+	grid := make(map[string]bool)
+	for c, chunk := range chunks {
+		forEachChunkTile(c, chunk, func(x, y int, tile models.WorldTile) {
+			if props, ok := TileDefs[TileType(tile.Type)]; ok {
+				if props.IsCollidable {
+					grid[strconv.Itoa(x)+","+strconv.Itoa(y)] = true
+				}
+			}
+		})
+	}
+
+	collisionGridMu.Lock()
+	collisionGrid = grid
+	collisionGridVersion++
+	collisionDirty = make(map[pathCluster]bool)
+	collisionReplaced = true
+	collisionClusterVersions = make(map[pathCluster]uint64)
+	collisionVersionFloor = collisionGridVersion
+	collisionGridMu.Unlock()
+	log.Printf("Successfully built and cached collision grid with %d collidable tiles.", len(grid))
+}
 
-	worldData, err := rdb.HGetAll(ctx, string(RedisKeyWorldZone0)).Result()
-	if err != nil {
-		log.Fatalf("FATAL: Failed to get world data for collision grid: %v", err)
+// addTileCollisions marks the tiles of a newly cached chunk as collidable.
+func addTileCollisions(coordKeys []string) {
+	if len(coordKeys) == 0 {
 		return
 	}
+	collisionGridMu.Lock()
+	defer collisionGridMu.Unlock()
+	collisionGridVersion++
+	for _, coordKey := range coordKeys {
+		collisionGrid[coordKey] = true
+		markCollisionChanged(coordKey)
+	}
+}
 
-	for coord, tileJSON := range worldData {
-		var tile models.WorldTile
-		if err := json.Unmarshal([]byte(tileJSON), &tile); err != nil {
-			log.Printf("Error unmarshalling tile %s: %v", coord, err)
-			continue
+// removeTileCollisions takes the tiles of evicted chunks off the grid.
+func removeTileCollisions(coordKeys []string) {
+	if len(coordKeys) == 0 {
+		return
+	}
+	collisionGridMu.Lock()
+	defer collisionGridMu.Unlock()
+	collisionGridVersion++
+	for _, coordKey := range coordKeys {
+		delete(collisionGrid, coordKey)
+		markCollisionChanged(coordKey)
+	}
+}
+
+// setTileCollision records whether a tile blocks movement.
+func setTileCollision(x, y int, collidable bool) {
+	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
+	collisionGridMu.Lock()
+	defer collisionGridMu.Unlock()
+	if collisionGrid[coordKey] == collidable {
+		return
+	}
+	if collidable {
+		collisionGrid[coordKey] = true
+	} else {
+		delete(collisionGrid, coordKey)
+	}
+	collisionGridVersion++
+	markCollisionChanged(coordKey)
+}
+
+// markCollisionChanged records that a tile changed at the current grid version. The
+// caller must hold collisionGridMu for writing.
+func markCollisionChanged(coordKey string) {
+	x, y := parsePathKey(coordKey)
+	c := pathClusterFor(pathPoint{x, y})
+	collisionDirty[c] = true
+	if _, ok := collisionClusterVersions[c]; !ok && len(collisionClusterVersions) >= collisionClusterVersionLimit {
+		collisionClusterVersions = make(map[pathCluster]uint64)
+		collisionVersionFloor = collisionGridVersion
+	}
+	collisionClusterVersions[c] = collisionGridVersion
+}
+
+// takeCollisionChanges returns the grid version and the path clusters that changed
+// since the last call, or replaced if the whole grid was. Only the path graph calls it,
+// as it catches up with the grid.
+func takeCollisionChanges() (version uint64, dirty map[pathCluster]bool, replaced bool) {
+	collisionGridMu.Lock()
+	defer collisionGridMu.Unlock()
+	version, dirty, replaced = collisionGridVersion, collisionDirty, collisionReplaced
+	collisionDirty = make(map[pathCluster]bool)
+	collisionReplaced = false
+	return version, dirty, replaced
+}
+
+// collisionChangedSince reports whether any of the clusters changed after a grid version.
+func collisionChangedSince(clusters []pathCluster, version uint64) bool {
+	collisionGridMu.RLock()
+	defer collisionGridMu.RUnlock()
+	for _, c := range clusters {
+		changed, ok := collisionClusterVersions[c]
+		if !ok {
+			changed = collisionVersionFloor
 		}
+		if changed > version {
+			return true
+		}
+	}
+	return false
+}
 
-		if props, ok := TileDefs[TileType(tile.Type)]; ok {
-			if props.IsCollidable {
-				CollisionGrid[coord] = true
+// collisionsIn returns the collidable tiles within bounds.
+func collisionsIn(b pathBounds) []pathPoint {
+	collisionGridMu.RLock()
+	defer collisionGridMu.RUnlock()
+	var tiles []pathPoint
+	for y := b.MinY; y <= b.MaxY; y++ {
+		for x := b.MinX; x <= b.MaxX; x++ {
+			if collisionGrid[strconv.Itoa(x)+","+strconv.Itoa(y)] {
+				tiles = append(tiles, pathPoint{x, y})
 			}
 		}
 	}
-	log.Printf("Successfully built and cached collision grid with %d collidable tiles.", len(CollisionGrid))
+	return tiles
+}
+
+// CollisionGridVersion returns a counter that changes whenever the collision grid does.
+func CollisionGridVersion() uint64 {
+	collisionGridMu.RLock()
+	defer collisionGridMu.RUnlock()
+	return collisionGridVersion
 }
 
-// BuildCollisionGrid now simply returns the pre-built, cached grid.
+// BuildCollisionGrid returns a snapshot of the collision grid. The snapshot is never
+// modified, so pathfinding can read it without locking while tiles keep changing; it is
+// only copied again after the grid has changed.
 func BuildCollisionGrid() map[string]bool {
-	if CollisionGrid == nil {
-		log.Println("Warning: Collision grid accessed before initialization.")
-		return make(map[string]bool)
+	collisionGridMu.RLock()
+	if collisionSnapshot != nil && collisionSnapshotVersion == collisionGridVersion {
+		snapshot := collisionSnapshot
+		collisionGridMu.RUnlock()
+		return snapshot
+	}
+	snapshot := make(map[string]bool, len(collisionGrid))
+	for coordKey := range collisionGrid {
+		snapshot[coordKey] = true
+	}
+	version := collisionGridVersion
+	collisionGridMu.RUnlock()
+
+	collisionGridMu.Lock()
+	if version == collisionGridVersion {
+		collisionSnapshot, collisionSnapshotVersion = snapshot, version
 	}
-	return CollisionGrid
+	collisionGridMu.Unlock()
+	return snapshot
 }
@@ -8,9 +8,20 @@ import (
 
 // Package-level variables to hold the Redis client and context
 var (
-	rdb               *redis.Client
-	ctx               = context.Background()
-	releaseLockScript *redis.Script
+	rdb                   *redis.Client
+	ctx                   = context.Background()
+	acquireTileLockScript *redis.Script
+	releaseTileLockScript *redis.Script
+	renewTileLockScript   *redis.Script
+	dropWorldItemScript   *redis.Script
+	claimWorldItemScript  *redis.Script
+	claimJobsScript       *redis.Script
+	ackJobScript          *redis.Script
+	retryJobScript        *redis.Script
+	requeueJobsScript     *redis.Script
+
+	claimDungeonEntranceScript   *redis.Script
+	releaseDungeonEntranceScript *redis.Script
 )
 
 // SendDirectMessageFunc is a function type for sending a message to a specific client.
@@ -25,19 +36,190 @@ var IsPlayerOnline IsPlayerOnlineFunc
 // Init initializes the game package with a Redis client.
 func Init(redisClient *redis.Client, directMessageFunc SendDirectMessageFunc, isOnlineFunc IsPlayerOnlineFunc) {
 	rdb = redisClient
+	rdb.AddHook(spatialIndexHook{})
 	sendDirectMessage = directMessageFunc
 	IsPlayerOnline = isOnlineFunc
 	loadScripts()
 }
 
 func loadScripts() {
-	// This script safely releases a lock only if the entityID matches.
-	luaScript := `
-if redis.call("get", KEYS[1]) == ARGV[1] then
-    return redis.call("del", KEYS[1])
-else
+	// Tile lock scripts keep the lock key, the lock index, the owner's set of tiles and
+	// the lease set in step. KEYS for all three: lock key, lock index, lease set.
+
+	// This script takes a free tile. A lease of 0 means the lock never expires.
+	// ARGV: owner, "x,y", owner set prefix, lease expiry
+	acquireTileLockScript = redis.NewScript(`
+if redis.call("setnx", KEYS[1], ARGV[1]) == 0 then
     return 0
 end
-`
-	releaseLockScript = redis.NewScript(luaScript)
+redis.call("hset", KEYS[2], ARGV[2], ARGV[1])
+redis.call("sadd", ARGV[3] .. ARGV[1], ARGV[2])
+if tonumber(ARGV[4]) > 0 then
+    redis.call("zadd", KEYS[3], ARGV[4], ARGV[2])
+end
+return 1
+`)
+
+	// This script safely releases a lock only if the owner matches. With a non-zero
+	// ARGV[4], it only releases the lock if its lease ran out by then.
+	// ARGV: owner, "x,y", owner set prefix, due by
+	releaseTileLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) ~= ARGV[1] then
+    return 0
+end
+if tonumber(ARGV[4]) > 0 then
+    local expiresAt = redis.call("zscore", KEYS[3], ARGV[2])
+    if not expiresAt or tonumber(expiresAt) > tonumber(ARGV[4]) then
+        return 0
+    end
+end
+redis.call("del", KEYS[1])
+redis.call("hdel", KEYS[2], ARGV[2])
+redis.call("srem", ARGV[3] .. ARGV[1], ARGV[2])
+redis.call("zrem", KEYS[3], ARGV[2])
+return 1
+`)
+
+	// This script extends a lease, but only for the lock's current owner.
+	// ARGV: owner, "x,y", new lease expiry
+	renewTileLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) ~= ARGV[1] then
+    return 0
+end
+redis.call("zadd", KEYS[3], ARGV[3], ARGV[2])
+return 1
+`)
+
+	// This script drops items onto a tile, adding them to the existing pile of the same
+	// item and owner if there is one, so a tile never holds two identical piles.
+	// KEYS: piles hash, positions, new drop ID
+	// ARGV: pile field, quantity, despawnAt, itemId, x, y, owner, createdAt, publicAt, lon, lat
+	dropWorldItemScript = redis.NewScript(`
+local existing = redis.call("hget", KEYS[1], ARGV[1])
+if existing and redis.call("exists", existing) == 1 then
+    local quantity = redis.call("hincrby", existing, "quantity", ARGV[2])
+    redis.call("hset", existing, "despawnAt", ARGV[3])
+    local times = redis.call("hmget", existing, "createdAt", "publicAt")
+    return {existing, quantity, times[1], times[2], 1}
+end
+redis.call("hset", KEYS[3],
+    "entityType", "item", "itemId", ARGV[4], "quantity", ARGV[2], "x", ARGV[5], "y", ARGV[6],
+    "owner", ARGV[7], "createdAt", ARGV[8], "publicAt", ARGV[9], "despawnAt", ARGV[3], "pile", ARGV[1])
+redis.call("geoadd", KEYS[2], ARGV[10], ARGV[11], KEYS[3])
+redis.call("hset", KEYS[1], ARGV[1], KEYS[3])
+return {KEYS[3], tonumber(ARGV[2]), ARGV[8], ARGV[9], 0}
+`)
+
+	// This script atomically removes a world item and all of its index entries, returning
+	// its fields. Only one caller can claim an item, so it cannot be picked up twice.
+	// With a non-zero ARGV[1], the item is only claimed if it despawns at or before then.
+	// KEYS: drop ID, positions, piles hash
+	claimWorldItemScript = redis.NewScript(`
+if redis.call("exists", KEYS[1]) == 0 then
+    redis.call("zrem", KEYS[2], KEYS[1])
+    return false
+end
+local limit = tonumber(ARGV[1])
+if limit > 0 then
+    local despawnAt = tonumber(redis.call("hget", KEYS[1], "despawnAt") or "0")
+    if despawnAt > limit then
+        return false
+    end
+end
+local data = redis.call("hgetall", KEYS[1])
+local pile = redis.call("hget", KEYS[1], "pile")
+if pile and redis.call("hget", KEYS[3], pile) == KEYS[1] then
+    redis.call("hdel", KEYS[3], pile)
+end
+redis.call("del", KEYS[1])
+redis.call("zrem", KEYS[2], KEYS[1])
+return data
+`)
+
+	// Job scheduler scripts. A job key is in at most one of the due and claimed sets;
+	// its JSON lives in the data hash until it finishes or is cancelled.
+
+	// This script claims up to ARGV[2] jobs due by ARGV[1], leasing them until ARGV[3].
+	// KEYS: due set, claimed set, data hash
+	claimJobsScript = redis.NewScript(`
+local keys = redis.call("zrangebyscore", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+local result = {}
+for _, key in ipairs(keys) do
+    redis.call("zrem", KEYS[1], key)
+    local data = redis.call("hget", KEYS[3], key)
+    if data then
+        redis.call("zadd", KEYS[2], ARGV[3], key)
+        table.insert(result, key)
+        table.insert(result, data)
+    end
+end
+return result
+`)
+
+	// This script finishes a run. The job is only deleted if it was not rescheduled while
+	// it ran, which would have given it a new token.
+	// KEYS: claimed set, data hash, due set. ARGV: job key, token
+	ackJobScript = redis.NewScript(`
+redis.call("zrem", KEYS[1], ARGV[1])
+local data = redis.call("hget", KEYS[2], ARGV[1])
+if data and cjson.decode(data).token == ARGV[2] and not redis.call("zscore", KEYS[3], ARGV[1]) then
+    redis.call("hdel", KEYS[2], ARGV[1])
+end
+return 1
+`)
+
+	// This script puts a failed run back in the due set, unless it was rescheduled or
+	// cancelled while it ran.
+	// KEYS: claimed set, data hash, due set. ARGV: job key, token, new data, retry at
+	retryJobScript = redis.NewScript(`
+redis.call("zrem", KEYS[1], ARGV[1])
+local data = redis.call("hget", KEYS[2], ARGV[1])
+if not data or cjson.decode(data).token ~= ARGV[2] then
+    return 0
+end
+redis.call("hset", KEYS[2], ARGV[1], ARGV[3])
+redis.call("zadd", KEYS[3], ARGV[4], ARGV[1])
+return 1
+`)
+
+	// This script makes jobs whose claim lease ran out due again.
+	// KEYS: claimed set, due set. ARGV: now
+	requeueJobsScript = redis.NewScript(`
+local keys = redis.call("zrangebyscore", KEYS[1], "-inf", ARGV[1])
+for _, key in ipairs(keys) do
+    redis.call("zrem", KEYS[1], key)
+    redis.call("zadd", KEYS[2], "NX", ARGV[1], key)
+end
+return #keys
+`)
+
+	// Dungeon entrance scripts. An entrance leads to the live instance recorded for it
+	// in the entrances hash; KEYS for both: entrances hash, live dungeon set.
+
+	// This script claims an entrance for a new instance, unless it already leads to a
+	// live one. It returns the instance the entrance leads to.
+	// ARGV: "x,y", new instance zone
+	claimDungeonEntranceScript = redis.NewScript(`
+local zone = redis.call("hget", KEYS[1], ARGV[1])
+if zone and redis.call("sismember", KEYS[2], zone) == 1 then
+    return zone
+end
+redis.call("hset", KEYS[1], ARGV[1], ARGV[2])
+redis.call("sadd", KEYS[2], ARGV[2])
+return ARGV[2]
+`)
+
+	// This script takes an instance off the live set and frees its entrance, unless the
+	// entrance already leads to another instance. It returns 0 if the instance was not
+	// live, so only one caller tears it down.
+	// ARGV: "x,y", instance zone
+	releaseDungeonEntranceScript = redis.NewScript(`
+if redis.call("srem", KEYS[2], ARGV[2]) == 0 then
+    return 0
+end
+if redis.call("hget", KEYS[1], ARGV[1]) == ARGV[2] then
+    redis.call("hdel", KEYS[1], ARGV[1])
+end
+return 1
+`)
 }
@@ -1,6 +1,7 @@
 package game
 
 import (
+	"fmt"
 	"log"
 	"math/rand"
 	"mmo-game/game/utils"
@@ -10,61 +11,186 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
-// CreateWorldItem places a new item into the world at a specific location.
-func CreateWorldItem(x, y int, itemID ItemID, quantity int, ownerID string, expiry time.Duration) (string, int64, int64, error) {
-	// Generate a unique ID for the item drop
+// DefaultItemDespawnTime is how long a dropped pile stays in the world when its item
+// does not set ItemProperties.DespawnTime.
+const DefaultItemDespawnTime = 5 * time.Minute
+
+// WorldItem is a pile of items lying on a tile.
+type WorldItem struct {
+	ID        string
+	ItemID    ItemID
+	Quantity  int
+	X, Y      int
+	Owner     string
+	CreatedAt int64
+	PublicAt  int64
+	DespawnAt int64
+}
+
+// JoinedMessage is the entity_joined update describing this pile. Clients treat it as an
+// upsert, so it is also sent when a pile grows or becomes public.
+func (w *WorldItem) JoinedMessage() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       string(ServerEventEntityJoined),
+		"entityId":   w.ID,
+		"entityType": string(EntityTypeItem),
+		"itemId":     string(w.ItemID),
+		"quantity":   w.Quantity,
+		"x":          w.X,
+		"y":          w.Y,
+		"owner":      w.Owner,
+		"createdAt":  w.CreatedAt,
+		"publicAt":   w.PublicAt,
+		"despawnAt":  w.DespawnAt,
+	}
+}
+
+// itemDespawnTime returns how long a dropped pile of itemID lasts.
+func itemDespawnTime(itemID ItemID) time.Duration {
+	if ms := ItemDefs[itemID].DespawnTime; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return DefaultItemDespawnTime
+}
+
+// worldItemPileField is the RedisKeyItemPiles field for a pile of itemID owned by ownerID.
+func worldItemPileField(x, y int, itemID ItemID, ownerID string) string {
+	return strconv.Itoa(x) + "," + strconv.Itoa(y) + "|" + string(itemID) + "|" + ownerID
+}
+
+// CreateWorldItem places items into the world at a specific location. If the tile already
+// holds a pile of the same item with the same owner, the items are added to it and its
+// despawn time is reset; the returned WorldItem is then that pile. Owned items become
+// public after expiry, and every pile despawns after its item's despawn time.
+func CreateWorldItem(x, y int, itemID ItemID, quantity int, ownerID string, expiry time.Duration) (*WorldItem, error) {
 	dropID := string(ItemPrefix) + utils.GenerateUniqueID()
 	createdAt := time.Now().UnixMilli()
 	var publicAt int64
 	if expiry > 0 && ownerID != "" {
 		publicAt = createdAt + expiry.Milliseconds()
 	}
+	despawnAt := createdAt + itemDespawnTime(itemID).Milliseconds()
+	lon, lat := NormalizeCoords(x, y)
 
-	pipe := rdb.Pipeline()
+	keys := []string{
+		string(RedisKeyItemPiles),
+		zonePositionsKey(zoneAt(x, y)),
+		dropID,
+	}
+	result, err := dropWorldItemScript.Run(ctx, rdb, keys,
+		worldItemPileField(x, y, itemID, ownerID), quantity, despawnAt,
+		string(itemID), x, y, ownerID, createdAt, publicAt, lon, lat,
+	).Slice()
+	if err != nil || len(result) < 5 {
+		log.Printf("Failed to create world item %s: %v", itemID, err)
+		return nil, err
+	}
 
-	// Set item properties
-	pipe.HSet(ctx, dropID,
-		"entityType", string(EntityTypeItem),
-		"itemId", string(itemID),
-		"quantity", quantity,
-		"x", x,
-		"y", y,
-		"owner", ownerID, // The player who gets initial access to it
-		"createdAt", createdAt,
-		"publicAt", publicAt,
-	)
+	item := &WorldItem{
+		ID:        fmt.Sprint(result[0]),
+		ItemID:    itemID,
+		X:         x,
+		Y:         y,
+		Owner:     ownerID,
+		DespawnAt: despawnAt,
+	}
+	item.Quantity, _ = strconv.Atoi(fmt.Sprint(result[1]))
+	item.CreatedAt, _ = strconv.ParseInt(fmt.Sprint(result[2]), 10, 64)
+	item.PublicAt, _ = strconv.ParseInt(fmt.Sprint(result[3]), 10, 64)
 
-	// Add to geospatial index
-	lon, lat := NormalizeCoords(x, y)
-	pipe.GeoAdd(ctx, string(RedisKeyZone0Positions), &redis.GeoLocation{
-		Name:      dropID,
-		Longitude: lon,
-		Latitude:  lat,
-	})
-
-	// Set an expiration time for the item drop - THIS IS WRONG, it deletes the item
-	/*
-		if expiry > 0 {
-			pipe.Expire(ctx, dropID, expiry)
-		}
-	*/
-	// TODO: When this expires, we also need to remove it from the geospatial index.
-	// A separate cleanup process will be needed for that.
+	indexEntity(rdb, item.ID, x, y)
+
+	if fmt.Sprint(result[4]) == "1" {
+		log.Printf("Merged %d %s into pile %s at (%d, %d)", quantity, itemID, item.ID, x, y)
+	} else {
+		log.Printf("Created world item %s at (%d, %d)", itemID, x, y)
+	}
+	scheduleWorldItemJobs(item)
+	return item, nil
+}
+
+// worldItemFromData builds a WorldItem from its entity hash.
+func worldItemFromData(dropID string, data map[string]string) *WorldItem {
+	item := &WorldItem{
+		ID:     dropID,
+		ItemID: ItemID(data["itemId"]),
+		Owner:  data["owner"],
+	}
+	item.Quantity, _ = strconv.Atoi(data["quantity"])
+	item.X, _ = strconv.Atoi(data["x"])
+	item.Y, _ = strconv.Atoi(data["y"])
+	item.CreatedAt, _ = strconv.ParseInt(data["createdAt"], 10, 64)
+	item.PublicAt, _ = strconv.ParseInt(data["publicAt"], 10, 64)
+	item.DespawnAt, _ = strconv.ParseInt(data["despawnAt"], 10, 64)
+	return item
+}
 
-	_, err := pipe.Exec(ctx)
+// ClaimWorldItem removes a world item and its index entries in one step and returns its
+// data. It returns false if the item is already gone, so two players picking up the same
+// pile cannot both get it. Callers that fail to use the item should RestoreWorldItem it.
+func ClaimWorldItem(dropID string) (map[string]string, bool) {
+	return claimWorldItem(dropID, 0)
+}
+
+// claimWorldItem claims an item; a non-zero dueBy only claims it if it despawns by then.
+func claimWorldItem(dropID string, dueBy int64) (map[string]string, bool) {
+	keys := []string{
+		dropID,
+		zonePositionsKey(entityZone(dropID)),
+		string(RedisKeyItemPiles),
+	}
+	fields, err := claimWorldItemScript.Run(ctx, rdb, keys, dueBy).StringSlice()
 	if err != nil {
-		log.Printf("Failed to create world item %s: %v", itemID, err)
-		return "", 0, 0, err
+		if err != redis.Nil {
+			log.Printf("Failed to claim world item %s: %v", dropID, err)
+			return nil, false
+		}
+		// Not claimed: either not due yet, or already gone.
+		if rdb.Exists(ctx, dropID).Val() == 0 {
+			unindexEntity(rdb, dropID)
+		}
+		return nil, false
+	}
+	if len(fields) == 0 {
+		return nil, false
 	}
+	unindexEntity(rdb, dropID)
+	data := make(map[string]string, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		data[fields[i]] = fields[i+1]
+	}
+	return data, true
+}
 
-	if expiry > 0 && ownerID != "" {
-		time.AfterFunc(expiry, func() {
-			makeItemPublic(dropID)
-		})
+// RestoreWorldItem puts back an item taken with ClaimWorldItem, keeping its ID and timers.
+func RestoreWorldItem(dropID string, data map[string]string) {
+	item := worldItemFromData(dropID, data)
+
+	fields := make(map[string]interface{}, len(data))
+	for field, value := range data {
+		fields[field] = value
 	}
 
-	log.Printf("Created world item %s at (%d, %d)", itemID, x, y)
-	return dropID, createdAt, publicAt, nil
+	pipe := rdb.TxPipeline()
+	pipe.HSet(ctx, dropID, fields)
+	setEntityPosition(pipe, dropID, item.X, item.Y)
+	if pile := data["pile"]; pile != "" {
+		pipe.HSetNX(ctx, string(RedisKeyItemPiles), pile, dropID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to restore world item %s: %v", dropID, err)
+		return
+	}
+	scheduleWorldItemJobs(item)
+}
+
+// scheduleWorldItemJobs schedules a pile's despawn and, if it is owned, the moment it
+// becomes public. Rescheduling replaces the old jobs, so merges push the despawn back.
+func scheduleWorldItemJobs(item *WorldItem) {
+	ScheduleJob(JobItemDespawn, item.ID, time.UnixMilli(item.DespawnAt), nil)
+	if item.Owner != "" && item.PublicAt > 0 {
+		ScheduleJob(JobItemPublic, item.ID, time.UnixMilli(item.PublicAt), nil)
+	}
 }
 
 func makeItemPublic(dropID string) {
@@ -80,42 +206,41 @@ func makeItemPublic(dropID string) {
 		return
 	}
 
-	// Update the owner to be public (empty string).
-	if err := rdb.HSet(ctx, dropID, "owner", "").Err(); err != nil {
+	// Update the owner to be public (empty string), and move the pile under its public
+	// key so later public drops of the same item merge into it.
+	item := worldItemFromData(dropID, itemData)
+	item.Owner = ""
+	publicPile := worldItemPileField(item.X, item.Y, item.ItemID, "")
+
+	pipe := rdb.TxPipeline()
+	pipe.HSet(ctx, dropID, "owner", "", "pile", publicPile)
+	if oldPile := itemData["pile"]; oldPile != "" {
+		pipe.HDel(ctx, string(RedisKeyItemPiles), oldPile)
+	}
+	pipe.HSetNX(ctx, string(RedisKeyItemPiles), publicPile, dropID)
+	if _, err := pipe.Exec(ctx); err != nil {
 		log.Printf("Failed to update item %s to public: %v", dropID, err)
 		return
 	}
 
 	// Announce the change to all players.
 	// We re-use the EntityJoined event; clients will treat this as an upsert.
-	x, _ := strconv.Atoi(itemData["x"])
-	y, _ := strconv.Atoi(itemData["y"])
-	createdAt, _ := strconv.ParseInt(itemData["createdAt"], 10, 64)
-	publicAt, _ := strconv.ParseInt(itemData["publicAt"], 10, 64)
-
-	itemUpdate := map[string]interface{}{
-		"type":       string(ServerEventEntityJoined),
-		"entityId":   dropID,
-		"entityType": string(EntityTypeItem),
-		"itemId":     itemData["itemId"],
-		"x":          x,
-		"y":          y,
-		"owner":      "", // This is the important change
-		"createdAt":  createdAt,
-		"publicAt":   publicAt,
-	}
-	PublishUpdate(itemUpdate)
+	PublishUpdate(item.JoinedMessage())
 	log.Printf("Item %s is now public and an update has been broadcast.", dropID)
 }
 
 // generateLoot determines what loot to drop based on the NPC's loot table.
 func generateLoot(npcType NPCType) map[ItemID]int {
-	drops := make(map[ItemID]int)
 	table, ok := NPCLootTables[npcType]
 	if !ok {
-		return drops // No loot table for this NPC type
+		return make(map[ItemID]int) // No loot table for this NPC type
 	}
+	return rollLoot(table)
+}
 
+// rollLoot rolls each entry of a loot table in turn.
+func rollLoot(table LootTable) map[ItemID]int {
+	drops := make(map[ItemID]int)
 	for _, entry := range table {
 		if rand.Float64() < entry.Chance {
 			quantity := rand.Intn(entry.Max-entry.Min+1) + entry.Min
@@ -0,0 +1,81 @@
+package game
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// despawnWorldItem removes a pile whose despawn time has come. It is run by the
+// JobItemDespawn job and does nothing if the pile was picked up or its despawn time was
+// pushed back by a merge.
+func despawnWorldItem(dropID string) {
+	data, ok := claimWorldItem(dropID, time.Now().UnixMilli())
+	if !ok {
+		return
+	}
+
+	item := worldItemFromData(dropID, data)
+	RecordItemMovement(rdb, ItemAuditEntry{
+		ItemID:   item.ItemID,
+		Quantity: item.Quantity,
+		Source:   ItemLocationWorld,
+		Dest:     ItemLocationNone,
+		Reason:   ItemAuditDespawn,
+		Ref:      dropID,
+	})
+
+	leftMsg := map[string]interface{}{
+		"type":     string(ServerEventEntityLeft),
+		"entityId": dropID,
+	}
+	PublishUpdate(leftMsg)
+	log.Printf("Despawned %d %s at (%d, %d).", item.Quantity, item.ItemID, item.X, item.Y)
+}
+
+// ScheduleUnindexedWorldItems schedules despawn jobs for world items that have none,
+// such as items dropped before the job scheduler existed, which would otherwise never
+// leave the world. It reads every position, so it only runs at startup.
+func ScheduleUnindexedWorldItems() {
+	var members []string
+	for _, zone := range activeZones() {
+		zoneMembers, err := rdb.ZRange(ctx, zonePositionsKey(zone), 0, -1).Result()
+		if err != nil {
+			log.Printf("Failed to scan world items in zone %s for despawn: %v", zone, err)
+			continue
+		}
+		members = append(members, zoneMembers...)
+	}
+
+	count := 0
+	now := time.Now()
+	for _, dropID := range members {
+		if !strings.HasPrefix(dropID, string(ItemPrefix)) {
+			continue
+		}
+		if IsJobScheduled(JobItemDespawn, dropID) {
+			continue
+		}
+		itemData, err := rdb.HGetAll(ctx, dropID).Result()
+		if err != nil {
+			continue
+		}
+		if len(itemData) == 0 {
+			// The hash is gone but the position was left behind; drop the dangling member.
+			removeEntityPosition(rdb, dropID)
+			continue
+		}
+
+		item := worldItemFromData(dropID, itemData)
+		if despawnAt, _ := strconv.ParseInt(itemData["despawnAt"], 10, 64); despawnAt == 0 {
+			item.DespawnAt = now.Add(itemDespawnTime(item.ItemID)).UnixMilli()
+			rdb.HSet(ctx, dropID, "despawnAt", item.DespawnAt)
+		}
+		scheduleWorldItemJobs(item)
+		count++
+	}
+	if count > 0 {
+		log.Printf("Scheduled despawn for %d world items without a despawn job.", count)
+	}
+}
@@ -1,29 +1,88 @@
 package game
 
 import (
+	"log"
+	"mmo-game/game/utils"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+const (
+	// TileLockLease is how long an entity's tile lock lasts without being renewed.
+	TileLockLease = 30 * time.Second
+	// TileLockRenewInterval is how often live entities renew their leases.
+	TileLockRenewInterval = 10 * time.Second
+
+	tileLockReapInterval  = 5 * time.Second
+	tileLockAuditInterval = 30 * time.Second
+)
+
+// tileLockKeys returns the KEYS shared by the tile lock scripts.
+func tileLockKeys(coordKey string) []string {
+	return []string{string(RedisKeyLockTile) + coordKey, string(RedisKeyLockIndex), string(RedisKeyLockLeases)}
+}
+
 // LockTileForEntity attempts to acquire a lock on a specific tile for a given entity.
-// It returns true if the lock was acquired, false otherwise.
+// It returns true if the lock was acquired, false otherwise. The lock is leased for
+// TileLockLease and renewed by RenewTileLeases while the entity stands on the tile.
 func LockTileForEntity(entityID string, x, y int) (bool, error) {
-	tileKey := string(RedisKeyLockTile) + strconv.Itoa(x) + "," + strconv.Itoa(y)
-	return rdb.SetNX(ctx, tileKey, entityID, 0).Result()
+	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
+	expiresAt := time.Now().Add(TileLockLease).UnixMilli()
+	acquired, err := acquireTileLockScript.Run(ctx, rdb, tileLockKeys(coordKey),
+		entityID, coordKey, string(RedisKeyLockOwnerPrefix), expiresAt).Int()
+	return acquired == 1, err
+}
+
+// LockTileForWorldObject locks a tile for a world object such as a wall. These locks
+// have no lease; they are released when the object is destroyed.
+func LockTileForWorldObject(x, y int) (bool, error) {
+	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
+	acquired, err := acquireTileLockScript.Run(ctx, rdb, tileLockKeys(coordKey),
+		string(RedisKeyLockWorldObject), coordKey, string(RedisKeyLockOwnerPrefix), 0).Int()
+	return acquired == 1, err
 }
 
 // UnlockTileForEntity releases a lock on a tile, but only if the provided entityID
 // is the current owner of the lock.
 func UnlockTileForEntity(entityID string, x, y int) error {
-	tileKey := string(RedisKeyLockTile) + strconv.Itoa(x) + "," + strconv.Itoa(y)
-	_, err := releaseLockScript.Run(ctx, rdb, []string{tileKey}, entityID).Result()
+	return releaseTileLock(entityID, strconv.Itoa(x)+","+strconv.Itoa(y), 0)
+}
+
+// UnlockTileForWorldObject releases the lock held by a destroyed world object.
+func UnlockTileForWorldObject(x, y int) error {
+	return releaseTileLock(string(RedisKeyLockWorldObject), strconv.Itoa(x)+","+strconv.Itoa(y), 0)
+}
+
+// releaseTileLock releases owner's lock on a tile. A non-zero dueBy only releases it if
+// its lease ran out by then.
+func releaseTileLock(owner, coordKey string, dueBy int64) error {
+	_, err := releaseTileLockScript.Run(ctx, rdb, tileLockKeys(coordKey),
+		owner, coordKey, string(RedisKeyLockOwnerPrefix), dueBy).Result()
 	if err != redis.Nil {
 		return err
 	}
 	return nil
 }
 
+// ReleaseAllTileLocks releases every tile lock an entity holds, wherever it is. Use it
+// when an entity leaves the world, so a stale position cannot leave a lock behind.
+func ReleaseAllTileLocks(entityID string) {
+	coords, err := rdb.SMembers(ctx, string(RedisKeyLockOwnerPrefix)+entityID).Result()
+	if err != nil {
+		log.Printf("Failed to list tile locks for %s: %v", entityID, err)
+		return
+	}
+	for _, coordKey := range coords {
+		releaseTileLock(entityID, coordKey, 0)
+	}
+	// Drop the set too, in case it listed tiles whose locks were already gone.
+	rdb.Del(ctx, string(RedisKeyLockOwnerPrefix)+entityID)
+}
+
 // IsTileLocked checks if a tile is currently locked by any entity.
 func IsTileLocked(x, y int) bool {
 	tileKey := string(RedisKeyLockTile) + strconv.Itoa(x) + "," + strconv.Itoa(y)
@@ -33,3 +92,211 @@ func IsTileLocked(x, y int) bool {
 	}
 	return val == 1
 }
+
+// GetTileLocks returns every locked tile and its owner, keyed by "x,y".
+func GetTileLocks() (map[string]string, error) {
+	return rdb.HGetAll(ctx, string(RedisKeyLockIndex)).Result()
+}
+
+// lastTileLeaseRenewal is the Unix millisecond time of the last renewal. AI ticks run
+// concurrently, so it is only accessed atomically.
+var lastTileLeaseRenewal int64
+
+// RenewTileLeases extends the lease on the tile each living entity stands on. It is the
+// entities' heartbeat: the AI loop calls it every tick with the tick's entity data, and it
+// only does work once per TileLockRenewInterval.
+func RenewTileLeases(entityData map[string]map[string]string) {
+	now := time.Now().UnixMilli()
+	last := atomic.LoadInt64(&lastTileLeaseRenewal)
+	if now-last < TileLockRenewInterval.Milliseconds() || !atomic.CompareAndSwapInt64(&lastTileLeaseRenewal, last, now) {
+		return
+	}
+
+	expiresAt := time.Now().Add(TileLockLease).UnixMilli()
+	pipe := rdb.Pipeline()
+	for entityID, data := range entityData {
+		entityType := data["entityType"]
+		if entityType != string(EntityTypePlayer) && entityType != string(EntityTypeNPC) {
+			continue
+		}
+		coordKey := data["x"] + "," + data["y"]
+		// Eval rather than Run: a pipeline cannot fall back from EVALSHA on NOSCRIPT.
+		renewTileLockScript.Eval(ctx, pipe, tileLockKeys(coordKey), entityID, coordKey, expiresAt)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		log.Printf("Failed to renew tile lock leases: %v", err)
+	}
+}
+
+// IndexTileLocks adds tile locks created before the lock index existed to it, giving
+// entity locks a fresh lease. It scans the keyspace, so it only runs at startup.
+func IndexTileLocks() {
+	indexed, err := GetTileLocks()
+	if err != nil {
+		log.Printf("Failed to read tile lock index: %v", err)
+		return
+	}
+
+	count := 0
+	expiresAt := time.Now().Add(TileLockLease).UnixMilli()
+	iter := rdb.Scan(ctx, 0, string(RedisKeyLockTile)+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		coordKey := strings.TrimPrefix(iter.Val(), string(RedisKeyLockTile))
+		if _, ok := indexed[coordKey]; ok {
+			continue
+		}
+		owner, err := rdb.Get(ctx, iter.Val()).Result()
+		if err != nil {
+			continue
+		}
+		pipe := rdb.Pipeline()
+		pipe.HSet(ctx, string(RedisKeyLockIndex), coordKey, owner)
+		pipe.SAdd(ctx, string(RedisKeyLockOwnerPrefix)+owner, coordKey)
+		if owner != string(RedisKeyLockWorldObject) {
+			pipe.ZAdd(ctx, string(RedisKeyLockLeases), &redis.Z{Score: float64(expiresAt), Member: coordKey})
+		}
+		if _, err := pipe.Exec(ctx); err == nil {
+			count++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Error scanning tile locks: %v", err)
+	}
+	if count > 0 {
+		log.Printf("Indexed %d tile locks created before the lock index.", count)
+	}
+}
+
+// StartTileLockReaper releases orphaned tile locks: entity locks whose lease ran out, and
+// locks that no longer match a living entity or a wall tile.
+func StartTileLockReaper() {
+	ticker := time.NewTicker(tileLockReapInterval)
+	defer ticker.Stop()
+
+	lastAudit := time.Now()
+	suspects := make(map[string]string)
+	for range ticker.C {
+		reapExpiredTileLeases()
+		if time.Since(lastAudit) >= tileLockAuditInterval {
+			lastAudit = time.Now()
+			suspects = auditTileLocks(suspects)
+		}
+	}
+}
+
+// entityHoldsTile reports whether owner is a living entity standing on coordKey.
+func entityHoldsTile(owner, coordKey string) (exists bool, holds bool) {
+	position, err := rdb.HMGet(ctx, owner, "x", "y").Result()
+	if err != nil || position[0] == nil || position[1] == nil {
+		return false, false
+	}
+	x, _ := position[0].(string)
+	y, _ := position[1].(string)
+	return true, x+","+y == coordKey
+}
+
+func reapExpiredTileLeases() {
+	now := time.Now().UnixMilli()
+	coords, err := rdb.ZRangeByScore(ctx, string(RedisKeyLockLeases), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now, 10),
+	}).Result()
+	if err != nil {
+		log.Printf("Failed to read tile lock leases: %v", err)
+		return
+	}
+
+	for _, coordKey := range coords {
+		owner, err := rdb.Get(ctx, string(RedisKeyLockTile)+coordKey).Result()
+		if err == redis.Nil {
+			// The lock key is already gone; clear the index entries that outlived it.
+			rdb.ZRem(ctx, string(RedisKeyLockLeases), coordKey)
+			rdb.HDel(ctx, string(RedisKeyLockIndex), coordKey)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		// A missed heartbeat alone is not proof the entity is gone, e.g. after a slow
+		// tick. Keep the lock if its owner still stands there.
+		if _, holds := entityHoldsTile(owner, coordKey); holds {
+			renewTileLockScript.Run(ctx, rdb, tileLockKeys(coordKey), owner, coordKey, time.Now().Add(TileLockLease).UnixMilli())
+			continue
+		}
+
+		if err := releaseTileLock(owner, coordKey, now); err != nil {
+			log.Printf("Failed to reap tile lock %s held by %s: %v", coordKey, owner, err)
+			continue
+		}
+		log.Printf("Reaped expired tile lock %s held by %s.", coordKey, owner)
+	}
+}
+
+// auditTileLocks cross-checks every lock against the world. Wall locks must sit on a
+// collidable tile, and every collidable placed structure must be locked. Entity locks
+// must belong to an entity that exists and stands on the tile; because an entity briefly
+// holds two tiles while moving, a position mismatch is only reaped if it is still there
+// on the next audit. It returns the mismatches to check next time.
+func auditTileLocks(previousSuspects map[string]string) map[string]string {
+	locks, err := GetTileLocks()
+	if err != nil {
+		log.Printf("Failed to read tile locks for audit: %v", err)
+		return previousSuspects
+	}
+
+	suspects := make(map[string]string)
+	reaped := 0
+	for coordKey, owner := range locks {
+		if owner == string(RedisKeyLockWorldObject) {
+			x, y := utils.ParseCoordKey(coordKey)
+			_, props, err := GetWorldTile(x, y)
+			if (err == nil && props.IsCollidable) || (err != nil && err != redis.Nil) {
+				continue
+			}
+			if UnlockTileForWorldObject(x, y) == nil {
+				reaped++
+				log.Printf("Reaped world object lock %s with no wall under it.", coordKey)
+			}
+			continue
+		}
+
+		exists, holds := entityHoldsTile(owner, coordKey)
+		if holds {
+			continue
+		}
+		if exists && previousSuspects[coordKey] != owner {
+			suspects[coordKey] = owner
+			continue
+		}
+		if releaseTileLock(owner, coordKey, 0) == nil {
+			reaped++
+			log.Printf("Reaped tile lock %s held by %s, which is no longer there.", coordKey, owner)
+		}
+	}
+
+	// Walls placed or restored while their tile was occupied have no lock; add it now.
+	// Every wall decays, so only the decay set needs checking, not the whole world.
+	if decaying, err := rdb.SMembers(ctx, string(RedisKeyActiveDecay)).Result(); err == nil {
+		for _, coordKey := range decaying {
+			if _, ok := locks[coordKey]; ok {
+				continue
+			}
+			x, y := utils.ParseCoordKey(coordKey)
+			tile, _, err := GetWorldTile(x, y)
+			if err != nil || TileType(tile.Type) != TileTypeWoodenWall {
+				continue
+			}
+			if locked, _ := LockTileForWorldObject(x, y); locked {
+				log.Printf("Locked unlocked wall at %s.", coordKey)
+			}
+		}
+	} else {
+		log.Printf("Failed to read the decay set for the tile lock audit: %v", err)
+	}
+
+	if reaped > 0 {
+		log.Printf("Tile lock audit reaped %d orphaned locks.", reaped)
+	}
+	return suspects
+}
@@ -0,0 +1,159 @@
+package game
+
+import (
+	"log"
+	"mmo-game/game/utils"
+	"mmo-game/models"
+	"strconv"
+	"sync"
+)
+
+// Players are shown a minimap of the chunks of the designed zones they have discovered:
+// every chunk that has come into their view. Each chunk's minimap is built once and
+// cached until one of its tiles changes. Dungeons have none; they are torn down once left.
+const (
+	// minimapCellSize is the side of the square of tiles each minimap cell covers.
+	minimapCellSize = 4
+	// minimapCellCodes are the characters coding the tile types of minimap cells, in
+	// the order of worldTileTypeCodes.
+	minimapCellCodes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// minimapGeneration counts the invalidations of minimapCache, so that a minimap built
+// while one of its tiles changed is not cached.
+var (
+	minimapCache      = make(map[worldChunkCoord]*models.MinimapChunk)
+	minimapGeneration uint64
+	minimapCacheMu    sync.Mutex
+)
+
+// minimapPalette names the tile types of the minimap cell codes.
+var minimapPalette = func() []string {
+	palette := make([]string, len(worldTileTypeCodes))
+	for code, tileType := range worldTileTypeCodes {
+		palette[code] = string(tileType)
+	}
+	return palette
+}()
+
+// minimapChunk returns the minimap of a chunk.
+func minimapChunk(c worldChunkCoord) (*models.MinimapChunk, error) {
+	minimapCacheMu.Lock()
+	cached, ok := minimapCache[c]
+	generation := minimapGeneration
+	minimapCacheMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	chunk, err := loadWorldChunk(c)
+	if err != nil {
+		return nil, err
+	}
+	const cells = WorldChunkSize / minimapCellSize
+	counts := make([][]int, cells*cells)
+	for i := range counts {
+		counts[i] = make([]int, len(worldTileTypeCodes))
+	}
+	minimap := &models.MinimapChunk{X: c.CX, Y: c.CY}
+	forEachChunkTile(c, chunk, func(x, y int, tile models.WorldTile) {
+		tileType := TileType(tile.Type)
+		lx, ly := x-c.CX*WorldChunkSize, y-c.CY*WorldChunkSize
+		counts[(ly/minimapCellSize)*cells+lx/minimapCellSize][worldTileTypeIndex[tileType]]++
+		if tileType == TileTypeSanctuaryStone {
+			minimap.Sanctuaries = append(minimap.Sanctuaries, [2]int{x, y})
+		} else if TileDefs[tileType].IsStructure {
+			minimap.Structures = append(minimap.Structures, [2]int{x, y})
+		}
+	})
+
+	cellCodes := make([]byte, len(counts))
+	for i, cell := range counts {
+		dominant := 0
+		for code, count := range cell {
+			if count > cell[dominant] {
+				dominant = code
+			}
+		}
+		cellCodes[i] = minimapCellCodes[dominant]
+	}
+	minimap.Cells = string(cellCodes)
+
+	minimapCacheMu.Lock()
+	if minimapGeneration == generation {
+		minimapCache[c] = minimap
+	}
+	minimapCacheMu.Unlock()
+	return minimap, nil
+}
+
+// invalidateMinimapChunk drops the cached minimap of the chunk a changed tile is in.
+func invalidateMinimapChunk(x, y int) {
+	c, _ := worldChunkFor(x, y)
+	minimapCacheMu.Lock()
+	delete(minimapCache, c)
+	minimapGeneration++
+	minimapCacheMu.Unlock()
+}
+
+// resetMinimapCache drops every cached minimap, e.g. after the world is replaced.
+func resetMinimapCache() {
+	minimapCacheMu.Lock()
+	minimapCache = make(map[worldChunkCoord]*models.MinimapChunk)
+	minimapGeneration++
+	minimapCacheMu.Unlock()
+}
+
+// minimapMessage builds a MinimapMessage of some chunks. Chunks that cannot be read are
+// left out.
+func minimapMessage(chunks []worldChunkCoord) *models.MinimapMessage {
+	msg := &models.MinimapMessage{
+		Type:     string(ServerEventMinimap),
+		CellSize: minimapCellSize,
+		Palette:  minimapPalette,
+		Chunks:   make([]models.MinimapChunk, 0, len(chunks)),
+	}
+	for _, c := range chunks {
+		minimap, err := minimapChunk(c)
+		if err != nil {
+			log.Printf("Failed to build the minimap of chunk %d,%d: %v", c.CX, c.CY, err)
+			continue
+		}
+		msg.Chunks = append(msg.Chunks, *minimap)
+	}
+	return msg
+}
+
+// discoverWorldChunks records that a player has seen some chunks, and returns those of
+// them in the designed zones, which their minimap shows.
+func discoverWorldChunks(playerID string, chunks []worldChunkCoord) []worldChunkCoord {
+	var discovered []worldChunkCoord
+	members := make([]interface{}, 0, len(chunks))
+	for _, c := range chunks {
+		if isZoneLayer(zoneOfChunk(c)) {
+			discovered = append(discovered, c)
+			members = append(members, strconv.Itoa(c.CX)+","+strconv.Itoa(c.CY))
+		}
+	}
+	if len(discovered) == 0 {
+		return nil
+	}
+	if err := rdb.SAdd(ctx, string(RedisKeyPlayerDiscovered)+playerID, members...).Err(); err != nil {
+		log.Printf("Failed to record the chunks %s discovered: %v", playerID, err)
+	}
+	return discovered
+}
+
+// playerMinimap returns the minimap of every chunk a player has discovered.
+func playerMinimap(playerID string) *models.MinimapMessage {
+	members, err := rdb.SMembers(ctx, string(RedisKeyPlayerDiscovered)+playerID).Result()
+	if err != nil {
+		log.Printf("Failed to read the chunks %s discovered: %v", playerID, err)
+	}
+	chunks := make([]worldChunkCoord, 0, len(members))
+	for _, member := range members {
+		cx, cy := utils.ParseCoordKey(member)
+		chunks = append(chunks, worldChunkCoord{CX: cx, CY: cy})
+	}
+	return minimapMessage(chunks)
+}
@@ -9,7 +9,7 @@ import (
 // Node represents a point in the grid for pathfinding.
 type Node struct {
 	X, Y    int
-	Parent  *Node
+	Parent  *Node `json:"-"`
 	G, H, F float64
 	index   int // Index in the priority queue.
 }
@@ -20,6 +20,9 @@ type PriorityQueue []*Node
 func (pq PriorityQueue) Len() int { return len(pq) }
 
 func (pq PriorityQueue) Less(i, j int) bool {
+	if pq[i].F == pq[j].F {
+		return pq[i].G > pq[j].G // Prefer the node further along on ties
+	}
 	return pq[i].F < pq[j].F
 }
 
@@ -46,9 +49,103 @@ func (pq *PriorityQueue) Pop() interface{} {
 	return node
 }
 
-// manhattanDistance is a heuristic for A*.
-func manhattanDistance(a, b *Node) float64 {
-	return math.Abs(float64(a.X-b.X)) + math.Abs(float64(a.Y-b.Y))
+// PathLimits bounds the work a single path search may do.
+type PathLimits struct {
+	// LocalNodes is the most tiles one tile-level search may expand.
+	LocalNodes int
+	// AbstractNodes is the most portals the cluster-level search may expand.
+	AbstractNodes int
+	// DirectRange is the Manhattan distance below which the cluster graph is skipped and
+	// a tile-level search is run straight away.
+	DirectRange int
+}
+
+// PathSearchLimits are the limits used by FindPath and FindPathToAdjacent.
+var PathSearchLimits = PathLimits{
+	LocalNodes:    4000,
+	AbstractNodes: 3000,
+	DirectRange:   2 * pathClusterSize,
+}
+
+// pathPoint is a tile coordinate used as a map key by the pathfinder.
+type pathPoint struct{ X, Y int }
+
+func (p pathPoint) key() string {
+	return strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)
+}
+
+func pathDistance(a, b pathPoint) int {
+	return abs(a.X-b.X) + abs(a.Y-b.Y)
+}
+
+// pathBounds restricts a search to an inclusive rectangle of tiles.
+type pathBounds struct{ MinX, MinY, MaxX, MaxY int }
+
+// pathSearchMargin is how far a search may stray outside the rectangle spanned by its
+// start and end, and pathMaxSearchSpan how wide that rectangle may grow. Searches for
+// ends further away are clipped around the start and find a partial path.
+const (
+	pathSearchMargin  = WorldChunkSize
+	pathMaxSearchSpan = 16 * WorldChunkSize
+)
+
+// pathSearchArea returns the area a search from start to end may cover.
+func pathSearchArea(start, end pathPoint) pathBounds {
+	b := pathBounds{min(start.X, end.X), min(start.Y, end.Y), max(start.X, end.X), max(start.Y, end.Y)}
+	half := pathMaxSearchSpan / 2
+	return pathBounds{
+		max(b.MinX, start.X-half) - pathSearchMargin,
+		max(b.MinY, start.Y-half) - pathSearchMargin,
+		min(b.MaxX, start.X+half) + pathSearchMargin,
+		min(b.MaxY, start.Y+half) + pathSearchMargin,
+	}
+}
+
+func (b pathBounds) contains(p pathPoint) bool {
+	return p.X >= b.MinX && p.X <= b.MaxX && p.Y >= b.MinY && p.Y <= b.MaxY
+}
+
+func (b pathBounds) union(o pathBounds) pathBounds {
+	return pathBounds{min(b.MinX, o.MinX), min(b.MinY, o.MinY), max(b.MaxX, o.MaxX), max(b.MaxY, o.MaxY)}
+}
+
+// octileDistance is a heuristic for A* on a grid with diagonal steps: the cost of the
+// shortest walk between two tiles if nothing were in the way.
+func octileDistance(a, b *Node) float64 {
+	dx := math.Abs(float64(a.X - b.X))
+	dy := math.Abs(float64(a.Y - b.Y))
+	return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy)
+}
+
+// pathStep is a move onto a neighbouring tile and what it costs.
+type pathStep struct {
+	To   pathPoint
+	Cost float64
+}
+
+// pathDirections are the eight steps the pathfinder may take, straight ones first.
+var pathDirections = [8][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+
+// pathSteps returns the steps from p onto walkable tiles. Diagonal steps cost the square
+// root of two, and may not squeeze between two tiles that are not walkable; no step
+// climbs or jumps a cliff. These match the rules checkMove applies.
+func pathSteps(p pathPoint, walkable func(pathPoint) bool) []pathStep {
+	steps := make([]pathStep, 0, len(pathDirections))
+	for _, d := range pathDirections {
+		to := pathPoint{p.X + d[0], p.Y + d[1]}
+		if !walkable(to) || isCliffEdge(p, to) {
+			continue
+		}
+		cost := 1.0
+		if d[0] != 0 && d[1] != 0 {
+			if !walkable(pathPoint{p.X + d[0], p.Y}) && !walkable(pathPoint{p.X, p.Y + d[1]}) {
+				continue
+			}
+			cost = math.Sqrt2
+		}
+		steps = append(steps, pathStep{To: to, Cost: cost})
+	}
+	return steps
 }
 
 // isWalkable checks if a tile is passable, allowing the destination to be a collidable target.
@@ -58,76 +155,103 @@ func isWalkable(x, y, endX, endY int, tickCache *TickCache) bool {
 	if x == endX && y == endY {
 		return true
 	}
+	return isActuallyWalkable(x, y, tickCache)
+}
 
-	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
+// FindPath finds a path from start to end. Long paths are planned over the cluster graph
+// and then refined tile by tile; short ones are searched directly. Every search is
+// bounded by PathSearchLimits, and recent paths are reused from a cache.
+//
+// If end cannot be reached within the limits, the path leads to the closest tile the
+// search found, so callers can make progress towards it; check the last node to tell the
+// difference. It returns nil if no step towards end is possible.
+func FindPath(startX, startY, endX, endY int, tickCache *TickCache) []*Node {
+	path, _ := findPath(pathPoint{startX, startY}, pathPoint{endX, endY}, tickCache)
+	return pathNodes(path)
+}
 
-	// 1. Check world boundaries
-	if x < -WorldSize || x > WorldSize || y < -WorldSize || y > WorldSize {
-		return false
+// findPath is FindPath on pathPoints; it also reports whether the path reaches end.
+func findPath(start, end pathPoint, tickCache *TickCache) ([]pathPoint, bool) {
+	if start == end {
+		return []pathPoint{start}, true
 	}
-
-	// 2. Check for tile locks from the tick-local cache
-	if tickCache.LockedTiles[coordKey] {
-		return false
+	if path, complete, ok := cachedPath(start, end, tickCache); ok {
+		return path, complete
 	}
 
-	// 3. Check tile properties from the global in-memory grid
-	if tickCache.CollisionGrid[coordKey] {
-		return false // Tile is collidable
+	// The world is generated as it is needed, so the area may reach chunks no one has
+	// looked at yet. Load them first, so their walls are on the collision grid.
+	area := pathSearchArea(start, end)
+	if loadWorldChunksIn(area.MinX, area.MinY, area.MaxX, area.MaxY) {
+		tickCache.CollisionGrid = BuildCollisionGrid()
 	}
 
-	return true
+	walkable := func(p pathPoint) bool { return isWalkable(p.X, p.Y, end.X, end.Y, tickCache) }
+	var path []pathPoint
+	var complete bool
+	if pathDistance(start, end) <= PathSearchLimits.DirectRange {
+		path, complete = searchTiles(start, end, area, PathSearchLimits.LocalNodes, walkable)
+	} else {
+		path, complete = searchHierarchical(start, end, area, walkable)
+	}
+	if len(path) <= 1 {
+		return nil, false
+	}
+	storeCachedPath(end, path, complete)
+	return path, complete
 }
 
-// FindPath implements the A* algorithm.
-func FindPath(startX, startY, endX, endY int, tickCache *TickCache) []*Node {
-	startNode := &Node{X: startX, Y: startY}
-	endNode := &Node{X: endX, Y: endY}
+// searchTiles runs a bounded A* over tiles within bounds. It returns the path and
+// whether it reaches end; if it does not, the path leads to the explored tile closest
+// to end.
+func searchTiles(start, end pathPoint, bounds pathBounds, limit int, walkable func(pathPoint) bool) ([]pathPoint, bool) {
+	endNode := &Node{X: end.X, Y: end.Y}
+	startNode := &Node{X: start.X, Y: start.Y}
+	startNode.H = octileDistance(startNode, endNode)
+	startNode.F = startNode.H
 
 	openSet := make(PriorityQueue, 0)
 	heap.Push(&openSet, startNode)
 
 	// nodeMap helps us find existing nodes quickly to update them.
-	nodeMap := make(map[string]*Node)
-	nodeMap[strconv.Itoa(startNode.X)+","+strconv.Itoa(startNode.Y)] = startNode
+	nodeMap := map[pathPoint]*Node{start: startNode}
+	closedSet := make(map[pathPoint]bool)
+	closest := startNode
+	inBounds := func(p pathPoint) bool { return bounds.contains(p) && walkable(p) }
 
-	closedSet := make(map[string]bool)
-
-	for openSet.Len() > 0 {
+	for openSet.Len() > 0 && len(closedSet) < limit {
 		currentNode := heap.Pop(&openSet).(*Node)
-
-		coordKey := strconv.Itoa(currentNode.X) + "," + strconv.Itoa(currentNode.Y)
-		if closedSet[coordKey] {
+		current := pathPoint{currentNode.X, currentNode.Y}
+		if closedSet[current] {
 			continue
 		}
-		closedSet[coordKey] = true
+		closedSet[current] = true
 
-		if currentNode.X == endNode.X && currentNode.Y == endNode.Y {
-			return reconstructPath(currentNode)
+		if current == end {
+			return reconstructPathPoints(currentNode), true
+		}
+		if currentNode.H < closest.H || (currentNode.H == closest.H && currentNode.G < closest.G) {
+			closest = currentNode
 		}
 
-		for _, neighborCoords := range getNeighborCoords(currentNode) {
-			if !isWalkable(neighborCoords.X, neighborCoords.Y, endNode.X, endNode.Y, tickCache) {
-				continue
-			}
-
-			neighborKey := strconv.Itoa(neighborCoords.X) + "," + strconv.Itoa(neighborCoords.Y)
-			if closedSet[neighborKey] {
+		for _, step := range pathSteps(current, inBounds) {
+			neighbor := step.To
+			if closedSet[neighbor] {
 				continue
 			}
 
-			gScore := currentNode.G + 1 // Cost to move to a neighbor is always 1
+			gScore := currentNode.G + step.Cost
 
-			neighborNode, inOpenSet := nodeMap[neighborKey]
+			neighborNode, inOpenSet := nodeMap[neighbor]
 			if !inOpenSet || gScore < neighborNode.G {
 				if !inOpenSet {
-					neighborNode = &Node{X: neighborCoords.X, Y: neighborCoords.Y}
-					nodeMap[neighborKey] = neighborNode
+					neighborNode = &Node{X: neighbor.X, Y: neighbor.Y}
+					nodeMap[neighbor] = neighborNode
 				}
 
 				neighborNode.Parent = currentNode
 				neighborNode.G = gScore
-				neighborNode.H = manhattanDistance(neighborNode, endNode)
+				neighborNode.H = octileDistance(neighborNode, endNode)
 				neighborNode.F = neighborNode.G + neighborNode.H
 
 				if !inOpenSet {
@@ -138,35 +262,39 @@ func FindPath(startX, startY, endX, endY int, tickCache *TickCache) []*Node {
 			}
 		}
 	}
-	return nil // No path found
+	return reconstructPathPoints(closest), false
 }
 
 // FindPathToAdjacent finds a path from a start point to a tile adjacent to the target endpoint.
 // This is useful for things like resource gathering or interacting with objects where the
-// character needs to be next to the target, not on top of it.
+// character needs to be next to the target, not on top of it. Diagonal neighbours count,
+// as interactions and melee reach them. Only complete paths count.
 func FindPathToAdjacent(startX, startY, endX, endY int, tickCache *TickCache) []*Node {
-	var bestPath []*Node
-	var closestDist float64 = -1
+	var bestPath []pathPoint
+	bestCost := math.Inf(1)
+	if loadWorldChunksIn(endX-1, endY-1, endX+1, endY+1) {
+		tickCache.CollisionGrid = BuildCollisionGrid()
+	}
 
-	// Check all four neighbors of the target tile
-	for _, neighbor := range getNeighborCoords(&Node{X: endX, Y: endY}) {
+	// Check all eight neighbors of the target tile
+	for _, d := range pathDirections {
+		neighbor := Node{X: endX + d[0], Y: endY + d[1]}
 		// The isWalkable check for the pathfinder's destination is special.
 		// Here, we need to know if the tile is *actually* walkable for a move.
-		if isActuallyWalkable(neighbor.X, neighbor.Y, tickCache) {
-			path := FindPath(startX, startY, neighbor.X, neighbor.Y, tickCache)
-			if path != nil {
-				// We found a valid path. See if it's the best one so far.
-				// "Best" is defined as the shortest path.
-				pathLength := float64(len(path))
-				if bestPath == nil || pathLength < closestDist {
-					bestPath = path
-					closestDist = pathLength
-				}
-			}
+		if !isActuallyWalkable(neighbor.X, neighbor.Y, tickCache) {
+			continue
+		}
+		if neighbor.X == startX && neighbor.Y == startY {
+			return pathNodes([]pathPoint{{startX, startY}})
+		}
+		path, complete := findPath(pathPoint{startX, startY}, pathPoint{neighbor.X, neighbor.Y}, tickCache)
+		// "Best" is defined as the shortest path.
+		if cost := pathCost(path); complete && cost < bestCost {
+			bestPath, bestCost = path, cost
 		}
 	}
 
-	return bestPath
+	return pathNodes(bestPath)
 }
 
 // isActuallyWalkable is a stricter version of isWalkable that does not
@@ -175,17 +303,12 @@ func FindPathToAdjacent(startX, startY, endX, endY int, tickCache *TickCache) []
 func isActuallyWalkable(x, y int, tickCache *TickCache) bool {
 	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
 
-	// 1. Check world boundaries
-	if x < -WorldSize || x > WorldSize || y < -WorldSize || y > WorldSize {
-		return false
-	}
-
-	// 2. Check for tile locks from the tick-local cache
+	// 1. Check for tile locks from the tick-local cache
 	if tickCache.LockedTiles[coordKey] {
 		return false
 	}
 
-	// 3. Check tile properties from the global in-memory grid
+	// 2. Check tile properties from the global in-memory grid
 	if tickCache.CollisionGrid[coordKey] {
 		return false // Tile is collidable
 	}
@@ -193,18 +316,11 @@ func isActuallyWalkable(x, y int, tickCache *TickCache) bool {
 	return true
 }
 
-func getNeighborCoords(node *Node) []Node {
-	var neighbors []Node
-	for _, d := range []struct{ dx, dy int }{{0, 1}, {0, -1}, {1, 0}, {-1, 0}} {
-		neighbors = append(neighbors, Node{X: node.X + d.dx, Y: node.Y + d.dy})
-	}
-	return neighbors
-}
-
-func reconstructPath(node *Node) []*Node {
-	var path []*Node
+// reconstructPathPoints follows parents back from node and returns the path in order.
+func reconstructPathPoints(node *Node) []pathPoint {
+	var path []pathPoint
 	for current := node; current != nil; current = current.Parent {
-		path = append(path, current)
+		path = append(path, pathPoint{current.X, current.Y})
 	}
 	// Reverse path
 	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
@@ -212,3 +328,28 @@ func reconstructPath(node *Node) []*Node {
 	}
 	return path
 }
+
+// pathCost is the total cost of walking a path.
+func pathCost(path []pathPoint) float64 {
+	cost := 0.0
+	for i := 1; i < len(path); i++ {
+		if path[i].X != path[i-1].X && path[i].Y != path[i-1].Y {
+			cost += math.Sqrt2
+		} else {
+			cost++
+		}
+	}
+	return cost
+}
+
+// pathNodes converts a path to the Nodes returned by FindPath.
+func pathNodes(path []pathPoint) []*Node {
+	if path == nil {
+		return nil
+	}
+	nodes := make([]*Node, len(path))
+	for i, p := range path {
+		nodes[i] = &Node{X: p.X, Y: p.Y, G: float64(i)}
+	}
+	return nodes
+}
@@ -0,0 +1,562 @@
+package game
+
+import (
+	"container/heap"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The hierarchical pathfinder splits the world into square clusters aligned with the
+// world chunks (each chunk holds 2x2 clusters). Wherever two neighbouring clusters share
+// an open stretch of border, a pair of portal tiles joins them. Paths are first planned
+// over the graph of portals, which only changes with the collision grid, and then
+// refined tile by tile between consecutive portals, taking tile locks into account.
+const (
+	pathClusterSize = WorldChunkSize / 2
+
+	// pathLongEntrance is the entrance width from which an entrance gets a portal at each
+	// end rather than a single one in its middle.
+	pathLongEntrance = 6
+
+	pathCacheSize = 512
+	pathCacheTTL  = 5 * time.Second
+)
+
+type pathCluster struct{ CX, CY int }
+
+func pathClusterFor(p pathPoint) pathCluster {
+	return pathCluster{floorDiv(p.X, pathClusterSize), floorDiv(p.Y, pathClusterSize)}
+}
+
+// bounds returns the cluster's tiles.
+func (c pathCluster) bounds() pathBounds {
+	return pathBounds{
+		MinX: c.CX * pathClusterSize,
+		MinY: c.CY * pathClusterSize,
+		MaxX: c.CX*pathClusterSize + pathClusterSize - 1,
+		MaxY: c.CY*pathClusterSize + pathClusterSize - 1,
+	}
+}
+
+// pathBorder is the border on the east (South false) or south (South true) side of a cluster.
+type pathBorder struct {
+	Cluster pathCluster
+	South   bool
+}
+
+type portalEdge struct {
+	To   pathPoint
+	Cost float64
+}
+
+// pathGraph is the cluster-level graph built from the collision grid. The world has no
+// edge, so clusters are only built as searches reach them; tiles of clusters that have
+// not been built are not walkable.
+type pathGraph struct {
+	mu         sync.RWMutex
+	version    uint64
+	generation uint64
+	built      bool
+	clusters   map[pathCluster]bool
+	// blocked holds the collidable tiles of the built clusters, keyed by tile, which is
+	// much cheaper to look up while searching than the grid's string keys.
+	blocked map[pathPoint]bool
+
+	// borders holds the portal pairs of each border, the first of each pair on the
+	// border's own cluster.
+	borders map[pathBorder][][2]pathPoint
+	// intra holds the walking distance between the portals of each cluster.
+	intra map[pathCluster]map[pathPoint][]portalEdge
+	// inter joins each portal to its partners across borders.
+	inter map[pathPoint][]pathPoint
+}
+
+var worldPathGraph = &pathGraph{}
+
+// staticWalkable reports whether a tile can be walked on, ignoring tile locks.
+func (g *pathGraph) staticWalkable(p pathPoint) bool {
+	return g.clusters[pathClusterFor(p)] && !g.blocked[p]
+}
+
+// covers reports whether every cluster of area has been built. The caller must hold g.mu.
+func (g *pathGraph) covers(area pathBounds) bool {
+	minC, maxC := pathClusterFor(pathPoint{area.MinX, area.MinY}), pathClusterFor(pathPoint{area.MaxX, area.MaxY})
+	for cx := minC.CX; cx <= maxC.CX; cx++ {
+		for cy := minC.CY; cy <= maxC.CY; cy++ {
+			if !g.clusters[pathCluster{cx, cy}] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// refresh brings the graph up to date with the collision grid and builds the clusters
+// of area that have not been built yet, whose chunks must already be loaded. Only new
+// clusters and those the grid reports changed since the last refresh are rebuilt, in
+// place; the graph only starts over when the world or the whole grid was replaced.
+func (g *pathGraph) refresh(area pathBounds) {
+	version, generation := CollisionGridVersion(), currentWorldChunkGeneration()
+	g.mu.RLock()
+	upToDate := g.built && g.version == version && g.generation == generation && g.covers(area)
+	g.mu.RUnlock()
+	if upToDate {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	version, generation = CollisionGridVersion(), currentWorldChunkGeneration()
+	if g.built && g.version == version && g.generation == generation && g.covers(area) {
+		return
+	}
+	version, dirty, replaced := takeCollisionChanges()
+
+	if !g.built || g.generation != generation || replaced {
+		// Nothing is built yet, or the world was replaced: start over.
+		g.clusters = make(map[pathCluster]bool)
+		g.blocked = make(map[pathPoint]bool)
+		g.borders = make(map[pathBorder][][2]pathPoint)
+		g.intra = make(map[pathCluster]map[pathPoint][]portalEdge)
+		g.inter = make(map[pathPoint][]pathPoint)
+		dirty = make(map[pathCluster]bool)
+	}
+	for c := range dirty {
+		if !g.clusters[c] {
+			delete(dirty, c) // Read from the grid when it is first built.
+			continue
+		}
+		g.loadBlocked(c)
+	}
+	minC, maxC := pathClusterFor(pathPoint{area.MinX, area.MinY}), pathClusterFor(pathPoint{area.MaxX, area.MaxY})
+	for cx := minC.CX; cx <= maxC.CX; cx++ {
+		for cy := minC.CY; cy <= maxC.CY; cy++ {
+			if c := (pathCluster{cx, cy}); !g.clusters[c] {
+				g.clusters[c] = true
+				g.loadBlocked(c)
+				dirty[c] = true
+			}
+		}
+	}
+	g.version = version
+	g.generation = generation
+	g.built = true
+
+	// A changed cluster changes its four borders, and with them the portals of the
+	// neighbours on the other side.
+	affected := make(map[pathCluster]bool)
+	for c := range dirty {
+		g.buildBorder(pathBorder{c, false})
+		g.buildBorder(pathBorder{c, true})
+		g.buildBorder(pathBorder{pathCluster{c.CX - 1, c.CY}, false})
+		g.buildBorder(pathBorder{pathCluster{c.CX, c.CY - 1}, true})
+		affected[c] = true
+		affected[pathCluster{c.CX - 1, c.CY}] = true
+		affected[pathCluster{c.CX + 1, c.CY}] = true
+		affected[pathCluster{c.CX, c.CY - 1}] = true
+		affected[pathCluster{c.CX, c.CY + 1}] = true
+	}
+	for c := range affected {
+		if g.clusters[c] {
+			g.buildIntra(c)
+		}
+	}
+}
+
+// loadBlocked copies the collidable tiles of a cluster from the collision grid.
+func (g *pathGraph) loadBlocked(c pathCluster) {
+	b := c.bounds()
+	for y := b.MinY; y <= b.MaxY; y++ {
+		for x := b.MinX; x <= b.MaxX; x++ {
+			delete(g.blocked, pathPoint{x, y})
+		}
+	}
+	for _, p := range collisionsIn(b) {
+		g.blocked[p] = true
+	}
+}
+
+func parsePathKey(coordKey string) (int, int) {
+	xs, ys, _ := strings.Cut(coordKey, ",")
+	x, _ := strconv.Atoi(xs)
+	y, _ := strconv.Atoi(ys)
+	return x, y
+}
+
+// buildBorder finds the entrances across a border: runs of tiles open on both sides,
+// with no cliff between them, replacing the border's old portal pairs in inter.
+func (g *pathGraph) buildBorder(border pathBorder) {
+	for _, pair := range g.borders[border] {
+		g.unlinkPortal(pair[0], pair[1])
+		g.unlinkPortal(pair[1], pair[0])
+	}
+	delete(g.borders, border)
+	b := border.Cluster.bounds()
+
+	var inside, outside func(i int) pathPoint
+	var from, to int
+	if border.South {
+		inside = func(i int) pathPoint { return pathPoint{i, b.MaxY} }
+		outside = func(i int) pathPoint { return pathPoint{i, b.MaxY + 1} }
+		from, to = b.MinX, b.MaxX
+	} else {
+		inside = func(i int) pathPoint { return pathPoint{b.MaxX, i} }
+		outside = func(i int) pathPoint { return pathPoint{b.MaxX + 1, i} }
+		from, to = b.MinY, b.MaxY
+	}
+
+	var pairs [][2]pathPoint
+	inRun, runStart := false, 0
+	closeRun := func(end int) {
+		if !inRun {
+			return
+		}
+		if end-runStart+1 >= pathLongEntrance {
+			pairs = append(pairs, [2]pathPoint{inside(runStart), outside(runStart)})
+			pairs = append(pairs, [2]pathPoint{inside(end), outside(end)})
+		} else {
+			mid := (runStart + end) / 2
+			pairs = append(pairs, [2]pathPoint{inside(mid), outside(mid)})
+		}
+		inRun = false
+	}
+	for i := from; i <= to; i++ {
+		if g.staticWalkable(inside(i)) && g.staticWalkable(outside(i)) && !isCliffEdge(inside(i), outside(i)) {
+			if !inRun {
+				inRun, runStart = true, i
+			}
+			continue
+		}
+		closeRun(i - 1)
+	}
+	closeRun(to)
+
+	if len(pairs) > 0 {
+		g.borders[border] = pairs
+	}
+	for _, pair := range pairs {
+		g.inter[pair[0]] = append(g.inter[pair[0]], pair[1])
+		g.inter[pair[1]] = append(g.inter[pair[1]], pair[0])
+	}
+}
+
+// unlinkPortal takes partner off a portal's partners across borders.
+func (g *pathGraph) unlinkPortal(portal, partner pathPoint) {
+	partners := g.inter[portal]
+	for i, p := range partners {
+		if p == partner {
+			partners = append(partners[:i:i], partners[i+1:]...)
+			break
+		}
+	}
+	if len(partners) == 0 {
+		delete(g.inter, portal)
+	} else {
+		g.inter[portal] = partners
+	}
+}
+
+// clusterPortals returns the portal tiles on a cluster's side of its four borders.
+func (g *pathGraph) clusterPortals(c pathCluster) []pathPoint {
+	var portals []pathPoint
+	for _, pair := range g.borders[pathBorder{c, false}] {
+		portals = append(portals, pair[0])
+	}
+	for _, pair := range g.borders[pathBorder{c, true}] {
+		portals = append(portals, pair[0])
+	}
+	for _, pair := range g.borders[pathBorder{pathCluster{c.CX - 1, c.CY}, false}] {
+		portals = append(portals, pair[1])
+	}
+	for _, pair := range g.borders[pathBorder{pathCluster{c.CX, c.CY - 1}, true}] {
+		portals = append(portals, pair[1])
+	}
+	return portals
+}
+
+// buildIntra measures the walking cost between every pair of portals in a cluster
+// without leaving it.
+func (g *pathGraph) buildIntra(c pathCluster) {
+	portals := g.clusterPortals(c)
+	edges := make(map[pathPoint][]portalEdge, len(portals))
+	bounds := c.bounds()
+	for _, from := range portals {
+		distances := clusterDistances(from, bounds, g.staticWalkable)
+		for _, to := range portals {
+			if to == from {
+				continue
+			}
+			if d, ok := distances.cost(to); ok {
+				edges[from] = append(edges[from], portalEdge{To: to, Cost: d})
+			}
+		}
+	}
+	g.intra[c] = edges
+}
+
+// clusterDistanceTable holds the walking cost from one tile to the others in a bounded
+// area, indexed by tile.
+type clusterDistanceTable struct {
+	bounds pathBounds
+	costs  []float64 // -1 where unreachable
+}
+
+func (t *clusterDistanceTable) index(p pathPoint) int {
+	return (p.Y-t.bounds.MinY)*(t.bounds.MaxX-t.bounds.MinX+1) + (p.X - t.bounds.MinX)
+}
+
+// cost returns the walking cost to p, and whether p can be reached at all.
+func (t *clusterDistanceTable) cost(p pathPoint) (float64, bool) {
+	if !t.bounds.contains(p) {
+		return 0, false
+	}
+	c := t.costs[t.index(p)]
+	return c, c >= 0
+}
+
+// clusterDistances returns the walking cost from a tile to every tile reachable from it
+// within bounds.
+func clusterDistances(from pathPoint, bounds pathBounds, walkable func(pathPoint) bool) *clusterDistanceTable {
+	table := &clusterDistanceTable{
+		bounds: bounds,
+		costs:  make([]float64, (bounds.MaxX-bounds.MinX+1)*(bounds.MaxY-bounds.MinY+1)),
+	}
+	for i := range table.costs {
+		table.costs[i] = -1
+	}
+	if !bounds.contains(from) {
+		return table
+	}
+	inBounds := func(p pathPoint) bool { return bounds.contains(p) && walkable(p) }
+	nodes := make([]*Node, len(table.costs))
+	done := make([]bool, len(table.costs))
+
+	start := &Node{X: from.X, Y: from.Y}
+	nodes[table.index(from)] = start
+	table.costs[table.index(from)] = 0
+	openSet := PriorityQueue{}
+	heap.Push(&openSet, start)
+	for openSet.Len() > 0 {
+		current := heap.Pop(&openSet).(*Node)
+		p := pathPoint{current.X, current.Y}
+		done[table.index(p)] = true
+		for _, step := range pathSteps(p, inBounds) {
+			i := table.index(step.To)
+			if done[i] {
+				continue
+			}
+			d := current.G + step.Cost
+			if known := table.costs[i]; known >= 0 && known <= d {
+				continue
+			}
+			table.costs[i] = d
+			if next := nodes[i]; next != nil {
+				next.G, next.F = d, d
+				heap.Fix(&openSet, next.index)
+			} else {
+				next = &Node{X: step.To.X, Y: step.To.Y, G: d, F: d}
+				nodes[i] = next
+				heap.Push(&openSet, next)
+			}
+		}
+	}
+	return table
+}
+
+// searchHierarchical plans a path over the portal graph and refines it tile by tile.
+func searchHierarchical(start, end pathPoint, area pathBounds, walkable func(pathPoint) bool) ([]pathPoint, bool) {
+	g := worldPathGraph
+	g.refresh(area)
+	g.mu.RLock()
+	waypoints, ok := g.searchPortals(start, end)
+	g.mu.RUnlock()
+	if !ok {
+		// The portal graph has no route, or not one within the limit; head straight for
+		// the target as far as a bounded tile search gets.
+		return searchTiles(start, end, area, PathSearchLimits.LocalNodes, walkable)
+	}
+
+	path := []pathPoint{start}
+	for i := 1; i < len(waypoints); i++ {
+		from, to := path[len(path)-1], waypoints[i]
+		bounds := pathClusterFor(from).bounds().union(pathClusterFor(to).bounds())
+		segment, complete := searchTiles(from, to, bounds, PathSearchLimits.LocalNodes, walkable)
+		path = append(path, segment[1:]...)
+		if !complete {
+			// Something not on the collision grid, such as another entity, blocks the way.
+			return path, false
+		}
+	}
+	return path, true
+}
+
+// searchPortals runs A* over the portal graph from start to end, with start and end
+// joined to the portals of their clusters. It returns the waypoints, start and end
+// included. The caller must hold g.mu.
+func (g *pathGraph) searchPortals(start, end pathPoint) ([]pathPoint, bool) {
+	startCluster, endCluster := pathClusterFor(start), pathClusterFor(end)
+
+	startLinks := g.clusterLinks(start, startCluster, false)
+	endLinks := make(map[pathPoint]float64)
+	for _, edge := range g.clusterLinks(end, endCluster, true) {
+		endLinks[edge.To] = edge.Cost
+	}
+
+	endNode := &Node{X: end.X, Y: end.Y}
+	startNode := &Node{X: start.X, Y: start.Y}
+	nodes := map[pathPoint]*Node{start: startNode}
+	openSet := PriorityQueue{}
+	heap.Push(&openSet, startNode)
+	closedSet := make(map[pathPoint]bool)
+
+	for openSet.Len() > 0 && len(closedSet) < PathSearchLimits.AbstractNodes {
+		current := heap.Pop(&openSet).(*Node)
+		p := pathPoint{current.X, current.Y}
+		if closedSet[p] {
+			continue
+		}
+		closedSet[p] = true
+		if p == end {
+			return reconstructPathPoints(current), true
+		}
+
+		var edges []portalEdge
+		if p == start {
+			edges = startLinks
+		} else {
+			edges = append(edges, g.intra[pathClusterFor(p)][p]...)
+			for _, partner := range g.inter[p] {
+				edges = append(edges, portalEdge{To: partner, Cost: 1})
+			}
+			if cost, ok := endLinks[p]; ok {
+				edges = append(edges, portalEdge{To: end, Cost: cost})
+			}
+		}
+
+		for _, edge := range edges {
+			if closedSet[edge.To] {
+				continue
+			}
+			gScore := current.G + edge.Cost
+			next, seen := nodes[edge.To]
+			if seen && gScore >= next.G {
+				continue
+			}
+			if !seen {
+				next = &Node{X: edge.To.X, Y: edge.To.Y}
+				nodes[edge.To] = next
+			}
+			next.Parent = current
+			next.G = gScore
+			next.H = octileDistance(next, endNode)
+			next.F = next.G + next.H
+			if !seen {
+				heap.Push(&openSet, next)
+			} else {
+				heap.Fix(&openSet, next.index) // Update priority
+			}
+		}
+	}
+	return nil, false
+}
+
+// clusterLinks joins a tile that is not a portal to the portals of its cluster. The end
+// tile may be a collidable target, so it is not itself checked.
+func (g *pathGraph) clusterLinks(p pathPoint, c pathCluster, isEnd bool) []portalEdge {
+	walkable := g.staticWalkable
+	if isEnd {
+		walkable = func(q pathPoint) bool { return q == p || g.staticWalkable(q) }
+	}
+	distances := clusterDistances(p, c.bounds(), walkable)
+	var links []portalEdge
+	for _, portal := range g.clusterPortals(c) {
+		if d, ok := distances.cost(portal); ok {
+			links = append(links, portalEdge{To: portal, Cost: d})
+		}
+	}
+	return links
+}
+
+// cachedPathEntry is a recently found path, stored under its destination so that any
+// entity already on it, such as an NPC chasing the same target, can reuse the rest. It
+// holds the collision grid version it was found at, and stays valid until one of the
+// clusters it crosses changes after that.
+type cachedPathEntry struct {
+	path     []pathPoint
+	complete bool
+	version  uint64
+	expires  time.Time
+}
+
+var (
+	pathCacheMu sync.Mutex
+	pathCache   = make(map[pathPoint]*cachedPathEntry)
+)
+
+// cachedPath returns the rest of a cached path to end that passes through start, as
+// long as none of the clusters it crosses has changed on the collision grid and no
+// locked tile now blocks it.
+func cachedPath(start, end pathPoint, tickCache *TickCache) ([]pathPoint, bool, bool) {
+	pathCacheMu.Lock()
+	entry, ok := pathCache[end]
+	pathCacheMu.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false, false
+	}
+
+	for i, p := range entry.path {
+		if p != start {
+			continue
+		}
+		rest := entry.path[i:]
+		if collisionChangedSince(pathClusters(rest), entry.version) {
+			return nil, false, false
+		}
+		for _, step := range rest[1:] {
+			if !isWalkable(step.X, step.Y, end.X, end.Y, tickCache) {
+				return nil, false, false
+			}
+		}
+		return append([]pathPoint(nil), rest...), entry.complete, true
+	}
+	return nil, false, false
+}
+
+// pathClusters returns the clusters a path crosses, in order.
+func pathClusters(path []pathPoint) []pathCluster {
+	var clusters []pathCluster
+	for _, p := range path {
+		if c := pathClusterFor(p); len(clusters) == 0 || clusters[len(clusters)-1] != c {
+			clusters = append(clusters, c)
+		}
+	}
+	return clusters
+}
+
+func storeCachedPath(end pathPoint, path []pathPoint, complete bool) {
+	pathCacheMu.Lock()
+	defer pathCacheMu.Unlock()
+	if len(pathCache) >= pathCacheSize {
+		now := time.Now()
+		for key, entry := range pathCache {
+			if now.After(entry.expires) {
+				delete(pathCache, key)
+			}
+		}
+		// Still full of live paths: drop an arbitrary one.
+		for key := range pathCache {
+			if len(pathCache) < pathCacheSize {
+				break
+			}
+			delete(pathCache, key)
+		}
+	}
+	pathCache[end] = &cachedPathEntry{
+		path:     append([]pathPoint(nil), path...),
+		complete: complete,
+		version:  CollisionGridVersion(),
+		expires:  time.Now().Add(pathCacheTTL),
+	}
+}
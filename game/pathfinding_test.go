@@ -0,0 +1,364 @@
+package game
+
+import (
+	"mmo-game/models"
+	"testing"
+)
+
+// setUpPathWorld replaces the world with open ground around the origin, with rock on the
+// given tiles, and returns a tick cache for it. Every chunk the tests search is cached,
+// so nothing is read from Redis.
+func setUpPathWorld(t *testing.T, walls []pathPoint) *TickCache {
+	t.Helper()
+	resetWorldChunkCache()
+	ground := encodeWorldTile(models.WorldTile{Type: string(TileTypeGround)})
+	rock := encodeWorldTile(models.WorldTile{Type: string(TileTypeRock)})
+	for cx := -4; cx <= 6; cx++ {
+		for cy := -4; cy <= 4; cy++ {
+			chunk := make([]byte, worldChunkBytes)
+			for offset := 0; offset < worldChunkBytes; offset += worldTileRecordSize {
+				copy(chunk[offset:], ground[:])
+			}
+			cacheWorldChunk(worldChunkCoord{CX: cx, CY: cy}, chunk, true)
+		}
+	}
+	for _, p := range walls {
+		c, offset := worldChunkFor(p.X, p.Y)
+		chunk, err := loadWorldChunk(c)
+		if err != nil {
+			t.Fatalf("chunk %v is not cached: %v", c, err)
+		}
+		copy(chunk[offset:], rock[:])
+	}
+	return resetPathState()
+}
+
+// setUpGeneratedPathWorld replaces the world with the chunks the world generator makes
+// around the origin, cliffs and all, and returns a tick cache for it.
+func setUpGeneratedPathWorld(t *testing.T) *TickCache {
+	t.Helper()
+	if !worldGen.cfg.Cliffs {
+		t.Skip("the world generator makes no cliffs")
+	}
+	resetWorldChunkCache()
+	for cx := -4; cx <= 4; cx++ {
+		for cy := -4; cy <= 4; cy++ {
+			c := worldChunkCoord{CX: cx, CY: cy}
+			chunk := make([]byte, worldChunkBytes)
+			fillWorldChunk(c, chunk)
+			cacheWorldChunk(c, chunk, true)
+		}
+	}
+	return resetPathState()
+}
+
+// resetPathState rebuilds the collision grid from the cached chunks, drops the path
+// graph and cached paths, and returns a tick cache for the new world.
+func resetPathState() *TickCache {
+	InitializeCollisionGrid()
+
+	worldPathGraph = &pathGraph{}
+	pathCacheMu.Lock()
+	pathCache = make(map[pathPoint]*cachedPathEntry)
+	pathCacheMu.Unlock()
+	return &TickCache{LockedTiles: map[string]bool{}, CollisionGrid: BuildCollisionGrid()}
+}
+
+// column returns the tiles of a column from y0 to y1, skipping gap.
+func column(x, y0, y1 int, gap ...int) []pathPoint {
+	var tiles []pathPoint
+	for y := y0; y <= y1; y++ {
+		skip := false
+		for _, g := range gap {
+			skip = skip || y == g
+		}
+		if !skip {
+			tiles = append(tiles, pathPoint{x, y})
+		}
+	}
+	return tiles
+}
+
+// ring returns the eight tiles around a tile.
+func ring(center pathPoint) []pathPoint {
+	var tiles []pathPoint
+	for _, d := range pathDirections {
+		tiles = append(tiles, pathPoint{center.X + d[0], center.Y + d[1]})
+	}
+	return tiles
+}
+
+// checkPath fails the test unless path starts at start, takes single steps, never
+// steps on a wall and never climbs or jumps a cliff.
+func checkPath(t *testing.T, path []pathPoint, start pathPoint, walls []pathPoint) {
+	t.Helper()
+	if len(path) == 0 || path[0] != start {
+		t.Fatalf("path %v does not start at %v", path, start)
+	}
+	blocked := make(map[pathPoint]bool)
+	for _, p := range walls {
+		blocked[p] = true
+	}
+	for i, p := range path {
+		if blocked[p] {
+			t.Fatalf("path steps on wall %v", p)
+		}
+		if i > 0 && (abs(p.X-path[i-1].X) > 1 || abs(p.Y-path[i-1].Y) > 1) {
+			t.Fatalf("path jumps from %v to %v", path[i-1], p)
+		}
+		if i > 0 && isCliffEdge(path[i-1], p) {
+			t.Fatalf("path crosses a cliff from %v to %v", path[i-1], p)
+		}
+	}
+}
+
+func TestSearchTiles(t *testing.T) {
+	bounds := pathBounds{-20, -20, 200, 20}
+	tests := []struct {
+		name       string
+		start, end pathPoint
+		bounds     pathBounds
+		limit      int
+		walls      []pathPoint
+		complete   bool
+		last       pathPoint
+		maxLen     int
+	}{
+		{
+			name:     "open ground",
+			start:    pathPoint{0, 0},
+			end:      pathPoint{5, 0},
+			bounds:   bounds,
+			limit:    100,
+			complete: true,
+			last:     pathPoint{5, 0},
+			maxLen:   6,
+		},
+		{
+			name:     "through a gap in a wall",
+			start:    pathPoint{0, 0},
+			end:      pathPoint{4, 0},
+			bounds:   bounds,
+			limit:    1000,
+			walls:    column(2, -10, 10, 5),
+			complete: true,
+			last:     pathPoint{4, 0},
+		},
+		{
+			name:     "walled in end leads to the closest tile",
+			start:    pathPoint{0, 0},
+			end:      pathPoint{6, 0},
+			bounds:   bounds,
+			limit:    1000,
+			walls:    ring(pathPoint{6, 0}),
+			complete: false,
+			last:     pathPoint{4, 0},
+		},
+		{
+			name:     "node limit cuts the search short",
+			start:    pathPoint{0, 0},
+			end:      pathPoint{150, 0},
+			bounds:   bounds,
+			limit:    10,
+			complete: false,
+			maxLen:   11,
+		},
+		{
+			name:     "end outside the bounds",
+			start:    pathPoint{0, 0},
+			end:      pathPoint{30, 0},
+			bounds:   pathBounds{-5, -5, 10, 5},
+			limit:    1000,
+			complete: false,
+			last:     pathPoint{10, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocked := make(map[pathPoint]bool)
+			for _, p := range tt.walls {
+				blocked[p] = true
+			}
+			walkable := func(p pathPoint) bool { return !blocked[p] }
+
+			path, complete := searchTiles(tt.start, tt.end, tt.bounds, tt.limit, walkable)
+			checkPath(t, path, tt.start, tt.walls)
+			if complete != tt.complete {
+				t.Errorf("complete = %v, want %v", complete, tt.complete)
+			}
+			last := path[len(path)-1]
+			if tt.last != (pathPoint{}) && last != tt.last {
+				t.Errorf("path ends at %v, want %v", last, tt.last)
+			}
+			if !complete && last == tt.end {
+				t.Errorf("incomplete path reaches the end")
+			}
+			if !complete && pathDistance(last, tt.end) >= pathDistance(tt.start, tt.end) {
+				t.Errorf("partial path ending at %v gets no closer to %v", last, tt.end)
+			}
+			if tt.maxLen > 0 && len(path) > tt.maxLen {
+				t.Errorf("path has %d tiles, want at most %d", len(path), tt.maxLen)
+			}
+		})
+	}
+}
+
+func TestFindPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end pathPoint
+		walls      []pathPoint
+		localNodes int
+		complete   bool
+		nilPath    bool
+	}{
+		{
+			name:     "already there",
+			start:    pathPoint{3, 3},
+			end:      pathPoint{3, 3},
+			complete: true,
+		},
+		{
+			name:     "short path",
+			start:    pathPoint{0, 0},
+			end:      pathPoint{10, 0},
+			complete: true,
+		},
+		{
+			name:     "long path around a wall",
+			start:    pathPoint{0, 0},
+			end:      pathPoint{60, 0},
+			walls:    column(30, -20, 20),
+			complete: true,
+		},
+		{
+			name:     "walled in end gives a partial path",
+			start:    pathPoint{0, 0},
+			end:      pathPoint{60, 0},
+			walls:    ring(pathPoint{60, 0}),
+			complete: false,
+		},
+		{
+			name:       "node limit gives a partial path",
+			start:      pathPoint{0, 0},
+			end:        pathPoint{20, 0},
+			walls:      column(10, -30, 30),
+			localNodes: 50,
+			complete:   false,
+		},
+		{
+			name:    "boxed in start has no path",
+			start:   pathPoint{0, 0},
+			end:     pathPoint{10, 0},
+			walls:   ring(pathPoint{0, 0}),
+			nilPath: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.localNodes > 0 {
+				limits := PathSearchLimits
+				PathSearchLimits.LocalNodes = tt.localNodes
+				t.Cleanup(func() { PathSearchLimits = limits })
+			}
+			tickCache := setUpPathWorld(t, tt.walls)
+
+			path, complete := findPath(tt.start, tt.end, tickCache)
+			if tt.nilPath {
+				if path != nil {
+					t.Fatalf("path = %v, want none", path)
+				}
+				return
+			}
+			checkPath(t, path, tt.start, tt.walls)
+			if complete != tt.complete {
+				t.Errorf("complete = %v, want %v", complete, tt.complete)
+			}
+			if last := path[len(path)-1]; complete != (last == tt.end) {
+				t.Errorf("path ends at %v with complete = %v", last, complete)
+			}
+		})
+	}
+}
+
+// TestFindPathFollowsCollisionChanges checks that a wall added after a path was found
+// reaches both the portal graph and the path cache.
+func TestFindPathFollowsCollisionChanges(t *testing.T) {
+	start, end := pathPoint{0, 0}, pathPoint{60, 0}
+	tickCache := setUpPathWorld(t, nil)
+	if _, complete := findPath(start, end, tickCache); !complete {
+		t.Fatalf("no path across open ground")
+	}
+
+	walls := column(30, -60, 60, 25)
+	for _, p := range walls {
+		setTileCollision(p.X, p.Y, true)
+	}
+	tickCache.CollisionGrid = BuildCollisionGrid()
+
+	path, complete := findPath(start, end, tickCache)
+	if !complete {
+		t.Fatalf("no path through the gap")
+	}
+	checkPath(t, path, start, walls)
+	gap := false
+	for _, p := range path {
+		gap = gap || p == pathPoint{30, 25}
+	}
+	if !gap {
+		t.Errorf("path %v does not pass through the gap", path)
+	}
+}
+
+// TestFindPathGoesAroundCliffs checks that paths between tiles either side of a cliff
+// edge, where the straight route is a single step across it, go around it instead,
+// both searched directly and over the cluster graph.
+func TestFindPathGoesAroundCliffs(t *testing.T) {
+	tickCache := setUpGeneratedPathWorld(t)
+
+	// Find the cliff edges between two walkable tiles, such as diagonally past the end
+	// of a cliff, that no wall stands on.
+	var edges [][2]pathPoint
+	for x := -100; x < 100 && len(edges) < 20; x++ {
+		for y := -100; y < 100 && len(edges) < 20; y++ {
+			from := pathPoint{x, y}
+			if !isActuallyWalkable(from.X, from.Y, tickCache) {
+				continue
+			}
+			for _, d := range pathDirections {
+				to := pathPoint{x + d[0], y + d[1]}
+				if isActuallyWalkable(to.X, to.Y, tickCache) && isCliffEdge(from, to) {
+					edges = append(edges, [2]pathPoint{from, to})
+					break
+				}
+			}
+		}
+	}
+	if len(edges) == 0 {
+		t.Fatalf("no cliff edges between walkable tiles near the origin")
+	}
+
+	around := 0
+	for _, edge := range edges {
+		from, to := edge[0], edge[1]
+		path, complete := findPath(from, to, tickCache)
+		if path == nil {
+			continue // Boxed in on this side of the cliff
+		}
+		checkPath(t, path, from, nil)
+		if complete {
+			around++
+		}
+
+		// A long path that starts by crossing the edge, searched over the cluster graph.
+		far := pathPoint{to.X + 2*(to.X-from.X) + PathSearchLimits.DirectRange, to.Y}
+		if path, _ := findPath(from, far, tickCache); path != nil {
+			checkPath(t, path, from, nil)
+		}
+	}
+	if around == 0 {
+		t.Errorf("no path found around any of %d cliff edges", len(edges))
+	}
+}
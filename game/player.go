@@ -46,6 +46,11 @@ func LoginPlayer(secretKey string) (string, *models.InitialStateMessage) {
 
 	log.Printf("Player %s reconnecting with secret key.", playerID)
 
+	// Bring records saved by older server versions up to the current schema.
+	if fromVersion, err := MigratePlayer(playerID); err != nil {
+		log.Printf("Failed to migrate player %s from schema version %d: %v", playerID, fromVersion, err)
+	}
+
 	// --- NEW: Add player back to the world and announce their arrival ---
 	// Get player's state before announcing their return
 	initialState := getPlayerState(playerID)
@@ -54,8 +59,9 @@ func LoginPlayer(secretKey string) (string, *models.InitialStateMessage) {
 
 			// --- NEW: Handle reconnecting as an Echo ---
 			playerData, _ := rdb.HGetAll(ctx, playerID).Result()
-			if isEcho, _ := strconv.ParseBool(playerData["isEcho"]); isEcho {
+			if playerBool(playerData, "isEcho") {
 				rdb.HSet(ctx, playerID, "isEcho", "false")
+				rdb.HDel(ctx, playerID, "following")
 				log.Printf("Player %s is reclaiming their Echo.", playerID)
 				// Announce the Echo is gone
 				updateMsg := map[string]interface{}{
@@ -81,12 +87,7 @@ func LoginPlayer(secretKey string) (string, *models.InitialStateMessage) {
 			PublishUpdate(updateMsg)
 
 			// Also add them back to the geospatial index
-			lon, lat := NormalizeCoords(playerEntityState.X, playerEntityState.Y)
-			rdb.GeoAdd(ctx, string(RedisKeyZone0Positions), &redis.GeoLocation{
-				Name:      playerID,
-				Longitude: lon,
-				Latitude:  lat,
-			})
+			setEntityPosition(rdb, playerID, playerEntityState.X, playerEntityState.Y)
 			rdb.HSet(ctx, playerID, "loginTimestamp", time.Now().UnixMilli())
 		}
 	}
@@ -156,17 +157,25 @@ func getPlayerState(playerID string) *models.InitialStateMessage {
 	inventoryKey := string(RedisKeyPlayerInventory) + playerID
 	gearKey := string(RedisKeyPlayerGear) + playerID
 
-	// Use a large radius to find all entities in the zone.
-	locations, _ := rdb.GeoRadius(ctx, string(RedisKeyZone0Positions), 0, 0, &redis.GeoRadiusQuery{
-		Radius:    TilesToKilometers(WorldSize), // Search the entire world
-		Unit:      "km",
-		WithCoord: true,
-	}).Result()
+	// Every entity in the zone comes from the spatial index; their data is read in one round trip.
+	zone := playerZone(playerData)
+	var entries []SpatialEntry
+	for _, entry := range EntityIndex.All("") {
+		if zoneAt(entry.X, entry.Y) == zone {
+			entries = append(entries, entry)
+		}
+	}
+	pipe := rdb.Pipeline()
+	entityDataCmds := make([]*redis.StringStringMapCmd, len(entries))
+	for i, entry := range entries {
+		entityDataCmds[i] = pipe.HGetAll(ctx, entry.ID)
+	}
+	pipe.Exec(ctx)
 	allEntitiesState := make(map[string]models.EntityState)
 
-	for _, loc := range locations {
-		entityData, err := rdb.HGetAll(ctx, loc.Name).Result()
-		if err != nil {
+	for i, loc := range entries {
+		entityData, err := entityDataCmds[i].Result()
+		if err != nil || len(entityData) == 0 {
 			continue
 		}
 
@@ -174,8 +183,8 @@ func getPlayerState(playerID string) *models.InitialStateMessage {
 
 		if entityType == string(EntityTypeItem) {
 			owner := entityData["owner"]
-			createdAt, _ := strconv.ParseInt(entityData["createdAt"], 10, 64)
-			isPublic := time.Now().UnixMilli()-createdAt >= 60000
+			publicAt, _ := strconv.ParseInt(entityData["publicAt"], 10, 64)
+			isPublic := publicAt > 0 && time.Now().UnixMilli() >= publicAt
 
 			if owner != "" && owner != playerID && !isPublic {
 				continue
@@ -183,8 +192,8 @@ func getPlayerState(playerID string) *models.InitialStateMessage {
 		}
 
 		entityState := models.EntityState{
-			X:    int(loc.Longitude),
-			Y:    int(loc.Latitude),
+			X:    loc.X,
+			Y:    loc.Y,
 			Type: entityType,
 		}
 
@@ -209,22 +218,23 @@ func getPlayerState(playerID string) *models.InitialStateMessage {
 		}
 
 		if entityType == string(EntityTypeItem) {
-			createdAt, _ := strconv.ParseInt(entityData["createdAt"], 10, 64)
-			entityState.ItemID = entityData["itemId"]
-			entityState.Owner = entityData["owner"]
-			entityState.CreatedAt = createdAt
+			item := worldItemFromData(loc.ID, entityData)
+			entityState.ItemID = string(item.ItemID)
+			entityState.Owner = item.Owner
+			entityState.CreatedAt = item.CreatedAt
+			entityState.PublicAt = item.PublicAt
+			entityState.Quantity = item.Quantity
 		}
 		if entityType == string(EntityTypePlayer) {
-			gear, _ := GetGear(loc.Name)
+			gear, _ := GetGear(loc.ID)
 			entityState.Gear = gear
-			isEcho, _ := strconv.ParseBool(entityData["isEcho"])
-			entityState.IsEcho = isEcho
+			entityState.IsEcho = playerBool(entityData, "isEcho")
 		}
-		allEntitiesState[loc.Name] = entityState
+		allEntitiesState[loc.ID] = entityState
 	}
 
 	// --- NEW: Ensure the player's own entity is included ---
-	// This is crucial because the player might not be in the GeoRadius result
+	// This is crucial because the player might not be in the spatial index
 	// if they are reconnecting after a cleanup.
 	if _, ok := allEntitiesState[playerID]; !ok {
 		playerEntityData, err := rdb.HGetAll(ctx, playerID).Result()
@@ -241,22 +251,20 @@ func getPlayerState(playerID string) *models.InitialStateMessage {
 			}
 			gear, _ := GetGear(playerID)
 			entityState.Gear = gear
-			isEcho, _ := strconv.ParseBool(playerEntityData["isEcho"])
-			entityState.IsEcho = isEcho
+			entityState.IsEcho = playerBool(playerEntityData, "isEcho")
 			allEntitiesState[playerID] = entityState
 		}
 	}
 	// --- END NEW ---
 
-	worldDataRaw, _ := rdb.HGetAll(ctx, string(RedisKeyWorldZone0)).Result()
-	worldDataTyped := make(map[string]models.WorldTile)
-	for coord, tileJSON := range worldDataRaw {
-		var tile models.WorldTile
-		json.Unmarshal([]byte(tileJSON), &tile)
-		// Only filter out plain ground tiles. Keep everything else, including sanctuary grounds.
-		if TileType(tile.Type) != TileTypeGround || tile.IsSanctuary {
-			worldDataTyped[coord] = tile
-		}
+	// The player is sent the chunks around them; more are sent as they move.
+	playerX, _ := strconv.Atoi(playerData["x"])
+	playerY, _ := strconv.Atoi(playerData["y"])
+	storeWorldChunksAround(playerX, playerY)
+	worldDataTyped, viewChunks := worldViewTiles(playerX, playerY)
+	var minimap *models.MinimapMessage
+	if len(discoverWorldChunks(playerID, viewChunks)) > 0 {
+		minimap = playerMinimap(playerID) // Only the designed zones have a minimap
 	}
 
 	inventoryDataRaw, _ := rdb.HGetAll(ctx, inventoryKey).Result()
@@ -300,21 +308,16 @@ func getPlayerState(playerID string) *models.InitialStateMessage {
 	}
 
 	experience := make(map[models.Skill]float64)
-	experienceJSON, err := rdb.HGet(ctx, playerID, "experience").Result()
-	if err == nil {
-		json.Unmarshal([]byte(experienceJSON), &experience)
-	}
+	json.Unmarshal([]byte(playerString(playerData, "experience")), &experience)
 
-	resonance, _ := strconv.ParseInt(playerData["resonance"], 10, 64)
-	echoUnlocked, _ := strconv.ParseBool(playerData["echoUnlocked"])
-	runesJSON, _ := rdb.HGet(ctx, playerID, "runes").Result()
+	resonance := playerInt64(playerData, "resonance")
+	echoUnlocked := playerBool(playerData, "echoUnlocked")
 	var runes []string
-	json.Unmarshal([]byte(runesJSON), &runes)
-	activeRune, _ := rdb.HGet(ctx, playerID, "activeRune").Result()
+	json.Unmarshal([]byte(playerString(playerData, "runes")), &runes)
+	activeRune := playerString(playerData, "activeRune")
 
-	knownRecipesJSON, _ := rdb.HGet(ctx, playerID, "knownRecipes").Result()
 	var knownRecipes map[string]bool
-	json.Unmarshal([]byte(knownRecipesJSON), &knownRecipes)
+	json.Unmarshal([]byte(playerString(playerData, "knownRecipes")), &knownRecipes)
 
 	initialState := &models.InitialStateMessage{
 		Type:         string(ServerEventInitialState),
@@ -332,9 +335,11 @@ func getPlayerState(playerID string) *models.InitialStateMessage {
 		Runes:        runes,
 		ActiveRune:   activeRune,
 		KnownRecipes: knownRecipes,
+		BiomeMap:     BuildBiomeMap(viewChunks),
+		Minimap:      minimap,
 	}
 
-	playerHealth, _ := strconv.Atoi(playerData["health"])
+	playerHealth := playerStoredInt(playerData, "health")
 	maxHealth := PlayerDefs.MaxHealth
 	mr := int64(1800) // TODO: Make this dynamic
 	statsUpdateMsg := models.PlayerStatsUpdateMessage{
@@ -347,6 +352,8 @@ func getPlayerState(playerID string) *models.InitialStateMessage {
 		EchoUnlocked: &echoUnlocked,
 	}
 	statsUpdateJSON, _ := json.Marshal(statsUpdateMsg)
+	// Sent from this node, which holds the player's connection, once they have the
+	// initial state.
 	time.AfterFunc(100*time.Millisecond, func() {
 		if sendDirectMessage != nil {
 			sendDirectMessage(playerID, statsUpdateJSON)
@@ -373,14 +380,10 @@ func isTileAvailable(x, y int) bool {
 		return false
 	}
 
-	tileJSON, err := rdb.HGet(ctx, string(RedisKeyWorldZone0), strconv.Itoa(x)+","+strconv.Itoa(y)).Result()
+	_, props, err := GetWorldTile(x, y)
 	if err != nil {
 		return false // Tile doesn't exist in world data.
 	}
-
-	var tile models.WorldTile
-	json.Unmarshal([]byte(tileJSON), &tile)
-	props := TileDefs[TileType(tile.Type)]
 	return !props.IsCollidable
 }
 
@@ -403,8 +406,7 @@ func InitializePlayer(playerID string) *models.InitialStateMessage {
 
 	pipe := rdb.Pipeline()
 
-	firstSanctuary := Sanctuaries[0]
-	bindingCoords := strconv.Itoa(firstSanctuary.X) + "," + strconv.Itoa(firstSanctuary.Y)
+	bindingCoords := defaultPlayerBinding()
 
 	// Set the player's position, cooldown, and entityType
 	pipe.HSet(ctx, playerID,
@@ -426,10 +428,10 @@ func InitializePlayer(playerID string) *models.InitialStateMessage {
 		"activeRune", "",
 		"knownRecipes", `{"wooden_wall":true, "fire":true, "cooked_rat_meat":true, "crude_axe":true}`,
 		"binding", bindingCoords,
+		PlayerFieldSchemaVersion, PlayerSchemaVersion,
 	)
 	// --- Player position in Geo set ---
-	lon, lat := NormalizeCoords(spawnX, spawnY)
-	pipe.GeoAdd(ctx, string(RedisKeyZone0Positions), &redis.GeoLocation{Name: playerID, Longitude: lon, Latitude: lat})
+	setEntityPosition(pipe, playerID, spawnX, spawnY)
 
 	// --- NEW: Initialize a 10-slot inventory ---
 	inventory := make(map[string]interface{})
@@ -488,6 +490,25 @@ func InitializePlayer(playerID string) *models.InitialStateMessage {
 
 	pipe.HSet(ctx, gearKey, map[string]interface{}{"weapon-slot": ""})
 
+	// Record the starter kit so audit history accounts for everything the player begins with.
+	for location, slots := range map[ItemLocation]map[string]interface{}{ItemLocationInventory: inventory, ItemLocationBank: bank} {
+		for _, itemJSON := range slots {
+			if itemJSON == "" {
+				continue
+			}
+			var item models.Item
+			json.Unmarshal([]byte(itemJSON.(string)), &item)
+			RecordItemMovement(pipe, ItemAuditEntry{
+				PlayerID: playerID,
+				ItemID:   ItemID(item.ID),
+				Quantity: item.Quantity,
+				Source:   ItemLocationNone,
+				Dest:     location,
+				Reason:   ItemAuditStarterKit,
+			})
+		}
+	}
+
 	// For testing: complete the first quest
 	playerQuests := &models.PlayerQuests{
 		Quests:          make(map[models.QuestID]*models.Quest),
@@ -500,15 +521,7 @@ func InitializePlayer(playerID string) *models.InitialStateMessage {
 	pipe.HSet(ctx, playerID, "quests", questsJSON)
 
 	// --- END NEW ---
-	experience := make(map[models.Skill]float64)
-	experience[models.SkillWoodcutting] = 0
-	experience[models.SkillMining] = 0
-	experience[models.SkillSmithing] = 0
-	experience[models.SkillCooking] = 0
-	experience[models.SkillConstruction] = 0
-	experience[models.SkillAttack] = 0
-	experience[models.SkillDefense] = 0
-	experienceJSON, _ := json.Marshal(experience)
+	experienceJSON, _ := json.Marshal(defaultPlayerExperience())
 	pipe.HSet(ctx, playerID, "experience", experienceJSON)
 
 	_, err = pipe.Exec(ctx)
@@ -555,10 +568,11 @@ func CleanupPlayer(playerID string) {
 		}
 	}
 
-	resonance, _ := strconv.ParseInt(playerData["resonance"], 10, 64)
+	resonance := playerInt64(playerData, "resonance")
 
 	if resonance > 0 {
 		// --- BECOME AN ECHO ---
+		handWalkToEcho(playerID)
 		rdb.HSet(ctx, playerID, "isEcho", "true")
 		updateMsg := map[string]interface{}{
 			"type":     string(ServerEventEntityUpdate),
@@ -570,6 +584,7 @@ func CleanupPlayer(playerID string) {
 
 	} else {
 		// --- DISCONNECT NORMALLY ---
+		StopWalking(playerID)
 		// Announce the entity has left
 		leftMsg := map[string]interface{}{
 			"type":     string(ServerEventEntityLeft),
@@ -578,7 +593,7 @@ func CleanupPlayer(playerID string) {
 		PublishUpdate(leftMsg)
 
 		// Remove the entity from the geospatial index, but do NOT delete their data.
-		rdb.ZRem(ctx, string(RedisKeyZone0Positions), playerID)
+		removeEntityPosition(rdb, playerID)
 		log.Printf("Player %s has disconnected.", playerID)
 	}
 }
@@ -594,6 +609,13 @@ func SetEchoState(playerID string, enabled bool) {
 	pipe.HSet(ctx, playerID, "isEcho", strconv.FormatBool(enabled))
 	// Also reset the echo state machine to idling for a clean transition.
 	pipe.HSet(ctx, playerID, "echoState", string(EchoStateIdling))
+	if enabled {
+		// A follow in progress carries on under the Echo.
+		handWalkToEcho(playerID)
+	} else {
+		// An Echo's follow ends when the player takes back control.
+		pipe.HDel(ctx, playerID, "following")
+	}
 	pipe.Exec(ctx)
 
 	// Update the player's client to reflect the change.
@@ -612,16 +634,14 @@ func AddExperience(playerID string, skill models.Skill, amount float64) {
 		return
 	}
 
-	var isEchoStr string
-	if vals[0] != nil {
-		isEchoStr, _ = vals[0].(string)
-	}
-	isEcho, _ := strconv.ParseBool(isEchoStr)
-
-	var experienceJSON string
-	if vals[1] != nil {
-		experienceJSON, _ = vals[1].(string)
+	fields := make(map[string]string)
+	for i, field := range []string{"isEcho", "experience"} {
+		if value, ok := vals[i].(string); ok {
+			fields[field] = value
+		}
 	}
+	isEcho := playerBool(fields, "isEcho")
+	experienceJSON := fields["experience"]
 
 	pipe := rdb.Pipeline()
 
@@ -647,9 +667,9 @@ func AddExperience(playerID string, skill models.Skill, amount float64) {
 		return
 	}
 	playerData, _ := rdb.HGetAll(ctx, playerID).Result()
-	playerHealth, _ := strconv.Atoi(playerData["health"])
-	resonance, _ := strconv.ParseInt(playerData["resonance"], 10, 64)
-	echoUnlocked, _ := strconv.ParseBool(playerData["echoUnlocked"])
+	playerHealth := playerStoredInt(playerData, "health")
+	resonance := playerInt64(playerData, "resonance")
+	echoUnlocked := playerBool(playerData, "echoUnlocked")
 	maxHealth := PlayerDefs.MaxHealth
 	mr := int64(1800) // TODO: Make this dynamic
 	statsUpdateMsg := models.PlayerStatsUpdateMessage{
@@ -6,22 +6,21 @@ import (
 	"mmo-game/models"
 	"strconv"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
 // HandlePlayerDeath resets the player's health and moves them to a new spawn point.
 func HandlePlayerDeath(playerID string) {
 	log.Printf("Player %s has been defeated.", playerID)
+	StopWalking(playerID)
 
 	// Get the player's current data to release their tile lock
 	playerData, err := rdb.HGetAll(ctx, playerID).Result()
+	currentX, _ := strconv.Atoi(playerData["x"])
+	currentY, _ := strconv.Atoi(playerData["y"])
 	if err != nil {
 		log.Printf("Could not get player data for death handling: %v", err)
 		// Continue anyway, try to respawn them
 	} else {
-		currentX, _ := strconv.Atoi(playerData["x"])
-		currentY, _ := strconv.Atoi(playerData["y"])
 		UnlockTileForEntity(playerID, currentX, currentY)
 	}
 
@@ -41,12 +40,7 @@ func HandlePlayerDeath(playerID string) {
 	)
 
 	// --- Player position in Geo set ---
-	lon, lat := NormalizeCoords(spawnX, spawnY)
-	pipe.GeoAdd(ctx, string(RedisKeyZone0Positions), &redis.GeoLocation{
-		Name:      playerID,
-		Longitude: lon,
-		Latitude:  lat,
-	})
+	setEntityPosition(pipe, playerID, spawnX, spawnY)
 
 	_, err = pipe.Exec(ctx)
 	if err != nil {
@@ -79,6 +73,7 @@ func HandlePlayerDeath(playerID string) {
 		"y":        spawnY,
 	}
 	PublishUpdate(updateMsg)
+	visitWorld(playerID, currentX, currentY, spawnX, spawnY)
 
 	// Send health update message to the client
 	statsUpdateMsg := models.PlayerStatsUpdateMessage{
@@ -0,0 +1,148 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// PlayerMigrationFunc upgrades a player record by one schema version.
+// playerData is the current contents of the player hash; a migration must write its
+// changes through pipe and mirror them into playerData (setPlayerField does both) so
+// later migrations in the same run see the upgraded record.
+type PlayerMigrationFunc func(pipe redis.Pipeliner, playerID string, playerData map[string]string) error
+
+// PlayerMigration is a registered upgrade step to a specific schema version.
+type PlayerMigration struct {
+	Version     int
+	Description string
+	Migrate     PlayerMigrationFunc
+}
+
+// PlayerMigrations maps a target schema version to the migration that produces it.
+var PlayerMigrations = make(map[int]PlayerMigration)
+
+// RegisterPlayerMigration registers the migration that upgrades a record from
+// version-1 to version. This should be called from init() in player_migrations_init.go.
+//
+// Example:
+//   RegisterPlayerMigration(3, "add mana", func(pipe redis.Pipeliner, playerID string, data map[string]string) error {
+//       setPlayerField(pipe, playerID, data, "mana", "0")
+//       return nil
+//   })
+func RegisterPlayerMigration(version int, description string, migrate PlayerMigrationFunc) {
+	if _, exists := PlayerMigrations[version]; exists {
+		log.Printf("WARNING: Player migration for version %d is being overwritten", version)
+	}
+	PlayerMigrations[version] = PlayerMigration{Version: version, Description: description, Migrate: migrate}
+}
+
+// setPlayerField writes a field through the migration pipeline and updates the local copy.
+func setPlayerField(pipe redis.Pipeliner, playerID string, playerData map[string]string, field, value string) {
+	pipe.HSet(ctx, playerID, field, value)
+	playerData[field] = value
+}
+
+// MigratePlayer upgrades a single player record to PlayerSchemaVersion.
+// Each step runs in a WATCHed transaction, so a login and the batch job racing on the
+// same record cannot both apply a migration. Returns the version the record started at.
+func MigratePlayer(playerID string) (int, error) {
+	fromVersion := -1
+	for {
+		var version int
+		err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+			playerData, err := tx.HGetAll(ctx, playerID).Result()
+			if err != nil {
+				return err
+			}
+			if len(playerData) == 0 {
+				return fmt.Errorf("player %s does not exist", playerID)
+			}
+
+			version = playerInt(playerData, PlayerFieldSchemaVersion)
+			if fromVersion < 0 {
+				fromVersion = version
+			}
+			if version >= PlayerSchemaVersion {
+				return nil
+			}
+
+			next := version + 1
+			migration, ok := PlayerMigrations[next]
+			if !ok {
+				return fmt.Errorf("no migration registered for player schema version %d", next)
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				if err := migration.Migrate(pipe, playerID, playerData); err != nil {
+					return err
+				}
+				pipe.HSet(ctx, playerID, PlayerFieldSchemaVersion, next)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			log.Printf("Migrated player %s to schema version %d (%s).", playerID, next, migration.Description)
+			version = next
+			return nil
+		}, playerID)
+
+		if err == redis.TxFailedErr {
+			continue // The record changed under us; re-read and retry this step.
+		}
+		if err != nil {
+			return fromVersion, err
+		}
+		if version >= PlayerSchemaVersion {
+			return fromVersion, nil
+		}
+	}
+}
+
+// MigrateAllPlayers upgrades every player record in Redis. It uses SCAN so it can run
+// against a live server. Returns the number of records upgraded and the number that failed.
+func MigrateAllPlayers() (int, int) {
+	migrated, failed := 0, 0
+	iter := rdb.Scan(ctx, 0, string(RedisKeyPlayerPrefix)+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		playerID := iter.Val()
+		if keyType, err := rdb.Type(ctx, playerID).Result(); err != nil || keyType != "hash" {
+			continue
+		}
+		fromVersion, err := MigratePlayer(playerID)
+		if err != nil {
+			log.Printf("Failed to migrate player %s: %v", playerID, err)
+			failed++
+			continue
+		}
+		if fromVersion < PlayerSchemaVersion {
+			migrated++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("Error scanning player records: %v", err)
+	}
+	return migrated, failed
+}
+
+// CountPlayerSchemaVersions reports how many player records are at each schema version.
+func CountPlayerSchemaVersions() (map[int]int, error) {
+	counts := make(map[int]int)
+	iter := rdb.Scan(ctx, 0, string(RedisKeyPlayerPrefix)+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		playerID := iter.Val()
+		if keyType, err := rdb.Type(ctx, playerID).Result(); err != nil || keyType != "hash" {
+			continue
+		}
+		versionStr, err := rdb.HGet(ctx, playerID, PlayerFieldSchemaVersion).Result()
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		version, _ := strconv.Atoi(versionStr)
+		counts[version]++
+	}
+	return counts, iter.Err()
+}
@@ -0,0 +1,88 @@
+package game
+
+import (
+	"encoding/json"
+	"mmo-game/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// init registers all player schema migrations.
+// To change the player record layout, add a migration here with the next version
+// number and bump PlayerSchemaVersion. Never edit a migration that has shipped.
+func init() {
+	RegisterPlayerMigration(1, "backfill missing fields", migrateBackfillPlayerFields)
+	RegisterPlayerMigration(2, "normalize embedded JSON fields", migrateNormalizePlayerJSON)
+	RegisterPlayerMigration(3, "record zone membership", migrateRecordPlayerZone)
+}
+
+// migrateBackfillPlayerFields gives records created before a field existed
+// (binding, echoUnlocked, activeRune, ...) that field's default value.
+func migrateBackfillPlayerFields(pipe redis.Pipeliner, playerID string, playerData map[string]string) error {
+	for _, field := range backfilledPlayerFields {
+		if _, ok := playerData[field]; ok {
+			continue
+		}
+		if value, ok := playerFieldDefault(field); ok {
+			setPlayerField(pipe, playerID, playerData, field, value)
+		}
+	}
+	return nil
+}
+
+// migrateNormalizePlayerJSON repairs the embedded JSON blobs: null or malformed
+// values are replaced with defaults, nil maps become empty maps and missing skills are
+// added to experience.
+func migrateNormalizePlayerJSON(pipe redis.Pipeliner, playerID string, playerData map[string]string) error {
+	var quests models.PlayerQuests
+	if err := json.Unmarshal([]byte(playerData["quests"]), &quests); err != nil {
+		quests = models.PlayerQuests{}
+	}
+	if quests.Quests == nil {
+		quests.Quests = make(map[models.QuestID]*models.Quest)
+	}
+	if quests.CompletedQuests == nil {
+		quests.CompletedQuests = make(map[models.QuestID]bool)
+	}
+	questsJSON, _ := json.Marshal(quests)
+	setPlayerField(pipe, playerID, playerData, "quests", string(questsJSON))
+
+	var experience map[models.Skill]float64
+	if err := json.Unmarshal([]byte(playerData["experience"]), &experience); err != nil || experience == nil {
+		experience = make(map[models.Skill]float64)
+	}
+	for skill := range defaultPlayerExperience() {
+		if _, ok := experience[skill]; !ok {
+			experience[skill] = 0
+		}
+	}
+	experienceJSON, _ := json.Marshal(experience)
+	setPlayerField(pipe, playerID, playerData, "experience", string(experienceJSON))
+
+	var runes []string
+	if err := json.Unmarshal([]byte(playerData["runes"]), &runes); err != nil || runes == nil {
+		runes = []string{}
+	}
+	runesJSON, _ := json.Marshal(runes)
+	setPlayerField(pipe, playerID, playerData, "runes", string(runesJSON))
+
+	var knownRecipes map[string]bool
+	if err := json.Unmarshal([]byte(playerData["knownRecipes"]), &knownRecipes); err != nil || knownRecipes == nil {
+		knownRecipes = make(map[string]bool)
+	}
+	knownRecipesJSON, _ := json.Marshal(knownRecipes)
+	setPlayerField(pipe, playerID, playerData, "knownRecipes", string(knownRecipesJSON))
+
+	return nil
+}
+
+// migrateRecordPlayerZone records the zone of players saved before zone membership was
+// kept on the player hash, going by their position. Players with no position yet are
+// spawned into the overworld, the default.
+func migrateRecordPlayerZone(pipe redis.Pipeliner, playerID string, playerData map[string]string) error {
+	if _, ok := playerData["x"]; !ok {
+		return nil
+	}
+	setPlayerField(pipe, playerID, playerData, "zone", string(zoneAt(GetEntityPosition(playerData))))
+	return nil
+}
@@ -0,0 +1,152 @@
+package game
+
+import (
+	"encoding/json"
+	"mmo-game/game/utils"
+	"mmo-game/models"
+	"strconv"
+)
+
+// PlayerSchemaVersion is the current version of the player hash layout.
+// Bump it whenever a migration is registered in player_migrations_init.go.
+const PlayerSchemaVersion = 3
+
+// PlayerFieldSchemaVersion is the player hash field holding the record's schema version.
+// Records created before versioning existed have no such field and are treated as version 0.
+const PlayerFieldSchemaVersion = "schemaVersion"
+
+// playerFieldDefaults holds the value a player field takes when it is missing from the hash.
+// This is the single source of truth for defaults; both the read helpers below and the
+// backfill migration use it, so adding a field means adding one line here.
+var playerFieldDefaults = map[string]string{
+	PlayerFieldSchemaVersion: "0",
+	"entityType":             string(EntityTypePlayer),
+	"nextActionAt":           "0",
+	"moveCooldown":           "100",
+	"loginTimestamp":         "0",
+	"direction":              string(MoveDirectionDown),
+	"resonance":              "0",
+	"isEcho":                 "false",
+	"echoUnlocked":           "false",
+	"echoState":              string(EchoStateIdling),
+	"echoTarget":             "",
+	"echoPath":               "",
+	"runes":                  "[]",
+	"activeRune":             "",
+	"knownRecipes":           "{}",
+	"following":              "",
+	"zone":                   string(ZoneOverworld),
+	"digSite":                "",
+	"lastPortal":             "",
+}
+
+// playerFieldDefault returns the default for a player field, including the
+// fields whose defaults depend on game data rather than being constants.
+func playerFieldDefault(field string) (string, bool) {
+	switch field {
+	case "health":
+		return strconv.Itoa(PlayerDefs.MaxHealth), true
+	case "shirtColor":
+		return utils.GenerateRandomColor(), true
+	case "binding":
+		return defaultPlayerBinding(), true
+	case "quests":
+		questsJSON, _ := json.Marshal(models.PlayerQuests{
+			Quests:          make(map[models.QuestID]*models.Quest),
+			CompletedQuests: make(map[models.QuestID]bool),
+		})
+		return string(questsJSON), true
+	case "experience":
+		experienceJSON, _ := json.Marshal(defaultPlayerExperience())
+		return string(experienceJSON), true
+	}
+	value, ok := playerFieldDefaults[field]
+	return value, ok
+}
+
+// backfilledPlayerFields lists every field the backfill migration guarantees exists.
+// Position and name are deliberately absent: a missing position means "spawn me" and a
+// missing name means the player has not registered.
+var backfilledPlayerFields = []string{
+	"entityType", "health", "nextActionAt", "moveCooldown", "shirtColor", "loginTimestamp",
+	"direction", "resonance", "isEcho", "echoUnlocked", "echoState", "echoTarget", "echoPath",
+	"runes", "activeRune", "knownRecipes", "binding", "quests", "experience",
+}
+
+func defaultPlayerBinding() string {
+	sanctuaries := knownSanctuaries()
+	if len(sanctuaries) == 0 {
+		return ""
+	}
+	return strconv.Itoa(sanctuaries[0].X) + "," + strconv.Itoa(sanctuaries[0].Y)
+}
+
+func defaultPlayerExperience() map[models.Skill]float64 {
+	return map[models.Skill]float64{
+		models.SkillWoodcutting:  0,
+		models.SkillMining:       0,
+		models.SkillSmithing:     0,
+		models.SkillCooking:      0,
+		models.SkillConstruction: 0,
+		models.SkillAttack:       0,
+		models.SkillDefense:      0,
+	}
+}
+
+// playerString returns a field from a player hash, falling back to its default.
+func playerString(playerData map[string]string, field string) string {
+	if value, ok := playerData[field]; ok && value != "" {
+		return value
+	}
+	value, _ := playerFieldDefault(field)
+	return value
+}
+
+// playerStoredString returns a field from a player hash without falling back to its
+// default, and whether it is set. Use it where a missing field means something, such
+// as a player with no binding.
+func playerStoredString(playerData map[string]string, field string) (string, bool) {
+	value, ok := playerData[field]
+	return value, ok && value != ""
+}
+
+// playerBool parses a boolean player field, falling back to its default when the
+// field is missing or malformed.
+func playerBool(playerData map[string]string, field string) bool {
+	if value, err := strconv.ParseBool(playerData[field]); err == nil {
+		return value
+	}
+	value, _ := playerFieldDefault(field)
+	parsed, _ := strconv.ParseBool(value)
+	return parsed
+}
+
+// playerInt parses an integer player field, falling back to its default when the
+// field is missing or malformed.
+func playerInt(playerData map[string]string, field string) int {
+	if value, err := strconv.Atoi(playerData[field]); err == nil {
+		return value
+	}
+	value, _ := playerFieldDefault(field)
+	parsed, _ := strconv.Atoi(value)
+	return parsed
+}
+
+// playerStoredInt parses an integer player field without falling back to its default:
+// a missing or malformed field is 0. Use it where a missing field must not pass for its
+// default, such as health, whose default is full.
+func playerStoredInt(playerData map[string]string, field string) int {
+	value, _ := strconv.Atoi(playerData[field])
+	return value
+}
+
+// playerInt64 parses a 64-bit integer player field, falling back to its default when
+// the field is missing or malformed.
+func playerInt64(playerData map[string]string, field string) int64 {
+	if value, err := strconv.ParseInt(playerData[field], 10, 64); err == nil {
+		return value
+	}
+	value, _ := playerFieldDefault(field)
+	parsed, _ := strconv.ParseInt(value, 10, 64)
+	return parsed
+}
@@ -0,0 +1,172 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"mmo-game/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Points of interest are searched, or dug up if they are buried, once: the first player
+// to get there claims them in RedisKeySearchedPOIs and their loot drops at their feet.
+//
+// A treasure map leads to a dig site, which is kept in the player's "digSite" field. A
+// player has one dig site at a time, so every map they read leads there until it has
+// been dug up.
+const (
+	// Dig sites are this many tiles from where the map is first read.
+	treasureMapMinDistance = 20
+	treasureMapMaxDistance = 60
+
+	// treasureMapAttempts is how many spots a map tries before giving up on a dig site.
+	treasureMapAttempts = 20
+)
+
+// interactPOITile handles a player interacting with a point of interest, searching it
+// unless it is buried. It reports whether the tile was one.
+func interactPOITile(playerID string, playerData map[string]string, tileType TileType, x, y int) bool {
+	poi, ok := POIDefs[tileType]
+	if !ok {
+		return false
+	}
+	UpdateEntityDirection(playerID, x, y)
+	if poi.Buried {
+		sendNotification(playerID, "Something is buried here. Dig to unearth it.")
+		return true
+	}
+	if !claimPOI(playerID, poi, x, y) {
+		return true
+	}
+	px, py := GetEntityPosition(playerData)
+	if dropLoot(playerID, px, py, rollLoot(poi.Loot), ItemAuditSearch, poiKey(x, y)) {
+		sendNotification(playerID, "You search the "+poi.Name+" and find something.")
+	} else {
+		sendNotification(playerID, "You search the "+poi.Name+" but find nothing.")
+	}
+	rdb.HSet(ctx, playerID, "nextActionAt", time.Now().Add(BaseActionCooldown).UnixMilli())
+	return true
+}
+
+// digPOI digs up the buried point of interest at a coordinate, leaving the floor of its
+// biome behind.
+func digPOI(playerID string, playerData map[string]string, poi POIProperties, x, y int) {
+	if !claimPOI(playerID, poi, x, y) {
+		return
+	}
+	floor := models.WorldTile{Type: string(floorTileAt(x, y))}
+	SetWorldTile(rdb, x, y, floor)
+	PublishUpdate(models.WorldUpdateMessage{
+		Type: string(ServerEventWorldUpdate),
+		X:    x,
+		Y:    y,
+		Tile: floor,
+	})
+
+	px, py := GetEntityPosition(playerData)
+	if dropLoot(playerID, px, py, rollLoot(poi.Loot), ItemAuditDig, poiKey(x, y)) {
+		sendNotification(playerID, "You dig up the "+poi.Name+".")
+	} else {
+		sendNotification(playerID, "You dig up the "+poi.Name+", but it is empty.")
+	}
+}
+
+// claimPOI marks a point of interest as searched, telling the player if it already was.
+// It reports whether the player was first.
+func claimPOI(playerID string, poi POIProperties, x, y int) bool {
+	added, err := rdb.SAdd(ctx, string(RedisKeySearchedPOIs), poiKey(x, y)).Result()
+	if err != nil {
+		log.Printf("Failed to claim the %s at %d,%d: %v", poi.Name, x, y, err)
+		return false
+	}
+	if added == 0 {
+		sendNotification(playerID, "There is nothing left to find at the "+poi.Name+".")
+		return false
+	}
+	return true
+}
+
+func poiKey(x, y int) string {
+	return strconv.Itoa(x) + "," + strconv.Itoa(y)
+}
+
+// dropLoot drops loot at a coordinate for a player, who can pick it up before anyone
+// else. It reports whether there was any.
+func dropLoot(playerID string, x, y int, loot map[ItemID]int, reason ItemAuditReason, ref string) bool {
+	for itemID, quantity := range loot {
+		worldItem, err := CreateWorldItem(x, y, itemID, quantity, playerID, time.Minute*1)
+		if err != nil {
+			log.Printf("Failed to create world item from loot: %v", err)
+			continue
+		}
+		RecordItemMovement(rdb, ItemAuditEntry{
+			PlayerID: playerID,
+			ItemID:   itemID,
+			Quantity: quantity,
+			Source:   ItemLocationNone,
+			Dest:     ItemLocationWorld,
+			Reason:   reason,
+			Ref:      ref,
+		})
+		PublishPrivately(playerID, worldItem.JoinedMessage())
+	}
+	return len(loot) > 0
+}
+
+// playerDigSite returns the dig site a player's treasure maps lead to, if they have one.
+func playerDigSite(playerData map[string]string) (x, y int, ok bool) {
+	parts := strings.Split(playerData["digSite"], ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	x, errX := strconv.Atoi(parts[0])
+	y, errY := strconv.Atoi(parts[1])
+	return x, y, errX == nil && errY == nil
+}
+
+// chooseDigSite picks a dig site for a treasure map read at a coordinate: open floor in
+// the same zone, some way off in a random direction.
+func chooseDigSite(x, y int) (siteX, siteY int, ok bool) {
+	zone := zoneAt(x, y)
+	for i := 0; i < treasureMapAttempts; i++ {
+		angle := rand.Float64() * 2 * math.Pi
+		distance := treasureMapMinDistance + rand.Float64()*(treasureMapMaxDistance-treasureMapMinDistance)
+		siteX = x + int(math.Round(distance*math.Cos(angle)))
+		siteY = y + int(math.Round(distance*math.Sin(angle)))
+		if zoneAt(siteX, siteY) != zone {
+			continue
+		}
+		tile, _, err := GetWorldTile(siteX, siteY)
+		if err == nil && TileType(tile.Type) == floorTileAt(siteX, siteY) {
+			return siteX, siteY, true
+		}
+	}
+	return 0, 0, false
+}
+
+// describeOffset tells a player how far a coordinate is from them, as "12 tiles north
+// and 3 tiles east". It is empty if the coordinate is theirs.
+func describeOffset(dx, dy int) string {
+	var parts []string
+	if dy < 0 {
+		parts = append(parts, describeTiles(-dy)+" north")
+	} else if dy > 0 {
+		parts = append(parts, describeTiles(dy)+" south")
+	}
+	if dx > 0 {
+		parts = append(parts, describeTiles(dx)+" east")
+	} else if dx < 0 {
+		parts = append(parts, describeTiles(-dx)+" west")
+	}
+	return strings.Join(parts, " and ")
+}
+
+func describeTiles(n int) string {
+	if n == 1 {
+		return "1 tile"
+	}
+	return fmt.Sprintf("%d tiles", n)
+}
@@ -0,0 +1,102 @@
+package game
+
+import "slices"
+
+// POIProperties defines a kind of point of interest: where the world generator places
+// it and what can be found there.
+type POIProperties struct {
+	// Name is what players are told they are searching.
+	Name string
+
+	// Biomes lists the biomes it is found in.
+	Biomes []BiomeType
+
+	// Weight is how often it is chosen over the other points of interest of a biome.
+	Weight float64
+
+	// Loot is rolled once, the first time it is searched.
+	Loot LootTable
+
+	// Buried points of interest are dug up rather than searched, and leave the floor
+	// of their biome behind.
+	Buried bool
+}
+
+// POIDefs is our master map of the points of interest, keyed by their tile type.
+var POIDefs map[TileType]POIProperties
+
+// POIOrder lists every point of interest. The world generator picks among them in this
+// order, so the same seed always places the same ones.
+var POIOrder = []TileType{TileTypeRuins, TileTypeShrine, TileTypeAbandonedCamp, TileTypeBuriedCache}
+
+// TreasureLootTable is rolled when the dig site of a treasure map is dug up.
+var TreasureLootTable LootTable
+
+func init() {
+	POIDefs = make(map[TileType]POIProperties)
+
+	POIDefs[TileTypeRuins] = POIProperties{
+		Name:   "ruins",
+		Biomes: []BiomeType{BiomePlains, BiomeForest, BiomeHighlands, BiomeCaverns},
+		Weight: 3,
+		Loot: LootTable{
+			{ItemID: ItemStone, Chance: 1.0, Min: 3, Max: 8},
+			{ItemID: ItemIronOre, Chance: 0.5, Min: 1, Max: 3},
+			{ItemID: ItemRecipeIronHelmet, Chance: 0.1, Min: 1, Max: 1},
+			{ItemID: ItemTreasureMap, Chance: 0.25, Min: 1, Max: 1},
+		},
+	}
+	POIDefs[TileTypeShrine] = POIProperties{
+		Name:   "shrine",
+		Biomes: []BiomeType{BiomeForest, BiomeHighlands, BiomeTundra, BiomeEthereal},
+		Weight: 1,
+		Loot: LootTable{
+			{ItemID: ItemSliceOfPizza, Chance: 0.5, Min: 1, Max: 2},
+			{ItemID: ItemTreasureMap, Chance: 0.5, Min: 1, Max: 1},
+		},
+	}
+	POIDefs[TileTypeAbandonedCamp] = POIProperties{
+		Name:   "abandoned camp",
+		Biomes: []BiomeType{BiomePlains, BiomeForest, BiomeSwamp, BiomeTundra},
+		Weight: 3,
+		Loot: LootTable{
+			{ItemID: ItemWood, Chance: 1.0, Min: 5, Max: 15},
+			{ItemID: ItemCookedRatMeat, Chance: 0.6, Min: 1, Max: 3},
+			{ItemID: ItemFire, Chance: 0.3, Min: 1, Max: 1},
+			{ItemID: ItemCrudeAxe, Chance: 0.1, Min: 1, Max: 1},
+			{ItemID: ItemTreasureMap, Chance: 0.2, Min: 1, Max: 1},
+		},
+	}
+	POIDefs[TileTypeBuriedCache] = POIProperties{
+		Name:   "buried cache",
+		Biomes: []BiomeType{BiomePlains, BiomeForest, BiomeSwamp, BiomeHighlands, BiomeTundra, BiomeCaverns, BiomeEthereal},
+		Weight: 2,
+		Loot: LootTable{
+			{ItemID: ItemIronOre, Chance: 0.8, Min: 2, Max: 5},
+			{ItemID: ItemGoop, Chance: 0.5, Min: 1, Max: 4},
+			{ItemID: ItemIronHelmet, Chance: 0.05, Min: 1, Max: 1},
+			{ItemID: ItemTreasureMap, Chance: 0.15, Min: 1, Max: 1},
+		},
+		Buried: true,
+	}
+
+	TreasureLootTable = LootTable{
+		{ItemID: ItemIronOre, Chance: 1.0, Min: 5, Max: 10},
+		{ItemID: ItemSliceOfPizza, Chance: 0.8, Min: 1, Max: 3},
+		{ItemID: ItemRecipeIronHelmet, Chance: 0.4, Min: 1, Max: 1},
+		{ItemID: ItemIronHelmet, Chance: 0.2, Min: 1, Max: 1},
+		{ItemID: ItemCrudeAxe, Chance: 0.2, Min: 1, Max: 1},
+		{ItemID: ItemTreasureMap, Chance: 0.1, Min: 1, Max: 1},
+	}
+}
+
+// biomePOIs returns the points of interest found in a biome, in POIOrder.
+func biomePOIs(biome BiomeType) []TileType {
+	var pois []TileType
+	for _, poi := range POIOrder {
+		if slices.Contains(POIDefs[poi].Biomes, biome) {
+			pois = append(pois, poi)
+		}
+	}
+	return pois
+}
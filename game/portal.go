@@ -0,0 +1,121 @@
+package game
+
+import (
+	"log"
+	"mmo-game/models"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// interactPortalTile handles a player interacting with a portal, moving them beside the
+// portal at its other end. It reports whether the tile was one.
+func interactPortalTile(playerID string, playerData map[string]string, tileType TileType, x, y int) bool {
+	if tileType != TileTypePortal {
+		return false
+	}
+	destX, destY, dest, ok := zonePortalAt(x, y)
+	if !ok {
+		sendNotification(playerID, "The portal is dormant.")
+		return true
+	}
+	destX, destY = findNearbyOpenTile(destX, destY, portalClearing+1)
+	movePlayerToZone(playerID, playerData, destX, destY)
+	sendNotification(playerID, "You step through the portal into "+zoneName(dest)+".")
+	return true
+}
+
+// stepThroughPortal carries an entity that has just stepped onto the portal at (x, y)
+// to beside the portal at its other end. Like writeMove, the change is written through
+// c, which the caller executes, and entityData is updated to match. The portal is kept
+// in the entity's "lastPortal" field, so that anyone following it can follow it in. It
+// returns the message announcing the entity in its new zone, or ok false if the portal
+// is dormant or the tile beside its other end is taken. The lock on that tile is taken
+// straight away; if c fails, the caller must release it and take back the portal tile.
+func stepThroughPortal(c redis.Cmdable, entityID string, entityData map[string]string, x, y int) (joinMsg map[string]interface{}, destX, destY int, ok bool) {
+	destX, destY, _, ok = zonePortalAt(x, y)
+	if !ok {
+		return nil, 0, 0, false
+	}
+	destX, destY = findNearbyOpenTile(destX, destY, portalClearing+1)
+	if locked, err := LockTileForEntity(entityID, destX, destY); err != nil || !locked {
+		return nil, 0, 0, false
+	}
+
+	portalKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
+	c.HSet(ctx, entityID, "x", destX, "y", destY, "lastPortal", portalKey)
+	entityData["x"] = strconv.Itoa(destX)
+	entityData["y"] = strconv.Itoa(destY)
+	entityData["lastPortal"] = portalKey
+	setEntityPosition(c, entityID, destX, destY)
+	releaseTileLockScript.Eval(ctx, c, tileLockKeys(portalKey),
+		entityID, portalKey, string(RedisKeyLockOwnerPrefix), 0)
+
+	// Players in the new zone have not seen them yet; clients treat this as an upsert.
+	joinMsg = map[string]interface{}{
+		"type":     string(ServerEventEntityJoined),
+		"entityId": entityID,
+		"x":        destX,
+		"y":        destY,
+	}
+	if strings.HasPrefix(entityID, string(RedisKeyPlayerPrefix)) {
+		gear, _ := GetGear(entityID)
+		joinMsg["entityType"] = string(EntityTypePlayer)
+		joinMsg["name"] = entityData["name"]
+		joinMsg["shirtColor"] = entityData["shirtColor"]
+		joinMsg["gear"] = gear
+	} else {
+		// An NPC makes its home where it comes out, rather than leashing back across zones.
+		c.HSet(ctx, entityID, "originX", destX, "originY", destY)
+		entityData["originX"] = strconv.Itoa(destX)
+		entityData["originY"] = strconv.Itoa(destY)
+		joinMsg["entityType"] = string(EntityTypeNPC)
+		joinMsg["name"] = entityData["npcType"]
+	}
+	return joinMsg, destX, destY, true
+}
+
+// portalFollowedThrough returns the portal a followed entity left the follower's zone
+// by, if the entity is now in another zone and the portal is within FollowRange of the
+// follower at (x, y). The follower steps onto it to be carried after them.
+func portalFollowedThrough(targetID string, target SpatialEntry, x, y int) (pathPoint, bool) {
+	zone := zoneAt(x, y)
+	if zoneAt(target.X, target.Y) == zone {
+		return pathPoint{}, false
+	}
+	lastPortal, err := rdb.HGet(ctx, targetID, "lastPortal").Result()
+	if err != nil || lastPortal == "" {
+		return pathPoint{}, false
+	}
+	portal := pathPoint{}
+	portal.X, portal.Y = parsePathKey(lastPortal)
+	if zoneAt(portal.X, portal.Y) != zone {
+		return pathPoint{}, false
+	}
+	return portal, withinFollowRange(x, y, SpatialEntry{X: portal.X, Y: portal.Y})
+}
+
+// placeZonePortals puts up the portals whose chunks were stored before they were added
+// to ZonePortals. Portals in chunks stored since are generated with them.
+func placeZonePortals() {
+	if !worldGen.cfg.Zones {
+		return
+	}
+	for _, p := range ZonePortals {
+		for _, zone := range [2]ZoneID{p.From, p.To} {
+			originX, originY := zoneOrigin(zone)
+			x, y := originX+p.X, originY+p.Y
+			tile, _, err := GetWorldTile(x, y)
+			if err != nil {
+				log.Printf("Failed to read the portal tile at %d,%d: %v", x, y, err)
+				continue
+			}
+			if TileType(tile.Type) == TileTypePortal {
+				continue
+			}
+			log.Printf("Placing the portal from %s to %s at %d,%d.", p.From, p.To, x, y)
+			SetWorldTile(rdb, x, y, models.WorldTile{Type: string(TileTypePortal)})
+		}
+	}
+}
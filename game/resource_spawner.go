@@ -1,16 +1,11 @@
 package game
 
 import (
-	"encoding/json"
 	"log"
 	"mmo-game/models"
-	"strconv"
-	"strings"
 	"time"
 
 	"mmo-game/game/utils"
-
-	"github.com/go-redis/redis/v8"
 )
 
 const (
@@ -29,38 +24,43 @@ func StartResourceSpawner() {
 }
 
 func checkAndSpawnResources() {
-	resourceCounts := make(map[TileType]int)
-	for _, tileType := range []TileType{TileTypeTree, TileTypeRock, TileTypeIronRock} {
-		resourceCounts[tileType] = 0
-	}
-
-	members, err := rdb.ZRange(ctx, string(RedisKeyResourcePositions), 0, -1).Result()
-	if err != nil {
-		log.Printf("Error getting resource positions: %v", err)
-		return
+	// Chunks stored since the last check, here or on other nodes, add to the targets and
+	// may hold sanctuaries.
+	loadWorldStats()
+	refreshSanctuaries()
+
+	tileTypes := make(map[TileType]bool)
+	for _, targets := range ResourceTargets {
+		for tileType := range targets {
+			tileTypes[tileType] = true
+		}
 	}
 
-	for _, member := range members {
-		parts := strings.Split(member, ":")
-		if len(parts) > 0 {
-			tileType := TileType(parts[0])
-			if _, ok := resourceCounts[tileType]; ok {
-				resourceCounts[tileType]++
+	// Count the resources standing in each biome.
+	resourceCounts := make(map[BiomeType]map[TileType]int)
+	for tileType := range tileTypes {
+		for _, entry := range ResourceIndex.All(string(tileType)) {
+			biome := BiomeAt(entry.X, entry.Y)
+			if resourceCounts[biome] == nil {
+				resourceCounts[biome] = make(map[TileType]int)
 			}
+			resourceCounts[biome][tileType]++
 		}
 	}
 
-	for tileType, target := range ResourceTargets {
-		currentCount := resourceCounts[tileType]
-		if currentCount < target {
-			spawnResources(tileType, target-currentCount)
+	for biome, targets := range ResourceTargets {
+		for tileType, target := range targets {
+			currentCount := resourceCounts[biome][tileType]
+			if currentCount < target {
+				spawnResources(biome, tileType, target-currentCount)
+			}
 		}
 	}
 }
 
-func spawnResources(tileType TileType, count int) {
-	log.Printf("Spawning %d of %s", count, tileType)
-	redisKey := "potential_spawns:" + string(tileType)
+func spawnResources(biome BiomeType, tileType TileType, count int) {
+	log.Printf("Spawning %d of %s in %s", count, tileType, biome)
+	redisKey := potentialSpawnsKey(biome, tileType)
 
 	numToTry := count * 5
 	if numToTry < 20 {
@@ -69,10 +69,11 @@ func spawnResources(tileType TileType, count int) {
 
 	potentialCoords, err := rdb.SRandMemberN(ctx, redisKey, int64(numToTry)).Result()
 	if err != nil {
-		log.Printf("Error getting random spawn points for %s: %v", tileType, err)
+		log.Printf("Error getting random spawn points for %s in %s: %v", tileType, biome, err)
 		return
 	}
 
+	floor := BiomeDefs[biome].Floor
 	spawnedCount := 0
 	for _, coordKey := range potentialCoords {
 		if spawnedCount >= count {
@@ -82,14 +83,14 @@ func spawnResources(tileType TileType, count int) {
 		x, y := utils.ParseCoordKey(coordKey)
 
 		tile, _, err := GetWorldTile(x, y)
-		if err == nil && tile.Type == string(TileTypeGround) && !tile.IsSanctuary {
+		if err == nil && TileType(tile.Type) == floor && !tile.IsSanctuary {
 			spawnResourceAt(x, y, tileType)
 			spawnedCount++
 		}
 	}
 
 	if spawnedCount < count {
-		log.Printf("Only spawned %d/%d of %s. Not enough available ground tiles in natural habitat.", spawnedCount, count, tileType)
+		log.Printf("Only spawned %d/%d of %s in %s. Not enough available floor tiles in natural habitat.", spawnedCount, count, tileType, biome)
 	}
 }
 
@@ -100,20 +101,10 @@ func spawnResourceAt(x, y int, tileType TileType) {
 		Health: props.MaxHealth,
 	}
 
-	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
-	newTileJSON, _ := json.Marshal(newTile)
-
 	pipe := rdb.Pipeline()
-	pipe.HSet(ctx, string(RedisKeyWorldZone0), coordKey, string(newTileJSON))
-
-	// Member format: "tileType:x,y" e.g., "tree:10,20"
-	member := string(tileType) + ":" + coordKey
-	lon, lat := NormalizeCoords(x, y)
-	pipe.GeoAdd(ctx, string(RedisKeyResourcePositions), &redis.GeoLocation{
-		Name:      member,
-		Longitude: lon,
-		Latitude:  lat,
-	})
+	SetWorldTile(pipe, x, y, newTile)
+
+	setResourcePosition(pipe, tileType, x, y)
 
 	_, err := pipe.Exec(ctx)
 	if err != nil {
@@ -0,0 +1,200 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	jobPollInterval = 100 * time.Millisecond
+	jobClaimBatch   = 100
+	// jobLease is how long a node may run a claimed job before another node may run it
+	// again. Jobs therefore run at least once, and handlers must be idempotent.
+	jobLease       = 30 * time.Second
+	jobMaxAttempts = 5
+	jobRetryDelay  = 1 * time.Second
+)
+
+// JobHandler is the interface that all scheduled job handlers must implement.
+// Jobs run at least once: a job whose node dies mid-run is run again by another node,
+// so Run must be safe to repeat.
+type JobHandler interface {
+	// Run performs the job. payload is the JSON the job was scheduled with.
+	// Returning an error retries the job with backoff, up to jobMaxAttempts times.
+	Run(key string, payload json.RawMessage) error
+}
+
+// JobRegistry maintains a mapping of job types to their handlers.
+var JobRegistry = make(map[JobType]JobHandler)
+
+// RegisterJob registers a handler for a job type.
+// This should be called during package initialization (init() functions).
+//
+// Example:
+//   RegisterJob(JobExpireFire, &ExpireFireJobHandler{})
+func RegisterJob(jobType JobType, handler JobHandler) {
+	if JobRegistry[jobType] != nil {
+		log.Printf("WARNING: Job handler for %s is being overwritten", jobType)
+	}
+	JobRegistry[jobType] = handler
+}
+
+// scheduledJob is a job as stored in RedisKeyJobsData.
+type scheduledJob struct {
+	Type     JobType         `json:"type"`
+	Key      string          `json:"key"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	Attempts int             `json:"attempts"`
+	// Token changes every time the job is scheduled, so a node finishing an old run
+	// cannot delete a job that was rescheduled while it ran.
+	Token string `json:"token"`
+}
+
+func jobKey(jobType JobType, key string) string {
+	return string(jobType) + ":" + key
+}
+
+// ScheduleJob schedules a job to run at runAt. Jobs are identified by type and key;
+// scheduling a job that already exists replaces it, so rescheduling is just scheduling
+// again. payload is marshalled to JSON and passed to the handler.
+//
+// Usage:
+//   ScheduleJob(JobExpireFire, coordKey, time.Now().Add(2*time.Minute), nil)
+func ScheduleJob(jobType JobType, key string, runAt time.Time, payload interface{}) error {
+	job := scheduledJob{Type: jobType, Key: key, Token: uuid.New().String()}
+	if payload != nil {
+		payloadJSON, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		job.Payload = payloadJSON
+	}
+	jobJSON, _ := json.Marshal(job)
+
+	k := jobKey(jobType, key)
+	pipe := rdb.TxPipeline()
+	pipe.HSet(ctx, string(RedisKeyJobsData), k, string(jobJSON))
+	pipe.ZAdd(ctx, string(RedisKeyJobsDue), &redis.Z{Score: float64(runAt.UnixMilli()), Member: k})
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Failed to schedule job %s: %v", k, err)
+		return err
+	}
+	return nil
+}
+
+// CancelJob removes a scheduled job. A run already in progress finishes, but is not retried.
+func CancelJob(jobType JobType, key string) error {
+	k := jobKey(jobType, key)
+	pipe := rdb.TxPipeline()
+	pipe.ZRem(ctx, string(RedisKeyJobsDue), k)
+	pipe.HDel(ctx, string(RedisKeyJobsData), k)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// IsJobScheduled reports whether a job is scheduled or running.
+func IsJobScheduled(jobType JobType, key string) bool {
+	exists, err := rdb.HExists(ctx, string(RedisKeyJobsData), jobKey(jobType, key)).Result()
+	return err == nil && exists
+}
+
+// StartJobScheduler claims and runs due jobs. Any number of nodes can run it against
+// the same Redis; each due job is claimed by exactly one of them.
+func StartJobScheduler() {
+	log.Println("Starting job scheduler...")
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		requeueExpiredJobs()
+		for _, job := range claimDueJobs() {
+			go runJob(job)
+		}
+	}
+}
+
+// claimDueJobs atomically moves due jobs from the due set to the claimed set.
+func claimDueJobs() []scheduledJob {
+	now := time.Now()
+	keys := []string{string(RedisKeyJobsDue), string(RedisKeyJobsClaimed), string(RedisKeyJobsData)}
+	values, err := claimJobsScript.Run(ctx, rdb, keys,
+		now.UnixMilli(), jobClaimBatch, now.Add(jobLease).UnixMilli()).StringSlice()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("Failed to claim due jobs: %v", err)
+		}
+		return nil
+	}
+
+	jobs := make([]scheduledJob, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		var job scheduledJob
+		if err := json.Unmarshal([]byte(values[i+1]), &job); err != nil {
+			log.Printf("Dropping unreadable job %s: %v", values[i], err)
+			rdb.HDel(ctx, string(RedisKeyJobsData), values[i])
+			rdb.ZRem(ctx, string(RedisKeyJobsClaimed), values[i])
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+func runJob(job scheduledJob) {
+	k := jobKey(job.Type, job.Key)
+	handler, ok := JobRegistry[job.Type]
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler registered for job type %s", job.Type)
+	} else {
+		err = runJobHandler(handler, job)
+	}
+
+	keys := []string{string(RedisKeyJobsClaimed), string(RedisKeyJobsData), string(RedisKeyJobsDue)}
+	if err == nil {
+		ackJobScript.Run(ctx, rdb, keys, k, job.Token)
+		return
+	}
+
+	job.Attempts++
+	if job.Attempts >= jobMaxAttempts {
+		log.Printf("Job %s failed %d times, giving up: %v", k, job.Attempts, err)
+		ackJobScript.Run(ctx, rdb, keys, k, job.Token)
+		return
+	}
+	log.Printf("Job %s failed (attempt %d), retrying: %v", k, job.Attempts, err)
+	retryAt := time.Now().Add(jobRetryDelay * time.Duration(1<<(job.Attempts-1)))
+	jobJSON, _ := json.Marshal(job)
+	retryJobScript.Run(ctx, rdb, keys, k, job.Token, string(jobJSON), retryAt.UnixMilli())
+}
+
+// runJobHandler runs a handler, turning a panic into an error so one bad job cannot
+// take the scheduler down.
+func runJobHandler(handler JobHandler, job scheduledJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler.Run(job.Key, job.Payload)
+}
+
+// requeueExpiredJobs makes jobs claimed by a node that stopped before finishing them due
+// again.
+func requeueExpiredJobs() {
+	keys := []string{string(RedisKeyJobsClaimed), string(RedisKeyJobsDue)}
+	count, err := requeueJobsScript.Run(ctx, rdb, keys, strconv.FormatInt(time.Now().UnixMilli(), 10)).Int()
+	if err != nil && err != redis.Nil {
+		log.Printf("Failed to requeue expired jobs: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Requeued %d jobs whose claim expired.", count)
+	}
+}
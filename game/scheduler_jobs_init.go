@@ -0,0 +1,57 @@
+package game
+
+import (
+	"encoding/json"
+	"mmo-game/game/utils"
+	"time"
+)
+
+// init registers all scheduled job handlers with the JobRegistry.
+// To add a timed event, add a JobType, create a handler struct that implements
+// JobHandler, and register it here with RegisterJob. Handlers may run more than once.
+func init() {
+	RegisterJob(JobCompleteTeleport, &CompleteTeleportJobHandler{})
+	RegisterJob(JobExpireFire, &ExpireFireJobHandler{})
+	RegisterJob(JobItemPublic, &ItemPublicJobHandler{})
+	RegisterJob(JobItemDespawn, &ItemDespawnJobHandler{})
+}
+
+// CompleteTeleportJobHandler finishes a teleport channel. The job is keyed by player ID;
+// completeTeleport ignores it if the teleport was cancelled or already completed.
+type CompleteTeleportJobHandler struct{}
+
+func (h *CompleteTeleportJobHandler) Run(playerID string, payload json.RawMessage) error {
+	var job teleportJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return err
+	}
+	completeTeleport(playerID, time.UnixMilli(job.CompleteAt))
+	return nil
+}
+
+// ExpireFireJobHandler turns a fire back into ground. The job is keyed by "x,y";
+// expireFire does nothing if the tile is no longer a fire.
+type ExpireFireJobHandler struct{}
+
+func (h *ExpireFireJobHandler) Run(coordKey string, payload json.RawMessage) error {
+	x, y := utils.ParseCoordKey(coordKey)
+	expireFire(x, y)
+	return nil
+}
+
+// ItemPublicJobHandler makes an owned drop public. The job is keyed by drop ID.
+type ItemPublicJobHandler struct{}
+
+func (h *ItemPublicJobHandler) Run(dropID string, payload json.RawMessage) error {
+	makeItemPublic(dropID)
+	return nil
+}
+
+// ItemDespawnJobHandler removes an expired pile. The job is keyed by drop ID.
+type ItemDespawnJobHandler struct{}
+
+func (h *ItemDespawnJobHandler) Run(dropID string, payload json.RawMessage) error {
+	despawnWorldItem(dropID)
+	return nil
+}
+
@@ -0,0 +1,54 @@
+package game
+
+// Line of sight is traced along the tiles between two coordinates. A tile blocks it if
+// its type blocks sight (rock, walls, cliffs), or if it stands higher than both ends, so
+// a ridge hides what is behind it while whoever is on it can see down both sides.
+
+// hasLineOfSight reports whether nothing blocks the view between two coordinates. The
+// tiles at either end never block it. Like movement, a diagonal step only blocks if the
+// tiles on both sides of it do.
+func hasLineOfSight(x0, y0, x1, y1 int) bool {
+	from, _, err := GetWorldTile(x0, y0)
+	if err != nil {
+		return false
+	}
+	to, _, err := GetWorldTile(x1, y1)
+	if err != nil {
+		return false
+	}
+	eyeLevel := max(from.Height, to.Height)
+
+	blocks := func(x, y int) bool {
+		tile, props, err := GetWorldTile(x, y)
+		return err != nil || props.BlocksSight || tile.Height > eyeLevel
+	}
+
+	// Bresenham's line, stepping both axes at once on diagonals.
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	x, y, e := x0, y0, dx+dy
+	for x != x1 || y != y1 {
+		stepX, stepY := 2*e >= dy, 2*e <= dx
+		if stepX && stepY && blocks(x+sx, y) && blocks(x, y+sy) {
+			return false // Squeezed between two blocking tiles
+		}
+		if stepX {
+			e += dy
+			x += sx
+		}
+		if stepY {
+			e += dx
+			y += sy
+		}
+		if (x != x1 || y != y1) && blocks(x, y) {
+			return false
+		}
+	}
+	return true
+}
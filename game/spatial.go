@@ -0,0 +1,364 @@
+package game
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// spatialCellSize is the width in tiles of a SpatialIndex bucket.
+const spatialCellSize = 16
+
+// SpatialEntry is something with a tile position in a SpatialIndex.
+type SpatialEntry struct {
+	ID   string
+	Kind string
+	X, Y int
+}
+
+type spatialCell struct{ CX, CY int }
+
+// SpatialIndex is an in-memory spatial hash of positions, answering exact tile-range
+// queries without going to Redis. Entries have a kind (an EntityType or TileType) so
+// queries can ask for one kind only. It is safe for concurrent use.
+type SpatialIndex struct {
+	mu      sync.RWMutex
+	entries map[string]SpatialEntry
+	cells   map[spatialCell]map[string]struct{}
+	byKind  map[string]map[string]struct{}
+	// minCell and maxCell bound every cell ever used, so Nearest knows when to stop.
+	minCell, maxCell spatialCell
+}
+
+// NewSpatialIndex creates an empty index.
+func NewSpatialIndex() *SpatialIndex {
+	s := &SpatialIndex{}
+	s.Reset()
+	return s
+}
+
+var (
+	// EntityIndex holds the position of every player, NPC and item on the map, keyed by
+	// entity ID with the entity type as its kind.
+	EntityIndex = NewSpatialIndex()
+	// ResourceIndex holds every resource node, keyed by its RedisKeyResourcePositions
+	// member ("tileType:x,y") with the tile type as its kind.
+	ResourceIndex = NewSpatialIndex()
+)
+
+func spatialCellFor(x, y int) spatialCell {
+	return spatialCell{floorDiv(x, spatialCellSize), floorDiv(y, spatialCellSize)}
+}
+
+// Reset empties the index.
+func (s *SpatialIndex) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = make(map[string]SpatialEntry)
+	s.cells = make(map[spatialCell]map[string]struct{})
+	s.byKind = make(map[string]map[string]struct{})
+	s.minCell, s.maxCell = spatialCell{}, spatialCell{}
+}
+
+// Upsert adds an entry or moves an existing one.
+func (s *SpatialIndex) Upsert(id, kind string, x, y int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(id)
+
+	entry := SpatialEntry{ID: id, Kind: kind, X: x, Y: y}
+	s.entries[id] = entry
+	cell := spatialCellFor(x, y)
+	if s.cells[cell] == nil {
+		s.cells[cell] = make(map[string]struct{})
+	}
+	s.cells[cell][id] = struct{}{}
+	if s.byKind[kind] == nil {
+		s.byKind[kind] = make(map[string]struct{})
+	}
+	s.byKind[kind][id] = struct{}{}
+
+	if len(s.entries) == 1 {
+		s.minCell, s.maxCell = cell, cell
+		return
+	}
+	s.minCell.CX, s.minCell.CY = min(s.minCell.CX, cell.CX), min(s.minCell.CY, cell.CY)
+	s.maxCell.CX, s.maxCell.CY = max(s.maxCell.CX, cell.CX), max(s.maxCell.CY, cell.CY)
+}
+
+// Remove drops an entry. Removing an unknown ID does nothing.
+func (s *SpatialIndex) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(id)
+}
+
+func (s *SpatialIndex) removeLocked(id string) {
+	entry, ok := s.entries[id]
+	if !ok {
+		return
+	}
+	delete(s.entries, id)
+	cell := spatialCellFor(entry.X, entry.Y)
+	delete(s.cells[cell], id)
+	if len(s.cells[cell]) == 0 {
+		delete(s.cells, cell)
+	}
+	delete(s.byKind[entry.Kind], id)
+}
+
+// Get returns an entry by ID.
+func (s *SpatialIndex) Get(id string) (SpatialEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[id]
+	return entry, ok
+}
+
+// All returns every entry of a kind, or every entry if kind is empty.
+func (s *SpatialIndex) All(kind string) []SpatialEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var result []SpatialEntry
+	if kind == "" {
+		result = make([]SpatialEntry, 0, len(s.entries))
+		for _, entry := range s.entries {
+			result = append(result, entry)
+		}
+		return result
+	}
+	result = make([]SpatialEntry, 0, len(s.byKind[kind]))
+	for id := range s.byKind[kind] {
+		result = append(result, s.entries[id])
+	}
+	return result
+}
+
+// InRange returns the entries of a kind (any kind if empty) within radius tiles of
+// (x, y), measured as a straight line, nearest first.
+//
+// Usage:
+//   for _, entry := range EntityIndex.InRange(x, y, 10, string(EntityTypePlayer)) { ... }
+func (s *SpatialIndex) InRange(x, y, radius int, kind string) []SpatialEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []SpatialEntry
+	lo, hi := spatialCellFor(x-radius, y-radius), spatialCellFor(x+radius, y+radius)
+	for cx := lo.CX; cx <= hi.CX; cx++ {
+		for cy := lo.CY; cy <= hi.CY; cy++ {
+			for id := range s.cells[spatialCell{cx, cy}] {
+				entry := s.entries[id]
+				if kind != "" && entry.Kind != kind {
+					continue
+				}
+				if spatialDistSq(x, y, entry) <= radius*radius {
+					result = append(result, entry)
+				}
+			}
+		}
+	}
+	sortByDistance(result, x, y)
+	return result
+}
+
+// At returns the entries of a kind (any kind if empty) standing exactly on (x, y).
+func (s *SpatialIndex) At(x, y int, kind string) []SpatialEntry {
+	return s.InRange(x, y, 0, kind)
+}
+
+// Nearest returns the closest entry of a kind to (x, y), within maxRadius tiles, that
+// accept allows, searching outwards one ring of cells at a time. A nil accept allows
+// every entry.
+func (s *SpatialIndex) Nearest(x, y int, kind string, maxRadius int, accept func(SpatialEntry) bool) (SpatialEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.byKind[kind]) == 0 {
+		return SpatialEntry{}, false
+	}
+
+	center := spatialCellFor(x, y)
+	maxRing := min(
+		max(
+			max(center.CX-s.minCell.CX, s.maxCell.CX-center.CX),
+			max(center.CY-s.minCell.CY, s.maxCell.CY-center.CY),
+		),
+		maxRadius/spatialCellSize+1,
+	)
+
+	var best SpatialEntry
+	bestDistSq := -1
+	visit := func(cell spatialCell) {
+		for id := range s.cells[cell] {
+			entry := s.entries[id]
+			if entry.Kind != kind || (accept != nil && !accept(entry)) {
+				continue
+			}
+			distSq := spatialDistSq(x, y, entry)
+			if distSq > maxRadius*maxRadius {
+				continue
+			}
+			if bestDistSq < 0 || distSq < bestDistSq || (distSq == bestDistSq && entry.ID < best.ID) {
+				best, bestDistSq = entry, distSq
+			}
+		}
+	}
+	for ring := 0; ring <= maxRing; ring++ {
+		// Every tile in this ring is at least (ring-1) cells away along one axis.
+		if bestDistSq >= 0 {
+			minDist := (ring - 1) * spatialCellSize
+			if minDist > 0 && minDist*minDist > bestDistSq {
+				break
+			}
+		}
+		if ring == 0 {
+			visit(center)
+			continue
+		}
+		// Only the perimeter: the rows above and below, then the columns between them.
+		for cx := center.CX - ring; cx <= center.CX+ring; cx++ {
+			visit(spatialCell{cx, center.CY - ring})
+			visit(spatialCell{cx, center.CY + ring})
+		}
+		for cy := center.CY - ring + 1; cy <= center.CY+ring-1; cy++ {
+			visit(spatialCell{center.CX - ring, cy})
+			visit(spatialCell{center.CX + ring, cy})
+		}
+	}
+	return best, bestDistSq >= 0
+}
+
+func spatialDistSq(x, y int, entry SpatialEntry) int {
+	dx, dy := entry.X-x, entry.Y-y
+	return dx*dx + dy*dy
+}
+
+// sortByDistance sorts entries nearest first, breaking ties by ID so results are stable.
+func sortByDistance(entries []SpatialEntry, x, y int) {
+	sort.Slice(entries, func(i, j int) bool {
+		di, dj := spatialDistSq(x, y, entries[i]), spatialDistSq(x, y, entries[j])
+		if di != dj {
+			return di < dj
+		}
+		return entries[i].ID < entries[j].ID
+	})
+}
+
+// entityKind returns the entity type encoded in an entity ID such as "player:uuid".
+func entityKind(entityID string) string {
+	kind, _, _ := strings.Cut(entityID, ":")
+	return kind
+}
+
+// setEntityPosition records an entity's position in the positions of its zone through c
+// (a client or a pipeline) and in the EntityIndex of every node. An entity moving to another zone is
+// taken out of the positions of the one it left, and a player has the zone they are in
+// recorded on their hash.
+func setEntityPosition(c redis.Cmdable, entityID string, x, y int) {
+	zone := zoneAt(x, y)
+	_, indexed := EntityIndex.Get(entityID)
+	previous := entityZone(entityID)
+	if previous != zone {
+		c.ZRem(ctx, zonePositionsKey(previous), entityID)
+	}
+	if (previous != zone || !indexed) && entityKind(entityID) == string(EntityTypePlayer) {
+		c.HSet(ctx, entityID, "zone", string(zone))
+	}
+	lon, lat := NormalizeCoords(x, y)
+	c.GeoAdd(ctx, zonePositionsKey(zone), &redis.GeoLocation{
+		Name:      entityID,
+		Longitude: lon,
+		Latitude:  lat,
+	})
+	indexEntity(c, entityID, x, y)
+}
+
+// removeEntityPosition takes an entity off the map.
+func removeEntityPosition(c redis.Cmdable, entityID string) {
+	c.ZRem(ctx, zonePositionsKey(entityZone(entityID)), entityID)
+	unindexEntity(c, entityID)
+}
+
+// resourceMember is the RedisKeyResourcePositions member for a resource node.
+func resourceMember(tileType TileType, x, y int) string {
+	return string(tileType) + ":" + strconv.Itoa(x) + "," + strconv.Itoa(y)
+}
+
+// setResourcePosition records a resource node in RedisKeyResourcePositions and the
+// ResourceIndex of every node.
+func setResourcePosition(c redis.Cmdable, tileType TileType, x, y int) {
+	member := resourceMember(tileType, x, y)
+	lon, lat := NormalizeCoords(x, y)
+	c.GeoAdd(ctx, string(RedisKeyResourcePositions), &redis.GeoLocation{
+		Name:      member,
+		Longitude: lon,
+		Latitude:  lat,
+	})
+	indexResource(c, member, tileType, x, y)
+}
+
+// removeResourcePosition removes a resource node.
+func removeResourcePosition(c redis.Cmdable, tileType TileType, x, y int) {
+	member := resourceMember(tileType, x, y)
+	c.ZRem(ctx, string(RedisKeyResourcePositions), member)
+	unindexResource(c, member)
+}
+
+// IndexSpatialPositions rebuilds the EntityIndex and ResourceIndex from Redis. Call it
+// at startup, after the world is generated or restored.
+func IndexSpatialPositions() {
+	EntityIndex.Reset()
+	ResourceIndex.Reset()
+
+	for _, zone := range activeZones() {
+		indexZonePositions(zone)
+	}
+
+	members, err := rdb.ZRange(ctx, string(RedisKeyResourcePositions), 0, -1).Result()
+	if err != nil {
+		log.Printf("Failed to read resource positions for the spatial index: %v", err)
+		return
+	}
+	for _, member := range members {
+		tileType, coordKey, ok := strings.Cut(member, ":")
+		if !ok {
+			continue
+		}
+		coords := strings.Split(coordKey, ",")
+		if len(coords) != 2 {
+			continue
+		}
+		x, _ := strconv.Atoi(coords[0])
+		y, _ := strconv.Atoi(coords[1])
+		ResourceIndex.Upsert(member, tileType, x, y)
+	}
+	log.Printf("Spatial index holds %d entities and %d resource nodes.", len(EntityIndex.All("")), len(ResourceIndex.All("")))
+}
+
+// indexZonePositions adds the entities positioned in a zone to the EntityIndex.
+func indexZonePositions(zone ZoneID) {
+	entityIDs, err := rdb.ZRange(ctx, zonePositionsKey(zone), 0, -1).Result()
+	if err != nil {
+		log.Printf("Failed to read entity positions in zone %s for the spatial index: %v", zone, err)
+		return
+	}
+	pipe := rdb.Pipeline()
+	cmds := make(map[string]*redis.SliceCmd, len(entityIDs))
+	for _, entityID := range entityIDs {
+		cmds[entityID] = pipe.HMGet(ctx, entityID, "x", "y")
+	}
+	pipe.Exec(ctx)
+	for entityID, cmd := range cmds {
+		position, err := cmd.Result()
+		if err != nil || position[0] == nil || position[1] == nil {
+			continue
+		}
+		x, _ := strconv.Atoi(position[0].(string))
+		y, _ := strconv.Atoi(position[1].(string))
+		EntityIndex.Upsert(entityID, entityKind(entityID), x, y)
+	}
+}
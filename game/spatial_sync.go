@@ -0,0 +1,137 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The EntityIndex and ResourceIndex are held by every server node. Each change a node
+// makes to them is published on RedisKeySpatialUpdates alongside the write to Redis, and
+// the other nodes apply it to their own. The node making the change applies it once the
+// announcement reaches Redis, through spatialIndexHook, so a change queued on a pipeline
+// that fails is not applied anywhere.
+
+// spatialChange is a message on RedisKeySpatialUpdates. A change with Reset set means
+// the positions in Redis were replaced, and the indexes are rebuilt from them; the other
+// fields are then unused.
+type spatialChange struct {
+	Node     string `json:"node"`
+	Resource bool   `json:"resource,omitempty"` // The ResourceIndex, else the EntityIndex
+	ID       string `json:"id"`
+	Kind     string `json:"kind,omitempty"`
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Removed  bool   `json:"removed,omitempty"`
+	Reset    bool   `json:"reset,omitempty"`
+}
+
+// publishSpatialChange announces an index change to the other server nodes through c.
+func publishSpatialChange(c redis.Cmdable, change spatialChange) {
+	change.Node = nodeID
+	changeJSON, err := json.Marshal(change)
+	if err != nil {
+		return
+	}
+	c.Publish(ctx, string(RedisKeySpatialUpdates), string(changeJSON))
+}
+
+// indexEntity puts an entity in the EntityIndex of every node.
+func indexEntity(c redis.Cmdable, entityID string, x, y int) {
+	publishSpatialChange(c, spatialChange{ID: entityID, Kind: entityKind(entityID), X: x, Y: y})
+}
+
+// unindexEntity takes an entity out of the EntityIndex of every node.
+func unindexEntity(c redis.Cmdable, entityID string) {
+	publishSpatialChange(c, spatialChange{ID: entityID, Removed: true})
+}
+
+// indexResource puts a resource node in the ResourceIndex of every node.
+func indexResource(c redis.Cmdable, member string, tileType TileType, x, y int) {
+	publishSpatialChange(c, spatialChange{Resource: true, ID: member, Kind: string(tileType), X: x, Y: y})
+}
+
+// unindexResource takes a resource node out of the ResourceIndex of every node.
+func unindexResource(c redis.Cmdable, member string) {
+	publishSpatialChange(c, spatialChange{Resource: true, ID: member, Removed: true})
+}
+
+// applySpatialChange applies an index change to this node's indexes.
+func applySpatialChange(change spatialChange) {
+	index := EntityIndex
+	if change.Resource {
+		index = ResourceIndex
+	}
+	if change.Removed {
+		index.Remove(change.ID)
+	} else {
+		index.Upsert(change.ID, change.Kind, change.X, change.Y)
+	}
+}
+
+// spatialIndexHook applies this node's own index changes when the commands announcing
+// them succeed, whether sent on their own or in a pipeline.
+type spatialIndexHook struct{}
+
+func (spatialIndexHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (spatialIndexHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	applyPublishedSpatialChange(cmd)
+	return nil
+}
+
+func (spatialIndexHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (spatialIndexHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		applyPublishedSpatialChange(cmd)
+	}
+	return nil
+}
+
+// applyPublishedSpatialChange applies the change cmd announced, if it is a successful
+// PUBLISH of one of this node's changes. Resets are left to the code making them.
+func applyPublishedSpatialChange(cmd redis.Cmder) {
+	args := cmd.Args()
+	if cmd.Name() != "publish" || cmd.Err() != nil || len(args) != 3 || args[1] != string(RedisKeySpatialUpdates) {
+		return
+	}
+	payload, ok := args[2].(string)
+	if !ok {
+		return
+	}
+	var change spatialChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil || change.Node != nodeID || change.Reset {
+		return
+	}
+	applySpatialChange(change)
+}
+
+// StartSpatialSync applies the index changes made by other server nodes to this node's
+// EntityIndex and ResourceIndex.
+func StartSpatialSync() {
+	pubsub := rdb.Subscribe(ctx, string(RedisKeySpatialUpdates))
+	defer pubsub.Close()
+
+	for msg := range pubsub.Channel() {
+		var change spatialChange
+		if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+			log.Printf("Ignoring unreadable spatial index change: %v", err)
+			continue
+		}
+		if change.Node == nodeID {
+			continue
+		}
+		if change.Reset {
+			IndexSpatialPositions()
+			continue
+		}
+		applySpatialChange(change)
+	}
+}
@@ -7,25 +7,36 @@ import (
 	"strconv"
 	"strings"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
-// findRandomOpenTile attempts to find a random, un-collidable, and unlocked tile.
-func findRandomOpenTile(occupied map[string]bool) (int, int) {
-	for i := 0; i < 100; i++ { // Try 100 times to find a valid spot
-		x := rand.Intn(WorldSize*2) - WorldSize
-		y := rand.Intn(WorldSize*2) - WorldSize
-		coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
-		if isTileAvailable(x, y) && !occupied[coordKey] {
-			tile, _, err := GetWorldTile(x, y)
-			if err == nil && !tile.IsSanctuary {
-				return x, y
-			}
+// roadSpawnChance is how likely a spawn that lands on a road is to be kept there.
+const roadSpawnChance = 0.2
+
+// findRandomOpenTileIn attempts to find a random, un-collidable, and unlocked tile in a
+// biome, in a stored chunk of the zone the biome is in, seldom on a road. It reports
+// false if none was found.
+func findRandomOpenTileIn(biome BiomeType) (int, int, bool) {
+	chunks, err := rdb.SRandMemberN(ctx, zoneChunksKey(biomeZone(biome)), 50).Result()
+	if err != nil || len(chunks) == 0 {
+		return 0, 0, false
+	}
+	for i := 0; i < 500; i++ { // Most tries land outside the biome, and those are cheap
+		cx, cy := utils.ParseCoordKey(chunks[i%len(chunks)])
+		x := cx*WorldChunkSize + rand.Intn(WorldChunkSize)
+		y := cy*WorldChunkSize + rand.Intn(WorldChunkSize)
+		if BiomeAt(x, y) != biome || !isTileAvailable(x, y) {
+			continue
+		}
+		tile, props, err := GetWorldTile(x, y)
+		if err != nil || tile.IsSanctuary {
+			continue
+		}
+		if props.IsRoad && rand.Float64() >= roadSpawnChance {
+			continue
 		}
+		return x, y, true
 	}
-	// Fallback, though unlikely to be hit in a large world
-	return 0, 0
+	return 0, 0, false
 }
 
 // findNearbyOpenTile finds an open tile within a certain radius of a given point.
@@ -57,8 +68,7 @@ func findNearbyOpenTile(x, y, radius int) (int, int) {
 }
 
 func SpawnPlayer(playerID string, playerData map[string]string) (int, int) {
-	binding, ok := playerData["binding"]
-	if ok && binding != "" {
+	if binding, ok := playerStoredString(playerData, "binding"); ok {
 		parts := strings.Split(binding, ",")
 		if len(parts) == 2 {
 			x, errX := strconv.Atoi(parts[0])
@@ -72,7 +82,7 @@ func SpawnPlayer(playerID string, playerData map[string]string) (int, int) {
 	// Fallback to a random sanctuary
 	// randomSanctuary := Sanctuaries[rand.Intn(len(Sanctuaries))]
 	// return findNearbyOpenTile(randomSanctuary.X, randomSanctuary.Y, randomSanctuary.Radius)
-	firstSanctuary := Sanctuaries[0]
+	firstSanctuary := knownSanctuaries()[0]
 	return findNearbyOpenTile(firstSanctuary.X, firstSanctuary.Y, firstSanctuary.Radius)
 }
 
@@ -98,18 +108,14 @@ func spawnPreLockedNPC(entityID string, x, y int, npcType NPCType, groupID strin
 	}
 	pipe.HSet(ctx, entityID, hsetArgs...)
 
-	lon, lat := NormalizeCoords(x, y)
-	pipe.GeoAdd(ctx, string(RedisKeyZone0Positions), &redis.GeoLocation{
-		Name:      entityID,
-		Longitude: lon,
-		Latitude:  lat,
-	})
+	setEntityPosition(pipe, entityID, x, y)
 
 	_, err := pipe.Exec(ctx)
 	if err != nil {
 		log.Printf("Failed to spawn %s %s: %v", npcType, entityID, err)
 		// If spawning fails, unlock the tile
 		UnlockTileForEntity(entityID, x, y)
+		unindexEntity(rdb, entityID)
 		return
 	}
 
@@ -134,20 +140,31 @@ func spawnNPC(entityID string, x, y int, npcType NPCType, groupID string, origin
 	spawnPreLockedNPC(entityID, x, y, npcType, groupID, originX, originY, wanderDistance)
 }
 
-// spawnSlime creates a new slime entity in the world.
-func spawnSlime() {
-	entityID := string(NPCSlimePrefix) + utils.GenerateUniqueID()
-	spawnX, spawnY := findRandomOpenTile(nil)
-	spawnNPC(entityID, spawnX, spawnY, NPCTypeSlime, "", spawnX, spawnY, NPCDefs[NPCTypeSlime].WanderDistance)
+// spawnBiomeNPC creates an NPC from a biome's spawn table somewhere in that biome.
+func spawnBiomeNPC(biome BiomeType, npcType NPCType) {
+	if npcType == NPCTypeSlimeBoss {
+		spawnSlimeBoss(biome)
+		return
+	}
+	spawnX, spawnY, found := findRandomOpenTileIn(biome)
+	if !found {
+		log.Printf("Could not find a spawn location for %s in %s.", npcType, biome)
+		return
+	}
+	entityID := string(npcIDPrefixes[npcType]) + utils.GenerateUniqueID()
+	spawnNPC(entityID, spawnX, spawnY, npcType, "", spawnX, spawnY, NPCDefs[npcType].WanderDistance)
 }
 
-func findGroupSpawn(numMembers int, formation [][2]int) ([][2]int, bool) {
+func findGroupSpawn(biome BiomeType, numMembers int, formation [][2]int) ([][2]int, bool) {
 	for i := 0; i < 100; i++ {
-		centerX, centerY := findRandomOpenTile(nil)
+		centerX, centerY, found := findRandomOpenTileIn(biome)
+		if !found {
+			continue
+		}
 
 		// Check distance from all sanctuaries
 		tooClose := false
-		for _, s := range Sanctuaries {
+		for _, s := range knownSanctuaries() {
 			dx := centerX - s.X
 			dy := centerY - s.Y
 			// Using Chebyshev distance (max of absolute differences)
@@ -201,19 +218,36 @@ func findGroupSpawn(numMembers int, formation [][2]int) ([][2]int, bool) {
 	return nil, false
 }
 
-// spawnSlimeBoss creates a new slime boss entity in the world.
-func spawnSlimeBoss() {
-	formation := [][2]int{
-		{-1, -1}, {1, -1}, // Top-left, Top-right
-		{-1, 1}, {1, 1}, // Bottom-left, Bottom-right
-	}
+// slimeBossFormation is where a slime boss's slimes stand around it.
+var slimeBossFormation = [][2]int{
+	{-1, -1}, {1, -1}, // Top-left, Top-right
+	{-1, 1}, {1, 1}, // Bottom-left, Bottom-right
+}
 
-	positions, found := findGroupSpawn(5, formation)
+// spawnSlimeBoss creates a new slime boss entity, with its slimes, in a biome.
+func spawnSlimeBoss(biome BiomeType) {
+	positions, found := findGroupSpawn(biome, 5, slimeBossFormation)
 	if !found {
-		log.Println("Could not find a valid spawn location for slime boss group after 100 attempts.")
+		log.Printf("Could not find a valid spawn location for slime boss group in %s after 100 attempts.", biome)
 		return
 	}
 
+	spawnSlimeBossGroup(positions)
+}
+
+// spawnSlimeBossAt creates a slime boss with its slimes around a given tile, as in a
+// dungeon's boss room. The tiles must be open.
+func spawnSlimeBossAt(x, y int) {
+	positions := [][2]int{{x, y}}
+	for _, offset := range slimeBossFormation {
+		positions = append(positions, [2]int{x + offset[0], y + offset[1]})
+	}
+	spawnSlimeBossGroup(positions)
+}
+
+// spawnSlimeBossGroup spawns a slime boss at the first position and its slimes at the
+// rest, locking every tile first.
+func spawnSlimeBossGroup(positions [][2]int) {
 	bossPosition := positions[0]
 	bossX, bossY := bossPosition[0], bossPosition[1]
 
@@ -252,13 +286,6 @@ func spawnSlimeBoss() {
 	}
 }
 
-// spawnRat creates a new rat entity in the world.
-func spawnRat() {
-	entityID := string(NPCRatPrefix) + utils.GenerateUniqueID()
-	spawnX, spawnY := findRandomOpenTile(nil)
-	spawnNPC(entityID, spawnX, spawnY, NPCTypeRat, "", spawnX, spawnY, NPCDefs[NPCTypeRat].WanderDistance)
-}
-
 func spawnWizard() {
 	entityID := string(NPCWizardPrefix) + utils.GenerateUniqueID()
 	spawnNPC(entityID, 4, 0, NPCTypeWizard, "", 4, 0, NPCDefs[NPCTypeWizard].WanderDistance)
@@ -8,12 +8,16 @@ import (
 )
 
 const (
-	targetSlimeCount     = 20
-	targetRatCount       = 20
-	targetSlimeBossCount = 4
 	spawnerCheckInterval = 30 * time.Second
 )
 
+// npcIDPrefixes maps the NPCs the spawner keeps populated to their entity ID prefixes.
+var npcIDPrefixes = map[NPCType]RedisKey{
+	NPCTypeSlime:     NPCSlimePrefix,
+	NPCTypeRat:       NPCRatPrefix,
+	NPCTypeSlimeBoss: NPCBossSlimePrefix,
+}
+
 // StartSpawnerLoop begins the loop for checking and spawning NPCs.
 func StartSpawnerLoop() {
 	log.Println("Starting NPC spawner loop...")
@@ -31,56 +35,61 @@ func StartSpawnerLoop() {
 }
 
 func checkAndSpawnNPCs() {
-	entityIDs, err := rdb.ZRange(ctx, string(RedisKeyZone0Positions), 0, -1).Result()
-	if err != nil {
-		log.Printf("Error fetching entities for spawner: %v", err)
-		return
+	var entityIDs []string
+	for _, zone := range zoneLayers {
+		members, err := rdb.ZRange(ctx, zonePositionsKey(zone), 0, -1).Result()
+		if err != nil {
+			log.Printf("Error fetching entities in zone %s for spawner: %v", zone, err)
+			return
+		}
+		entityIDs = append(entityIDs, members...)
 	}
 
-	// Spawner needs to know about wizards too.
-	currentSlimeCount := 0
-	currentRatCount := 0
+	// Count each NPC type in the biome it is standing in. Spawner needs to know about
+	// wizards too.
+	counts := make(map[BiomeType]map[NPCType]int)
+	totals := make(map[NPCType]int)
 	currentWizardCount := 0
-	currentSlimeBossCount := 0
 	for _, entityID := range entityIDs {
-		if strings.HasPrefix(entityID, string(NPCSlimePrefix)) {
-			currentSlimeCount++
-		} else if strings.HasPrefix(entityID, string(NPCRatPrefix)) {
-			currentRatCount++
-		} else if strings.HasPrefix(entityID, string(NPCWizardPrefix)) {
+		if strings.HasPrefix(entityID, string(NPCWizardPrefix)) {
 			currentWizardCount++
-		} else if strings.HasPrefix(entityID, string(NPCBossSlimePrefix)) {
-			currentSlimeBossCount++
+			continue
+		}
+		for npcType, prefix := range npcIDPrefixes {
+			if !strings.HasPrefix(entityID, string(prefix)) {
+				continue
+			}
+			if entry, ok := EntityIndex.Get(entityID); ok {
+				biome := BiomeAt(entry.X, entry.Y)
+				if counts[biome] == nil {
+					counts[biome] = make(map[NPCType]int)
+				}
+				counts[biome][npcType]++
+			}
+			totals[npcType]++
+			break
 		}
 	}
 
-	log.Printf("Spawner check: Slimes=%d/%d, Rats=%d/%d", currentSlimeCount, targetSlimeCount, currentRatCount, targetRatCount)
+	log.Printf("Spawner check: Slimes=%d, Rats=%d, Slime bosses=%d", totals[NPCTypeSlime], totals[NPCTypeRat], totals[NPCTypeSlimeBoss])
 
 	if currentWizardCount == 0 {
 		spawnWizard()
 	}
 
-	// Spawn missing slimes
-	for i := currentSlimeCount; i < targetSlimeCount; i++ {
-		go func() {
-			// Stagger the spawns to make them feel more natural
-			time.Sleep(time.Duration(rand.Intn(5000)) * time.Millisecond)
-			spawnSlime()
-		}()
-	}
-
-	for i := currentSlimeBossCount; i < targetSlimeBossCount; i++ {
-		go func() {
-			time.Sleep(time.Duration(rand.Intn(5000)) * time.Millisecond)
-			spawnSlimeBoss()
-		}()
-	}
-
-	// Spawn missing rats
-	for i := currentRatCount; i < targetRatCount; i++ {
-		go func() {
-			time.Sleep(time.Duration(rand.Intn(5000)) * time.Millisecond)
-			spawnRat()
-		}()
+	// Spawn whatever each biome's spawn table is missing.
+	for _, biome := range BiomeOrder {
+		if biomeArea(biome) == 0 {
+			continue // This world has none of the biome
+		}
+		for npcType, target := range BiomeDefs[biome].NPCSpawns {
+			for i := counts[biome][npcType]; i < target; i++ {
+				go func(biome BiomeType, npcType NPCType) {
+					// Stagger the spawns to make them feel more natural
+					time.Sleep(time.Duration(rand.Intn(5000)) * time.Millisecond)
+					spawnBiomeNPC(biome, npcType)
+				}(biome, npcType)
+			}
+		}
 	}
 }
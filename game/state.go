@@ -7,6 +7,13 @@ import (
 const (
 	BaseActionCooldown = 100 * time.Millisecond
 	WaterMovePenalty   = 500 * time.Millisecond
-	WorldSize          = 200
+	// DiagonalMoveCooldownFactor lengthens the cooldown of a diagonal step, which covers
+	// more ground than a straight one.
+	DiagonalMoveCooldownFactor = 1.4
 	// REMOVED: WoodPerWall is now defined in the new recipe data structure.
 )
+
+// WorldSize is how far the starting area extends from the origin along each axis; the
+// world itself has no edge. It comes from the world's WorldGenConfig; see
+// SetWorldGenConfig.
+var WorldSize = DefaultWorldGenConfig().WorldSize
@@ -0,0 +1,360 @@
+package game
+
+import (
+	"encoding/json"
+	"log"
+	"mmo-game/models"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// walkTickInterval is how often walking players are checked for their next step.
+	// Steps themselves are paced by each player's move cooldown.
+	walkTickInterval = 50 * time.Millisecond
+
+	// walkMaxRepaths is how many times in a row a walk may re-path without taking a
+	// step before it gives up.
+	walkMaxRepaths = 3
+
+	// FollowRange is how far, in tiles along either axis, a followed entity may get
+	// before the follower loses track of it.
+	FollowRange = 24
+)
+
+// walk is a player's server-driven walk. Walks are kept on the node the player is
+// connected to, which is the only node that receives their commands.
+//
+// A walk with Follow set never arrives: it keeps re-pathing to stay next to the
+// followed entity, whose last known position is the target. While following, the
+// entity ID is also stored in the player's "following" field, so that if the player
+// becomes an Echo the AI can carry on following.
+type walk struct {
+	TargetX, TargetY int
+	Follow           string
+	// Interact, if set, is performed on arrival; the walk then ends next to the target.
+	Interact *models.InteractPayload
+	// Adjacent ends the walk next to the target, which is not itself walkable.
+	Adjacent bool
+
+	path    []pathPoint // Remaining steps, not including the player's tile
+	repaths int
+}
+
+var (
+	walksMu sync.Mutex
+	walks   = make(map[string]*walk)
+)
+
+// walkKeepingEvents are the commands that leave a player's walk going when they send
+// them; any other command stops it. Chatting does not get in the way, walk_to and
+// follow replace the walk themselves, and toggle_echo hands a follow to the Echo.
+var walkKeepingEvents = map[ClientEventType]bool{
+	ClientEventSendChat:   true,
+	ClientEventWalkTo:     true,
+	ClientEventFollow:     true,
+	ClientEventToggleEcho: true,
+}
+
+// StartWalk plans a path for a player and starts walking them along it, replacing any
+// walk in progress. It returns false if no step can be taken towards the target.
+func StartWalk(playerID string, targetX, targetY int, interact *models.InteractPayload) bool {
+	w := &walk{TargetX: targetX, TargetY: targetY, Interact: interact}
+	if _, props, err := GetWorldTile(targetX, targetY); err != nil || props.IsCollidable {
+		w.Adjacent = true
+	}
+
+	playerX, playerY, err := getPlayerPosition(playerID)
+	if err != nil {
+		return false
+	}
+	if !w.plan(playerID, pathPoint{playerX, playerY}, nil) {
+		StopWalking(playerID)
+		return false
+	}
+
+	setWalk(playerID, w)
+	return true
+}
+
+// StartFollow starts keeping a player next to another entity, replacing any walk in
+// progress. It returns false if the entity is not within FollowRange.
+func StartFollow(playerID, targetID string) bool {
+	target, ok := EntityIndex.Get(targetID)
+	if !ok || targetID == playerID {
+		return false
+	}
+	playerX, playerY, err := getPlayerPosition(playerID)
+	if err != nil || !withinFollowRange(playerX, playerY, target) {
+		return false
+	}
+
+	w := &walk{TargetX: target.X, TargetY: target.Y, Follow: targetID, Adjacent: true}
+	if !w.arrived(pathPoint{playerX, playerY}) && !w.plan(playerID, pathPoint{playerX, playerY}, nil) {
+		return false
+	}
+
+	setWalk(playerID, w)
+	return true
+}
+
+// setWalk replaces a player's walk, keeping their "following" field in step.
+func setWalk(playerID string, w *walk) {
+	walksMu.Lock()
+	previous := walks[playerID]
+	walks[playerID] = w
+	walksMu.Unlock()
+	if w.Follow != "" {
+		rdb.HSet(ctx, playerID, "following", w.Follow)
+	} else if previous != nil && previous.Follow != "" {
+		rdb.HDel(ctx, playerID, "following")
+	}
+}
+
+// StopWalking cancels a player's walk, if they have one, and tells them it ended.
+func StopWalking(playerID string) {
+	walksMu.Lock()
+	w, ok := walks[playerID]
+	walksMu.Unlock()
+	if ok {
+		endWalk(playerID, w, false)
+	}
+}
+
+// handWalkToEcho drops a player's walk when their Echo takes over. A follow carries
+// on under the AI through the player's "following" field; any other walk just stops.
+func handWalkToEcho(playerID string) {
+	walksMu.Lock()
+	w, ok := walks[playerID]
+	if ok && w.Follow != "" {
+		delete(walks, playerID)
+	}
+	walksMu.Unlock()
+	if ok && w.Follow == "" {
+		endWalk(playerID, w, false)
+	}
+}
+
+func withinFollowRange(x, y int, target SpatialEntry) bool {
+	return abs(target.X-x) <= FollowRange && abs(target.Y-y) <= FollowRange
+}
+
+// StartWalkSystem advances every walk on this node on a fixed tick.
+func StartWalkSystem() {
+	log.Println("Starting walk system...")
+	ticker := time.NewTicker(walkTickInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		walksMu.Lock()
+		playerIDs := make([]string, 0, len(walks))
+		for playerID := range walks {
+			playerIDs = append(playerIDs, playerID)
+		}
+		walksMu.Unlock()
+		sort.Strings(playerIDs)
+
+		for _, playerID := range playerIDs {
+			walksMu.Lock()
+			w := walks[playerID]
+			walksMu.Unlock()
+			if w != nil {
+				advanceWalk(playerID, w)
+			}
+		}
+	}
+}
+
+// advanceWalk takes the next step of a walk once the player's cooldown allows it. A
+// step that turns out to be blocked makes the walk re-path around the blocking tile.
+func advanceWalk(playerID string, w *walk) {
+	canAct, playerData := CanEntityAct(playerID)
+	if !canAct {
+		if health, err := strconv.Atoi(playerData["health"]); len(playerData) == 0 || (err == nil && health <= 0) {
+			endWalk(playerID, w, false)
+		}
+		return
+	}
+	if playerBool(playerData, "isEcho") {
+		handWalkToEcho(playerID)
+		return
+	}
+	current := pathPoint{}
+	current.X, current.Y = GetEntityPosition(playerData)
+
+	if w.Follow != "" {
+		target, ok := EntityIndex.Get(w.Follow)
+		if ok && !withinFollowRange(current.X, current.Y, target) {
+			// The target may have stepped through a portal; follow them in.
+			portal, through := portalFollowedThrough(w.Follow, target, current.X, current.Y)
+			if through && IsAdjacentOrDiagonal(current.X, current.Y, portal.X, portal.Y) {
+				w.path = nil
+				ProcessMove(playerID, directionTowards(current.X, current.Y, portal.X, portal.Y))
+				return
+			}
+			target.X, target.Y, ok = portal.X, portal.Y, through
+		}
+		if !ok {
+			endWalk(playerID, w, false)
+			return
+		}
+		moved := target.X != w.TargetX || target.Y != w.TargetY
+		w.TargetX, w.TargetY = target.X, target.Y
+		if w.arrived(current) {
+			w.path = nil // Caught up; wait for the target to move on
+			w.repaths = 0
+			return
+		}
+		if moved || len(w.path) == 0 {
+			if !w.replan(playerID, current, nil) {
+				endWalk(playerID, w, false)
+				return
+			}
+		}
+	}
+
+	if len(w.path) == 0 {
+		if w.arrived(current) {
+			endWalk(playerID, w, true)
+			if w.Interact != nil {
+				interactOnArrival(playerID, w.Interact)
+			}
+			return
+		}
+		// A partial path ran out short of the target; look again from here.
+		if !w.replan(playerID, current, nil) {
+			endWalk(playerID, w, false)
+		}
+		return
+	}
+
+	next := w.path[0]
+	if !IsAdjacentOrDiagonal(current.X, current.Y, next.X, next.Y) {
+		// The player was moved some other way, such as by a teleport.
+		if !w.replan(playerID, current, nil) {
+			endWalk(playerID, w, false)
+		}
+		return
+	}
+
+	ProcessMove(playerID, directionTowards(current.X, current.Y, next.X, next.Y))
+	if entry, ok := EntityIndex.Get(playerID); ok && entry.X == next.X && entry.Y == next.Y {
+		w.path = w.path[1:]
+		w.repaths = 0
+		return
+	} else if ok && (entry.X != current.X || entry.Y != current.Y) {
+		w.path = nil // Carried off by the step, such as through a portal
+		return
+	}
+	if !w.replan(playerID, current, &next) {
+		endWalk(playerID, w, false)
+	}
+}
+
+// plan finds a path from start, avoiding blocked if it is set. It sends the player the
+// new route and reports whether it leads anywhere.
+func (w *walk) plan(playerID string, start pathPoint, blocked *pathPoint) bool {
+	tickCache := &TickCache{
+		CollisionGrid: BuildCollisionGrid(),
+		LockedTiles:   make(map[string]bool),
+	}
+	if blocked != nil {
+		tickCache.LockedTiles[blocked.key()] = true
+	}
+
+	var path []*Node
+	if w.Interact != nil || w.Adjacent {
+		path = FindPathToAdjacent(start.X, start.Y, w.TargetX, w.TargetY, tickCache)
+	} else {
+		path = FindPath(start.X, start.Y, w.TargetX, w.TargetY, tickCache)
+	}
+	if path == nil {
+		return false
+	}
+
+	w.path = w.path[:0]
+	for _, node := range path[1:] {
+		w.path = append(w.path, pathPoint{node.X, node.Y})
+	}
+	if len(w.path) == 0 && !w.arrived(start) {
+		return false
+	}
+
+	// A follow re-paths whenever its target moves, so its routes are not worth sending.
+	if w.Follow == "" {
+		walkPathMsg := models.WalkPathMessage{
+			Type:       string(ServerEventWalkPath),
+			Directions: convertPathToDirections(path),
+		}
+		walkPathJSON, _ := json.Marshal(walkPathMsg)
+		sendDirectMessage(playerID, walkPathJSON)
+	}
+	return true
+}
+
+// replan is plan for a walk already under way, giving up after walkMaxRepaths tries
+// without progress.
+func (w *walk) replan(playerID string, start pathPoint, blocked *pathPoint) bool {
+	w.repaths++
+	if w.repaths > walkMaxRepaths {
+		return false
+	}
+	return w.plan(playerID, start, blocked)
+}
+
+// arrived reports whether a walk standing on p has reached its destination.
+func (w *walk) arrived(p pathPoint) bool {
+	if w.Follow != "" {
+		return IsAdjacentOrDiagonal(p.X, p.Y, w.TargetX, w.TargetY)
+	}
+	if w.Interact != nil || w.Adjacent {
+		return IsWithinPickupRange(p.X, p.Y, w.TargetX, w.TargetY)
+	}
+	return p.X == w.TargetX && p.Y == w.TargetY
+}
+
+// endWalk removes a walk, unless it was already replaced by a new one.
+func endWalk(playerID string, w *walk, arrived bool) {
+	walksMu.Lock()
+	current := walks[playerID]
+	if current == w {
+		delete(walks, playerID)
+	}
+	walksMu.Unlock()
+	if current == w {
+		if w.Follow != "" {
+			rdb.HDel(ctx, playerID, "following")
+		}
+		sendWalkEnded(playerID, arrived)
+	}
+}
+
+func sendWalkEnded(playerID string, arrived bool) {
+	walkEndedMsg := models.WalkEndedMessage{
+		Type:    string(ServerEventWalkEnded),
+		Arrived: arrived,
+	}
+	walkEndedJSON, _ := json.Marshal(walkEndedMsg)
+	sendDirectMessage(playerID, walkEndedJSON)
+}
+
+// interactOnArrival performs a walk's interaction and sends the player its results.
+func interactOnArrival(playerID string, interact *models.InteractPayload) {
+	handler, ok := ActionRegistry[ClientEventInteract]
+	if !ok {
+		return
+	}
+	payload, err := json.Marshal(interact)
+	if err != nil {
+		log.Printf("Error marshalling walk interaction for %s: %v", playerID, err)
+		return
+	}
+	result := handler.Process(playerID, payload)
+	if result == nil || !result.Success {
+		return
+	}
+	for _, wsMsg := range result.ToPlayer {
+		sendDirectMessage(playerID, wsMsg.Payload)
+	}
+}
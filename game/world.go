@@ -3,12 +3,12 @@ package game
 import (
 	"encoding/json"
 	"log"
-	"math/rand"
 	"mmo-game/models"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 
-	"github.com/aquilax/go-perlin"
 	"github.com/go-redis/redis/v8"
 )
 
@@ -16,168 +16,193 @@ const (
 	perlinAlpha = 2.
 	perlinBeta  = 2.
 	perlinN     = 3
-	perlinSeed  = 100
+	heightN     = 2       // Fewer octaves give the height map fewer hollows for rivers to pool in
 	noiseOffset = 10000.5 // A large offset to sample noise away from the origin, avoiding artifacts.
-
-	// Sanctuary Generation Constants
-	SanctuaryNoiseFrequency = 50.0
-	SanctuaryNoiseThreshold = 0.60
-	MinSanctuaryDistance    = 100
-	SanctuaryMinRadius      = 8
-	SanctuaryRadiusVariance = 5 // Max radius will be MinRadius + Variance - 1
 )
 
-var p *perlin.Perlin
-var sanctuaryPerlin *perlin.Perlin
-
-func init() {
-	p = perlin.NewPerlin(perlinAlpha, perlinBeta, perlinN, perlinSeed)
-	// Use a different seed for sanctuaries to ensure the noise maps are different
-	sanctuaryPerlin = perlin.NewPerlin(perlinAlpha, perlinBeta, perlinN, perlinSeed+1)
-}
-
 // GetNaturalTileType determines the natural tile type for a given coordinate using Perlin noise.
 func GetNaturalTileType(x, y int) TileType {
-	noiseVal := p.Noise2D((float64(x)+noiseOffset)/10.0, (float64(y)+noiseOffset)/10.0)
-	oreNoiseVal := p.Noise2D((float64(x)+noiseOffset)/8.0, (float64(y)+noiseOffset)/8.0)
-
-	if noiseVal < -0.5 {
-		return TileTypeWater
-	} else if oreNoiseVal > 0.7 {
-		return TileTypeIronRock
-	} else if noiseVal > 0.58 {
-		return TileTypeRock
-	} else if noiseVal > 0.55 {
-		return TileTypeTree
-	}
-
-	if rand.Float64() > 0.98 {
-		return TileTypeTree
-	}
-
-	return TileTypeGround
+	return worldGen.naturalTile(x, y)
 }
 
+// GenerateWorld creates the world from the current WorldGenConfig, unless one already
+// exists, in which case the config saved with it is loaded instead. Only the starting
+// area is stored now; the rest of the world is generated as it is explored.
 func GenerateWorld() {
 	log.Println("Generating world terrain and health...")
-	worldKey := string(RedisKeyWorldZone0)
 
-	if rdb.Exists(ctx, worldKey).Val() > 0 {
+	if worldExists() {
 		log.Println("World already exists. Skipping generation.")
+		if err := MigrateLegacyWorldHash(); err != nil {
+			log.Fatalf("Failed to migrate legacy world tiles: %v", err)
+		}
+		if err := IndexStoredWorldChunks(); err != nil {
+			log.Fatalf("Failed to list stored world chunks: %v", err)
+		}
+		loadWorldGenConfig()
+		loadSanctuaries()
+		placeZonePortals()
 		return
 	}
 
-	// --- NEW: Procedurally determine sanctuary locations ---
-	// First, a pass to find sanctuary hotspots without creating them yet.
-	// This ensures that all sanctuaries are known before we start creating tiles.
-	potentialSanctuaries := []Sanctuary{}
-	for x := -WorldSize; x <= WorldSize; x++ {
-		for y := -WorldSize; y <= WorldSize; y++ {
-			// Check for sanctuary hotspots
-			sanctuaryNoiseVal := sanctuaryPerlin.Noise2D((float64(x)+noiseOffset)/SanctuaryNoiseFrequency, (float64(y)+noiseOffset)/SanctuaryNoiseFrequency)
-			if sanctuaryNoiseVal > SanctuaryNoiseThreshold { // High threshold for rarity
-				// Check if this spot is too close to an existing sanctuary
-				isTooClose := false
-				for _, s := range potentialSanctuaries {
-					distSq := (x-s.X)*(x-s.X) + (y-s.Y)*(y-s.Y)
-					if distSq < MinSanctuaryDistance*MinSanctuaryDistance { // Minimum distance between sanctuaries (e.g., 100 tiles)
-						isTooClose = true
-						break
-					}
-				}
-
-				if !isTooClose && GetNaturalTileType(x, y) != TileTypeWater {
-					radius := SanctuaryMinRadius + rand.Intn(SanctuaryRadiusVariance) // Random radius between 8 and 12
-					potentialSanctuaries = append(potentialSanctuaries, Sanctuary{X: x, Y: y, Radius: radius})
-					log.Printf("Found potential sanctuary at (%d, %d)", x, y)
-				}
-			}
-		}
+	resetWorldChunkCache()
+	setSanctuaries([]Sanctuary{startingSanctuary})
+	saveSanctuaries()
+	saveWorldGenConfig()
+	if err := rdb.Set(ctx, string(RedisKeyWorldFormat), WorldChunkFormatVersion, 0).Err(); err != nil {
+		log.Fatalf("Failed to generate world: %v", err)
 	}
 
-	// Ensure the starting sanctuary at (0,1) is always present
-	hasStartingSanctuary := false
-	for _, s := range potentialSanctuaries {
-		if s.X == 0 && s.Y == 1 {
-			hasStartingSanctuary = true
-			break
+	minC, maxC := worldChunkRange()
+	for cx := minC; cx <= maxC; cx++ {
+		for cy := minC; cy <= maxC; cy++ {
+			if err := storeWorldChunk(worldChunkCoord{CX: cx, CY: cy}); err != nil {
+				log.Fatalf("Failed to generate world: %v", err)
+			}
 		}
 	}
-	if !hasStartingSanctuary {
-		Sanctuaries = append(Sanctuaries, Sanctuary{X: 0, Y: 1, Radius: 8})
-	}
 
-	// Add the potential sanctuaries to the global list
-	Sanctuaries = append(Sanctuaries, potentialSanctuaries...)
-	log.Printf("Total sanctuaries to be generated: %d", len(Sanctuaries))
-	// --- END NEW ---
+	log.Printf("World generation complete (seed %d, %d sanctuaries in the starting area).", worldGen.cfg.Seed, len(knownSanctuaries()))
+}
 
-	pipe := rdb.Pipeline()
+// sanctuariesMu guards Sanctuaries, which grows as chunks are stored.
+var sanctuariesMu sync.RWMutex
 
-	isSanctuaryTile := func(x, y int) (bool, bool) {
-		for _, s := range Sanctuaries {
-			if x == s.X && y == s.Y {
-				return true, true // This is the center stone
-			}
+// knownSanctuaries returns the sanctuaries of the stored world, the starting sanctuary
+// first.
+func knownSanctuaries() []Sanctuary {
+	sanctuariesMu.RLock()
+	defer sanctuariesMu.RUnlock()
+	return Sanctuaries
+}
 
-			// Use perlin noise to make the radius variable and the shape irregular
-			noise := p.Noise2D(float64(x)/15.0, float64(y)/15.0)
-			variableRadius := float64(s.Radius) * (0.7 + (noise+1)/2*0.6) // Vary radius between 70% and 130%
+func setSanctuaries(sanctuaries []Sanctuary) {
+	sanctuariesMu.Lock()
+	Sanctuaries = sanctuaries
+	sanctuariesMu.Unlock()
+}
 
-			distSq := float64((x-s.X)*(x-s.X) + (y-s.Y)*(y-s.Y))
-			if distSq < variableRadius*variableRadius {
-				return true, false // It's a sanctuary tile, but not the center stone
-			}
-		}
-		return false, false
+// saveSanctuaries persists the sanctuary list alongside the world. It lists what the
+// world's stored chunks contain, and sanctuaries stored before they were placed cell
+// by cell cannot be recomputed at all.
+func saveSanctuaries() {
+	sanctuariesJSON, _ := json.Marshal(knownSanctuaries())
+	if err := rdb.Set(ctx, string(RedisKeySanctuaries), sanctuariesJSON, 0).Err(); err != nil {
+		log.Printf("Failed to save sanctuaries: %v", err)
 	}
+}
 
-	for x := -WorldSize; x <= WorldSize; x++ {
-		for y := -WorldSize; y <= WorldSize; y++ {
-			coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
-
-			isSanctuary, isStone := isSanctuaryTile(x, y)
-			var tile models.WorldTile
+// readSanctuaries returns the saved sanctuary list, or nil if there is none.
+func readSanctuaries(c redis.Cmdable) ([]Sanctuary, error) {
+	sanctuariesJSON, err := c.Get(ctx, string(RedisKeySanctuaries)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var sanctuaries []Sanctuary
+	if err := json.Unmarshal([]byte(sanctuariesJSON), &sanctuaries); err != nil {
+		return nil, err
+	}
+	return sanctuaries, nil
+}
 
-			if isStone {
-				tile.Type = string(TileTypeSanctuaryStone)
-				tile.IsSanctuary = true // Point 3: Tile under stone is a sanctuary
-			} else if isSanctuary {
-				// Point 1 & 2: Sanctuary tiles are continuous ground, no resources
-				tile.Type = string(TileTypeGround)
-				tile.IsSanctuary = true
-			} else {
-				tile.Type = string(GetNaturalTileType(x, y))
-			}
+// loadSanctuaries restores the sanctuary list for a world generated by an earlier run.
+// The generator keeps building them, and keeps new sanctuaries away from them.
+func loadSanctuaries() {
+	sanctuaries, err := readSanctuaries(rdb)
+	if err != nil || len(sanctuaries) == 0 {
+		log.Printf("Ignoring invalid saved sanctuaries: %v", err)
+		return
+	}
+	setSanctuaries(sanctuaries)
+	worldGen.setFixedSanctuaries(sanctuaries)
+}
 
-			props := TileDefs[TileType(tile.Type)]
-			tile.Health = props.MaxHealth
+// refreshSanctuaries picks up sanctuaries that other nodes found in chunks they stored.
+func refreshSanctuaries() {
+	sanctuaries, err := readSanctuaries(rdb)
+	if err != nil {
+		log.Printf("Failed to load sanctuaries: %v", err)
+		return
+	}
+	if len(sanctuaries) > 0 {
+		setSanctuaries(sanctuaries)
+	}
+}
 
-			tileJSON, _ := json.Marshal(tile)
-			pipe.HSet(ctx, worldKey, coordKey, string(tileJSON))
+// addSanctuaries appends sanctuaries found in a newly stored chunk to the saved list.
+// Several nodes may store chunks at once, so the list is updated in a transaction.
+func addSanctuaries(added []Sanctuary) {
+	key := string(RedisKeySanctuaries)
+	var merged []Sanctuary
+	err := rdb.Watch(ctx, func(tx *redis.Tx) error {
+		saved, err := readSanctuaries(tx)
+		if err != nil {
+			return err
+		}
+		if len(saved) == 0 {
+			saved = []Sanctuary{startingSanctuary}
 		}
+		merged = saved
+		for _, s := range added {
+			if !slices.Contains(merged, s) {
+				merged = append(merged, s)
+			}
+		}
+		sanctuariesJSON, _ := json.Marshal(merged)
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, sanctuariesJSON, 0)
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		log.Printf("Failed to save sanctuaries: %v", err)
+		return
 	}
+	setSanctuaries(merged)
+}
 
-	_, err := pipe.Exec(ctx)
+// loadWorldTiles reads every tile of the stored world, keyed by "x,y". Chunks come from
+// the chunk cache, so after the first call this only reads chunks stored since.
+func loadWorldTiles() (map[string]models.WorldTile, error) {
+	chunks, err := loadAllWorldChunks()
 	if err != nil {
-		log.Fatalf("Failed to generate world: %v", err)
+		return nil, err
 	}
 
-	log.Println("World generation complete.")
+	tiles := make(map[string]models.WorldTile, len(chunks)*WorldChunkSize*WorldChunkSize)
+	for c, chunk := range chunks {
+		forEachChunkTile(c, chunk, func(x, y int, tile models.WorldTile) {
+			tiles[strconv.Itoa(x)+","+strconv.Itoa(y)] = tile
+		})
+	}
+	return tiles, nil
+}
+
+// worldIndexed reports whether the stored chunks have been indexed as they were stored,
+// which worlds stored before that was done, and restored snapshots, have not.
+func worldIndexed() bool {
+	return rdb.Exists(ctx, string(RedisKeyWorldStats)).Val() > 0
 }
 
+// IndexWorldResources records the position of every resource in the stored world. New
+// chunks record theirs as they are stored, so this only runs for worlds that have not
+// been indexed that way.
 func IndexWorldResources() {
+	if worldIndexed() {
+		return
+	}
 	log.Println("Indexing world resources...")
-	worldData, err := rdb.HGetAll(ctx, string(RedisKeyWorldZone0)).Result()
+	worldTiles, err := loadWorldTiles()
 	if err != nil {
 		log.Fatalf("Failed to get world data for indexing: %v", err)
 	}
 
 	pipe := rdb.Pipeline()
 	count := 0
-	for coord, tileJSON := range worldData {
-		var tile models.WorldTile
-		json.Unmarshal([]byte(tileJSON), &tile)
+	for coord, tile := range worldTiles {
 		props := TileDefs[TileType(tile.Type)]
 
 		if props.IsGatherable {
@@ -185,14 +210,7 @@ func IndexWorldResources() {
 			x, _ := strconv.Atoi(coords[0])
 			y, _ := strconv.Atoi(coords[1])
 
-			// Member format: "tileType:x,y" e.g., "tree:10,20"
-			member := tile.Type + ":" + coord
-			lon, lat := NormalizeCoords(x, y)
-			pipe.GeoAdd(ctx, string(RedisKeyResourcePositions), &redis.GeoLocation{
-				Name:      member,
-				Longitude: lon,
-				Latitude:  lat,
-			})
+			setResourcePosition(pipe, TileType(tile.Type), x, y)
 			count++
 		}
 	}
@@ -204,50 +222,149 @@ func IndexWorldResources() {
 	log.Printf("Indexed %d resource locations.", count)
 }
 
+// IndexPotentialSpawnPoints loads the resource targets and biome areas of the stored
+// world. A world whose chunks were not indexed as they were stored has every stored
+// chunk's potential spawn points indexed first.
 func IndexPotentialSpawnPoints() {
-	log.Println("Indexing potential resource spawn points...")
+	if !worldIndexed() {
+		log.Println("Indexing potential resource spawn points...")
+		coords, err := storedWorldChunks()
+		if err != nil {
+			log.Printf("Error indexing potential spawn points: %v", err)
+		}
+		for _, c := range coords {
+			pipe := rdb.Pipeline()
+			indexChunkSpawnPoints(pipe, c, false)
+			if _, err := pipe.Exec(ctx); err != nil {
+				log.Printf("Error indexing potential spawn points: %v", err)
+			}
+		}
+	}
+	loadWorldStats()
+
+	log.Printf("Calculated resource targets: %v", ResourceTargets)
+}
+
+// indexWorldChunk records what a newly stored chunk adds to the world: its resources,
+// the places they can respawn, its share of each biome and any sanctuary centered in
+// it. It runs once per chunk, on the node that stored it.
+func indexWorldChunk(c worldChunkCoord) {
 	pipe := rdb.Pipeline()
-	potentialCounts := make(map[TileType]int)
-	ResourceTargets = make(map[TileType]int)
-
-	for x := -WorldSize; x <= WorldSize; x++ {
-		for y := -WorldSize; y <= WorldSize; y++ {
-			naturalType := GetNaturalTileType(x, y)
-			props, ok := TileDefs[naturalType]
-			if ok && props.IsGatherable {
-				coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
-				redisKey := "potential_spawns:" + string(naturalType)
-				pipe.SAdd(ctx, redisKey, coordKey)
-				potentialCounts[naturalType]++
+	indexChunkSpawnPoints(pipe, c, true)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Error indexing chunk %d,%d: %v", c.CX, c.CY, err)
+	}
+
+	minX, minY := c.CX*WorldChunkSize, c.CY*WorldChunkSize
+	var added []Sanctuary
+	known := knownSanctuaries()
+	for _, s := range worldGen.sanctuariesIn(minX, minY, minX+WorldChunkSize-1, minY+WorldChunkSize-1) {
+		if !slices.Contains(known, s) {
+			added = append(added, s)
+		}
+	}
+	if len(added) > 0 {
+		addSanctuaries(added)
+	}
+}
+
+// indexChunkSpawnPoints records where each resource can respawn in a chunk: every tile
+// the world generator makes a resource, which is why it must run with the world's
+// config. It adds the chunk's counts to RedisKeyWorldStats and, with resources set,
+// records the resources themselves.
+func indexChunkSpawnPoints(pipe redis.Pipeliner, c worldChunkCoord, resources bool) {
+	sanctuaries := worldGen.sanctuariesNear(c)
+	areas := make(map[BiomeType]int)
+	potentialCounts := make(map[string]int)
+	for ly := 0; ly < WorldChunkSize; ly++ {
+		for lx := 0; lx < WorldChunkSize; lx++ {
+			x, y := c.CX*WorldChunkSize+lx, c.CY*WorldChunkSize+ly
+			biome := worldGen.biome(x, y)
+			areas[biome]++
+			tileType := TileType(worldGen.tile(x, y, sanctuaries).Type)
+			if !TileDefs[tileType].IsGatherable {
+				continue
+			}
+			pipe.SAdd(ctx, potentialSpawnsKey(biome, tileType), strconv.Itoa(x)+","+strconv.Itoa(y))
+			potentialCounts["potential:"+string(biome)+":"+string(tileType)]++
+			if resources {
+				setResourcePosition(pipe, tileType, x, y)
 			}
 		}
 	}
-	_, err := pipe.Exec(ctx)
-	if err != nil {
-		log.Printf("Error indexing potential spawn points: %v", err)
+	for biome, area := range areas {
+		pipe.HIncrBy(ctx, string(RedisKeyWorldStats), "area:"+string(biome), int64(area))
+	}
+	for field, count := range potentialCounts {
+		pipe.HIncrBy(ctx, string(RedisKeyWorldStats), field, int64(count))
 	}
+}
 
-	for tileType, count := range potentialCounts {
-		fillPercentage := resourceFillPercentage[tileType]
-		ResourceTargets[tileType] = int(float64(count) * fillPercentage)
+// loadWorldStats sets ResourceTargets and the biome areas from RedisKeyWorldStats, which
+// grows as chunks are stored.
+func loadWorldStats() {
+	stats, err := rdb.HGetAll(ctx, string(RedisKeyWorldStats)).Result()
+	if err != nil {
+		log.Printf("Failed to load world stats: %v", err)
+		return
+	}
+	potentialCounts := make(map[BiomeType]map[TileType]int)
+	areas := make(map[BiomeType]int)
+	for field, value := range stats {
+		count, _ := strconv.Atoi(value)
+		parts := strings.Split(field, ":")
+		switch {
+		case len(parts) == 2 && parts[0] == "area":
+			areas[BiomeType(parts[1])] = count
+		case len(parts) == 3 && parts[0] == "potential":
+			biome := BiomeType(parts[1])
+			if potentialCounts[biome] == nil {
+				potentialCounts[biome] = make(map[TileType]int)
+			}
+			potentialCounts[biome][TileType(parts[2])] = count
+		}
 	}
 
-	log.Printf("Indexed potential spawn points: %v", potentialCounts)
-	log.Printf("Calculated resource targets: %v", ResourceTargets)
+	ResourceTargets = resourceTargetsFor(potentialCounts)
+	biomeAreasMu.Lock()
+	biomeAreas = areas
+	biomeAreasMu.Unlock()
 }
 
-// GetWorldTile retrieves a single tile and its properties from the world data.
-func GetWorldTile(x, y int) (*models.WorldTile, *TileProperties, error) {
-	coordKey := strconv.Itoa(x) + "," + strconv.Itoa(y)
+// potentialSpawnsKey is the Redis set of "x,y" tiles where a resource may spawn in a biome.
+func potentialSpawnsKey(biome BiomeType, tileType TileType) string {
+	return "potential_spawns:" + string(biome) + ":" + string(tileType)
+}
+
+// resourceTargetsFor returns how many of each resource to keep in each biome, given how
+// many places it can spawn there.
+func resourceTargetsFor(potentialCounts map[BiomeType]map[TileType]int) map[BiomeType]map[TileType]int {
+	targets := make(map[BiomeType]map[TileType]int)
+	for biome, counts := range potentialCounts {
+		targets[biome] = make(map[TileType]int)
+		for tileType, count := range counts {
+			fillPercentage := BiomeDefs[biome].ResourceFill[tileType]
+			targets[biome][tileType] = int(float64(count) * fillPercentage)
+		}
+	}
+	return targets
+}
 
-	tileJSON, err := rdb.HGet(ctx, string(RedisKeyWorldZone0), coordKey).Result()
+// GetWorldTile retrieves a single tile and its properties from the world data,
+// generating its chunk if no one has been there yet. It returns redis.Nil if there is no
+// tile at the coordinate.
+func GetWorldTile(x, y int) (*models.WorldTile, *TileProperties, error) {
+	chunkCoord, offset := worldChunkFor(x, y)
+	chunk, err := loadWorldChunk(chunkCoord)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	var tile models.WorldTile
-	if err := json.Unmarshal([]byte(tileJSON), &tile); err != nil {
-		return nil, nil, err
+	worldChunkCacheMu.RLock()
+	tile, ok := decodeWorldTile(chunk, offset)
+	worldChunkCacheMu.RUnlock()
+	if !ok {
+		return nil, nil, redis.Nil
 	}
 
 	props := TileDefs[TileType(tile.Type)]
@@ -0,0 +1,676 @@
+package game
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"mmo-game/game/utils"
+	"mmo-game/models"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// World tiles are stored in fixed-size square chunks. Each chunk is a Redis string of
+// WorldChunkSize*WorldChunkSize records of worldTileRecordSize bytes, laid out row by row:
+//
+//	byte 0    tile type code (see worldTileTypeCodes; 0 means no tile)
+//	byte 1-2  health, big-endian uint16
+//	byte 3    flags (worldTileFlagSanctuary), and the tile's height in the bits above them
+//
+// A single tile is updated in place with SETRANGE, so concurrent writers never overwrite
+// each other's tiles the way rewriting a whole chunk would.
+const (
+	WorldChunkSize          = 32
+	worldTileRecordSize     = 4
+	worldChunkBytes         = WorldChunkSize * WorldChunkSize * worldTileRecordSize
+	worldTileFlagSanctuary  = 1 << 0
+	worldTileHeightShift    = 1
+	worldTileMaxHeight      = 0xFF >> worldTileHeightShift
+	WorldChunkFormatVersion = "chunks:v1"
+)
+
+// Cached chunks are evicted once no player is near them and they have gone unused for a
+// while; see evictIdleWorldChunks.
+const (
+	worldChunkEvictInterval = time.Minute
+	worldChunkIdleTime      = 5 * time.Minute
+
+	// worldChunkKeepRadius is how many chunks around a player stay cached, used or not.
+	worldChunkKeepRadius = 2
+)
+
+// worldTileTypeCodes maps stored type codes to tile types. Codes are persisted, so new
+// tile types must only ever be appended.
+var worldTileTypeCodes = []TileType{
+	"", // 0: no tile
+	TileTypeGround,
+	TileTypeWater,
+	TileTypeTree,
+	TileTypeRock,
+	TileTypeIronRock,
+	TileTypeWoodenWall,
+	TileTypeFire,
+	TileTypeSanctuaryStone,
+	TileTypeGrass,
+	TileTypeMud,
+	TileTypeGravel,
+	TileTypeSnow,
+	TileTypeIce,
+	TileTypePineTree,
+	TileTypeRoad,
+	TileTypeBridge,
+	TileTypeDungeonEntrance,
+	TileTypeDungeonFloor,
+	TileTypeDungeonWall,
+	TileTypeDungeonExit,
+	TileTypeCliff,
+	TileTypePortal,
+	TileTypeCaveFloor,
+	TileTypeCaveWall,
+	TileTypeEtherealGround,
+	TileTypeRift,
+	TileTypeSpiritTree,
+	TileTypeRuins,
+	TileTypeShrine,
+	TileTypeAbandonedCamp,
+	TileTypeBuriedCache,
+}
+
+var worldTileTypeIndex = func() map[TileType]byte {
+	index := make(map[TileType]byte, len(worldTileTypeCodes))
+	for code, tileType := range worldTileTypeCodes {
+		index[tileType] = byte(code)
+	}
+	return index
+}()
+
+// worldChunkCoord identifies a chunk by its chunk-space coordinates.
+type worldChunkCoord struct {
+	CX, CY int
+}
+
+// worldChunkCache holds decoded-on-demand chunk bytes for this process. Every tile write
+// goes through SetWorldTile, which updates the cache and Redis together.
+//
+// Only chunks that have been visited or changed are stored in Redis (see
+// storeWorldChunk); the rest are generated from the seed as they are needed, and cached
+// like any other. worldChunkStored records which cached chunks are stored.
+// worldChunkGeneration changes whenever the cache is reset. worldChunkUsed holds when
+// each cached chunk was last read, in Unix milliseconds, for evictIdleWorldChunks.
+var (
+	worldChunkCache      = make(map[worldChunkCoord][]byte)
+	worldChunkStored     = make(map[worldChunkCoord]bool)
+	worldChunkUsed       = make(map[worldChunkCoord]*atomic.Int64)
+	worldChunkGeneration uint64
+	worldChunkCacheMu    sync.RWMutex
+)
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+// worldChunkFor returns the chunk containing a tile and the tile's byte offset in it.
+func worldChunkFor(x, y int) (worldChunkCoord, int) {
+	c := worldChunkCoord{CX: floorDiv(x, WorldChunkSize), CY: floorDiv(y, WorldChunkSize)}
+	lx := x - c.CX*WorldChunkSize
+	ly := y - c.CY*WorldChunkSize
+	return c, (ly*WorldChunkSize + lx) * worldTileRecordSize
+}
+
+// worldChunkKey returns the key a chunk is stored under, which depends on its zone.
+func worldChunkKey(c worldChunkCoord) string {
+	return zoneChunkPrefix(zoneOfChunk(c)) + strconv.Itoa(c.CX) + "," + strconv.Itoa(c.CY)
+}
+
+// fillWorldChunk generates the tiles of a chunk that have none stored: dungeon chunks
+// from their dungeon's layout, and the designed zones by the world generator.
+func fillWorldChunk(c worldChunkCoord, chunk []byte) {
+	if zone := zoneOfChunk(c); !isZoneLayer(zone) {
+		fillDungeonChunk(zone, c, chunk)
+		return
+	}
+	worldGen.fillChunk(c, chunk)
+}
+
+func encodeWorldTile(tile models.WorldTile) [worldTileRecordSize]byte {
+	var record [worldTileRecordSize]byte
+	code, ok := worldTileTypeIndex[TileType(tile.Type)]
+	if !ok {
+		log.Printf("Unknown tile type %q cannot be stored; storing ground instead.", tile.Type)
+		code = worldTileTypeIndex[TileTypeGround]
+	}
+	health := tile.Health
+	if health < 0 {
+		health = 0
+	} else if health > 0xFFFF {
+		health = 0xFFFF
+	}
+	record[0] = code
+	binary.BigEndian.PutUint16(record[1:3], uint16(health))
+	if tile.IsSanctuary {
+		record[3] |= worldTileFlagSanctuary
+	}
+	record[3] |= byte(max(0, min(tile.Height, worldTileMaxHeight))) << worldTileHeightShift
+	return record
+}
+
+// decodeWorldTile reads the tile at offset. ok is false if no tile is stored there.
+func decodeWorldTile(chunk []byte, offset int) (tile models.WorldTile, ok bool) {
+	if offset+worldTileRecordSize > len(chunk) {
+		return tile, false
+	}
+	code := int(chunk[offset])
+	if code == 0 || code >= len(worldTileTypeCodes) {
+		return tile, false
+	}
+	tile.Type = string(worldTileTypeCodes[code])
+	tile.Health = int(binary.BigEndian.Uint16(chunk[offset+1 : offset+3]))
+	tile.IsSanctuary = chunk[offset+3]&worldTileFlagSanctuary != 0
+	tile.Height = int(chunk[offset+3] >> worldTileHeightShift)
+	return tile, true
+}
+
+// loadWorldChunk returns a chunk from the cache, reading it from Redis on a miss.
+// Chunks that are not stored are generated instead. Tiles a stored chunk lacks, such
+// as those past the edge of a world stored when the world had one, are generated too,
+// though only in the cache.
+func loadWorldChunk(c worldChunkCoord) ([]byte, error) {
+	worldChunkCacheMu.RLock()
+	chunk, ok := worldChunkCache[c]
+	if ok {
+		worldChunkUsed[c].Store(time.Now().UnixMilli())
+	}
+	worldChunkCacheMu.RUnlock()
+	if ok {
+		return chunk, nil
+	}
+
+	data, err := rdb.Get(ctx, worldChunkKey(c)).Bytes()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	chunk = make([]byte, worldChunkBytes)
+	copy(chunk, data)
+	fillWorldChunk(c, chunk)
+	return cacheWorldChunk(c, chunk, err == nil), nil
+}
+
+// cachedWorldTile returns a tile from the cache, without loading its chunk. ok is false
+// if the chunk is not cached.
+func cachedWorldTile(x, y int) (tile models.WorldTile, ok bool) {
+	c, offset := worldChunkFor(x, y)
+	worldChunkCacheMu.RLock()
+	defer worldChunkCacheMu.RUnlock()
+	chunk, ok := worldChunkCache[c]
+	if !ok {
+		return tile, false
+	}
+	return decodeWorldTile(chunk, offset)
+}
+
+// cacheWorldChunk adds a chunk to the cache and its collidable tiles to the collision
+// grid, unless another goroutine cached it first. It returns the cached chunk.
+func cacheWorldChunk(c worldChunkCoord, chunk []byte, stored bool) []byte {
+	worldChunkCacheMu.Lock()
+	if cached, ok := worldChunkCache[c]; ok {
+		worldChunkCacheMu.Unlock()
+		return cached // Another goroutine loaded it first and may already have written to it.
+	}
+	worldChunkCache[c] = chunk
+	worldChunkStored[c] = stored
+	worldChunkUsed[c] = new(atomic.Int64)
+	worldChunkUsed[c].Store(time.Now().UnixMilli())
+	worldChunkCacheMu.Unlock()
+
+	var collidable []string
+	forEachChunkTile(c, chunk, func(x, y int, tile models.WorldTile) {
+		if TileDefs[TileType(tile.Type)].IsCollidable {
+			collidable = append(collidable, strconv.Itoa(x)+","+strconv.Itoa(y))
+		}
+	})
+	addTileCollisions(collidable)
+	return chunk
+}
+
+// forEachChunkTile calls fn for every tile in a chunk. The caller must not hold
+// worldChunkCacheMu.
+func forEachChunkTile(c worldChunkCoord, chunk []byte, fn func(x, y int, tile models.WorldTile)) {
+	worldChunkCacheMu.RLock()
+	defer worldChunkCacheMu.RUnlock()
+	for ly := 0; ly < WorldChunkSize; ly++ {
+		for lx := 0; lx < WorldChunkSize; lx++ {
+			tile, ok := decodeWorldTile(chunk, (ly*WorldChunkSize+lx)*worldTileRecordSize)
+			if ok {
+				fn(c.CX*WorldChunkSize+lx, c.CY*WorldChunkSize+ly, tile)
+			}
+		}
+	}
+}
+
+// storeWorldChunk makes sure a chunk is stored in Redis, as it must be before any of its
+// tiles change or a player sets foot in it. The node that stores an overworld chunk
+// first also indexes what it adds to the world; see indexWorldChunk.
+func storeWorldChunk(c worldChunkCoord) error {
+	chunk, err := loadWorldChunk(c)
+	if err != nil {
+		return err
+	}
+	worldChunkCacheMu.RLock()
+	stored := worldChunkStored[c]
+	data := string(chunk)
+	worldChunkCacheMu.RUnlock()
+	if stored {
+		return nil
+	}
+
+	zone := zoneOfChunk(c)
+	pipe := rdb.TxPipeline()
+	created := pipe.SetNX(ctx, worldChunkKey(c), data, 0)
+	pipe.SAdd(ctx, zoneChunksKey(zone), strconv.Itoa(c.CX)+","+strconv.Itoa(c.CY))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	if created.Val() && isZoneLayer(zone) {
+		indexWorldChunk(c)
+	} else if data, err := rdb.Get(ctx, worldChunkKey(c)).Bytes(); err == nil {
+		// Another node stored it first; its copy may already have changed, so the
+		// collisions and minimap of this one are derived again from it.
+		worldChunkCacheMu.Lock()
+		if cached, ok := worldChunkCache[c]; ok {
+			removeTileCollisions(appendChunkCollisions(nil, c, cached))
+			copy(cached, data)
+			addTileCollisions(appendChunkCollisions(nil, c, cached))
+		}
+		worldChunkCacheMu.Unlock()
+		invalidateMinimapChunk(c.CX*WorldChunkSize, c.CY*WorldChunkSize)
+	}
+
+	worldChunkCacheMu.Lock()
+	if _, ok := worldChunkCache[c]; ok {
+		worldChunkStored[c] = true // Unless it was evicted meanwhile
+	}
+	worldChunkCacheMu.Unlock()
+	return nil
+}
+
+// SetWorldTile writes a tile to the chunk cache and to Redis. Pass the pipeline that
+// performs the rest of the change so the tile is written alongside it, or rdb. The tile
+// keeps the height of the one it replaces; building or gathering never reshapes the land.
+//
+// Usage:
+//   pipe := rdb.Pipeline()
+//   SetWorldTile(pipe, x, y, models.WorldTile{Type: string(TileTypeWoodenWall), Health: wallProps.MaxHealth})
+//   pipe.SAdd(ctx, string(RedisKeyActiveDecay), coordKey)
+//   pipe.Exec(ctx)
+func SetWorldTile(c redis.Cmdable, x, y int, tile models.WorldTile) {
+	chunkCoord, offset := worldChunkFor(x, y)
+	tile.Height = worldGen.heightLevel(x, y)
+	var record [worldTileRecordSize]byte
+
+	if err := storeWorldChunk(chunkCoord); err != nil {
+		log.Printf("Failed to store chunk for tile %d,%d: %v", x, y, err)
+	}
+	if chunk, err := loadWorldChunk(chunkCoord); err == nil {
+		worldChunkCacheMu.Lock()
+		if previous, ok := decodeWorldTile(chunk, offset); ok {
+			tile.Height = previous.Height
+		}
+		record = encodeWorldTile(tile)
+		copy(chunk[offset:], record[:])
+		worldChunkCacheMu.Unlock()
+	} else {
+		log.Printf("Failed to load chunk for tile %d,%d: %v", x, y, err)
+		record = encodeWorldTile(tile)
+	}
+	setTileCollision(x, y, TileDefs[TileType(tile.Type)].IsCollidable)
+	invalidateMinimapChunk(x, y)
+
+	if err := c.SetRange(ctx, worldChunkKey(chunkCoord), int64(offset), string(record[:])).Err(); err != nil {
+		log.Printf("Failed to write tile %d,%d: %v", x, y, err)
+	}
+	publishWorldTileChange(c, worldTileChange{X: x, Y: y, Tile: tile})
+}
+
+// applyWorldTile applies a tile written by another node to the cache and collision grid.
+// Chunks that are not cached are left alone; they are read fresh when first needed.
+func applyWorldTile(x, y int, tile models.WorldTile) {
+	chunkCoord, offset := worldChunkFor(x, y)
+	record := encodeWorldTile(tile)
+
+	worldChunkCacheMu.Lock()
+	chunk, cached := worldChunkCache[chunkCoord]
+	if cached {
+		copy(chunk[offset:], record[:])
+	}
+	worldChunkCacheMu.Unlock()
+	if cached {
+		setTileCollision(x, y, TileDefs[TileType(tile.Type)].IsCollidable)
+	}
+	invalidateMinimapChunk(x, y)
+}
+
+// worldChunkRange returns the chunk coordinates covering the starting area.
+func worldChunkRange() (minC, maxC int) {
+	return floorDiv(-WorldSize, WorldChunkSize), floorDiv(WorldSize, WorldChunkSize)
+}
+
+// worldChunkLoadBatch is how many chunks loadAllWorldChunks reads with each MGET.
+const worldChunkLoadBatch = 256
+
+// storedWorldChunks returns the coordinates of every stored chunk of the designed zones.
+// Instances are left out; they are torn down with their chunks.
+func storedWorldChunks() ([]worldChunkCoord, error) {
+	var coords []worldChunkCoord
+	for _, zone := range zoneLayers {
+		members, err := rdb.SMembers(ctx, zoneChunksKey(zone)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			cx, cy := utils.ParseCoordKey(member)
+			coords = append(coords, worldChunkCoord{CX: cx, CY: cy})
+		}
+	}
+	return coords, nil
+}
+
+// loadAllWorldChunks reads every stored chunk into the cache and returns them.
+func loadAllWorldChunks() (map[worldChunkCoord][]byte, error) {
+	coords, err := storedWorldChunks()
+	if err != nil {
+		return nil, err
+	}
+
+	worldChunkCacheMu.RLock()
+	var missing []worldChunkCoord
+	for _, c := range coords {
+		if _, ok := worldChunkCache[c]; !ok {
+			missing = append(missing, c)
+		}
+	}
+	worldChunkCacheMu.RUnlock()
+
+	for start := 0; start < len(missing); start += worldChunkLoadBatch {
+		batch := missing[start:min(start+worldChunkLoadBatch, len(missing))]
+		keys := make([]string, len(batch))
+		for i, c := range batch {
+			keys[i] = worldChunkKey(c)
+		}
+		values, err := rdb.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range batch {
+			chunk := make([]byte, worldChunkBytes)
+			s, stored := values[i].(string)
+			copy(chunk, s)
+			fillWorldChunk(c, chunk)
+			cacheWorldChunk(c, chunk, stored)
+		}
+	}
+
+	chunks := make(map[worldChunkCoord][]byte, len(coords))
+	worldChunkCacheMu.RLock()
+	for _, c := range coords {
+		chunks[c] = worldChunkCache[c]
+	}
+	worldChunkCacheMu.RUnlock()
+	return chunks, nil
+}
+
+// loadWorldChunksIn makes sure every chunk overlapping an inclusive rectangle of tiles is
+// cached, and so on the collision grid. It reports whether any chunk had to be loaded.
+func loadWorldChunksIn(minX, minY, maxX, maxY int) bool {
+	loaded := false
+	for cx := floorDiv(minX, WorldChunkSize); cx <= floorDiv(maxX, WorldChunkSize); cx++ {
+		for cy := floorDiv(minY, WorldChunkSize); cy <= floorDiv(maxY, WorldChunkSize); cy++ {
+			c := worldChunkCoord{CX: cx, CY: cy}
+			worldChunkCacheMu.RLock()
+			_, ok := worldChunkCache[c]
+			if ok {
+				worldChunkUsed[c].Store(time.Now().UnixMilli())
+			}
+			worldChunkCacheMu.RUnlock()
+			if ok {
+				continue
+			}
+			if _, err := loadWorldChunk(c); err != nil {
+				log.Printf("Failed to load chunk %d,%d: %v", cx, cy, err)
+				continue
+			}
+			loaded = true
+		}
+	}
+	return loaded
+}
+
+// resetWorldChunkCache drops every cached chunk, e.g. after the world is replaced.
+func resetWorldChunkCache() {
+	worldChunkCacheMu.Lock()
+	worldChunkCache = make(map[worldChunkCoord][]byte)
+	worldChunkStored = make(map[worldChunkCoord]bool)
+	worldChunkUsed = make(map[worldChunkCoord]*atomic.Int64)
+	worldChunkGeneration++
+	worldChunkCacheMu.Unlock()
+	resetMinimapCache()
+}
+
+// evictZoneChunks drops the cached chunks of a zone that has been torn down, and takes
+// their tiles off the collision grid.
+func evictZoneChunks(zone ZoneID) {
+	evicted := make(map[worldChunkCoord][]byte)
+	worldChunkCacheMu.Lock()
+	for c, chunk := range worldChunkCache {
+		if zoneOfChunk(c) == zone {
+			evicted[c] = chunk
+			delete(worldChunkCache, c)
+			delete(worldChunkStored, c)
+			delete(worldChunkUsed, c)
+		}
+	}
+	worldChunkGeneration++
+	worldChunkCacheMu.Unlock()
+
+	var collidable []string
+	for c, chunk := range evicted {
+		forEachChunkTile(c, chunk, func(x, y int, tile models.WorldTile) {
+			if TileDefs[TileType(tile.Type)].IsCollidable {
+				collidable = append(collidable, strconv.Itoa(x)+","+strconv.Itoa(y))
+			}
+		})
+	}
+	removeTileCollisions(collidable)
+}
+
+// StartWorldChunkEviction periodically evicts the cached chunks no player is near; see
+// evictIdleWorldChunks.
+func StartWorldChunkEviction() {
+	ticker := time.NewTicker(worldChunkEvictInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		evictIdleWorldChunks()
+	}
+}
+
+// evictIdleWorldChunks drops the cached chunks that have gone unused for
+// worldChunkIdleTime and are not within worldChunkKeepRadius chunks of a player, taking
+// their tiles off the collision grid, along with the rivers and roads the world
+// generator cached for them. They are read again, or generated, when next needed.
+func evictIdleWorldChunks() {
+	keep := make(map[worldChunkCoord]bool)
+	for _, player := range EntityIndex.All(string(EntityTypePlayer)) {
+		c, _ := worldChunkFor(player.X, player.Y)
+		for dx := -worldChunkKeepRadius; dx <= worldChunkKeepRadius; dx++ {
+			for dy := -worldChunkKeepRadius; dy <= worldChunkKeepRadius; dy++ {
+				keep[worldChunkCoord{CX: c.CX + dx, CY: c.CY + dy}] = true
+			}
+		}
+	}
+	idleSince := time.Now().Add(-worldChunkIdleTime).UnixMilli()
+
+	var evicted []worldChunkCoord
+	var collidable []string
+	worldChunkCacheMu.Lock()
+	for c, chunk := range worldChunkCache {
+		if keep[c] || worldChunkUsed[c].Load() > idleSince {
+			continue
+		}
+		collidable = appendChunkCollisions(collidable, c, chunk)
+		delete(worldChunkCache, c)
+		delete(worldChunkStored, c)
+		delete(worldChunkUsed, c)
+		evicted = append(evicted, c)
+	}
+	// Still under the lock, so a chunk cached again meanwhile cannot lose its tiles.
+	removeTileCollisions(collidable)
+	worldChunkCacheMu.Unlock()
+
+	if len(evicted) == 0 {
+		return
+	}
+	worldGen.forgetChunks(evicted)
+	log.Printf("Evicted %d idle world chunks.", len(evicted))
+}
+
+// appendChunkCollisions appends the collidable tiles of a chunk to coordKeys. The caller
+// must hold worldChunkCacheMu.
+func appendChunkCollisions(coordKeys []string, c worldChunkCoord, chunk []byte) []string {
+	for ly := 0; ly < WorldChunkSize; ly++ {
+		for lx := 0; lx < WorldChunkSize; lx++ {
+			tile, ok := decodeWorldTile(chunk, (ly*WorldChunkSize+lx)*worldTileRecordSize)
+			if ok && TileDefs[TileType(tile.Type)].IsCollidable {
+				coordKeys = append(coordKeys, strconv.Itoa(c.CX*WorldChunkSize+lx)+","+strconv.Itoa(c.CY*WorldChunkSize+ly))
+			}
+		}
+	}
+	return coordKeys
+}
+
+// currentWorldChunkGeneration returns a counter that changes whenever the chunk cache is
+// reset.
+func currentWorldChunkGeneration() uint64 {
+	worldChunkCacheMu.RLock()
+	defer worldChunkCacheMu.RUnlock()
+	return worldChunkGeneration
+}
+
+// worldExists reports whether a world has been stored, in either format.
+func worldExists() bool {
+	return rdb.Exists(ctx, string(RedisKeyWorldFormat), string(RedisKeyWorldZone0)).Val() > 0
+}
+
+// deleteWorldTiles removes every stored tile chunk of the designed zones, the index of
+// them and the legacy tile hash.
+func deleteWorldTiles() error {
+	keys := []string{string(RedisKeyWorldZone0), string(RedisKeyWorldFormat), string(RedisKeyWorldStats), string(RedisKeySearchedPOIs)}
+	for _, zone := range zoneLayers {
+		keys = append(keys, zoneChunksKey(zone))
+		iter := rdb.Scan(ctx, 0, zoneChunkPrefix(zone)+"*", 500).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+	}
+	resetWorldChunkCache()
+	return rdb.Del(ctx, keys...).Err()
+}
+
+// MigrateLegacyWorldHash converts a world stored in the old one-JSON-field-per-tile hash
+// into chunks and deletes the hash. It is a no-op once the world is chunked.
+func MigrateLegacyWorldHash() error {
+	if rdb.Exists(ctx, string(RedisKeyWorldZone0)).Val() == 0 {
+		return nil
+	}
+	log.Println("Migrating world tiles from the legacy hash to chunked storage...")
+
+	worldData, err := rdb.HGetAll(ctx, string(RedisKeyWorldZone0)).Result()
+	if err != nil {
+		return err
+	}
+
+	chunks := make(map[worldChunkCoord][]byte)
+	for coord, tileJSON := range worldData {
+		var tile models.WorldTile
+		if err := json.Unmarshal([]byte(tileJSON), &tile); err != nil {
+			log.Printf("Skipping unreadable legacy tile %s: %v", coord, err)
+			continue
+		}
+		x, y := utils.ParseCoordKey(coord)
+		putWorldTile(chunks, x, y, tile)
+	}
+
+	if err := writeWorldChunks(chunks, string(RedisKeyWorldZone0)); err != nil {
+		return err
+	}
+
+	log.Printf("Migrated %d legacy tiles into %d chunks.", len(worldData), len(chunks))
+	return nil
+}
+
+// IndexStoredWorldChunks lists every stored chunk in RedisKeyWorldChunks, for worlds
+// stored before the list was kept. It is a no-op once the list exists.
+func IndexStoredWorldChunks() error {
+	if rdb.Exists(ctx, string(RedisKeyWorldChunks)).Val() > 0 {
+		return nil
+	}
+	var members []interface{}
+	iter := rdb.Scan(ctx, 0, string(RedisKeyWorldChunkPrefix)+"*", 500).Iterator()
+	for iter.Next(ctx) {
+		members = append(members, strings.TrimPrefix(iter.Val(), string(RedisKeyWorldChunkPrefix)))
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	log.Printf("Listed %d stored world chunks.", len(members))
+	return rdb.SAdd(ctx, string(RedisKeyWorldChunks), members...).Err()
+}
+
+// putWorldTile encodes a tile into a set of chunks being built in memory.
+func putWorldTile(chunks map[worldChunkCoord][]byte, x, y int, tile models.WorldTile) {
+	c, offset := worldChunkFor(x, y)
+	chunk, ok := chunks[c]
+	if !ok {
+		chunk = make([]byte, worldChunkBytes)
+		chunks[c] = chunk
+	}
+	record := encodeWorldTile(tile)
+	copy(chunk[offset:], record[:])
+}
+
+// writeWorldChunks stores whole chunks built with putWorldTile in one transaction, marks
+// the world as chunked and deletes any keys in replaced. Tiles the chunks leave out are
+// generated when they are read. The chunk cache is reset so the
+// next read sees the new world.
+func writeWorldChunks(chunks map[worldChunkCoord][]byte, replaced ...string) error {
+	pipe := rdb.TxPipeline()
+	for c, chunk := range chunks {
+		pipe.Set(ctx, worldChunkKey(c), chunk, 0)
+		pipe.SAdd(ctx, zoneChunksKey(zoneOfChunk(c)), strconv.Itoa(c.CX)+","+strconv.Itoa(c.CY))
+	}
+	pipe.Set(ctx, string(RedisKeyWorldFormat), WorldChunkFormatVersion, 0)
+	if len(replaced) > 0 {
+		pipe.Del(ctx, replaced...)
+	}
+	publishWorldTileChange(pipe, worldTileChange{Reset: true})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	resetWorldChunkCache()
+	return nil
+}
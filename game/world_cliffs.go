@@ -0,0 +1,85 @@
+package game
+
+import (
+	"math"
+	"mmo-game/models"
+)
+
+// The height of a tile is its level in the height map, which Cliffs terraces into
+// HeightLevels steps. Where the height map falls by at least CliffMinDrop levels from a
+// tile to a lower step beside it, the step is too steep to walk: its top tile is a cliff
+// that blocks movement and sight, and no one may step between the two tiles even where
+// the cliff itself is missing, such as diagonally past its end. Gentler steps are slopes
+// that can be walked. Water cuts through cliffs, and roads are laid over them as ramps.
+
+// heightLevel returns the height of a coordinate, from 0 up to HeightLevels-1. Worlds
+// without cliffs, and every zone but the overworld, are flat at height 0.
+func (g *worldGenerator) heightLevel(x, y int) int {
+	if !g.cfg.Cliffs || zoneAt(x, y) != ZoneOverworld {
+		return 0
+	}
+	level := int((g.height(x, y) + 1) / 2 * float64(g.cfg.HeightLevels))
+	return max(0, min(level, g.cfg.HeightLevels-1))
+}
+
+// cliffDrop reports whether the ground falls from (x, y) to a lower step at the
+// neighbouring (nx, ny) steeply enough to be a cliff. The fall to a diagonal neighbour is
+// measured over its greater distance.
+func (g *worldGenerator) cliffDrop(x, y, nx, ny int) bool {
+	if !g.cfg.Cliffs || g.heightLevel(nx, ny) >= g.heightLevel(x, y) {
+		return false
+	}
+	drop := (g.height(x, y) - g.height(nx, ny)) / 2 * float64(g.cfg.HeightLevels)
+	if x != nx && y != ny {
+		drop /= math.Sqrt2
+	}
+	return drop >= g.cfg.CliffMinDrop
+}
+
+// isCliff reports whether a cliff stands at a coordinate: on the top of a step that
+// falls steeply to one of its straight neighbours.
+func (g *worldGenerator) isCliff(x, y int) bool {
+	if !g.cfg.Cliffs {
+		return false
+	}
+	for _, d := range pathDirections[:4] { // Straight neighbours
+		if g.cliffDrop(x, y, x+d[0], y+d[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCliffStep reports whether a step between two neighbouring tiles, either way, would
+// climb or jump a cliff. A road or bridge on the higher tile is a ramp down it.
+func isCliffStep(from *models.WorldTile, fromX, fromY int, to *models.WorldTile, toX, toY int) bool {
+	if from.Height == to.Height {
+		return false
+	}
+	high := from
+	if to.Height > from.Height {
+		high = to
+	}
+	if t := TileType(high.Type); t == TileTypeRoad || t == TileTypeBridge {
+		return false
+	}
+	return worldGen.cliffDrop(fromX, fromY, toX, toY) || worldGen.cliffDrop(toX, toY, fromX, fromY)
+}
+
+// isCliffEdge is isCliffStep for the pathfinder, which plans over the tiles already
+// loaded: it reports whether the step between two neighbouring tiles would climb or
+// jump a cliff. Tiles whose chunks are not cached count as level.
+func isCliffEdge(a, b pathPoint) bool {
+	if !worldGen.cfg.Cliffs {
+		return false
+	}
+	from, ok := cachedWorldTile(a.X, a.Y)
+	if !ok {
+		return false
+	}
+	to, ok := cachedWorldTile(b.X, b.Y)
+	if !ok {
+		return false
+	}
+	return isCliffStep(&from, a.X, a.Y, &to, b.X, b.Y)
+}
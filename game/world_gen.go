@@ -0,0 +1,752 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mmo-game/models"
+	"os"
+	"sync"
+
+	"github.com/aquilax/go-perlin"
+	"github.com/go-redis/redis/v8"
+)
+
+// WorldGenConfig holds every parameter of world generation. The same config always
+// produces the same world, so it is saved alongside the world when it is generated.
+//
+// The world has no edge. WorldSize is the reach of the starting area along each axis,
+// which is generated and stored with the new world; everything beyond it is generated
+// as it is explored.
+//
+// Scales are in tiles per unit of noise: larger values give larger features.
+// Thresholds are compared against noise values in [-1, 1].
+type WorldGenConfig struct {
+	Seed      int64 `json:"seed"`
+	WorldSize int   `json:"worldSize"`
+
+	TerrainScale   float64 `json:"terrainScale"`
+	OreScale       float64 `json:"oreScale"`
+	WaterThreshold float64 `json:"waterThreshold"` // Terrain noise below this is water
+	OreThreshold   float64 `json:"oreThreshold"`   // Ore noise above this is iron rock
+	RockThreshold  float64 `json:"rockThreshold"`  // Terrain noise above this is rock
+	TreeThreshold  float64 `json:"treeThreshold"`  // Terrain noise above this is a tree
+	// ScatteredTreeChance is the chance of a lone tree on otherwise open ground.
+	ScatteredTreeChance float64 `json:"scatteredTreeChance"`
+
+	SanctuaryScale          float64 `json:"sanctuaryScale"`
+	SanctuaryThreshold      float64 `json:"sanctuaryThreshold"`
+	SanctuaryMinDistance    int     `json:"sanctuaryMinDistance"`
+	SanctuaryMinRadius      int     `json:"sanctuaryMinRadius"`
+	SanctuaryRadiusVariance int     `json:"sanctuaryRadiusVariance"` // Max radius is MinRadius + Variance - 1
+	SanctuaryShapeScale     float64 `json:"sanctuaryShapeScale"`
+
+	// Biomes enables the biome layer. Worlds saved before biomes existed leave it off
+	// and are generated as plains throughout, exactly as they were.
+	Biomes     bool    `json:"biomes"`
+	BiomeScale float64 `json:"biomeScale"`
+	// Biomes are chosen from temperature and moisture noise: tundra is colder than
+	// TundraTemperature; swamp is wetter than SwampMoisture and warmer than
+	// SwampTemperature; forest is wetter than ForestMoisture; highlands are drier than
+	// HighlandsMoisture; everything else is plains.
+	TundraTemperature float64 `json:"tundraTemperature"`
+	SwampTemperature  float64 `json:"swampTemperature"`
+	SwampMoisture     float64 `json:"swampMoisture"`
+	ForestMoisture    float64 `json:"forestMoisture"`
+	HighlandsMoisture float64 `json:"highlandsMoisture"`
+
+	// Rivers enables the height map: lakes where it is low, and rivers running downhill
+	// from sources on high ground until they reach a lake or pool in a hollow. Worlds
+	// saved before rivers existed leave it off.
+	Rivers               bool    `json:"rivers"`
+	HeightScale          float64 `json:"heightScale"`
+	LakeThreshold        float64 `json:"lakeThreshold"`        // Height below this is lake
+	RiverSourceThreshold float64 `json:"riverSourceThreshold"` // Rivers rise above this height
+	RiverCellSize        int     `json:"riverCellSize"`        // Each cell this wide has at most one source
+	RiverChance          float64 `json:"riverChance"`          // Chance that a cell has a source
+	RiverMaxLength       int     `json:"riverMaxLength"`
+	PoolRadius           int     `json:"poolRadius"` // Radius of the lake a river pools into in a hollow
+
+	// Roads joins neighbouring sanctuaries closer than RoadMaxDistance with roads,
+	// bridged where they cross water. Worlds saved before roads existed leave it off.
+	Roads           bool `json:"roads"`
+	RoadMaxDistance int  `json:"roadMaxDistance"`
+
+	// Dungeons places dungeon entrances on open ground, at most one in each cell
+	// DungeonCellSize tiles wide, with DungeonChance of a cell having one. Worlds saved
+	// before dungeons existed leave it off.
+	Dungeons        bool    `json:"dungeons"`
+	DungeonCellSize int     `json:"dungeonCellSize"`
+	DungeonChance   float64 `json:"dungeonChance"`
+
+	// Cliffs terraces the height map into HeightLevels levels, the height of each tile,
+	// and raises a cliff along the top of a step wherever the height map falls by at
+	// least CliffMinDrop levels from one tile to the next; elsewhere the step is a slope.
+	// Worlds saved before cliffs existed leave it off and are flat.
+	Cliffs       bool    `json:"cliffs"`
+	HeightLevels int     `json:"heightLevels"`
+	CliffMinDrop float64 `json:"cliffMinDrop"`
+
+	// Zones generates the designed zones beyond the overworld, as ZoneDefs describes
+	// them, and the ZonePortals between them. Worlds saved before zones existed leave it
+	// off and have no portals.
+	Zones bool `json:"zones"`
+
+	// POIs places points of interest (see POIDefs) on open ground, at most one in each
+	// cell POICellSize tiles wide, with POIChance of a cell having one. Worlds saved
+	// before points of interest existed leave it off.
+	POIs        bool    `json:"pois"`
+	POICellSize int     `json:"poiCellSize"`
+	POIChance   float64 `json:"poiChance"`
+}
+
+// DefaultWorldGenConfig returns the config used when none is given.
+func DefaultWorldGenConfig() WorldGenConfig {
+	return WorldGenConfig{
+		Seed:      100,
+		WorldSize: 200,
+
+		TerrainScale:        10.0,
+		OreScale:            8.0,
+		WaterThreshold:      -0.6,
+		OreThreshold:        0.7,
+		RockThreshold:       0.58,
+		TreeThreshold:       0.55,
+		ScatteredTreeChance: 0.02,
+
+		SanctuaryScale:          50.0,
+		SanctuaryThreshold:      0.60,
+		SanctuaryMinDistance:    100,
+		SanctuaryMinRadius:      8,
+		SanctuaryRadiusVariance: 5,
+		SanctuaryShapeScale:     15.0,
+
+		Biomes:            true,
+		BiomeScale:        80.0,
+		TundraTemperature: -0.2,
+		SwampTemperature:  0.1,
+		SwampMoisture:     0.2,
+		ForestMoisture:    0.05,
+		HighlandsMoisture: -0.15,
+
+		Rivers:               true,
+		HeightScale:          160.0,
+		LakeThreshold:        -0.45,
+		RiverSourceThreshold: 0.0,
+		RiverCellSize:        96,
+		RiverChance:          0.8,
+		RiverMaxLength:       400,
+		PoolRadius:           5,
+
+		Roads:           true,
+		RoadMaxDistance: 240,
+
+		Dungeons:        true,
+		DungeonCellSize: 160,
+		DungeonChance:   0.6,
+
+		Cliffs:       true,
+		HeightLevels: 12,
+		CliffMinDrop: 0.05,
+
+		Zones: true,
+
+		POIs:        true,
+		POICellSize: 48,
+		POIChance:   0.5,
+	}
+}
+
+// LoadWorldGenConfigFile reads a JSON config. Fields missing from the file keep their
+// default values.
+func LoadWorldGenConfigFile(path string) (WorldGenConfig, error) {
+	cfg := DefaultWorldGenConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate reports the first parameter that cannot produce a world.
+func (c WorldGenConfig) Validate() error {
+	switch {
+	case c.WorldSize <= 0:
+		return fmt.Errorf("worldSize must be positive, got %d", c.WorldSize)
+	case c.TerrainScale <= 0 || c.OreScale <= 0 || c.SanctuaryScale <= 0 || c.SanctuaryShapeScale <= 0:
+		return fmt.Errorf("noise scales must be positive")
+	case c.ScatteredTreeChance < 0 || c.ScatteredTreeChance > 1:
+		return fmt.Errorf("scatteredTreeChance must be between 0 and 1, got %v", c.ScatteredTreeChance)
+	case c.SanctuaryMinRadius <= 0:
+		return fmt.Errorf("sanctuaryMinRadius must be positive, got %d", c.SanctuaryMinRadius)
+	case c.SanctuaryRadiusVariance <= 0:
+		return fmt.Errorf("sanctuaryRadiusVariance must be positive, got %d", c.SanctuaryRadiusVariance)
+	case c.SanctuaryMinDistance <= 0:
+		return fmt.Errorf("sanctuaryMinDistance must be positive, got %d", c.SanctuaryMinDistance)
+	case c.Biomes && c.BiomeScale <= 0:
+		return fmt.Errorf("biomeScale must be positive, got %v", c.BiomeScale)
+	case c.Rivers && c.HeightScale <= 0:
+		return fmt.Errorf("heightScale must be positive, got %v", c.HeightScale)
+	case c.Rivers && c.RiverCellSize <= 0:
+		return fmt.Errorf("riverCellSize must be positive, got %d", c.RiverCellSize)
+	case c.Rivers && (c.RiverChance < 0 || c.RiverChance > 1):
+		return fmt.Errorf("riverChance must be between 0 and 1, got %v", c.RiverChance)
+	case c.Rivers && c.RiverMaxLength <= 0:
+		return fmt.Errorf("riverMaxLength must be positive, got %d", c.RiverMaxLength)
+	case c.Rivers && c.PoolRadius < 0:
+		return fmt.Errorf("poolRadius must not be negative, got %d", c.PoolRadius)
+	case c.Roads && c.RoadMaxDistance <= 0:
+		return fmt.Errorf("roadMaxDistance must be positive, got %d", c.RoadMaxDistance)
+	case c.Dungeons && c.DungeonCellSize <= 0:
+		return fmt.Errorf("dungeonCellSize must be positive, got %d", c.DungeonCellSize)
+	case c.Dungeons && (c.DungeonChance < 0 || c.DungeonChance > 1):
+		return fmt.Errorf("dungeonChance must be between 0 and 1, got %v", c.DungeonChance)
+	case c.Cliffs && c.HeightScale <= 0:
+		return fmt.Errorf("heightScale must be positive, got %v", c.HeightScale)
+	case c.Cliffs && c.CliffMinDrop < 0:
+		return fmt.Errorf("cliffMinDrop must not be negative, got %v", c.CliffMinDrop)
+	case c.Cliffs && (c.HeightLevels <= 0 || c.HeightLevels > worldTileMaxHeight+1):
+		return fmt.Errorf("heightLevels must be between 1 and %d, got %d", worldTileMaxHeight+1, c.HeightLevels)
+	case c.POIs && c.POICellSize <= 0:
+		return fmt.Errorf("poiCellSize must be positive, got %d", c.POICellSize)
+	case c.POIs && (c.POIChance < 0 || c.POIChance > 1):
+		return fmt.Errorf("poiChance must be between 0 and 1, got %v", c.POIChance)
+	}
+	return nil
+}
+
+// worldGenerator produces the tiles of a world from a WorldGenConfig. Everything it
+// decides is a function of the config and the tile's position, so tiles can be
+// generated in any order and generating one twice gives the same answer.
+type worldGenerator struct {
+	cfg         WorldGenConfig
+	terrain     *perlin.Perlin
+	sanctuary   *perlin.Perlin
+	temperature *perlin.Perlin
+	moisture    *perlin.Perlin
+	elevation   *perlin.Perlin
+	zoneWalls   *perlin.Perlin
+
+	// fixed holds the sanctuaries placed other than by cell: the starting sanctuary, and
+	// those of a world stored before sanctuaries were placed cell by cell.
+	fixed       []Sanctuary
+	sanctuaryMu sync.Mutex
+	candidates  map[sanctuaryCell]sanctuaryCandidate
+
+	// Rivers and roads, and the tiles of each in the chunks generated so far.
+	featureMu   sync.Mutex
+	rivers      map[riverCell][]pathPoint
+	riverChunks map[worldChunkCoord]map[pathPoint]bool
+	roads       map[sanctuaryPair][]pathPoint
+	roadChunks  map[worldChunkCoord]map[pathPoint]bool
+}
+
+// worldGenCellCacheLimit is how many rivers, roads or sanctuary candidates the world
+// generator keeps cached by cell; see forgetChunks.
+const worldGenCellCacheLimit = 4096
+
+func newWorldGenerator(cfg WorldGenConfig) *worldGenerator {
+	// Each noise map gets its own seed so they are independent of each other.
+	return &worldGenerator{
+		cfg:         cfg,
+		terrain:     perlin.NewPerlin(perlinAlpha, perlinBeta, perlinN, cfg.Seed),
+		sanctuary:   perlin.NewPerlin(perlinAlpha, perlinBeta, perlinN, cfg.Seed+1),
+		temperature: perlin.NewPerlin(perlinAlpha, perlinBeta, perlinN, cfg.Seed+2),
+		moisture:    perlin.NewPerlin(perlinAlpha, perlinBeta, perlinN, cfg.Seed+3),
+		elevation:   perlin.NewPerlin(perlinAlpha, perlinBeta, heightN, cfg.Seed+4),
+		zoneWalls:   perlin.NewPerlin(perlinAlpha, perlinBeta, perlinN, cfg.Seed+6),
+		fixed:       []Sanctuary{startingSanctuary},
+		candidates:  make(map[sanctuaryCell]sanctuaryCandidate),
+		rivers:      make(map[riverCell][]pathPoint),
+		riverChunks: make(map[worldChunkCoord]map[pathPoint]bool),
+		roads:       make(map[sanctuaryPair][]pathPoint),
+		roadChunks:  make(map[worldChunkCoord]map[pathPoint]bool),
+	}
+}
+
+// worldGen generates the current world. It is replaced by SetWorldGenConfig.
+var worldGen = newWorldGenerator(DefaultWorldGenConfig())
+
+// SetWorldGenConfig makes cfg the config of the current world, including its size. It
+// must be called before the world is generated or loaded.
+func SetWorldGenConfig(cfg WorldGenConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	worldGen = newWorldGenerator(cfg)
+	WorldSize = cfg.WorldSize
+	resetWorldChunkCache() // Chunks generated by the old config
+	return nil
+}
+
+// CurrentWorldGenConfig returns the config of the current world.
+func CurrentWorldGenConfig() WorldGenConfig {
+	return worldGen.cfg
+}
+
+// random returns a number in [0, 1) determined by the seed, the tile and salt, which
+// tells apart the different decisions made about the same tile.
+func (g *worldGenerator) random(x, y int, salt uint64) float64 {
+	h := uint64(g.cfg.Seed) ^ uint64(int64(x))*0x9e3779b97f4a7c15 ^ uint64(int64(y))*0xc2b2ae3d27d4eb4f ^ salt*0x165667b19e3779f9
+	// splitmix64 finalizer
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return float64(h>>11) / (1 << 53)
+}
+
+const (
+	worldGenSaltScatteredTree = iota + 1
+	worldGenSaltSanctuaryRadius
+	worldGenSaltRiver
+	worldGenSaltRiverX
+	worldGenSaltRiverY
+	worldGenSaltDungeon
+	worldGenSaltDungeonX
+	worldGenSaltDungeonY
+	worldGenSaltDungeonSeed
+	worldGenSaltPOI
+	worldGenSaltPOIX
+	worldGenSaltPOIY
+	worldGenSaltPOIType
+)
+
+// biome returns the biome of a coordinate.
+func (g *worldGenerator) biome(x, y int) BiomeType {
+	if biome := ZoneDefs[zoneAt(x, y)].Biome; biome != "" {
+		return biome
+	}
+	c := g.cfg
+	if !c.Biomes {
+		return BiomePlains
+	}
+	temperature := g.temperature.Noise2D((float64(x)+noiseOffset)/c.BiomeScale, (float64(y)+noiseOffset)/c.BiomeScale)
+	moisture := g.moisture.Noise2D((float64(x)+noiseOffset)/c.BiomeScale, (float64(y)+noiseOffset)/c.BiomeScale)
+
+	switch {
+	case temperature < c.TundraTemperature:
+		return BiomeTundra
+	case moisture > c.SwampMoisture && temperature > c.SwampTemperature:
+		return BiomeSwamp
+	case moisture > c.ForestMoisture:
+		return BiomeForest
+	case moisture < c.HighlandsMoisture:
+		return BiomeHighlands
+	}
+	return BiomePlains
+}
+
+// naturalTile determines the natural tile type for a coordinate, ignoring sanctuaries:
+// the terrain, with roads laid over it. Zones other than the overworld have terrain of
+// their own.
+func (g *worldGenerator) naturalTile(x, y int) TileType {
+	if zone := zoneAt(x, y); zone != ZoneOverworld {
+		return g.zoneTerrainTile(zone, x, y)
+	}
+	if road := g.roadTile(x, y); road != "" {
+		return road
+	}
+	return g.terrainTile(x, y)
+}
+
+// terrainTile determines the tile type of the terrain at a coordinate. The biome picks
+// the tiles and shifts the thresholds between them; lakes and rivers are its water, and
+// cliffs break up its slopes.
+func (g *worldGenerator) terrainTile(x, y int) TileType {
+	b := BiomeDefs[g.biome(x, y)]
+	if g.isLake(x, y) || g.isRiver(x, y) {
+		return b.Water
+	}
+	if g.isCliff(x, y) {
+		return TileTypeCliff
+	}
+	return g.biomeTerrain(b, x, y)
+}
+
+// biomeTerrain determines the tile type of a biome's open terrain at the coordinate its
+// noise is sampled at: its water, ore, rock, trees or floor, by the WorldGenConfig
+// thresholds shifted by the biome's biases.
+func (g *worldGenerator) biomeTerrain(b BiomeProperties, x, y int) TileType {
+	c := g.cfg
+	noiseVal := g.terrain.Noise2D((float64(x)+noiseOffset)/c.TerrainScale, (float64(y)+noiseOffset)/c.TerrainScale)
+	oreNoiseVal := g.terrain.Noise2D((float64(x)+noiseOffset)/c.OreScale, (float64(y)+noiseOffset)/c.OreScale)
+
+	if noiseVal < c.WaterThreshold+b.WaterBias {
+		return b.Water
+	} else if oreNoiseVal > c.OreThreshold-b.OreBias {
+		return TileTypeIronRock
+	} else if noiseVal > c.RockThreshold-b.RockBias {
+		return TileTypeRock
+	} else if noiseVal > c.TreeThreshold-b.TreeBias {
+		return b.Tree
+	}
+
+	if g.random(x, y, worldGenSaltScatteredTree) < c.ScatteredTreeChance*b.ScatteredTreeFactor {
+		return b.Tree
+	}
+
+	return b.Floor
+}
+
+// Sanctuaries are placed cell by cell, so that the sanctuaries around any chunk can be
+// found without generating the rest of the world. Each cell is a square
+// SanctuaryMinDistance tiles wide whose candidate is its strongest sanctuary noise on
+// land. A candidate becomes a sanctuary unless a fixed sanctuary, or a stronger
+// candidate in a neighbouring cell, is closer than SanctuaryMinDistance; candidates
+// further apart than that can only be in neighbouring cells.
+type sanctuaryCell struct{ X, Y int }
+
+type sanctuaryCandidate struct {
+	Sanctuary
+	Noise float64
+	OK    bool
+}
+
+// beats reports whether c wins against other, the stronger noise winning and ties going
+// to the lower coordinate.
+func (c sanctuaryCandidate) beats(other sanctuaryCandidate) bool {
+	if c.Noise != other.Noise {
+		return c.Noise > other.Noise
+	}
+	if c.X != other.X {
+		return c.X < other.X
+	}
+	return c.Y < other.Y
+}
+
+// candidate returns the sanctuary candidate of a cell, which is cached since every
+// neighbouring cell needs it too.
+func (g *worldGenerator) candidate(cell sanctuaryCell) sanctuaryCandidate {
+	g.sanctuaryMu.Lock()
+	cached, ok := g.candidates[cell]
+	g.sanctuaryMu.Unlock()
+	if ok {
+		return cached
+	}
+
+	c := g.cfg
+	best := sanctuaryCandidate{Noise: c.SanctuaryThreshold}
+	for x := cell.X * c.SanctuaryMinDistance; x < (cell.X+1)*c.SanctuaryMinDistance; x++ {
+		for y := cell.Y * c.SanctuaryMinDistance; y < (cell.Y+1)*c.SanctuaryMinDistance; y++ {
+			noise := g.sanctuary.Noise2D((float64(x)+noiseOffset)/c.SanctuaryScale, (float64(y)+noiseOffset)/c.SanctuaryScale)
+			if noise <= best.Noise || zoneAt(x, y) != ZoneOverworld {
+				continue
+			}
+			if g.terrainTile(x, y) == BiomeDefs[g.biome(x, y)].Water {
+				continue
+			}
+			radius := c.SanctuaryMinRadius + int(g.random(x, y, worldGenSaltSanctuaryRadius)*float64(c.SanctuaryRadiusVariance))
+			best = sanctuaryCandidate{Sanctuary: Sanctuary{X: x, Y: y, Radius: radius}, Noise: noise, OK: true}
+		}
+	}
+
+	g.sanctuaryMu.Lock()
+	g.candidates[cell] = best
+	g.sanctuaryMu.Unlock()
+	return best
+}
+
+// cellSanctuary returns the sanctuary of a cell, if it has one.
+func (g *worldGenerator) cellSanctuary(cell sanctuaryCell) (Sanctuary, bool) {
+	candidate := g.candidate(cell)
+	if !candidate.OK {
+		return Sanctuary{}, false
+	}
+	minDistSq := g.cfg.SanctuaryMinDistance * g.cfg.SanctuaryMinDistance
+	tooClose := func(s Sanctuary) bool {
+		return (candidate.X-s.X)*(candidate.X-s.X)+(candidate.Y-s.Y)*(candidate.Y-s.Y) < minDistSq
+	}
+	for _, s := range g.fixed {
+		if tooClose(s) {
+			return Sanctuary{}, false
+		}
+	}
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			other := g.candidate(sanctuaryCell{cell.X + dx, cell.Y + dy})
+			if other.OK && other.beats(candidate) && tooClose(other.Sanctuary) {
+				return Sanctuary{}, false
+			}
+		}
+	}
+	return candidate.Sanctuary, true
+}
+
+// sanctuariesIn returns the sanctuaries whose centers lie in an inclusive rectangle.
+func (g *worldGenerator) sanctuariesIn(minX, minY, maxX, maxY int) []Sanctuary {
+	var sanctuaries []Sanctuary
+	for _, s := range g.fixed {
+		if s.X >= minX && s.X <= maxX && s.Y >= minY && s.Y <= maxY {
+			sanctuaries = append(sanctuaries, s)
+		}
+	}
+	d := g.cfg.SanctuaryMinDistance
+	for cx := floorDiv(minX, d); cx <= floorDiv(maxX, d); cx++ {
+		for cy := floorDiv(minY, d); cy <= floorDiv(maxY, d); cy++ {
+			s, ok := g.cellSanctuary(sanctuaryCell{cx, cy})
+			if ok && s.X >= minX && s.X <= maxX && s.Y >= minY && s.Y <= maxY {
+				sanctuaries = append(sanctuaries, s)
+			}
+		}
+	}
+	return sanctuaries
+}
+
+// sanctuaryReach is the furthest a sanctuary's tiles reach from its center.
+func (g *worldGenerator) sanctuaryReach() int {
+	return int(float64(g.cfg.SanctuaryMinRadius+g.cfg.SanctuaryRadiusVariance)*1.3) + 1
+}
+
+// sanctuariesNear returns every sanctuary with tiles in a chunk.
+func (g *worldGenerator) sanctuariesNear(c worldChunkCoord) []Sanctuary {
+	if zoneOfChunk(c) != ZoneOverworld {
+		return nil // Sanctuaries are only found in the overworld
+	}
+	reach := g.sanctuaryReach()
+	minX, minY := c.CX*WorldChunkSize, c.CY*WorldChunkSize
+	return g.sanctuariesIn(minX-reach, minY-reach, minX+WorldChunkSize-1+reach, minY+WorldChunkSize-1+reach)
+}
+
+// setFixedSanctuaries makes the generator build the sanctuaries stored with an existing
+// world, the starting sanctuary first, and keep new ones away from them. Worlds stored
+// before sanctuaries were placed cell by cell chose theirs differently.
+func (g *worldGenerator) setFixedSanctuaries(sanctuaries []Sanctuary) {
+	g.fixed = append([]Sanctuary(nil), sanctuaries...)
+	g.featureMu.Lock()
+	g.roadChunks = make(map[worldChunkCoord]map[pathPoint]bool) // Roads join the sanctuaries
+	g.featureMu.Unlock()
+}
+
+// forgetChunks drops the rivers and roads cached for chunks evicted from the chunk cache.
+// The rivers, roads and sanctuary candidates cached by cell are dropped altogether once
+// there are more than worldGenCellCacheLimit of any; they are found again as needed.
+func (g *worldGenerator) forgetChunks(chunks []worldChunkCoord) {
+	g.featureMu.Lock()
+	for _, c := range chunks {
+		delete(g.riverChunks, c)
+		delete(g.roadChunks, c)
+	}
+	if len(g.rivers) > worldGenCellCacheLimit {
+		g.rivers = make(map[riverCell][]pathPoint)
+	}
+	if len(g.roads) > worldGenCellCacheLimit {
+		g.roads = make(map[sanctuaryPair][]pathPoint)
+	}
+	g.featureMu.Unlock()
+
+	g.sanctuaryMu.Lock()
+	if len(g.candidates) > worldGenCellCacheLimit {
+		g.candidates = make(map[sanctuaryCell]sanctuaryCandidate)
+	}
+	g.sanctuaryMu.Unlock()
+}
+
+// chunk generates the tiles of a chunk.
+func (g *worldGenerator) chunk(c worldChunkCoord) []byte {
+	chunk := make([]byte, worldChunkBytes)
+	g.fillChunk(c, chunk)
+	return chunk
+}
+
+// fillChunk generates the tiles of a chunk that have none stored.
+func (g *worldGenerator) fillChunk(c worldChunkCoord, chunk []byte) {
+	var sanctuaries []Sanctuary
+	found := false
+	for ly := 0; ly < WorldChunkSize; ly++ {
+		for lx := 0; lx < WorldChunkSize; lx++ {
+			offset := (ly*WorldChunkSize + lx) * worldTileRecordSize
+			if chunk[offset] != 0 {
+				continue
+			}
+			if !found {
+				sanctuaries, found = g.sanctuariesNear(c), true
+			}
+			record := encodeWorldTile(g.tile(c.CX*WorldChunkSize+lx, c.CY*WorldChunkSize+ly, sanctuaries))
+			copy(chunk[offset:], record[:])
+		}
+	}
+}
+
+// tile generates the tile at a coordinate of a world with the given sanctuaries.
+// Sanctuary tiles are continuous ground with no resources, around a center stone.
+func (g *worldGenerator) tile(x, y int, sanctuaries []Sanctuary) models.WorldTile {
+	var tile models.WorldTile
+	isSanctuary, isStone := g.sanctuaryTile(x, y, sanctuaries)
+	if portal, clearing := g.portalTile(x, y); portal {
+		tile.Type = string(TileTypePortal)
+	} else if clearing && !isSanctuary {
+		tile.Type = string(BiomeDefs[g.biome(x, y)].Floor)
+	} else if isStone {
+		tile.Type = string(TileTypeSanctuaryStone)
+		tile.IsSanctuary = true // The tile under the stone is a sanctuary too
+	} else if isSanctuary {
+		tile.Type = string(TileTypeGround)
+		tile.IsSanctuary = true
+	} else if g.isDungeonEntrance(x, y) {
+		tile.Type = string(TileTypeDungeonEntrance)
+	} else if poi := g.poiAt(x, y); poi != "" {
+		tile.Type = string(poi)
+	} else {
+		tile.Type = string(g.naturalTile(x, y))
+	}
+	tile.Health = TileDefs[TileType(tile.Type)].MaxHealth
+	tile.Height = g.heightLevel(x, y)
+	return tile
+}
+
+// isDungeonEntrance reports whether a dungeon entrance stands at a coordinate. Each cell
+// may have one, at a random point of it that is on the floor of its biome.
+func (g *worldGenerator) isDungeonEntrance(x, y int) bool {
+	c := g.cfg
+	if !c.Dungeons || zoneAt(x, y) != ZoneOverworld {
+		return false
+	}
+	cx, cy := floorDiv(x, c.DungeonCellSize), floorDiv(y, c.DungeonCellSize)
+	if g.random(cx, cy, worldGenSaltDungeon) >= c.DungeonChance {
+		return false
+	}
+	ex := cx*c.DungeonCellSize + int(g.random(cx, cy, worldGenSaltDungeonX)*float64(c.DungeonCellSize))
+	ey := cy*c.DungeonCellSize + int(g.random(cx, cy, worldGenSaltDungeonY)*float64(c.DungeonCellSize))
+	return x == ex && y == ey && g.naturalTile(x, y) == BiomeDefs[g.biome(x, y)].Floor
+}
+
+// dungeonSeed returns the seed of the dungeon under the entrance at a coordinate.
+func (g *worldGenerator) dungeonSeed(x, y int) int64 {
+	return int64(g.random(x, y, worldGenSaltDungeonSeed) * (1 << 53))
+}
+
+func (g *worldGenerator) sanctuaryTile(x, y int, sanctuaries []Sanctuary) (isSanctuary, isStone bool) {
+	for _, s := range sanctuaries {
+		if x == s.X && y == s.Y {
+			return true, true
+		}
+
+		// Use perlin noise to make the radius variable and the shape irregular
+		noise := g.terrain.Noise2D(float64(x)/g.cfg.SanctuaryShapeScale, float64(y)/g.cfg.SanctuaryShapeScale)
+		variableRadius := float64(s.Radius) * (0.7 + (noise+1)/2*0.6) // Vary radius between 70% and 130%
+
+		distSq := float64((x-s.X)*(x-s.X) + (y-s.Y)*(y-s.Y))
+		if distSq < variableRadius*variableRadius {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// saveWorldGenConfig stores the current config with the world.
+func saveWorldGenConfig() {
+	cfgJSON, _ := json.Marshal(worldGen.cfg)
+	if err := rdb.Set(ctx, string(RedisKeyWorldGenConfig), cfgJSON, 0).Err(); err != nil {
+		log.Printf("Failed to save world generation config: %v", err)
+	}
+}
+
+// readWorldGenConfig returns the config stored with the world, or nil if there is none,
+// as for worlds generated before configs were saved.
+func readWorldGenConfig() (*WorldGenConfig, error) {
+	cfgJSON, err := rdb.Get(ctx, string(RedisKeyWorldGenConfig)).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var cfg WorldGenConfig
+	if err := json.Unmarshal([]byte(cfgJSON), &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadWorldGenConfig switches to the config stored with an existing world, so that
+// anything regenerated from it matches the tiles in Redis.
+func loadWorldGenConfig() {
+	cfg, err := readWorldGenConfig()
+	if err != nil {
+		log.Printf("Failed to load world generation config: %v", err)
+		return
+	}
+	if cfg == nil {
+		log.Printf("World has no saved generation config; using seed %d.", worldGen.cfg.Seed)
+		return
+	}
+	if *cfg != worldGen.cfg {
+		log.Printf("Using the world's saved generation config (seed %d) instead of the configured one (seed %d).", cfg.Seed, worldGen.cfg.Seed)
+	}
+	if err := SetWorldGenConfig(*cfg); err != nil {
+		log.Fatalf("Saved world generation config is invalid: %v", err)
+	}
+}
+
+// WorldPreview summarizes the starting area of the world a config generates.
+type WorldPreview struct {
+	Config      WorldGenConfig
+	Sanctuaries []Sanctuary
+	TileCounts  map[TileType]int
+	BiomeCounts map[BiomeType]int
+	// Resources counts the gatherable tiles in each biome, which are also the potential
+	// resource spawn points; ResourceTargets is how many of each the spawner keeps there.
+	Resources       map[BiomeType]map[TileType]int
+	ResourceTargets map[BiomeType]map[TileType]int
+	// ResourceQuadrants splits the resources by quadrant: north-west, north-east,
+	// south-west, south-east. Tiles on an axis count towards the quadrant after them.
+	ResourceQuadrants map[TileType][4]int
+}
+
+// PreviewWorld generates the starting area of a world in memory and summarizes it,
+// without touching Redis.
+func PreviewWorld(cfg WorldGenConfig) (*WorldPreview, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	g := newWorldGenerator(cfg)
+	preview := &WorldPreview{
+		Config:            cfg,
+		Sanctuaries:       g.sanctuariesIn(-cfg.WorldSize, -cfg.WorldSize, cfg.WorldSize, cfg.WorldSize),
+		TileCounts:        make(map[TileType]int),
+		BiomeCounts:       make(map[BiomeType]int),
+		Resources:         make(map[BiomeType]map[TileType]int),
+		ResourceQuadrants: make(map[TileType][4]int),
+	}
+	g.forEachTile(func(x, y int, biome BiomeType, tileType TileType) {
+		preview.TileCounts[tileType]++
+		preview.BiomeCounts[biome]++
+		if !TileDefs[tileType].IsGatherable {
+			return
+		}
+		if preview.Resources[biome] == nil {
+			preview.Resources[biome] = make(map[TileType]int)
+		}
+		preview.Resources[biome][tileType]++
+		quadrant := 0
+		if x >= 0 {
+			quadrant++
+		}
+		if y >= 0 {
+			quadrant += 2
+		}
+		counts := preview.ResourceQuadrants[tileType]
+		counts[quadrant]++
+		preview.ResourceQuadrants[tileType] = counts
+	})
+	preview.ResourceTargets = resourceTargetsFor(preview.Resources)
+	return preview, nil
+}
+
+// forEachTile generates every tile of the starting area in turn.
+func (g *worldGenerator) forEachTile(fn func(x, y int, biome BiomeType, tileType TileType)) {
+	reach := g.sanctuaryReach()
+	sanctuaries := g.sanctuariesIn(-g.cfg.WorldSize-reach, -g.cfg.WorldSize-reach, g.cfg.WorldSize+reach, g.cfg.WorldSize+reach)
+	for x := -g.cfg.WorldSize; x <= g.cfg.WorldSize; x++ {
+		for y := -g.cfg.WorldSize; y <= g.cfg.WorldSize; y++ {
+			fn(x, y, g.biome(x, y), TileType(g.tile(x, y, sanctuaries).Type))
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package game
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"mmo-game/models"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A world map is a picture of an area of the world, drawn from Redis or from a snapshot
+// without a running server, a block of Scale pixels square for each tile. Overlays mark
+// what the tiles alone do not show well: sanctuaries, resource nodes, player structures,
+// entities, and the regions resources and NPCs spawn in.
+
+// WorldMapOverlay is something drawn over the tiles of a world map.
+type WorldMapOverlay string
+
+const (
+	// WorldMapOverlaySanctuaries outlines each sanctuary and marks its stone.
+	WorldMapOverlaySanctuaries WorldMapOverlay = "sanctuaries"
+	// WorldMapOverlayResources marks every resource node.
+	WorldMapOverlayResources WorldMapOverlay = "resources"
+	// WorldMapOverlayStructures highlights walls, fires and anything else players built.
+	WorldMapOverlayStructures WorldMapOverlay = "structures"
+	// WorldMapOverlayEntities marks players, NPCs and items. Snapshots have none.
+	WorldMapOverlayEntities WorldMapOverlay = "entities"
+	// WorldMapOverlaySpawns draws the borders of the biomes, which each have their own
+	// spawn table, and marks the resource spawn points that are empty.
+	WorldMapOverlaySpawns WorldMapOverlay = "spawns"
+)
+
+// WorldMapOverlays lists every overlay, in the order they are drawn.
+var WorldMapOverlays = []WorldMapOverlay{
+	WorldMapOverlaySpawns,
+	WorldMapOverlaySanctuaries,
+	WorldMapOverlayStructures,
+	WorldMapOverlayResources,
+	WorldMapOverlayEntities,
+}
+
+// worldMapMaxPixels bounds the size of a map, which is held in memory while drawn.
+const worldMapMaxPixels = 1 << 26
+
+// worldMapTileColors match the tile colors of the client.
+var worldMapTileColors = map[TileType]color.RGBA{
+	TileTypeGround:          {0x6B, 0x8E, 0x23, 0xFF},
+	TileTypeWater:           {0x46, 0x82, 0xB4, 0xFF},
+	TileTypeTree:            {0x22, 0x8B, 0x22, 0xFF},
+	TileTypeRock:            {0xA9, 0xA9, 0xA9, 0xFF},
+	TileTypeIronRock:        {0x8A, 0x8A, 0x8A, 0xFF},
+	TileTypeWoodenWall:      {0xA0, 0x52, 0x2D, 0xFF},
+	TileTypeFire:            {0xFF, 0x45, 0x00, 0xFF},
+	TileTypeSanctuaryStone:  {0x80, 0x80, 0x80, 0xFF},
+	TileTypeGrass:           {0x4F, 0x7A, 0x28, 0xFF},
+	TileTypeMud:             {0x5C, 0x4A, 0x32, 0xFF},
+	TileTypeGravel:          {0x8B, 0x83, 0x78, 0xFF},
+	TileTypeSnow:            {0xEE, 0xF3, 0xF7, 0xFF},
+	TileTypeIce:             {0xBF, 0xE3, 0xF0, 0xFF},
+	TileTypePineTree:        {0x2F, 0x5D, 0x3A, 0xFF},
+	TileTypeRoad:            {0xA6, 0x8A, 0x64, 0xFF},
+	TileTypeBridge:          {0x7A, 0x52, 0x30, 0xFF},
+	TileTypeDungeonEntrance: {0x2B, 0x1D, 0x14, 0xFF},
+	TileTypeDungeonFloor:    {0x5A, 0x52, 0x48, 0xFF},
+	TileTypeDungeonWall:     {0x2E, 0x2A, 0x26, 0xFF},
+	TileTypeDungeonExit:     {0xC9, 0xB4, 0x58, 0xFF},
+	TileTypeCliff:           {0x6E, 0x5F, 0x50, 0xFF},
+	TileTypePortal:          {0x9B, 0x30, 0xFF, 0xFF},
+	TileTypeCaveFloor:       {0x4A, 0x44, 0x3F, 0xFF},
+	TileTypeCaveWall:        {0x24, 0x20, 0x1E, 0xFF},
+	TileTypeEtherealGround:  {0xB8, 0xC6, 0xE8, 0xFF},
+	TileTypeRift:            {0x14, 0x10, 0x2A, 0xFF},
+	TileTypeSpiritTree:      {0x7F, 0xE0, 0xD0, 0xFF},
+	TileTypeRuins:           {0x9E, 0x94, 0x84, 0xFF},
+	TileTypeShrine:          {0xE8, 0xD5, 0x8C, 0xFF},
+	TileTypeAbandonedCamp:   {0xB5, 0x65, 0x1D, 0xFF},
+	TileTypeBuriedCache:     {0x73, 0x56, 0x3A, 0xFF},
+}
+
+// Overlay colors are bright enough to stand out against any tile.
+var (
+	worldMapUnknownColor   = color.RGBA{0xFF, 0x00, 0xFF, 0xFF}
+	worldMapSanctuaryColor = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	worldMapStructureColor = color.RGBA{0xFF, 0x14, 0x93, 0xFF}
+	worldMapBorderColor    = color.RGBA{0x00, 0x00, 0x00, 0xFF}
+	worldMapSpawnColor     = color.RGBA{0xFF, 0xF0, 0x80, 0xFF}
+	worldMapResourceColors = map[TileType]color.RGBA{
+		TileTypeTree:     {0x7C, 0xFC, 0x00, 0xFF},
+		TileTypePineTree: {0x7C, 0xFC, 0x00, 0xFF},
+		TileTypeRock:     {0xF0, 0xF0, 0xF0, 0xFF},
+		TileTypeIronRock: {0xFF, 0x8C, 0x00, 0xFF},
+	}
+	worldMapEntityColors = map[string]color.RGBA{
+		string(EntityTypePlayer): {0x00, 0xFF, 0xFF, 0xFF},
+		string(EntityTypeNPC):    {0xFF, 0x00, 0x00, 0xFF},
+		string(EntityTypeItem):   {0xFF, 0xFF, 0x00, 0xFF},
+	}
+)
+
+// WorldMapArea is an inclusive rectangle of tiles.
+type WorldMapArea struct {
+	MinX, MinY, MaxX, MaxY int
+}
+
+// ParseWorldMapArea parses an area written "minX,minY,maxX,maxY".
+func ParseWorldMapArea(s string) (WorldMapArea, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return WorldMapArea{}, fmt.Errorf("area %q is not minX,minY,maxX,maxY", s)
+	}
+	var bounds [4]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return WorldMapArea{}, fmt.Errorf("area %q: %v", s, err)
+		}
+		bounds[i] = n
+	}
+	area := WorldMapArea{bounds[0], bounds[1], bounds[2], bounds[3]}
+	if area.MinX > area.MaxX || area.MinY > area.MaxY {
+		return WorldMapArea{}, fmt.Errorf("area %q is empty", s)
+	}
+	return area, nil
+}
+
+func (a WorldMapArea) contains(x, y int) bool {
+	return x >= a.MinX && x <= a.MaxX && y >= a.MinY && y <= a.MaxY
+}
+
+// WorldMapOptions says what a world map shows.
+type WorldMapOptions struct {
+	// Area is the rectangle drawn; nil draws the starting area.
+	Area *WorldMapArea
+	// Scale is the side of each tile's block, in pixels.
+	Scale    int
+	Overlays []WorldMapOverlay
+}
+
+// ParseWorldMapOverlays parses a comma separated list of overlays. "all" is every overlay.
+func ParseWorldMapOverlays(s string) ([]WorldMapOverlay, error) {
+	var overlays []WorldMapOverlay
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case name == "all":
+			overlays = append(overlays, WorldMapOverlays...)
+		case slices.Contains(WorldMapOverlays, WorldMapOverlay(name)):
+			overlays = append(overlays, WorldMapOverlay(name))
+		default:
+			return nil, fmt.Errorf("unknown overlay %q", name)
+		}
+	}
+	return overlays, nil
+}
+
+// worldMapSource is the world a map is drawn from.
+type worldMapSource struct {
+	gen *worldGenerator
+	// tile returns the tile at a coordinate, and whether it is stored rather than
+	// generated only for the map.
+	tile func(x, y int) (models.WorldTile, bool)
+	// resources and entities return what is positioned in an area; the resources of a
+	// source without them are its gatherable tiles.
+	resources func(area WorldMapArea) []SpatialEntry
+	entities  func(area WorldMapArea) []SpatialEntry
+}
+
+// RenderWorldMap draws the world stored in Redis, without changing it. Chunks that have
+// not been stored are drawn as they would be generated, but darkened.
+func RenderWorldMap(opts WorldMapOptions) (*image.RGBA, error) {
+	loadWorldGenConfig()
+	loadSanctuaries()
+	if slices.Contains(opts.Overlays, WorldMapOverlayResources) || slices.Contains(opts.Overlays, WorldMapOverlayEntities) {
+		IndexSpatialPositions()
+	}
+
+	inArea := func(index *SpatialIndex) func(area WorldMapArea) []SpatialEntry {
+		return func(area WorldMapArea) []SpatialEntry {
+			var entries []SpatialEntry
+			for _, entry := range index.All("") {
+				if area.contains(entry.X, entry.Y) {
+					entries = append(entries, entry)
+				}
+			}
+			return entries
+		}
+	}
+	src := worldMapSource{
+		gen:       worldGen,
+		resources: inArea(ResourceIndex),
+		entities:  inArea(EntityIndex),
+	}
+	var loadErr error
+	src.tile = func(x, y int) (models.WorldTile, bool) {
+		c, offset := worldChunkFor(x, y)
+		chunk, err := loadWorldChunk(c)
+		if err != nil {
+			loadErr = err
+			return models.WorldTile{}, false
+		}
+		worldChunkCacheMu.RLock()
+		tile, _ := decodeWorldTile(chunk, offset)
+		stored := worldChunkStored[c]
+		worldChunkCacheMu.RUnlock()
+		return tile, stored
+	}
+	img, err := renderWorldMap(src, opts)
+	if err == nil {
+		err = loadErr
+	}
+	return img, err
+}
+
+// RenderWorldSnapshotMap draws the world saved in a snapshot. Tiles the snapshot does not
+// hold are generated from its config, and darkened, as for chunks that are not stored.
+func RenderWorldSnapshotMap(snapshot *WorldSnapshot, opts WorldMapOptions) (*image.RGBA, error) {
+	cfg := DefaultWorldGenConfig()
+	if snapshot.WorldGen != nil {
+		cfg = *snapshot.WorldGen
+	} else if snapshot.WorldSize > 0 {
+		cfg.WorldSize = snapshot.WorldSize
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("snapshot world generation config: %v", err)
+	}
+	gen := newWorldGenerator(cfg)
+	if len(snapshot.Sanctuaries) > 0 {
+		gen.setFixedSanctuaries(snapshot.Sanctuaries)
+	}
+
+	tiles := make(map[pathPoint]models.WorldTile, len(snapshot.Tiles))
+	chunks := make(map[worldChunkCoord]bool)
+	for _, t := range snapshot.Tiles {
+		tiles[pathPoint{t.X, t.Y}] = models.WorldTile{Type: t.Type, Health: t.Health, IsSanctuary: t.Sanctuary, Height: t.Height}
+		c, _ := worldChunkFor(t.X, t.Y)
+		chunks[c] = true
+	}
+	src := worldMapSource{gen: gen}
+	src.tile = func(x, y int) (models.WorldTile, bool) {
+		c, _ := worldChunkFor(x, y)
+		if tile, ok := tiles[pathPoint{x, y}]; ok {
+			return tile, chunks[c]
+		}
+		if !isZoneLayer(zoneAt(x, y)) {
+			return models.WorldTile{Type: string(TileTypeDungeonWall)}, false // No dungeon is live in a snapshot
+		}
+		return gen.tile(x, y, gen.sanctuariesNear(c)), chunks[c]
+	}
+	return renderWorldMap(src, opts)
+}
+
+// renderWorldMap draws an area of a world and the overlays asked for.
+func renderWorldMap(src worldMapSource, opts WorldMapOptions) (*image.RGBA, error) {
+	area := WorldMapArea{-src.gen.cfg.WorldSize, -src.gen.cfg.WorldSize, src.gen.cfg.WorldSize, src.gen.cfg.WorldSize}
+	if opts.Area != nil {
+		area = *opts.Area
+	}
+	scale := max(opts.Scale, 1)
+	width, height := area.MaxX-area.MinX+1, area.MaxY-area.MinY+1
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("area %d,%d to %d,%d is empty", area.MinX, area.MinY, area.MaxX, area.MaxY)
+	}
+	if pixels := int64(width) * int64(height) * int64(scale) * int64(scale); pixels > worldMapMaxPixels {
+		return nil, fmt.Errorf("a %dx%d map at scale %d is too large; draw a smaller area", width, height, scale)
+	}
+
+	m := &worldMap{img: image.NewRGBA(image.Rect(0, 0, width*scale, height*scale)), area: area, scale: scale}
+	tiles := make([]models.WorldTile, width*height)
+	for y := area.MinY; y <= area.MaxY; y++ {
+		for x := area.MinX; x <= area.MaxX; x++ {
+			tile, stored := src.tile(x, y)
+			tiles[(y-area.MinY)*width+(x-area.MinX)] = tile
+			c, ok := worldMapTileColors[TileType(tile.Type)]
+			if !ok {
+				c = worldMapUnknownColor
+			}
+			if !stored {
+				c = darken(c)
+			}
+			m.fill(x, y, c)
+		}
+	}
+	tileAt := func(x, y int) TileType {
+		return TileType(tiles[(y-area.MinY)*width+(x-area.MinX)].Type)
+	}
+
+	for _, overlay := range WorldMapOverlays {
+		if !slices.Contains(opts.Overlays, overlay) {
+			continue
+		}
+		switch overlay {
+		case WorldMapOverlaySpawns:
+			drawWorldMapSpawns(m, src.gen, tileAt)
+		case WorldMapOverlaySanctuaries:
+			reach := src.gen.sanctuaryReach()
+			for _, s := range src.gen.sanctuariesIn(area.MinX-reach, area.MinY-reach, area.MaxX+reach, area.MaxY+reach) {
+				m.circle(s.X, s.Y, s.Radius, worldMapSanctuaryColor)
+				m.marker(s.X, s.Y, worldMapSanctuaryColor)
+			}
+		case WorldMapOverlayStructures:
+			for y := area.MinY; y <= area.MaxY; y++ {
+				for x := area.MinX; x <= area.MaxX; x++ {
+					if TileDefs[tileAt(x, y)].IsStructure {
+						m.fill(x, y, worldMapStructureColor)
+					}
+				}
+			}
+		case WorldMapOverlayResources:
+			var resources []SpatialEntry
+			if src.resources != nil {
+				resources = src.resources(area)
+			} else {
+				for y := area.MinY; y <= area.MaxY; y++ {
+					for x := area.MinX; x <= area.MaxX; x++ {
+						if tileType := tileAt(x, y); TileDefs[tileType].IsGatherable {
+							resources = append(resources, SpatialEntry{Kind: string(tileType), X: x, Y: y})
+						}
+					}
+				}
+			}
+			for _, r := range resources {
+				c, ok := worldMapResourceColors[TileType(r.Kind)]
+				if !ok {
+					c = worldMapUnknownColor
+				}
+				m.marker(r.X, r.Y, c)
+			}
+		case WorldMapOverlayEntities:
+			if src.entities == nil {
+				continue
+			}
+			for _, e := range src.entities(area) {
+				c, ok := worldMapEntityColors[e.Kind]
+				if !ok {
+					c = worldMapUnknownColor
+				}
+				m.marker(e.X, e.Y, c)
+			}
+		}
+	}
+	return m.img, nil
+}
+
+// drawWorldMapSpawns draws the borders between biomes, and marks each tile where the
+// generator places a resource, which the resource spawner refills, that has none.
+func drawWorldMapSpawns(m *worldMap, gen *worldGenerator, tileAt func(x, y int) TileType) {
+	area := m.area
+	var sanctuaries []Sanctuary
+	lastChunk := worldChunkCoord{CX: math.MinInt}
+	for y := area.MinY; y <= area.MaxY; y++ {
+		for x := area.MinX; x <= area.MaxX; x++ {
+			if !isZoneLayer(zoneAt(x, y)) {
+				continue
+			}
+			biome := gen.biome(x, y)
+			if (x < area.MaxX && gen.biome(x+1, y) != biome) || (y < area.MaxY && gen.biome(x, y+1) != biome) {
+				m.fill(x, y, worldMapBorderColor)
+			}
+
+			if c, _ := worldChunkFor(x, y); c != lastChunk {
+				sanctuaries, lastChunk = gen.sanctuariesNear(c), c
+			}
+			generated := TileType(gen.tile(x, y, sanctuaries).Type)
+			if TileDefs[generated].IsGatherable && tileAt(x, y) != generated {
+				m.marker(x, y, worldMapSpawnColor)
+			}
+		}
+	}
+}
+
+// worldMap is a world map being drawn.
+type worldMap struct {
+	img   *image.RGBA
+	area  WorldMapArea
+	scale int
+}
+
+// fill paints the whole block of a tile.
+func (m *worldMap) fill(x, y int, c color.RGBA) {
+	if !m.area.contains(x, y) {
+		return
+	}
+	px, py := (x-m.area.MinX)*m.scale, (y-m.area.MinY)*m.scale
+	for dy := 0; dy < m.scale; dy++ {
+		for dx := 0; dx < m.scale; dx++ {
+			m.img.SetRGBA(px+dx, py+dy, c)
+		}
+	}
+}
+
+// marker paints the middle of a tile's block, leaving the tile showing around it once
+// blocks are large enough.
+func (m *worldMap) marker(x, y int, c color.RGBA) {
+	if !m.area.contains(x, y) {
+		return
+	}
+	size := max(1, (m.scale+1)/2)
+	inset := (m.scale - size) / 2
+	px, py := (x-m.area.MinX)*m.scale+inset, (y-m.area.MinY)*m.scale+inset
+	for dy := 0; dy < size; dy++ {
+		for dx := 0; dx < size; dx++ {
+			m.img.SetRGBA(px+dx, py+dy, c)
+		}
+	}
+}
+
+// circle paints the tiles on a circle.
+func (m *worldMap) circle(cx, cy, radius int, c color.RGBA) {
+	for dy := -radius - 1; dy <= radius+1; dy++ {
+		for dx := -radius - 1; dx <= radius+1; dx++ {
+			if int(math.Round(math.Sqrt(float64(dx*dx+dy*dy)))) == radius {
+				m.fill(cx+dx, cy+dy, c)
+			}
+		}
+	}
+}
+
+// darken returns a color at half its brightness.
+func darken(c color.RGBA) color.RGBA {
+	return color.RGBA{c.R / 2, c.G / 2, c.B / 2, c.A}
+}
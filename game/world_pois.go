@@ -0,0 +1,37 @@
+package game
+
+// poiAt returns the point of interest at a coordinate, or "" if there is none. Like
+// dungeon entrances, each cell may have one, at a random point of it that is on the floor
+// of its biome; which one is weighted among those found in the biome.
+func (g *worldGenerator) poiAt(x, y int) TileType {
+	c := g.cfg
+	if !c.POIs || !isZoneLayer(zoneAt(x, y)) {
+		return ""
+	}
+	cx, cy := floorDiv(x, c.POICellSize), floorDiv(y, c.POICellSize)
+	if g.random(cx, cy, worldGenSaltPOI) >= c.POIChance {
+		return ""
+	}
+	px := cx*c.POICellSize + int(g.random(cx, cy, worldGenSaltPOIX)*float64(c.POICellSize))
+	py := cy*c.POICellSize + int(g.random(cx, cy, worldGenSaltPOIY)*float64(c.POICellSize))
+	if x != px || y != py {
+		return ""
+	}
+	biome := g.biome(x, y)
+	if g.naturalTile(x, y) != BiomeDefs[biome].Floor {
+		return ""
+	}
+
+	pois := biomePOIs(biome)
+	total := 0.0
+	for _, poi := range pois {
+		total += POIDefs[poi].Weight
+	}
+	pick := g.random(cx, cy, worldGenSaltPOIType) * total
+	for _, poi := range pois {
+		if pick -= POIDefs[poi].Weight; pick < 0 {
+			return poi
+		}
+	}
+	return ""
+}
@@ -0,0 +1,171 @@
+package game
+
+// Rivers are traced cell by cell, like sanctuaries, so that the rivers crossing any
+// chunk can be found without generating the rest of the world. Each cell is a square
+// RiverCellSize tiles wide that may hold one source on high ground. From there the river
+// runs down the height map, always onto its lowest neighbour, until it reaches a lake or
+// pools in a hollow. Rivers that meet follow the same course from then on.
+type riverCell struct{ X, Y int }
+
+// riverMaxPools is how many hollows a river may pool in and spill over from before it
+// ends in the next one.
+const riverMaxPools = 3
+
+// height returns the height map at a coordinate, in [-1, 1].
+func (g *worldGenerator) height(x, y int) float64 {
+	return g.elevation.Noise2D((float64(x)+noiseOffset)/g.cfg.HeightScale, (float64(y)+noiseOffset)/g.cfg.HeightScale)
+}
+
+// isLake reports whether the height map is low enough at a coordinate to hold a lake.
+func (g *worldGenerator) isLake(x, y int) bool {
+	return g.cfg.Rivers && g.height(x, y) < g.cfg.LakeThreshold
+}
+
+// river returns the tiles of the river rising in a cell, if it has one. Rivers are
+// cached since every chunk they cross needs them.
+func (g *worldGenerator) river(cell riverCell) []pathPoint {
+	g.featureMu.Lock()
+	cached, ok := g.rivers[cell]
+	g.featureMu.Unlock()
+	if ok {
+		return cached
+	}
+
+	tiles := g.traceRiver(cell)
+
+	g.featureMu.Lock()
+	g.rivers[cell] = tiles
+	g.featureMu.Unlock()
+	return tiles
+}
+
+func (g *worldGenerator) traceRiver(cell riverCell) []pathPoint {
+	c := g.cfg
+	if g.random(cell.X, cell.Y, worldGenSaltRiver) >= c.RiverChance {
+		return nil
+	}
+	p := pathPoint{
+		cell.X*c.RiverCellSize + int(g.random(cell.X, cell.Y, worldGenSaltRiverX)*float64(c.RiverCellSize)),
+		cell.Y*c.RiverCellSize + int(g.random(cell.X, cell.Y, worldGenSaltRiverY)*float64(c.RiverCellSize)),
+	}
+	h := g.height(p.X, p.Y)
+	if h < c.RiverSourceThreshold {
+		return nil
+	}
+
+	var tiles []pathPoint
+	visited := make(map[pathPoint]bool)
+	pools := 0
+	for length := 0; length < c.RiverMaxLength && h >= c.LakeThreshold; length++ {
+		tiles = append(tiles, p)
+		visited[p] = true
+		if length > c.RiverMaxLength/3 {
+			// Rivers widen as they run down
+			tiles = append(tiles, pathPoint{p.X + 1, p.Y}, pathPoint{p.X, p.Y + 1})
+		}
+
+		// Run onto the lowest neighbour not yet in the river
+		next, nextH, found := p, 0.0, false
+		for _, d := range pathDirections {
+			q := pathPoint{p.X + d[0], p.Y + d[1]}
+			if visited[q] {
+				continue
+			}
+			if qh := g.height(q.X, q.Y); !found || qh < nextH {
+				next, nextH, found = q, qh, true
+			}
+		}
+		if !found {
+			break
+		}
+		if nextH > h {
+			// A hollow: the river pools into a small lake, and runs on from the lowest
+			// tile around it unless it has pooled too often
+			if pools++; pools > riverMaxPools {
+				tiles = append(tiles, g.pool(p)...)
+				break
+			}
+			found = false
+			for _, q := range g.pool(p) {
+				visited[q] = true
+				tiles = append(tiles, q)
+				for _, d := range pathDirections {
+					rim := pathPoint{q.X + d[0], q.Y + d[1]}
+					if visited[rim] {
+						continue
+					}
+					if rh := g.height(rim.X, rim.Y); !found || rh < nextH {
+						next, nextH, found = rim, rh, true
+					}
+				}
+			}
+			if !found {
+				break
+			}
+		}
+		if next.X != p.X && next.Y != p.Y {
+			// Fill the corner of a diagonal step, so nothing slips through the river
+			tiles = append(tiles, pathPoint{next.X, p.Y})
+		}
+		p, h = next, nextH
+	}
+	return tiles
+}
+
+// pool returns the tiles of the small lake a river pools into around p.
+func (g *worldGenerator) pool(p pathPoint) []pathPoint {
+	r := g.cfg.PoolRadius
+	var tiles []pathPoint
+	for dx := -r; dx <= r; dx++ {
+		for dy := -r; dy <= r; dy++ {
+			if dx*dx+dy*dy <= r*r {
+				tiles = append(tiles, pathPoint{p.X + dx, p.Y + dy})
+			}
+		}
+	}
+	return tiles
+}
+
+// riverReach is the furthest a river's tiles reach from its cell.
+func (g *worldGenerator) riverReach() int {
+	return g.cfg.RiverMaxLength + g.cfg.PoolRadius + 1
+}
+
+// riversIn returns the river tiles in a chunk, which are cached since every tile of the
+// chunk is checked against them.
+func (g *worldGenerator) riversIn(c worldChunkCoord) map[pathPoint]bool {
+	g.featureMu.Lock()
+	cached, ok := g.riverChunks[c]
+	g.featureMu.Unlock()
+	if ok {
+		return cached
+	}
+
+	tiles := make(map[pathPoint]bool)
+	minX, minY := c.CX*WorldChunkSize, c.CY*WorldChunkSize
+	maxX, maxY := minX+WorldChunkSize-1, minY+WorldChunkSize-1
+	reach, size := g.riverReach(), g.cfg.RiverCellSize
+	for cx := floorDiv(minX-reach, size); cx <= floorDiv(maxX+reach, size); cx++ {
+		for cy := floorDiv(minY-reach, size); cy <= floorDiv(maxY+reach, size); cy++ {
+			for _, p := range g.river(riverCell{cx, cy}) {
+				if p.X >= minX && p.X <= maxX && p.Y >= minY && p.Y <= maxY {
+					tiles[p] = true
+				}
+			}
+		}
+	}
+
+	g.featureMu.Lock()
+	g.riverChunks[c] = tiles
+	g.featureMu.Unlock()
+	return tiles
+}
+
+// isRiver reports whether a river runs through a coordinate.
+func (g *worldGenerator) isRiver(x, y int) bool {
+	if !g.cfg.Rivers {
+		return false
+	}
+	c, _ := worldChunkFor(x, y)
+	return g.riversIn(c)[pathPoint{x, y}]
+}
@@ -0,0 +1,223 @@
+package game
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Roads join each sanctuary to its neighbours: two sanctuaries closer than
+// RoadMaxDistance are joined unless a third is closer to both of them than they are to
+// each other, in which case the roads through it serve. Each road is routed over the
+// terrain between its ends, preferring open ground, clearing trees and rocks in its way
+// and bridging rivers rather than lakes.
+const (
+	// roadSearchMargin is how far a road may stray outside the rectangle spanned by
+	// its ends, and roadSearchNodes the most tiles routing one may expand.
+	roadSearchMargin = 32
+	roadSearchNodes  = 60000
+
+	// The cost of laying a road over a tile: open ground, a tree or rock that must be
+	// cleared, a river that must be bridged, and the wider water of lakes and ponds.
+	roadCostOpen     = 1
+	roadCostObstacle = 2
+	roadCostRiver    = 4
+	roadCostWater    = 12
+)
+
+// sanctuaryPair is the two ends of a road, in the order given by roadEnds.
+type sanctuaryPair struct{ A, B pathPoint }
+
+// roadEnds orders the ends of a road, so that each road is routed once.
+func roadEnds(a, b Sanctuary) sanctuaryPair {
+	p, q := pathPoint{a.X, a.Y}, pathPoint{b.X, b.Y}
+	if q.X < p.X || (q.X == p.X && q.Y < p.Y) {
+		p, q = q, p
+	}
+	return sanctuaryPair{p, q}
+}
+
+// roadBounds is the area the road between a pair may cover.
+func (p sanctuaryPair) roadBounds() pathBounds {
+	return pathBounds{
+		min(p.A.X, p.B.X) - roadSearchMargin,
+		min(p.A.Y, p.B.Y) - roadSearchMargin,
+		max(p.A.X, p.B.X) + roadSearchMargin,
+		max(p.A.Y, p.B.Y) + roadSearchMargin,
+	}
+}
+
+// joinsRoad reports whether a road joins two sanctuaries.
+func (g *worldGenerator) joinsRoad(pair sanctuaryPair) bool {
+	dx, dy := pair.A.X-pair.B.X, pair.A.Y-pair.B.Y
+	distSq := dx*dx + dy*dy
+	if distSq > g.cfg.RoadMaxDistance*g.cfg.RoadMaxDistance {
+		return false
+	}
+	// Anything closer to both lies within that distance of each
+	d := int(math.Sqrt(float64(distSq)))
+	for _, s := range g.sanctuariesIn(max(pair.A.X, pair.B.X)-d, max(pair.A.Y, pair.B.Y)-d, min(pair.A.X, pair.B.X)+d, min(pair.A.Y, pair.B.Y)+d) {
+		p := pathPoint{s.X, s.Y}
+		if p == pair.A || p == pair.B {
+			continue
+		}
+		ax, ay, bx, by := p.X-pair.A.X, p.Y-pair.A.Y, p.X-pair.B.X, p.Y-pair.B.Y
+		if ax*ax+ay*ay < distSq && bx*bx+by*by < distSq {
+			return false
+		}
+	}
+	return true
+}
+
+// road returns the tiles of the road between a pair of sanctuaries, or nil if none can
+// be routed. Roads are cached since every chunk they cross needs them.
+func (g *worldGenerator) road(pair sanctuaryPair) []pathPoint {
+	g.featureMu.Lock()
+	cached, ok := g.roads[pair]
+	g.featureMu.Unlock()
+	if ok {
+		return cached
+	}
+
+	tiles := g.routeRoad(pair)
+
+	g.featureMu.Lock()
+	g.roads[pair] = tiles
+	g.featureMu.Unlock()
+	return tiles
+}
+
+// roadCost returns the cost of laying a road over a tile.
+func (g *worldGenerator) roadCost(x, y int) float64 {
+	tileType := g.terrainTile(x, y)
+	props := TileDefs[tileType]
+	switch {
+	case g.isRiver(x, y):
+		return roadCostRiver
+	case tileType == BiomeDefs[g.biome(x, y)].Water:
+		return roadCostWater
+	case props.IsCollidable:
+		return roadCostObstacle
+	}
+	return roadCostOpen
+}
+
+// routeRoad finds the cheapest road between a pair of sanctuaries with A* over the
+// terrain.
+func (g *worldGenerator) routeRoad(pair sanctuaryPair) []pathPoint {
+	bounds := pair.roadBounds()
+	end := &Node{X: pair.B.X, Y: pair.B.Y}
+	start := &Node{X: pair.A.X, Y: pair.A.Y}
+	start.H = octileDistance(start, end)
+	start.F = start.H
+
+	costs := make(map[pathPoint]float64)
+	cost := func(p pathPoint) float64 {
+		c, ok := costs[p]
+		if !ok {
+			c = g.roadCost(p.X, p.Y)
+			costs[p] = c
+		}
+		return c
+	}
+
+	open := &PriorityQueue{}
+	heap.Push(open, start)
+	nodes := map[pathPoint]*Node{pair.A: start}
+	closed := make(map[pathPoint]bool)
+	for expanded := 0; open.Len() > 0 && expanded < roadSearchNodes; expanded++ {
+		current := heap.Pop(open).(*Node)
+		p := pathPoint{current.X, current.Y}
+		if p == pair.B {
+			var tiles []pathPoint
+			for n := current; n != nil; n = n.Parent {
+				tiles = append(tiles, pathPoint{n.X, n.Y})
+			}
+			return tiles
+		}
+		closed[p] = true
+
+		for _, d := range pathDirections {
+			next := pathPoint{p.X + d[0], p.Y + d[1]}
+			if !bounds.contains(next) || closed[next] {
+				continue
+			}
+			step := cost(next)
+			if d[0] != 0 && d[1] != 0 {
+				step *= math.Sqrt2
+			}
+			tentative := current.G + step
+			node, seen := nodes[next]
+			if seen && tentative >= node.G {
+				continue
+			}
+			if !seen {
+				node = &Node{X: next.X, Y: next.Y}
+				node.H = octileDistance(node, end)
+				nodes[next] = node
+			}
+			node.Parent = current
+			node.G = tentative
+			node.F = tentative + node.H
+			if seen && node.index >= 0 {
+				heap.Fix(open, node.index)
+			} else {
+				heap.Push(open, node)
+			}
+		}
+	}
+	return nil
+}
+
+// roadsIn returns the road tiles in a chunk, which are cached since every tile of the
+// chunk is checked against them.
+func (g *worldGenerator) roadsIn(c worldChunkCoord) map[pathPoint]bool {
+	g.featureMu.Lock()
+	cached, ok := g.roadChunks[c]
+	g.featureMu.Unlock()
+	if ok {
+		return cached
+	}
+
+	tiles := make(map[pathPoint]bool)
+	chunk := pathBounds{c.CX * WorldChunkSize, c.CY * WorldChunkSize, (c.CX+1)*WorldChunkSize - 1, (c.CY+1)*WorldChunkSize - 1}
+	reach := g.cfg.RoadMaxDistance + roadSearchMargin
+	sanctuaries := g.sanctuariesIn(chunk.MinX-reach, chunk.MinY-reach, chunk.MaxX+reach, chunk.MaxY+reach)
+	for i, a := range sanctuaries {
+		for _, b := range sanctuaries[i+1:] {
+			pair := roadEnds(a, b)
+			area := pair.roadBounds()
+			if area.MaxX < chunk.MinX || area.MinX > chunk.MaxX || area.MaxY < chunk.MinY || area.MinY > chunk.MaxY {
+				continue
+			}
+			if !g.joinsRoad(pair) {
+				continue
+			}
+			for _, p := range g.road(pair) {
+				if chunk.contains(p) {
+					tiles[p] = true
+				}
+			}
+		}
+	}
+
+	g.featureMu.Lock()
+	g.roadChunks[c] = tiles
+	g.featureMu.Unlock()
+	return tiles
+}
+
+// roadTile returns the road tile at a coordinate: a bridge where it crosses water, or
+// nothing if no road runs there. Sanctuaries are not considered.
+func (g *worldGenerator) roadTile(x, y int) TileType {
+	if !g.cfg.Roads {
+		return ""
+	}
+	c, _ := worldChunkFor(x, y)
+	if !g.roadsIn(c)[pathPoint{x, y}] {
+		return ""
+	}
+	if g.terrainTile(x, y) == BiomeDefs[g.biome(x, y)].Water {
+		return TileTypeBridge
+	}
+	return TileTypeRoad
+}
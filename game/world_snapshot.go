@@ -0,0 +1,255 @@
+package game
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mmo-game/game/utils"
+	"mmo-game/models"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// WorldSnapshotFormatVersion is the version of the WorldSnapshot file layout.
+const WorldSnapshotFormatVersion = 1
+
+// WorldSnapshotTile is one tile in a snapshot. Short JSON names keep the file small;
+// the whole document is gzipped on disk as well.
+type WorldSnapshotTile struct {
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Type      string `json:"t"`
+	Health    int    `json:"h,omitempty"`
+	Sanctuary bool   `json:"s,omitempty"`
+	Height    int    `json:"z,omitempty"`
+}
+
+// WorldSnapshot is the persistent part of the world. Player-built structures (walls,
+// fires) and resource nodes are tiles, so they are captured by Tiles; everything else
+// (resource positions, spawn points, wall locks, the collision grid) is derived from
+// the tiles when the snapshot is restored.
+type WorldSnapshot struct {
+	FormatVersion int   `json:"formatVersion"`
+	CreatedAt     int64 `json:"createdAt"`
+	WorldSize     int   `json:"worldSize"`
+	// WorldGen is the config the world was generated from, if it was saved.
+	WorldGen    *WorldGenConfig     `json:"worldGen,omitempty"`
+	Sanctuaries []Sanctuary         `json:"sanctuaries"`
+	ActiveDecay []string            `json:"activeDecay"`
+	Tiles       []WorldSnapshotTile `json:"tiles"`
+}
+
+// CaptureWorldSnapshot reads the current world from Redis.
+func CaptureWorldSnapshot() (*WorldSnapshot, error) {
+	worldTiles, err := loadWorldTiles()
+	if err != nil {
+		return nil, err
+	}
+	activeDecay, err := rdb.SMembers(ctx, string(RedisKeyActiveDecay)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(activeDecay)
+	worldGenConfig, err := readWorldGenConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &WorldSnapshot{
+		FormatVersion: WorldSnapshotFormatVersion,
+		CreatedAt:     time.Now().UnixMilli(),
+		WorldSize:     WorldSize,
+		WorldGen:      worldGenConfig,
+		Sanctuaries:   knownSanctuaries(),
+		ActiveDecay:   activeDecay,
+		Tiles:         make([]WorldSnapshotTile, 0, len(worldTiles)),
+	}
+	for coord, tile := range worldTiles {
+		x, y := utils.ParseCoordKey(coord)
+		snapshot.Tiles = append(snapshot.Tiles, WorldSnapshotTile{
+			X:         x,
+			Y:         y,
+			Type:      tile.Type,
+			Health:    tile.Health,
+			Sanctuary: tile.IsSanctuary,
+			Height:    tile.Height,
+		})
+	}
+	// Sort so two snapshots of the same world produce identical files.
+	sort.Slice(snapshot.Tiles, func(i, j int) bool {
+		if snapshot.Tiles[i].X != snapshot.Tiles[j].X {
+			return snapshot.Tiles[i].X < snapshot.Tiles[j].X
+		}
+		return snapshot.Tiles[i].Y < snapshot.Tiles[j].Y
+	})
+	return snapshot, nil
+}
+
+// WriteWorldSnapshot encodes a snapshot as gzipped JSON.
+func WriteWorldSnapshot(w io.Writer, snapshot *WorldSnapshot) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(snapshot); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadWorldSnapshot decodes a snapshot written by WriteWorldSnapshot.
+func ReadWorldSnapshot(r io.Reader) (*WorldSnapshot, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var snapshot WorldSnapshot
+	if err := json.NewDecoder(zr).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.FormatVersion < 1 || snapshot.FormatVersion > WorldSnapshotFormatVersion {
+		return nil, fmt.Errorf("unsupported world snapshot format version %d", snapshot.FormatVersion)
+	}
+	return &snapshot, nil
+}
+
+// SaveWorldSnapshotFile captures the world and writes it to path.
+func SaveWorldSnapshotFile(path string) (*WorldSnapshot, error) {
+	snapshot, err := CaptureWorldSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := WriteWorldSnapshot(f, snapshot); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return snapshot, f.Close()
+}
+
+// LoadWorldSnapshotFile reads a snapshot from path.
+func LoadWorldSnapshotFile(path string) (*WorldSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadWorldSnapshot(f)
+}
+
+// RestoreWorldSnapshot replaces the world in Redis with the snapshot and rebuilds every
+// derived index: resource positions, potential spawn points, wall locks, fire expiry
+// timers and the in-memory CollisionGrid. Entity tile locks are left alone; a wall that
+// lands on an occupied tile is logged and locked by the lock reaper once it is free.
+func RestoreWorldSnapshot(snapshot *WorldSnapshot) error {
+	// A snapshot with its generation config brings its own world size; one without
+	// must fit the server's.
+	if snapshot.WorldGen == nil && snapshot.WorldSize != WorldSize {
+		return fmt.Errorf("snapshot world size %d does not match server world size %d", snapshot.WorldSize, WorldSize)
+	}
+	if snapshot.WorldGen != nil {
+		if err := snapshot.WorldGen.Validate(); err != nil {
+			return fmt.Errorf("snapshot world generation config: %v", err)
+		}
+		if snapshot.WorldGen.WorldSize != snapshot.WorldSize {
+			return fmt.Errorf("snapshot world generation config is for world size %d, not %d", snapshot.WorldGen.WorldSize, snapshot.WorldSize)
+		}
+	}
+
+	if err := clearWorldState(); err != nil {
+		return err
+	}
+
+	chunks := make(map[worldChunkCoord][]byte)
+	for _, t := range snapshot.Tiles {
+		putWorldTile(chunks, t.X, t.Y, models.WorldTile{Type: t.Type, Health: t.Health, IsSanctuary: t.Sanctuary, Height: t.Height})
+	}
+	if err := writeWorldChunks(chunks); err != nil {
+		return err
+	}
+	if len(snapshot.ActiveDecay) > 0 {
+		members := make([]interface{}, len(snapshot.ActiveDecay))
+		for i, coord := range snapshot.ActiveDecay {
+			members[i] = coord
+		}
+		if err := rdb.SAdd(ctx, string(RedisKeyActiveDecay), members...).Err(); err != nil {
+			return err
+		}
+	}
+
+	if len(snapshot.Sanctuaries) > 0 {
+		setSanctuaries(snapshot.Sanctuaries)
+	}
+	saveSanctuaries()
+	// Adopt the snapshot's generation config before the spawn points are re-indexed,
+	// since they are regenerated from it, as are the tiles the snapshot does not cover.
+	if snapshot.WorldGen != nil {
+		if err := SetWorldGenConfig(*snapshot.WorldGen); err != nil {
+			return fmt.Errorf("snapshot world generation config: %v", err)
+		}
+		saveWorldGenConfig()
+	}
+	worldGen.setFixedSanctuaries(knownSanctuaries())
+
+	for _, t := range snapshot.Tiles {
+		switch TileType(t.Type) {
+		case TileTypeWoodenWall:
+			coordKey := strconv.Itoa(t.X) + "," + strconv.Itoa(t.Y)
+			wasSet, err := LockTileForWorldObject(t.X, t.Y)
+			if err != nil || !wasSet {
+				log.Printf("Restored wall at %s is on an occupied tile; it will be locked once the occupant leaves.", coordKey)
+			}
+		case TileTypeFire:
+			setResourcePosition(rdb, TileTypeFire, t.X, t.Y)
+			scheduleFireExpiration(t.X, t.Y)
+		}
+	}
+
+	IndexWorldResources()
+	IndexPotentialSpawnPoints()
+	InitializeCollisionGrid()
+
+	log.Printf("Restored world snapshot with %d tiles from %s.", len(snapshot.Tiles), time.UnixMilli(snapshot.CreatedAt).Format(time.RFC3339))
+	return nil
+}
+
+// clearWorldState removes the world tiles and every index derived from them.
+func clearWorldState() error {
+	if err := deleteWorldTiles(); err != nil {
+		return err
+	}
+
+	keys := []string{string(RedisKeyResourcePositions), string(RedisKeyActiveDecay), string(RedisKeyWorldGenConfig)}
+	iter := rdb.Scan(ctx, 0, "potential_spawns:*", 500).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if err := rdb.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+	ResourceIndex.Reset()
+	publishSpatialChange(rdb, spatialChange{Reset: true})
+
+	// Only world-object locks belong to the world; entity locks stay with their entities.
+	locks, err := GetTileLocks()
+	if err != nil {
+		return err
+	}
+	for coordKey, owner := range locks {
+		if owner == string(RedisKeyLockWorldObject) {
+			x, y := utils.ParseCoordKey(coordKey)
+			UnlockTileForWorldObject(x, y)
+		}
+	}
+	return nil
+}
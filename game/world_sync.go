@@ -0,0 +1,66 @@
+package game
+
+import (
+	"encoding/json"
+	"log"
+	"mmo-game/models"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// nodeID identifies this server process on the world tile stream, so it can skip its
+// own changes, which it has already applied.
+var nodeID = uuid.New().String()
+
+// worldTileChange is a message on RedisKeyWorldTileUpdates. A change with Reset set
+// means the whole world was replaced, and one with Zone set that the zone was torn
+// down; the other fields are then unused.
+type worldTileChange struct {
+	Node  string           `json:"node"`
+	X     int              `json:"x"`
+	Y     int              `json:"y"`
+	Tile  models.WorldTile `json:"tile"`
+	Reset bool             `json:"reset,omitempty"`
+	Zone  ZoneID           `json:"zone,omitempty"`
+}
+
+// publishWorldTileChange announces a tile change to the other server nodes through c,
+// so it is published alongside the write.
+func publishWorldTileChange(c redis.Cmdable, change worldTileChange) {
+	change.Node = nodeID
+	changeJSON, err := json.Marshal(change)
+	if err != nil {
+		return
+	}
+	c.Publish(ctx, string(RedisKeyWorldTileUpdates), string(changeJSON))
+}
+
+// StartWorldTileSync applies tile changes made by other server nodes to this node's chunk
+// cache and collision grid.
+func StartWorldTileSync() {
+	pubsub := rdb.Subscribe(ctx, string(RedisKeyWorldTileUpdates))
+	defer pubsub.Close()
+
+	for msg := range pubsub.Channel() {
+		var change worldTileChange
+		if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+			log.Printf("Ignoring unreadable world tile change: %v", err)
+			continue
+		}
+		if change.Node == nodeID {
+			continue
+		}
+		if change.Reset {
+			resetWorldChunkCache()
+			InitializeCollisionGrid()
+			continue
+		}
+		if change.Zone != "" {
+			forgetDungeon(change.Zone)
+			evictZoneChunks(change.Zone)
+			continue
+		}
+		applyWorldTile(change.X, change.Y, change.Tile)
+	}
+}
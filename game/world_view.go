@@ -0,0 +1,105 @@
+package game
+
+import (
+	"log"
+	"mmo-game/models"
+	"strconv"
+)
+
+// worldViewChunks is how many chunks a player is sent on every side of the chunk they
+// are in. Chunks come into view as the player moves, and are sent in WorldChunkMessages.
+const worldViewChunks = 2
+
+// worldChunksAround returns the chunks within radius chunks of c, c included.
+func worldChunksAround(c worldChunkCoord, radius int) []worldChunkCoord {
+	chunks := make([]worldChunkCoord, 0, (2*radius+1)*(2*radius+1))
+	for cx := c.CX - radius; cx <= c.CX+radius; cx++ {
+		for cy := c.CY - radius; cy <= c.CY+radius; cy++ {
+			chunks = append(chunks, worldChunkCoord{CX: cx, CY: cy})
+		}
+	}
+	return chunks
+}
+
+// storeWorldChunksAround stores the chunk a player stands in and those next to it, so
+// that every tile they can reach or act on is stored before they change it.
+func storeWorldChunksAround(x, y int) {
+	c, _ := worldChunkFor(x, y)
+	for _, near := range worldChunksAround(c, 1) {
+		if err := storeWorldChunk(near); err != nil {
+			log.Printf("Failed to store chunk %d,%d: %v", near.CX, near.CY, err)
+		}
+	}
+}
+
+// worldChunkTiles returns the tiles of a chunk the client cannot draw from its biome
+// map: everything but plain floor. Sanctuary grounds are kept.
+func worldChunkTiles(c worldChunkCoord) map[string]models.WorldTile {
+	tiles := make(map[string]models.WorldTile)
+	chunk, err := loadWorldChunk(c)
+	if err != nil {
+		log.Printf("Failed to load chunk %d,%d: %v", c.CX, c.CY, err)
+		return tiles
+	}
+	forEachChunkTile(c, chunk, func(x, y int, tile models.WorldTile) {
+		// Clients draw the floor of the biome map themselves
+		if TileType(tile.Type) != BiomeDefs[BiomeAt(x, y)].Floor || tile.IsSanctuary {
+			tiles[strconv.Itoa(x)+","+strconv.Itoa(y)] = tile
+		}
+	})
+	return tiles
+}
+
+// worldViewTiles returns the tiles of every chunk in view of a player at (x, y), and the
+// chunks themselves.
+func worldViewTiles(x, y int) (map[string]models.WorldTile, []worldChunkCoord) {
+	c, _ := worldChunkFor(x, y)
+	view := worldChunksAround(c, worldViewChunks)
+	tiles := make(map[string]models.WorldTile)
+	for _, viewed := range view {
+		for coordKey, tile := range worldChunkTiles(viewed) {
+			tiles[coordKey] = tile
+		}
+	}
+	return tiles, view
+}
+
+// visitWorld follows a player from one tile to another. When the move takes them into
+// another chunk, the chunks around them are stored and the chunks that came into view
+// are sent to them, with their minimap. A move into another zone sends them the whole
+// state of that zone instead.
+//
+// Usage:
+//   visitWorld(playerID, currentX, currentY, targetX, targetY)
+func visitWorld(playerID string, fromX, fromY, toX, toY int) {
+	if zoneAt(fromX, fromY) != zoneAt(toX, toY) {
+		// Nothing the player knew of the zone they left is of use in the new one
+		SendToPlayer(playerID, getPlayerState(playerID))
+		return
+	}
+	from, _ := worldChunkFor(fromX, fromY)
+	to, _ := worldChunkFor(toX, toY)
+	if from == to {
+		return
+	}
+	storeWorldChunksAround(toX, toY)
+
+	var viewed []worldChunkCoord
+	for _, c := range worldChunksAround(to, worldViewChunks) {
+		if abs(c.CX-from.CX) <= worldViewChunks && abs(c.CY-from.CY) <= worldViewChunks {
+			continue // Already in view
+		}
+		viewed = append(viewed, c)
+		SendToPlayer(playerID, &models.WorldChunkMessage{
+			Type:   string(ServerEventWorldChunk),
+			X:      c.CX,
+			Y:      c.CY,
+			Tiles:  worldChunkTiles(c),
+			Biomes: chunkBiomeRuns(c),
+		})
+	}
+	// Chunks seen before are sent again too, in case they changed since
+	if discovered := discoverWorldChunks(playerID, viewed); len(discovered) > 0 {
+		SendToPlayer(playerID, minimapMessage(discovered))
+	}
+}
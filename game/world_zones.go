@@ -0,0 +1,77 @@
+package game
+
+import "slices"
+
+// The designed zones beyond the overworld are generated from their ZoneDefs: walls where
+// the zone's wall noise is high, and the terrain of the zone's biome between them. Noise
+// is sampled at local coordinates, since it degrades far from the origin, shifted by the
+// zone's layer so that no zone follows the terrain of another.
+const (
+	// zoneNoiseShift is how far apart the noise of neighbouring layers is sampled.
+	zoneNoiseShift = 1 << 16
+
+	// portalClearing is how far from a portal its clearing of floor reaches, so whoever
+	// arrives by it has somewhere to stand.
+	portalClearing = 2
+)
+
+// zoneTerrainTile determines the tile type of the terrain at a coordinate of a designed
+// zone other than the overworld.
+func (g *worldGenerator) zoneTerrainTile(zone ZoneID, x, y int) TileType {
+	z := ZoneDefs[zone]
+	originX, originY := zoneOrigin(zone)
+	nx := x - originX + slices.Index(zoneLayers, zone)*zoneNoiseShift
+	ny := y - originY
+	if g.zoneWalls.Noise2D((float64(nx)+noiseOffset)/z.WallScale, (float64(ny)+noiseOffset)/z.WallScale) > z.WallThreshold {
+		return z.Wall
+	}
+	return g.biomeTerrain(BiomeDefs[z.Biome], nx, ny)
+}
+
+// portalTile reports whether a portal stands at a coordinate, or else whether it lies in
+// the clearing around one.
+func (g *worldGenerator) portalTile(x, y int) (portal, clearing bool) {
+	if !g.cfg.Zones {
+		return false, false
+	}
+	zone := zoneAt(x, y)
+	for _, p := range ZonePortals {
+		for _, end := range [2]ZoneID{p.From, p.To} {
+			if end != zone {
+				continue
+			}
+			originX, originY := zoneOrigin(zone)
+			dx, dy := abs(x-originX-p.X), abs(y-originY-p.Y)
+			if dx == 0 && dy == 0 {
+				return true, false
+			}
+			if dx <= portalClearing && dy <= portalClearing {
+				clearing = true
+			}
+		}
+	}
+	return false, clearing
+}
+
+// zonePortalAt returns where the portal at a coordinate leads: the map coordinates of
+// the portal at the other end, and its zone.
+func zonePortalAt(x, y int) (destX, destY int, dest ZoneID, ok bool) {
+	zone := zoneAt(x, y)
+	originX, originY := zoneOrigin(zone)
+	for _, p := range ZonePortals {
+		if x-originX != p.X || y-originY != p.Y {
+			continue
+		}
+		switch zone {
+		case p.From:
+			dest = p.To
+		case p.To:
+			dest = p.From
+		default:
+			continue
+		}
+		destOriginX, destOriginY := zoneOrigin(dest)
+		return destOriginX + p.X, destOriginY + p.Y, dest, true
+	}
+	return 0, 0, "", false
+}
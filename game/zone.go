@@ -0,0 +1,122 @@
+package game
+
+import (
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ZoneID identifies a zone of the world: the overworld, another designed zone such as
+// the caves, or an instance such as a dungeon that is made for a party and torn down once
+// it is empty.
+//
+// Every zone lies in its own region of the one tile plane, so a tile's zone follows from
+// its coordinates and movement, collision, tile locks and pathfinding work the same in
+// all of them. The designed zones are layers: bands zoneLayerHeight rows tall, the
+// overworld's ending at dungeonOriginY and each of the others north of the one before.
+// South of dungeonOriginY, each dungeon slot is a column dungeonSpacing tiles wide. Each
+// zone keeps its own stored chunks and entity positions in Redis, and its own AI tick.
+type ZoneID string
+
+const (
+	// ZoneOverworld is the zone players start in, stored under the zone 0 keys.
+	ZoneOverworld ZoneID = "0"
+
+	// ZoneCaves is the cave layer, tunnels through the rock under the overworld.
+	ZoneCaves ZoneID = "caves"
+
+	// ZoneEthereal is the ethereal plane, islands adrift in a void.
+	ZoneEthereal ZoneID = "ethereal"
+)
+
+// zoneLayers lists the designed zones in the order of their layers.
+var zoneLayers = []ZoneID{ZoneOverworld, ZoneCaves, ZoneEthereal}
+
+// zoneLayerHeight is how many rows each designed zone spans. The overworld's layer is
+// centered on the origin. Layer boundaries must be chunk aligned.
+const zoneLayerHeight = 2 * dungeonOriginY
+
+// zoneAt returns the zone a tile belongs to. Rows north of the last layer belong to it.
+func zoneAt(x, y int) ZoneID {
+	if y >= dungeonOriginY {
+		return dungeonZone(floorDiv(x, dungeonSpacing))
+	}
+	layer := floorDiv(dungeonOriginY-1-y, zoneLayerHeight)
+	return zoneLayers[min(layer, len(zoneLayers)-1)]
+}
+
+// zoneOrigin returns where in the map a designed zone's local coordinates start: local
+// (x, y) is map (originX+x, originY+y). The overworld's origin is the map's.
+func zoneOrigin(zone ZoneID) (int, int) {
+	return 0, -slices.Index(zoneLayers, zone) * zoneLayerHeight
+}
+
+// isZoneLayer reports whether a zone is one of the designed zones, which last forever,
+// rather than an instance.
+func isZoneLayer(zone ZoneID) bool {
+	return slices.Contains(zoneLayers, zone)
+}
+
+// zoneOfChunk returns the zone a chunk belongs to. Zone boundaries are chunk aligned.
+func zoneOfChunk(c worldChunkCoord) ZoneID {
+	return zoneAt(c.CX*WorldChunkSize, c.CY*WorldChunkSize)
+}
+
+// zonePositionsKey is the geospatial key of the entity positions in a zone.
+func zonePositionsKey(zone ZoneID) string {
+	return string(RedisKeyZonePositionsPrefix) + string(zone)
+}
+
+// zoneChunkPrefix is the prefix of the keys of a zone's stored chunks.
+func zoneChunkPrefix(zone ZoneID) string {
+	return string(RedisKeyWorldZonePrefix) + string(zone) + ":chunk:"
+}
+
+// zoneChunksKey is the key of the set of a zone's stored chunks.
+func zoneChunksKey(zone ZoneID) string {
+	return string(RedisKeyWorldZonePrefix) + string(zone) + ":chunks"
+}
+
+// entityZone returns the zone an entity is in, going by the EntityIndex. Entities that
+// are not indexed are taken to be in the overworld.
+func entityZone(entityID string) ZoneID {
+	if entry, ok := EntityIndex.Get(entityID); ok {
+		return zoneAt(entry.X, entry.Y)
+	}
+	return ZoneOverworld
+}
+
+// playerZone returns the zone a player is in, as recorded on their hash.
+func playerZone(playerData map[string]string) ZoneID {
+	return ZoneID(playerString(playerData, "zone"))
+}
+
+// dungeonZone returns the zone ID of the dungeon in a slot.
+func dungeonZone(slot int) ZoneID {
+	return ZoneID(string(RedisKeyDungeonPrefix) + strconv.Itoa(slot))
+}
+
+// dungeonSlot returns the slot of a dungeon zone, or false for any other zone.
+func dungeonSlot(zone ZoneID) (int, bool) {
+	s, ok := strings.CutPrefix(string(zone), string(RedisKeyDungeonPrefix))
+	if !ok {
+		return 0, false
+	}
+	slot, err := strconv.Atoi(s)
+	return slot, err == nil
+}
+
+// activeZones returns the designed zones and every live instance.
+func activeZones() []ZoneID {
+	zones := slices.Clone(zoneLayers)
+	members, err := rdb.SMembers(ctx, string(RedisKeyDungeons)).Result()
+	if err != nil {
+		log.Printf("Failed to list live dungeons: %v", err)
+		return zones
+	}
+	for _, member := range members {
+		zones = append(zones, ZoneID(member))
+	}
+	return zones
+}
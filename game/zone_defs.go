@@ -0,0 +1,76 @@
+package game
+
+// ZoneProperties defines how a designed zone is generated and what lives in it.
+type ZoneProperties struct {
+	// Name is what players are told they have arrived in.
+	Name string
+
+	// Biome is the zone's one biome, which gives it its tile palette, resources and NPC
+	// spawn table. The overworld has none: its biomes follow temperature and moisture.
+	Biome BiomeType
+
+	// Wall fills the zone wherever its wall noise, sampled at WallScale, is above
+	// WallThreshold. The open ground between is the biome's terrain, laid out by the
+	// WorldGenConfig thresholds like the overworld's.
+	Wall          TileType
+	WallScale     float64
+	WallThreshold float64
+}
+
+// ZoneDefs is our master map of the designed zones but the overworld, which is
+// generated by the WorldGenConfig alone.
+var ZoneDefs map[ZoneID]ZoneProperties
+
+// ZonePortal joins two designed zones. A portal stands at the same local coordinates in
+// both (see zoneOrigin), with a clearing of their floor around it, and stepping into
+// either leads beside the other.
+type ZonePortal struct {
+	From, To ZoneID
+	X, Y     int
+}
+
+// ZonePortals lists every portal between the designed zones.
+var ZonePortals []ZonePortal
+
+func init() {
+	ZoneDefs = make(map[ZoneID]ZoneProperties)
+
+	ZoneDefs[ZoneCaves] = ZoneProperties{
+		Name:          "the caves",
+		Biome:         BiomeCaverns,
+		Wall:          TileTypeCaveWall,
+		WallScale:     18,
+		WallThreshold: 0.05,
+	}
+	ZoneDefs[ZoneEthereal] = ZoneProperties{
+		Name:          "the ethereal plane",
+		Biome:         BiomeEthereal,
+		Wall:          TileTypeRift,
+		WallScale:     40,
+		WallThreshold: 0.2,
+	}
+
+	ZonePortals = []ZonePortal{
+		{From: ZoneOverworld, To: ZoneCaves, X: 36, Y: -28},
+		{From: ZoneCaves, To: ZoneEthereal, X: -60, Y: 44},
+	}
+}
+
+// zoneName returns the name of a designed zone, as players are told it.
+func zoneName(zone ZoneID) string {
+	if zone == ZoneOverworld {
+		return "the overworld"
+	}
+	return ZoneDefs[zone].Name
+}
+
+// biomeZone returns the zone a biome belongs to. Biomes no designed zone claims are the
+// overworld's.
+func biomeZone(biome BiomeType) ZoneID {
+	for zone, props := range ZoneDefs {
+		if props.Biome == biome {
+			return zone
+		}
+	}
+	return ZoneOverworld
+}
@@ -268,6 +268,26 @@ func (c *Client) readPump() {
 						c.send <- wsMsg.Payload
 					}
 				}
+			case game.ClientEventWalkTo:
+				// Use the action registry for standardized processing
+				result := game.HandleAction(game.ClientEventType(msg.Type), c.id, msg.Payload)
+				if result != nil && result.Success {
+					// Send messages to the player
+					for _, wsMsg := range result.ToPlayer {
+						// Send the payload directly (not wrapped in WebSocketMessage)
+						c.send <- wsMsg.Payload
+					}
+				}
+			case game.ClientEventFollow:
+				// Use the action registry for standardized processing
+				result := game.HandleAction(game.ClientEventType(msg.Type), c.id, msg.Payload)
+				if result != nil && result.Success {
+					// Send messages to the player
+					for _, wsMsg := range result.ToPlayer {
+						// Send the payload directly (not wrapped in WebSocketMessage)
+						c.send <- wsMsg.Payload
+					}
+				}
 			case game.ClientEventDepositItem:
 				// Use the action registry for standardized processing
 				result := game.HandleAction(game.ClientEventType(msg.Type), c.id, msg.Payload)
@@ -298,6 +318,26 @@ func (c *Client) readPump() {
 						c.send <- wsMsg.Payload
 					}
 				}
+			case game.ClientEventReadMap:
+				// Use the action registry for standardized processing
+				result := game.HandleAction(game.ClientEventType(msg.Type), c.id, msg.Payload)
+				if result != nil && result.Success {
+					// Send messages to the player
+					for _, wsMsg := range result.ToPlayer {
+						// Send the payload directly (not wrapped in WebSocketMessage)
+						c.send <- wsMsg.Payload
+					}
+				}
+			case game.ClientEventDig:
+				// Use the action registry for standardized processing
+				result := game.HandleAction(game.ClientEventType(msg.Type), c.id, msg.Payload)
+				if result != nil && result.Success {
+					// Send messages to the player
+					for _, wsMsg := range result.ToPlayer {
+						// Send the payload directly (not wrapped in WebSocketMessage)
+						c.send <- wsMsg.Payload
+					}
+				}
 			}
 		}
 	}
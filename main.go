@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"log"
 	"mmo-game/game"
 	"net/http"
@@ -35,34 +36,37 @@ func nukeServerState() {
 	log.Println("Redis flushed.")
 }
 
-// cleanupServerState now uses the non-blocking SCAN command to find all locks.
-func cleanupServerState() {
-	log.Println("Cleaning up ALL player data and tile locks from Redis...")
-	ctx := context.Background()
-
-	// --- NEW: Use SCAN to find all tile locks iteratively ---
-	var allLockKeys []string
+// scanKeys uses SCAN to find every key matching pattern, a batch at a time.
+func scanKeys(ctx context.Context, pattern string) []string {
+	var found []string
 	var cursor uint64
 	scanCount := int64(100) // How many keys to check per iteration
 
 	for {
-		// Scan for a batch of keys matching the pattern
-		keys, nextCursor, err := rdb.Scan(ctx, cursor, "lock:tile:*", scanCount).Result()
+		keys, nextCursor, err := rdb.Scan(ctx, cursor, pattern, scanCount).Result()
 		if err != nil {
-			log.Printf("Error during Redis SCAN for lock keys: %v", err)
+			log.Printf("Error during Redis SCAN for %s: %v", pattern, err)
 			break // Exit the loop on error
 		}
-		// Add the found keys to our list
-		allLockKeys = append(allLockKeys, keys...)
+		found = append(found, keys...)
 
 		// If the next cursor is 0, we've finished iterating
 		if nextCursor == 0 {
 			break
 		}
-		// Otherwise, update the cursor for the next iteration
 		cursor = nextCursor
 	}
-	// --- End of SCAN loop ---
+	return found
+}
+
+// cleanupServerState now uses the non-blocking SCAN command to find all locks.
+func cleanupServerState() {
+	log.Println("Cleaning up ALL player data and tile locks from Redis...")
+	ctx := context.Background()
+
+	// Find every tile lock and every owner's set of locked tiles
+	allLockKeys := scanKeys(ctx, string(game.RedisKeyLockTile)+"*")
+	allLockKeys = append(allLockKeys, scanKeys(ctx, string(game.RedisKeyLockOwnerPrefix)+"*")...)
 
 	// Find all active player IDs from the geospatial index
 	playerKeys, err := rdb.ZRange(ctx, "zone:0:positions", 0, -1).Result()
@@ -76,7 +80,7 @@ func cleanupServerState() {
 		return
 	}
 
-	log.Printf("Found %d players and %d tile locks to remove.", len(playerKeys), len(allLockKeys))
+	log.Printf("Found %d players and %d lock keys to remove.", len(playerKeys), len(allLockKeys))
 
 	pipe := rdb.Pipeline()
 
@@ -88,10 +92,11 @@ func cleanupServerState() {
 		pipe.Del(ctx, playerKey)
 	}
 
-	// Add all found lock keys to the deletion list
+	// Add all found lock keys to the deletion list, along with the lock index and leases
 	if len(allLockKeys) > 0 {
 		pipe.Del(ctx, allLockKeys...)
 	}
+	pipe.Del(ctx, string(game.RedisKeyLockIndex), string(game.RedisKeyLockLeases))
 
 	// Execute all deletion commands
 	_, err = pipe.Exec(ctx)
@@ -103,6 +108,30 @@ func cleanupServerState() {
 }
 
 func main() {
+	restoreWorld := flag.String("restore-world", "", "restore the world from a snapshot file instead of generating it")
+	saveWorld := flag.String("save-world", "", "write a world snapshot to this file on shutdown, before Redis is flushed")
+	worldConfig := flag.String("world-config", "", "JSON world generation config to generate a new world from")
+	worldSeed := flag.Int64("world-seed", 0, "world generation seed, overriding the one in -world-config")
+	flag.Parse()
+
+	// An existing world keeps the config it was generated with; this one only applies
+	// to a newly generated world.
+	worldGenConfig := game.DefaultWorldGenConfig()
+	if *worldConfig != "" {
+		var err error
+		if worldGenConfig, err = game.LoadWorldGenConfigFile(*worldConfig); err != nil {
+			log.Fatalf("Could not load world config %s: %v", *worldConfig, err)
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "world-seed" {
+			worldGenConfig.Seed = *worldSeed
+		}
+	})
+	if err := game.SetWorldGenConfig(worldGenConfig); err != nil {
+		log.Fatalf("Invalid world config: %v", err)
+	}
+
 	rdb = redis.NewClient(&redis.Options{
 		Addr:     "localhost:6379",
 		Password: "",
@@ -122,11 +151,26 @@ func main() {
 		return ok
 	}
 	game.Init(rdb, SendDirectMessage, isPlayerOnline)
-	game.GenerateWorld()
-	game.SpawnBanker()
-	game.IndexWorldResources()
-	game.IndexPotentialSpawnPoints()
-	game.InitializeCollisionGrid()
+	if *restoreWorld != "" {
+		// RestoreWorldSnapshot rebuilds the resource and spawn indexes and the collision grid itself.
+		snapshot, err := game.LoadWorldSnapshotFile(*restoreWorld)
+		if err != nil {
+			log.Fatalf("Could not load world snapshot %s: %v", *restoreWorld, err)
+		}
+		if err := game.RestoreWorldSnapshot(snapshot); err != nil {
+			log.Fatalf("Could not restore world snapshot %s: %v", *restoreWorld, err)
+		}
+		game.SpawnBanker()
+	} else {
+		game.GenerateWorld()
+		game.SpawnBanker()
+		game.IndexWorldResources()
+		game.IndexPotentialSpawnPoints()
+		game.InitializeCollisionGrid()
+	}
+	game.IndexSpatialPositions()
+	game.IndexTileLocks()
+	game.ScheduleUnindexedWorldItems()
 	// --- For Testing: Spawn some NPCs ---
 	// game.SpawnNPC("npc:slime:"+utils.GenerateUniqueID(), 1, 2, game.NPCTypeSlime)
 	// game.SpawnNPC("npc:rat:"+utils.GenerateUniqueID(), -2, -3, game.NPCTypeRat)
@@ -137,6 +181,12 @@ func main() {
 	go game.StartDamageSystem()
 	go game.StartDecaySystem()
 	go game.StartResourceSpawner()
+	go game.StartJobScheduler()
+	go game.StartTileLockReaper()
+	go game.StartWorldTileSync()
+	go game.StartSpatialSync()
+	go game.StartWorldChunkEviction()
+	go game.StartWalkSystem()
 
 	go subscribeToWorldUpdates()
 
@@ -158,6 +208,13 @@ func main() {
 	<-quit
 
 	log.Println("Shutdown signal received, cleaning up...")
+	if *saveWorld != "" {
+		if _, err := game.SaveWorldSnapshotFile(*saveWorld); err != nil {
+			log.Printf("Could not save world snapshot to %s: %v", *saveWorld, err)
+		} else {
+			log.Printf("Saved world snapshot to %s.", *saveWorld)
+		}
+	}
 	// clean up server state but persist
 	// cleanupServerState()
 
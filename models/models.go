@@ -12,6 +12,8 @@ type WorldTile struct {
 	Type        string `json:"type"`
 	Health      int    `json:"health,omitempty"`
 	IsSanctuary bool   `json:"isSanctuary,omitempty"`
+	// Height is the tile's level in the terraced height map; 0 in flat worlds.
+	Height int `json:"height,omitempty"`
 }
 
 type ResourceDamagedMessage struct {
@@ -48,6 +50,8 @@ type EntityState struct {
 	ItemID     string          `json:"itemId,omitempty"`
 	Owner      string          `json:"owner,omitempty"`
 	CreatedAt  int64           `json:"createdAt,omitempty"`
+	PublicAt   int64           `json:"publicAt,omitempty"`
+	Quantity   int             `json:"quantity,omitempty"`
 	ShirtColor string          `json:"shirtColor,omitempty"`
 	Gear       map[string]Item `json:"gear,omitempty"`
 	IsEcho     bool            `json:"isEcho,omitempty"`
@@ -70,6 +74,53 @@ type InitialStateMessage struct {
 	Runes        []string               `json:"runes"`
 	ActiveRune   string                 `json:"activeRune"`
 	KnownRecipes map[string]bool        `json:"knownRecipes"`
+	BiomeMap     *BiomeMap              `json:"biomeMap,omitempty"`
+	Minimap      *MinimapMessage        `json:"minimap,omitempty"`
+}
+
+// BiomeMap tells the client which biome each tile is in. Biomes, Floors and
+// MoveFactors are indexed by biome code; Chunks holds the run-length encoded map of
+// each chunk sent, keyed by "cx,cy". Later chunks arrive in WorldChunkMessages.
+type BiomeMap struct {
+	ChunkSize   int               `json:"chunkSize"`
+	Biomes      []string          `json:"biomes"`
+	Floors      []string          `json:"floors"`
+	MoveFactors []float64         `json:"moveFactors"`
+	Chunks      map[string]string `json:"chunks"`
+}
+
+// WorldChunkMessage is sent to a player when a chunk of the world comes into view. Tiles
+// leaves out plain floor, which the client draws from Biomes, the chunk's run-length
+// encoded biome map.
+type WorldChunkMessage struct {
+	Type   string               `json:"type"`
+	X      int                  `json:"x"`
+	Y      int                  `json:"y"`
+	Tiles  map[string]WorldTile `json:"tiles"`
+	Biomes string               `json:"biomes"`
+}
+
+// MinimapMessage carries the minimap of some chunks of the overworld: with the initial
+// state, every chunk the player has discovered, and later the chunks coming into view.
+// Palette names the tile types the cells of each chunk are coded with.
+type MinimapMessage struct {
+	Type     string         `json:"type"`
+	CellSize int            `json:"cellSize"`
+	Palette  []string       `json:"palette"`
+	Chunks   []MinimapChunk `json:"chunks"`
+}
+
+// MinimapChunk is the minimap of one chunk. Cells holds the most common tile type of each
+// square of CellSize tiles, row by row from the chunk's north-west corner, as one
+// character per cell: 'a' for the first type in the palette, 'b' for the second, then
+// 'A' on after 'z'. Sanctuaries and Structures are the [x, y] tiles of sanctuary stones
+// and of anything players built.
+type MinimapChunk struct {
+	X           int      `json:"x"`
+	Y           int      `json:"y"`
+	Cells       string   `json:"cells"`
+	Sanctuaries [][2]int `json:"sanctuaries,omitempty"`
+	Structures  [][2]int `json:"structures,omitempty"`
 }
 
 type QuestUpdateMessage struct {
@@ -122,6 +173,15 @@ type LearnRecipePayload struct {
 	InventorySlot string `json:"inventorySlot"`
 }
 
+type ReadMapPayload struct {
+	InventorySlot string `json:"inventorySlot"`
+}
+
+type DigPayload struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
 type AttackPayload struct {
 	EntityID string `json:"entityId"`
 }
@@ -197,6 +257,15 @@ type NotificationMessage struct {
 	Message string `json:"message"`
 }
 
+// DigSiteMessage tells a player where the treasure map they read leads. Dug is set once
+// the site has been dug up.
+type DigSiteMessage struct {
+	Type string `json:"type"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	Dug  bool   `json:"dug,omitempty"`
+}
+
 type NpcQuestStateUpdateMessage struct {
 	Type       string `json:"type"`
 	NpcName    string `json:"npcName"`
@@ -291,6 +360,34 @@ type ValidPathMessage struct {
 	Directions []string `json:"directions"`
 }
 
+// WalkToPayload asks the server to walk the player to a tile. With Interact set, the
+// player walks next to the tile instead and interacts with it (or with Interact's
+// entity) on arrival.
+type WalkToPayload struct {
+	X        int              `json:"x"`
+	Y        int              `json:"y"`
+	Interact *InteractPayload `json:"interact,omitempty"`
+}
+
+// FollowPayload asks the server to keep the player next to an entity. An empty
+// EntityID stops following.
+type FollowPayload struct {
+	EntityID string `json:"entityId"`
+}
+
+// WalkPathMessage tells a player the route the server is walking them along.
+type WalkPathMessage struct {
+	Type       string   `json:"type"`
+	Directions []string `json:"directions"`
+}
+
+// WalkEndedMessage tells a player their walk is over. Arrived is false if it was
+// interrupted or the destination could not be reached.
+type WalkEndedMessage struct {
+	Type    string `json:"type"`
+	Arrived bool   `json:"arrived"`
+}
+
 type TeleportPayload struct {
 	X int `json:"x"`
 	Y int `json:"y"`